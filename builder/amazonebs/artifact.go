@@ -2,9 +2,11 @@ package amazonebs
 
 import (
 	"fmt"
+	"github.com/mitchellh/goamz/aws"
 	"github.com/mitchellh/goamz/ec2"
 	"github.com/mitchellh/packer/packer"
 	"log"
+	"sort"
 	"strings"
 )
 
@@ -14,9 +16,16 @@ type artifact struct {
 
 	// EC2 connection for performing API stuff.
 	conn *ec2.EC2
+
+	// The ID of the builder that made the AMIs, if not the EBS builder.
+	builderId string
 }
 
-func (*artifact) BuilderId() string {
+func (a *artifact) BuilderId() string {
+	if a.builderId != "" {
+		return a.builderId
+	}
+
 	return BuilderId
 }
 
@@ -25,10 +34,22 @@ func (*artifact) Files() []string {
 	return nil
 }
 
+// regions returns the regions of the AMIs, sorted so that the artifact
+// always describes itself the same way.
+func (a *artifact) regions() []string {
+	regions := make([]string, 0, len(a.amis))
+	for region := range a.amis {
+		regions = append(regions, region)
+	}
+
+	sort.Strings(regions)
+	return regions
+}
+
 func (a *artifact) Id() string {
 	parts := make([]string, 0, len(a.amis))
-	for region, amiId := range a.amis {
-		parts = append(parts, fmt.Sprintf("%s:%s", region, amiId))
+	for _, region := range a.regions() {
+		parts = append(parts, fmt.Sprintf("%s:%s", region, a.amis[region]))
 	}
 
 	return strings.Join(parts, ",")
@@ -36,8 +57,8 @@ func (a *artifact) Id() string {
 
 func (a *artifact) String() string {
 	amiStrings := make([]string, 0, len(a.amis))
-	for region, id := range a.amis {
-		single := fmt.Sprintf("%s: %s", region, id)
+	for _, region := range a.regions() {
+		single := fmt.Sprintf("%s: %s", region, a.amis[region])
 		amiStrings = append(amiStrings, single)
 	}
 
@@ -47,9 +68,14 @@ func (a *artifact) String() string {
 func (a *artifact) Destroy() error {
 	errors := make([]error, 0)
 
-	for _, imageId := range a.amis {
-		log.Printf("Deregistering image ID: %s", imageId)
-		if _, err := a.conn.DeregisterImage(imageId); err != nil {
+	for region, imageId := range a.amis {
+		log.Printf("Deregistering image ID (%s) from region (%s)", imageId, region)
+		regionconn := ec2.New(a.conn.Auth, aws.Regions[region])
+		err := retryThrottled(func() error {
+			_, err := regionconn.DeregisterImage(imageId)
+			return err
+		})
+		if err != nil {
 			errors = append(errors, err)
 		}
 
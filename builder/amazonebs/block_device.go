@@ -0,0 +1,63 @@
+package amazonebs
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+)
+
+// blockDevice is the configuration of a single block device mapping, for
+// either the source instance or the AMI.
+type blockDevice struct {
+	DeleteOnTermination bool   `mapstructure:"delete_on_termination"`
+	DeviceName          string `mapstructure:"device_name"`
+	IOPS                int64  `mapstructure:"iops"`
+	VirtualName         string `mapstructure:"virtual_name"`
+	VolumeSize          int64  `mapstructure:"volume_size"`
+	VolumeType          string `mapstructure:"volume_type"`
+}
+
+// buildBlockDevices converts the configured block devices into the
+// mappings the EC2 API expects.
+func buildBlockDevices(devices []blockDevice) []ec2.BlockDeviceMapping {
+	mappings := make([]ec2.BlockDeviceMapping, len(devices))
+	for i, device := range devices {
+		mappings[i] = ec2.BlockDeviceMapping{
+			DeleteOnTermination: device.DeleteOnTermination,
+			DeviceName:          device.DeviceName,
+			IOPS:                device.IOPS,
+			VirtualName:         device.VirtualName,
+			VolumeSize:          device.VolumeSize,
+			VolumeType:          device.VolumeType,
+		}
+	}
+
+	return mappings
+}
+
+// validateBlockDevices checks the block devices configured with the given
+// key, returning any errors.
+func validateBlockDevices(key string, devices []blockDevice) []error {
+	errs := make([]error, 0)
+	seen := make(map[string]bool)
+	for i, device := range devices {
+		if device.DeviceName == "" {
+			errs = append(errs, fmt.Errorf("%s[%d]: device_name must be specified", key, i))
+		} else if seen[device.DeviceName] {
+			errs = append(errs, fmt.Errorf(
+				"%s[%d]: duplicate device_name %s", key, i, device.DeviceName))
+		}
+		seen[device.DeviceName] = true
+
+		if device.IOPS != 0 && device.VolumeType != "io1" {
+			errs = append(errs, fmt.Errorf(
+				"%s[%d]: iops can only be set with volume_type io1", key, i))
+		}
+
+		if device.VirtualName != "" && (device.VolumeSize != 0 || device.VolumeType != "") {
+			errs = append(errs, fmt.Errorf(
+				"%s[%d]: virtual_name is for ephemeral devices, which can't have a volume_size or volume_type", key, i))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,95 @@
+package amazonebs
+
+import (
+	"github.com/mitchellh/goamz/ec2"
+	"reflect"
+	"testing"
+)
+
+func TestBuildBlockDevices(t *testing.T) {
+	devices := []blockDevice{
+		blockDevice{
+			DeviceName:          "/dev/sdb",
+			VolumeSize:          20,
+			VolumeType:          "io1",
+			IOPS:                1000,
+			DeleteOnTermination: true,
+		},
+		blockDevice{
+			DeviceName:  "/dev/sdc",
+			VirtualName: "ephemeral0",
+		},
+	}
+
+	expected := []ec2.BlockDeviceMapping{
+		ec2.BlockDeviceMapping{
+			DeviceName:          "/dev/sdb",
+			VolumeSize:          20,
+			VolumeType:          "io1",
+			IOPS:                1000,
+			DeleteOnTermination: true,
+		},
+		ec2.BlockDeviceMapping{
+			DeviceName:  "/dev/sdc",
+			VirtualName: "ephemeral0",
+		},
+	}
+
+	result := buildBlockDevices(devices)
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestValidateBlockDevices(t *testing.T) {
+	cases := []struct {
+		devices []blockDevice
+		err     bool
+	}{
+		{
+			[]blockDevice{
+				blockDevice{DeviceName: "/dev/sda1", VolumeSize: 20},
+				blockDevice{DeviceName: "/dev/sdb", VirtualName: "ephemeral0"},
+			},
+			false,
+		},
+		{
+			[]blockDevice{
+				blockDevice{DeviceName: "/dev/sdb", VolumeType: "io1", IOPS: 500},
+			},
+			false,
+		},
+		{
+			[]blockDevice{
+				blockDevice{DeviceName: "/dev/sdb"},
+				blockDevice{DeviceName: "/dev/sdb"},
+			},
+			true,
+		},
+		{
+			[]blockDevice{
+				blockDevice{DeviceName: "/dev/sdb", VolumeType: "standard", IOPS: 500},
+			},
+			true,
+		},
+		{
+			[]blockDevice{
+				blockDevice{VolumeSize: 20},
+			},
+			true,
+		},
+		{
+			[]blockDevice{
+				blockDevice{DeviceName: "/dev/sdb", VirtualName: "ephemeral0", VolumeSize: 20},
+			},
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		errs := validateBlockDevices("foo", tc.devices)
+		if (len(errs) > 0) != tc.err {
+			t.Errorf("case %d: bad: %#v", i, errs)
+		}
+	}
+}
@@ -1,11 +1,14 @@
-// The amazonebs package contains a packer.Builder implementation that
-// builds AMIs for Amazon EC2.
+// The amazonebs package contains packer.Builder implementations that
+// build AMIs for Amazon EC2.
 //
 // In general, there are two types of AMIs that can be created: ebs-backed or
-// instance-store. This builder _only_ builds ebs-backed images.
+// instance-store. Builder builds ebs-backed images, and InstanceBuilder
+// builds instance-store images.
 package amazonebs
 
 import (
+	"cgl.tideland.biz/identifier"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/mitchellh/goamz/aws"
@@ -16,6 +19,7 @@ import (
 	"github.com/mitchellh/packer/packer"
 	"log"
 	"os"
+	"strconv"
 	"text/template"
 	"time"
 )
@@ -23,24 +27,88 @@ import (
 // The unique ID for this builder
 const BuilderId = "mitchellh.amazonebs"
 
+// The unique ID for the instance-store builder
+const InstanceBuilderId = "mitchellh.amazon.instance"
+
+// The maximum size of the user data EC2 accepts, before base64 encoding.
+const maxUserDataSize = 16 * 1024
+
 type config struct {
-	// Access information
+	// Access information. If no keys are given, they're looked up in the
+	// environment, the shared credentials file and the instance metadata.
 	AccessKey string `mapstructure:"access_key"`
+	Profile   string `mapstructure:"profile"`
 	SecretKey string `mapstructure:"secret_key"`
+	Token     string `mapstructure:"token"`
 
 	// Information for the source instance
-	Region       string
-	SourceAmi    string `mapstructure:"source_ami"`
-	InstanceType string `mapstructure:"instance_type"`
-	SSHUsername  string `mapstructure:"ssh_username"`
-	SSHPort      int    `mapstructure:"ssh_port"`
-	SSHTimeout   time.Duration
+	Region             string
+	SourceAmi          string `mapstructure:"source_ami"`
+	InstanceType       string `mapstructure:"instance_type"`
+	IamInstanceProfile string `mapstructure:"iam_instance_profile"`
+	SSHUsername        string `mapstructure:"ssh_username"`
+	SSHPort            int    `mapstructure:"ssh_port"`
+	SSHPrivateIp       bool   `mapstructure:"ssh_private_ip"`
+	SSHTimeout         time.Duration
+	UserData           string `mapstructure:"user_data"`
+	UserDataFile       string `mapstructure:"user_data_file"`
+
+	// The key pair to launch the source instance with. If no existing key
+	// pair is given, a temporary one is created for the build.
+	SSHKeyPairName       string `mapstructure:"ssh_keypair_name"`
+	SSHPrivateKeyFile    string `mapstructure:"ssh_private_key_file"`
+	SSHKeyPassphrase     string `mapstructure:"ssh_key_passphrase"`
+	TemporaryKeyPairName string `mapstructure:"temporary_key_pair_name"`
+
+	// Block devices of the source instance and the resulting AMI
+	AMIBlockDevices    []blockDevice `mapstructure:"ami_block_device_mappings"`
+	LaunchBlockDevices []blockDevice `mapstructure:"launch_block_device_mappings"`
+
+	// Information for launching the source instance into a VPC
+	AssociatePublicIpAddress bool     `mapstructure:"associate_public_ip_address"`
+	SecurityGroupIds         []string `mapstructure:"security_group_ids"`
+	SubnetId                 string   `mapstructure:"subnet_id"`
+	VpcId                    string   `mapstructure:"vpc_id"`
+
+	// Information for launching the source instance as a spot instance
+	SpotPrice            string `mapstructure:"spot_price"`
+	SpotPriceAutoProduct string `mapstructure:"spot_price_auto_product"`
+	SpotRequestTimeout   time.Duration
 
 	// Configuration of the resulting AMI
-	AMIName string `mapstructure:"ami_name"`
-
-	PackerDebug   bool   `mapstructure:"packer_debug"`
-	RawSSHTimeout string `mapstructure:"ssh_timeout"`
+	AMIName       string            `mapstructure:"ami_name"`
+	AMIGroups     []string          `mapstructure:"ami_groups"`
+	AMIRegions    []string          `mapstructure:"ami_regions"`
+	AMIUsers      []string          `mapstructure:"ami_users"`
+	SnapshotUsers []string          `mapstructure:"snapshot_users"`
+	Tags          map[string]string `mapstructure:"tags"`
+	TagsRequired  bool              `mapstructure:"tags_required"`
+
+	// Configuration of instance-store AMIs, only used by InstanceBuilder
+	AccountId           string `mapstructure:"account_id"`
+	BundleDestination   string `mapstructure:"bundle_destination"`
+	BundlePrefix        string `mapstructure:"bundle_prefix"`
+	BundleUploadCommand string `mapstructure:"bundle_upload_command"`
+	BundleVolCommand    string `mapstructure:"bundle_vol_command"`
+	S3Bucket            string `mapstructure:"s3_bucket"`
+	X509CertPath        string `mapstructure:"x509_cert_path"`
+	X509KeyPath         string `mapstructure:"x509_key_path"`
+	X509UploadPath      string `mapstructure:"x509_upload_path"`
+
+	// How often and how long to poll the state of instances and AMIs
+	StatePollInterval time.Duration
+	StateTimeout      time.Duration
+
+	PackerBuildName         string `mapstructure:"packer_build_name"`
+	PackerDebug             bool   `mapstructure:"packer_debug"`
+	PackerForce             bool   `mapstructure:"packer_force"`
+	PackerOnError           string `mapstructure:"packer_on_error"`
+	PackerTemplateTimestamp int64  `mapstructure:"packer_template_timestamp"`
+
+	RawSSHTimeout         string `mapstructure:"ssh_timeout"`
+	RawSpotRequestTimeout string `mapstructure:"spot_request_timeout"`
+	RawStatePollInterval  string `mapstructure:"state_poll_interval"`
+	RawStateTimeout       string `mapstructure:"state_timeout"`
 }
 
 type Builder struct {
@@ -49,8 +117,6 @@ type Builder struct {
 }
 
 func (b *Builder) Prepare(raws ...interface{}) error {
-	var err error
-
 	for _, raw := range raws {
 		err := mapstructure.Decode(raw, &b.config)
 		if err != nil {
@@ -58,73 +124,7 @@ func (b *Builder) Prepare(raws ...interface{}) error {
 		}
 	}
 
-	if b.config.AccessKey == "" {
-		b.config.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
-	}
-
-	if b.config.AccessKey == "" {
-		b.config.AccessKey = os.Getenv("AWS_ACCESS_KEY")
-	}
-
-	if b.config.SecretKey == "" {
-		b.config.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
-	}
-
-	if b.config.SecretKey == "" {
-		b.config.SecretKey = os.Getenv("AWS_SECRET_KEY")
-	}
-
-	if b.config.SSHPort == 0 {
-		b.config.SSHPort = 22
-	}
-
-	if b.config.RawSSHTimeout == "" {
-		b.config.RawSSHTimeout = "1m"
-	}
-
-	// Accumulate any errors
-	errs := make([]error, 0)
-
-	if b.config.AccessKey == "" {
-		errs = append(errs, errors.New("An access_key must be specified"))
-	}
-
-	if b.config.SecretKey == "" {
-		errs = append(errs, errors.New("A secret_key must be specified"))
-	}
-
-	if b.config.SourceAmi == "" {
-		errs = append(errs, errors.New("A source_ami must be specified"))
-	}
-
-	if b.config.InstanceType == "" {
-		errs = append(errs, errors.New("An instance_type must be specified"))
-	}
-
-	if b.config.Region == "" {
-		errs = append(errs, errors.New("A region must be specified"))
-	} else if _, ok := aws.Regions[b.config.Region]; !ok {
-		errs = append(errs, fmt.Errorf("Unknown region: %s", b.config.Region))
-	}
-
-	if b.config.SSHUsername == "" {
-		errs = append(errs, errors.New("An ssh_username must be specified"))
-	}
-
-	b.config.SSHTimeout, err = time.ParseDuration(b.config.RawSSHTimeout)
-	if err != nil {
-		errs = append(errs, fmt.Errorf("Failed parsing ssh_timeout: %s", err))
-	}
-
-	if b.config.AMIName == "" {
-		errs = append(errs, errors.New("ami_name must be specified"))
-	} else {
-		_, err = template.New("ami").Parse(b.config.AMIName)
-		if err != nil {
-			errs = append(errs, fmt.Errorf("Failed parsing ami_name: %s", err))
-		}
-	}
-
+	errs := b.config.prepare()
 	if len(errs) > 0 {
 		return &packer.MultiError{errs}
 	}
@@ -139,7 +139,11 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		panic("region not found")
 	}
 
-	auth := aws.Auth{b.config.AccessKey, b.config.SecretKey}
+	auth := aws.Auth{
+		AccessKey: b.config.AccessKey,
+		SecretKey: b.config.SecretKey,
+		Token:     b.config.Token,
+	}
 	ec2conn := ec2.New(auth, region)
 
 	// Setup the state bag and initial state for the steps
@@ -151,26 +155,27 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 
 	// Build the steps
 	steps := []multistep.Step{
-		&stepKeyPair{},
+		&stepKeyPair{
+			Debug:        b.config.PackerDebug,
+			DebugKeyPath: fmt.Sprintf("ec2_%s.pem", b.config.PackerBuildName),
+		},
 		&stepSecurityGroup{},
 		&stepRunSourceInstance{},
 		&stepConnectSSH{},
 		&stepProvision{},
 		&stepStopInstance{},
+		&stepDeregisterAMI{},
 		&stepCreateAMI{},
+		&stepAMIRegionCopy{},
+		&stepModifyAMIAttributes{},
+		&stepCreateTags{},
 	}
 
 	// Run!
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewRunner(steps, b.config.PackerDebug, b.config.PackerOnError, ui)
 
 	b.runner.Run(state)
+	common.ReportStepTimings(ui, state)
 
 	// If there was an error, return that
 	if rawErr, ok := state["error"]; ok {
@@ -182,9 +187,14 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		return nil, nil
 	}
 
+	var amis map[string]string
+	if err := common.StateBag(state).Values("amis", &amis); err != nil {
+		return nil, err
+	}
+
 	// Build the artifact and return it
 	artifact := &artifact{
-		amis: state["amis"].(map[string]string),
+		amis: amis,
 		conn: ec2conn,
 	}
 
@@ -197,3 +207,249 @@ func (b *Builder) Cancel() {
 		b.runner.Cancel()
 	}
 }
+
+// prepare sets the defaults of the configuration shared by the builders
+// and validates it, returning any errors.
+func (c *config) prepare() []error {
+	var err error
+
+	// Accumulate any errors
+	errs := make([]error, 0)
+
+	// A session token in the environment only goes with the keys there
+	if c.AccessKey == "" && c.SecretKey == "" && c.Token == "" {
+		c.Token = os.Getenv("AWS_SESSION_TOKEN")
+		if c.Token == "" {
+			c.Token = os.Getenv("AWS_SECURITY_TOKEN")
+		}
+	}
+
+	if c.AccessKey == "" {
+		c.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+
+	if c.AccessKey == "" {
+		c.AccessKey = os.Getenv("AWS_ACCESS_KEY")
+	}
+
+	if c.SecretKey == "" {
+		c.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	if c.SecretKey == "" {
+		c.SecretKey = os.Getenv("AWS_SECRET_KEY")
+	}
+
+	if c.AccessKey == "" && c.SecretKey == "" {
+		profile := c.Profile
+		if profile == "" {
+			profile = os.Getenv("AWS_PROFILE")
+		}
+
+		if profile == "" {
+			profile = "default"
+		}
+
+		creds, err := sharedCredentials(profile)
+		if err == nil && creds == nil {
+			if c.Profile != "" {
+				err = fmt.Errorf("profile %s not found in the shared credentials file", c.Profile)
+			} else {
+				creds, err = instanceCredentials()
+			}
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		} else if creds != nil {
+			c.AccessKey = creds.AccessKey
+			c.SecretKey = creds.SecretKey
+			c.Token = creds.Token
+		}
+	}
+
+	if c.SSHPort == 0 {
+		c.SSHPort = 22
+	}
+
+	if c.RawSSHTimeout == "" {
+		c.RawSSHTimeout = "1m"
+	}
+
+	if c.RawSpotRequestTimeout == "" {
+		c.RawSpotRequestTimeout = "10m"
+	}
+
+	if c.RawStatePollInterval == "" {
+		c.RawStatePollInterval = "2s"
+	}
+
+	if c.RawStateTimeout == "" {
+		c.RawStateTimeout = "1h"
+	}
+
+	if c.AccessKey == "" && c.SecretKey == "" {
+		errs = append(errs, errors.New(
+			"No AWS credentials found. Specify access_key and secret_key, set "+
+				"AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, add them to "+
+				"~/.aws/credentials, or run in EC2 with an IAM role."))
+	} else if c.AccessKey == "" {
+		errs = append(errs, errors.New("An access_key must be specified"))
+	} else if c.SecretKey == "" {
+		errs = append(errs, errors.New("A secret_key must be specified"))
+	}
+
+	if c.SourceAmi == "" {
+		errs = append(errs, errors.New("A source_ami must be specified"))
+	}
+
+	if c.InstanceType == "" {
+		errs = append(errs, errors.New("An instance_type must be specified"))
+	}
+
+	if c.Region == "" {
+		errs = append(errs, errors.New("A region must be specified"))
+	} else if _, ok := aws.Regions[c.Region]; !ok {
+		errs = append(errs, fmt.Errorf("Unknown region: %s", c.Region))
+	}
+
+	// Copying into the source region or a region twice makes no sense
+	regions := make([]string, 0, len(c.AMIRegions))
+	regionSet := map[string]bool{c.Region: true}
+	for _, region := range c.AMIRegions {
+		if _, ok := aws.Regions[region]; !ok {
+			errs = append(errs, fmt.Errorf("Unknown region in ami_regions: %s", region))
+			continue
+		}
+
+		if !regionSet[region] {
+			regionSet[region] = true
+			regions = append(regions, region)
+		}
+	}
+	c.AMIRegions = regions
+
+	// EC2 only has the "all" group, which makes the AMI public
+	for _, group := range c.AMIGroups {
+		if group != "all" {
+			errs = append(errs, fmt.Errorf("Unknown group in ami_groups: %s", group))
+		}
+	}
+
+	if c.SSHUsername == "" {
+		errs = append(errs, errors.New("An ssh_username must be specified"))
+	}
+
+	if c.SSHKeyPairName != "" && c.SSHPrivateKeyFile == "" {
+		errs = append(errs, errors.New("ssh_keypair_name requires an ssh_private_key_file"))
+	}
+
+	if c.SSHPrivateKeyFile != "" {
+		if c.SSHKeyPairName == "" {
+			errs = append(errs, errors.New("ssh_private_key_file requires an ssh_keypair_name"))
+		}
+
+		if _, err := common.SSHKeychain(c.SSHPrivateKeyFile, c.SSHKeyPassphrase); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_private_key_file is invalid: %s", err))
+		}
+	} else if c.SSHKeyPassphrase != "" {
+		errs = append(errs, errors.New("ssh_key_passphrase requires an ssh_private_key_file"))
+	}
+
+	if c.UserData != "" && c.UserDataFile != "" {
+		errs = append(errs, errors.New("Only one of user_data or user_data_file can be specified"))
+	}
+
+	if len(c.UserData) > maxUserDataSize {
+		errs = append(errs, fmt.Errorf("user_data must be at most %d bytes", maxUserDataSize))
+	}
+
+	if c.UserDataFile != "" {
+		// The file is only read when launching, so it can be generated
+		// before the build, but it has to exist already.
+		if info, err := os.Stat(c.UserDataFile); err != nil {
+			errs = append(errs, fmt.Errorf("user_data_file is invalid: %s", err))
+		} else if info.Size() > maxUserDataSize {
+			errs = append(errs, fmt.Errorf("user_data_file must be at most %d bytes", maxUserDataSize))
+		}
+	}
+
+	if c.SubnetId == "" {
+		if c.AssociatePublicIpAddress {
+			errs = append(errs, errors.New("associate_public_ip_address requires a subnet_id"))
+		}
+
+		if c.VpcId != "" {
+			errs = append(errs, errors.New("vpc_id requires a subnet_id"))
+		}
+	} else if c.VpcId == "" && len(c.SecurityGroupIds) == 0 {
+		// The temporary security group has to be made in the VPC
+		errs = append(errs, errors.New(
+			"A vpc_id must be specified with subnet_id, unless security_group_ids are"))
+	}
+
+	errs = append(errs, validateBlockDevices(
+		"ami_block_device_mappings", c.AMIBlockDevices)...)
+	errs = append(errs, validateBlockDevices(
+		"launch_block_device_mappings", c.LaunchBlockDevices)...)
+
+	c.SSHTimeout, err = time.ParseDuration(c.RawSSHTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing ssh_timeout: %s", err))
+	}
+
+	c.SpotRequestTimeout, err = time.ParseDuration(c.RawSpotRequestTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing spot_request_timeout: %s", err))
+	}
+
+	c.StatePollInterval, err = time.ParseDuration(c.RawStatePollInterval)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing state_poll_interval: %s", err))
+	}
+
+	c.StateTimeout, err = time.ParseDuration(c.RawStateTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing state_timeout: %s", err))
+	}
+
+	if c.SpotPrice == "auto" {
+		if c.SpotPriceAutoProduct == "" {
+			errs = append(errs, errors.New(
+				"spot_price_auto_product must be specified when spot_price is auto"))
+		}
+	} else if c.SpotPrice != "" {
+		if _, err := strconv.ParseFloat(c.SpotPrice, 64); err != nil {
+			errs = append(errs, fmt.Errorf("spot_price must be a price or \"auto\": %s", err))
+		}
+	}
+
+	tpl := common.NewConfigTemplate(c.PackerBuildName, c.PackerTemplateTimestamp)
+	if c.AMIName == "" {
+		errs = append(errs, errors.New("ami_name must be specified"))
+	} else {
+		_, err = template.New("ami").Funcs(tpl.Funcs()).Parse(c.AMIName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed parsing ami_name: %s", err))
+		}
+	}
+
+	if c.TemporaryKeyPairName == "" {
+		c.TemporaryKeyPairName = fmt.Sprintf(
+			"packer %s", hex.EncodeToString(identifier.NewUUID().Raw()))
+	} else {
+		c.TemporaryKeyPairName, err = tpl.Process(c.TemporaryKeyPairName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed parsing temporary_key_pair_name: %s", err))
+		}
+	}
+
+	for k, v := range c.Tags {
+		c.Tags[k], err = tpl.Process(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error processing tag %s: %s", k, err))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,168 @@
+package amazonebs
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The URL of the IAM role credentials in the EC2 instance metadata. This is
+// a variable so that tests can point it somewhere else.
+var instanceCredentialsURL = "http://169.254.169.254/latest/meta-data/iam/security-credentials/"
+
+// How long to wait for the instance metadata, which isn't reachable at all
+// when Packer isn't running in EC2.
+var instanceMetadataTimeout = 2 * time.Second
+
+// credentials are AWS credentials found by one of the sources of the
+// credential chain.
+type credentials struct {
+	AccessKey string
+	SecretKey string
+	Token     string
+}
+
+// sharedCredentials reads the credentials of the profile from the shared
+// credentials file, ~/.aws/credentials by default. It returns nil if the
+// file or profile doesn't exist.
+func sharedCredentials(profile string) (*credentials, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			home = os.Getenv("USERPROFILE")
+		}
+
+		if home == "" {
+			return nil, nil
+		}
+
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer f.Close()
+
+	var creds *credentials
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == profile {
+				creds = new(credentials)
+			}
+
+			continue
+		}
+
+		if section != profile {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		value := strings.TrimSpace(parts[1])
+		switch strings.TrimSpace(parts[0]) {
+		case "aws_access_key_id":
+			creds.AccessKey = value
+		case "aws_secret_access_key":
+			creds.SecretKey = value
+		case "aws_session_token", "aws_security_token":
+			creds.Token = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if creds != nil && (creds.AccessKey == "" || creds.SecretKey == "") {
+		return nil, fmt.Errorf(
+			"profile %s in %s needs aws_access_key_id and aws_secret_access_key", profile, path)
+	}
+
+	return creds, nil
+}
+
+// instanceCredentials reads the temporary credentials of the IAM role of
+// the EC2 instance Packer is running in. It returns nil if Packer isn't
+// running in EC2 or the instance has no role.
+func instanceCredentials() (*credentials, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return net.DialTimeout(network, addr, instanceMetadataTimeout)
+			},
+		},
+	}
+
+	role, err := getMetadata(client, instanceCredentialsURL)
+	if err != nil {
+		return nil, nil
+	}
+
+	// The first role listed is the one of the instance profile
+	role = strings.TrimSpace(strings.SplitN(role, "\n", 2)[0])
+	if role == "" {
+		return nil, nil
+	}
+
+	body, err := getMetadata(client, instanceCredentialsURL+role)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading credentials of IAM role %s: %s", role, err)
+	}
+
+	var resp struct {
+		AccessKeyId     string
+		SecretAccessKey string
+		Token           string
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return nil, fmt.Errorf("Error parsing credentials of IAM role %s: %s", role, err)
+	}
+
+	if resp.AccessKeyId == "" || resp.SecretAccessKey == "" {
+		return nil, errors.New("IAM role credentials are incomplete")
+	}
+
+	return &credentials{resp.AccessKeyId, resp.SecretAccessKey, resp.Token}, nil
+}
+
+func getMetadata(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	return string(body), err
+}
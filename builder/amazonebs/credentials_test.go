@@ -0,0 +1,157 @@
+package amazonebs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+const testSharedCredentials = `
+[default]
+aws_access_key_id = foo
+aws_secret_access_key = bar
+
+[other]
+aws_access_key_id=baz
+aws_secret_access_key=qux
+aws_session_token=token
+`
+
+func testSharedCredentialsFile(t *testing.T) string {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer tf.Close()
+
+	if _, err := tf.Write([]byte(testSharedCredentials)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return tf.Name()
+}
+
+func TestSharedCredentials(t *testing.T) {
+	path := testSharedCredentialsFile(t)
+	defer os.Remove(path)
+
+	oldPath := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	defer os.Setenv("AWS_SHARED_CREDENTIALS_FILE", oldPath)
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+
+	creds, err := sharedCredentials("default")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := credentials{"foo", "bar", ""}
+	if creds == nil || *creds != expected {
+		t.Fatalf("bad: %#v", creds)
+	}
+
+	creds, err = sharedCredentials("other")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected = credentials{"baz", "qux", "token"}
+	if creds == nil || *creds != expected {
+		t.Fatalf("bad: %#v", creds)
+	}
+
+	creds, err = sharedCredentials("nope")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if creds != nil {
+		t.Fatalf("bad: %#v", creds)
+	}
+}
+
+func TestInstanceCredentials(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, "role\n")
+		case "/role":
+			fmt.Fprint(w, `{"AccessKeyId": "foo", "SecretAccessKey": "bar", "Token": "baz"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	oldURL := instanceCredentialsURL
+	defer func() { instanceCredentialsURL = oldURL }()
+	instanceCredentialsURL = ts.URL + "/"
+
+	creds, err := instanceCredentials()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := credentials{"foo", "bar", "baz"}
+	if creds == nil || *creds != expected {
+		t.Fatalf("bad: %#v", creds)
+	}
+}
+
+func TestBuilderPrepare_Profile(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	delete(config, "access_key")
+	delete(config, "secret_key")
+
+	path := testSharedCredentialsFile(t)
+	defer os.Remove(path)
+
+	oldPath := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	defer os.Setenv("AWS_SHARED_CREDENTIALS_FILE", oldPath)
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+
+	// Test default
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.AccessKey != "foo" || b.config.SecretKey != "bar" {
+		t.Fatalf("bad: %s %s", b.config.AccessKey, b.config.SecretKey)
+	}
+
+	// Test set
+	config["profile"] = "other"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.AccessKey != "baz" || b.config.Token != "token" {
+		t.Fatalf("bad: %s %s", b.config.AccessKey, b.config.Token)
+	}
+
+	// Test bad
+	config["profile"] = "nope"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_NoCredentials(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	delete(config, "access_key")
+	delete(config, "secret_key")
+
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -4,14 +4,18 @@ import (
 	"fmt"
 	"github.com/mitchellh/goamz/ec2"
 	"log"
-	"time"
 )
 
-func waitForState(ec2conn *ec2.EC2, originalInstance *ec2.Instance, pending []string, target string) (i *ec2.Instance, err error) {
+func waitForState(ec2conn *ec2.EC2, opts waitOpts, originalInstance *ec2.Instance, pending []string, target string) (i *ec2.Instance, err error) {
 	log.Printf("Waiting for instance state to become: %s", target)
 
 	i = originalInstance
-	for i.State.Name != target {
+	desc := fmt.Sprintf("instance %s to become %s", i.InstanceId, target)
+	err = waitFor(opts, desc, func() (bool, error) {
+		if i.State.Name == target {
+			return true, nil
+		}
+
 		found := false
 		for _, allowed := range pending {
 			if i.State.Name == allowed {
@@ -21,19 +25,17 @@ func waitForState(ec2conn *ec2.EC2, originalInstance *ec2.Instance, pending []st
 		}
 
 		if !found {
-			fmt.Errorf("unexpected state '%s', wanted target '%s'", i.State.Name, target)
-			return
+			return false, fmt.Errorf("unexpected state '%s', wanted target '%s'", i.State.Name, target)
 		}
 
-		var resp *ec2.InstancesResp
-		resp, err = ec2conn.Instances([]string{i.InstanceId}, ec2.NewFilter())
+		resp, err := ec2conn.Instances([]string{i.InstanceId}, ec2.NewFilter())
 		if err != nil {
-			return
+			return false, err
 		}
 
 		i = &resp.Reservations[0].Instances[0]
-		time.Sleep(2 * time.Second)
-	}
+		return i.State.Name == target, nil
+	})
 
 	return
 }
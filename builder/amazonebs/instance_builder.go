@@ -0,0 +1,193 @@
+package amazonebs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/mapstructure"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"os"
+	"text/template"
+)
+
+// InstanceBuilder builds instance-store backed AMIs. It launches an
+// instance-store instance, provisions it, and then bundles its volume with
+// the AMI tools in the instance, uploading the bundle to S3 and registering
+// it as an AMI.
+type InstanceBuilder struct {
+	config config
+	runner multistep.Runner
+}
+
+func (b *InstanceBuilder) Prepare(raws ...interface{}) error {
+	for _, raw := range raws {
+		err := mapstructure.Decode(raw, &b.config)
+		if err != nil {
+			return err
+		}
+	}
+
+	if b.config.BundleDestination == "" {
+		b.config.BundleDestination = "/tmp"
+	}
+
+	if b.config.BundlePrefix == "" {
+		b.config.BundlePrefix = "image-{{.CreateTime}}"
+	}
+
+	if b.config.BundleUploadCommand == "" {
+		b.config.BundleUploadCommand = "sudo -n ec2-upload-bundle " +
+			"-b {{.BucketName}} " +
+			"-m {{.ManifestPath}} " +
+			"-a {{.AccessKey}} " +
+			"-s {{.SecretKey}} " +
+			"{{if .Token}}-t {{.Token}} {{end}}" +
+			"-d {{.BundleDirectory}} " +
+			"--batch"
+	}
+
+	if b.config.BundleVolCommand == "" {
+		b.config.BundleVolCommand = "sudo -n ec2-bundle-vol " +
+			"-k {{.KeyPath}} " +
+			"-u {{.AccountId}} " +
+			"-c {{.CertPath}} " +
+			"-r {{.Architecture}} " +
+			"-e {{.PrivatePath}}/* " +
+			"-d {{.Destination}} " +
+			"-p {{.Prefix}} " +
+			"--batch"
+	}
+
+	if b.config.X509UploadPath == "" {
+		b.config.X509UploadPath = "/tmp"
+	}
+
+	errs := b.config.prepare()
+
+	if b.config.AccountId == "" {
+		errs = append(errs, errors.New("An account_id must be specified"))
+	}
+
+	if b.config.S3Bucket == "" {
+		errs = append(errs, errors.New("An s3_bucket must be specified"))
+	}
+
+	if b.config.X509CertPath == "" {
+		errs = append(errs, errors.New("An x509_cert_path must be specified"))
+	} else if _, err := os.Stat(b.config.X509CertPath); err != nil {
+		errs = append(errs, fmt.Errorf("x509_cert_path points to bad file: %s", err))
+	}
+
+	if b.config.X509KeyPath == "" {
+		errs = append(errs, errors.New("An x509_key_path must be specified"))
+	} else if _, err := os.Stat(b.config.X509KeyPath); err != nil {
+		errs = append(errs, fmt.Errorf("x509_key_path points to bad file: %s", err))
+	}
+
+	// The bundle prefix is rendered like the AMI name, so it can also use
+	// the configuration template functions.
+	tpl := common.NewConfigTemplate(b.config.PackerBuildName, b.config.PackerTemplateTimestamp)
+	if _, err := template.New("bundle_prefix").Funcs(tpl.Funcs()).Parse(b.config.BundlePrefix); err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing bundle_prefix: %s", err))
+	}
+
+	templates := map[string]string{
+		"bundle_upload_command": b.config.BundleUploadCommand,
+		"bundle_vol_command":    b.config.BundleVolCommand,
+	}
+
+	for key, value := range templates {
+		if _, err := template.New(key).Parse(value); err != nil {
+			errs = append(errs, fmt.Errorf("Failed parsing %s: %s", key, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return &packer.MultiError{errs}
+	}
+
+	log.Printf("Config: %+v", b.config)
+	return nil
+}
+
+func (b *InstanceBuilder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	region, ok := aws.Regions[b.config.Region]
+	if !ok {
+		panic("region not found")
+	}
+
+	auth := aws.Auth{
+		AccessKey: b.config.AccessKey,
+		SecretKey: b.config.SecretKey,
+		Token:     b.config.Token,
+	}
+	ec2conn := ec2.New(auth, region)
+
+	// Setup the state bag and initial state for the steps
+	state := make(map[string]interface{})
+	state["config"] = b.config
+	state["ec2"] = ec2conn
+	state["hook"] = hook
+	state["ui"] = ui
+
+	// Build the steps
+	steps := []multistep.Step{
+		&stepKeyPair{
+			Debug:        b.config.PackerDebug,
+			DebugKeyPath: fmt.Sprintf("ec2_%s.pem", b.config.PackerBuildName),
+		},
+		&stepSecurityGroup{},
+		&stepRunSourceInstance{},
+		&stepConnectSSH{},
+		&stepProvision{},
+		&stepUploadX509Cert{},
+		&stepBundleVolume{},
+		&stepUploadBundle{},
+		&stepDeregisterAMI{},
+		&stepRegisterAMI{},
+		&stepAMIRegionCopy{},
+		&stepModifyAMIAttributes{},
+		&stepCreateTags{},
+	}
+
+	// Run!
+	b.runner = common.NewRunner(steps, b.config.PackerDebug, b.config.PackerOnError, ui)
+
+	b.runner.Run(state)
+	common.ReportStepTimings(ui, state)
+
+	// If there was an error, return that
+	if rawErr, ok := state["error"]; ok {
+		return nil, rawErr.(error)
+	}
+
+	// If there are no AMIs, then just return
+	if _, ok := state["amis"]; !ok {
+		return nil, nil
+	}
+
+	var amis map[string]string
+	if err := common.StateBag(state).Values("amis", &amis); err != nil {
+		return nil, err
+	}
+
+	// Build the artifact and return it
+	artifact := &artifact{
+		amis:      amis,
+		conn:      ec2conn,
+		builderId: InstanceBuilderId,
+	}
+
+	return artifact, nil
+}
+
+func (b *InstanceBuilder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
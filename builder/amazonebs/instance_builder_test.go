@@ -0,0 +1,157 @@
+package amazonebs
+
+import (
+	"bytes"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func testInstanceConfig() map[string]interface{} {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		panic(err)
+	}
+	tf.Close()
+
+	config := testConfig()
+	config["account_id"] = "foo"
+	config["s3_bucket"] = "foo"
+	config["x509_cert_path"] = tf.Name()
+	config["x509_key_path"] = tf.Name()
+	return config
+}
+
+func TestInstanceBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &InstanceBuilder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Fatalf("InstanceBuilder should be a builder")
+	}
+}
+
+func TestInstanceBuilderPrepare_Defaults(t *testing.T) {
+	var b InstanceBuilder
+	config := testInstanceConfig()
+
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.BundleDestination != "/tmp" {
+		t.Fatalf("bad: %s", b.config.BundleDestination)
+	}
+
+	if b.config.BundleUploadCommand == "" {
+		t.Fatal("should have a bundle_upload_command")
+	}
+
+	if b.config.BundleVolCommand == "" {
+		t.Fatal("should have a bundle_vol_command")
+	}
+
+	if b.config.X509UploadPath != "/tmp" {
+		t.Fatalf("bad: %s", b.config.X509UploadPath)
+	}
+}
+
+func TestInstanceBuilderPrepare_BundleUploadCommandToken(t *testing.T) {
+	var b InstanceBuilder
+	config := testInstanceConfig()
+
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	render := func(data uploadCmdData) string {
+		var command bytes.Buffer
+		tpl := template.Must(template.New("upload").Parse(b.config.BundleUploadCommand))
+		if err := tpl.Execute(&command, data); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		return command.String()
+	}
+
+	// Without a token, there is no token flag
+	if command := render(uploadCmdData{}); strings.Contains(command, "-t ") {
+		t.Fatalf("bad: %s", command)
+	}
+
+	// Temporary credentials pass the token along
+	command := render(uploadCmdData{Token: "token"})
+	if !strings.Contains(command, "-t token -d ") {
+		t.Fatalf("bad: %s", command)
+	}
+}
+
+func TestInstanceBuilderPrepare_Required(t *testing.T) {
+	keys := []string{"account_id", "s3_bucket", "x509_cert_path", "x509_key_path"}
+
+	for _, key := range keys {
+		var b InstanceBuilder
+		config := testInstanceConfig()
+		delete(config, key)
+
+		err := b.Prepare(config)
+		if err == nil {
+			t.Fatalf("%s: should have error", key)
+		}
+	}
+}
+
+func TestInstanceBuilderPrepare_BundleVolCommand(t *testing.T) {
+	var b InstanceBuilder
+	config := testInstanceConfig()
+
+	// Test good
+	config["bundle_vol_command"] = "foo {{.Prefix}}"
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.BundleVolCommand != "foo {{.Prefix}}" {
+		t.Fatalf("bad: %s", b.config.BundleVolCommand)
+	}
+
+	// Test bad
+	config["bundle_vol_command"] = "{{.Prefix"
+	b = InstanceBuilder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestInstanceBuilderPrepare_X509CertPath(t *testing.T) {
+	var b InstanceBuilder
+	config := testInstanceConfig()
+
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	// Test good
+	config["x509_cert_path"] = tf.Name()
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test bad
+	config["x509_cert_path"] = tf.Name() + ".nope"
+	b = InstanceBuilder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
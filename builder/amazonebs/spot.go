@@ -0,0 +1,155 @@
+package amazonebs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// spotPriceAutoMargin is how far above the current spot price an "auto"
+// bid is, so that the instance isn't outbid as soon as the price ticks up.
+// Spot instances are charged the spot price rather than the bid, so this
+// only raises the price at which the instance is terminated.
+const spotPriceAutoMargin = 0.2
+
+// The spot request status codes that mean the instance was terminated
+// because the spot price went above the maximum price that was bid.
+var spotOutbidCodes = []string{
+	"instance-terminated-by-price",
+	"marked-for-termination",
+}
+
+// spotPriceAuto returns the price to bid for the instance type and product
+// in the region, which is the current spot price chosen by chooseSpotPrice
+// plus spotPriceAutoMargin, along with the availability zone to request.
+func spotPriceAuto(ec2conn *ec2.EC2, instanceType, product string, inSubnet bool) (string, string, error) {
+	var resp *ec2.DescribeSpotPriceHistoryResp
+	err := retryThrottled(func() (err error) {
+		resp, err = ec2conn.DescribeSpotPriceHistory(&ec2.DescribeSpotPriceHistory{
+			InstanceType:       []string{instanceType},
+			ProductDescription: []string{product},
+			StartTime:          time.Now().UTC(),
+		})
+		return
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	price, zone, ok := chooseSpotPrice(resp.History, inSubnet)
+	if !ok {
+		return "", "", fmt.Errorf(
+			"no spot prices found for %s instances of product %s", instanceType, product)
+	}
+
+	return spotBid(price), zone, nil
+}
+
+// spotBid returns the bid for the given current spot price, with
+// spotPriceAutoMargin added and rounded up to a hundredth of a cent.
+func spotBid(price float64) string {
+	// The small offset keeps float error, as in 0.05*1.2, from rounding up
+	bid := math.Ceil(price*(1+spotPriceAutoMargin)*10000-1e-6) / 10000
+	return strconv.FormatFloat(bid, 'f', -1, 64)
+}
+
+// chooseSpotPrice returns the lowest spot price in the history, along with
+// the availability zone that has it. An instance in a subnet is launched
+// in the zone of the subnet, and EC2 rejects requests for any other zone,
+// so then the highest price is returned with no zone instead, so that the
+// bid is enough whichever zone the subnet is in. It returns false if the
+// history has no prices.
+func chooseSpotPrice(history []ec2.SpotPriceHistory, inSubnet bool) (float64, string, bool) {
+	var price float64
+	zone := ""
+	found := false
+	for _, h := range history {
+		p, err := strconv.ParseFloat(h.SpotPrice, 64)
+		if err != nil {
+			log.Printf("Error parsing spot price %s: %s", h.SpotPrice, err)
+			continue
+		}
+
+		if !found || (inSubnet && p > price) || (!inSubnet && p < price) {
+			price = p
+			zone = h.AvailabilityZone
+			found = true
+		}
+	}
+
+	if inSubnet {
+		zone = ""
+	}
+
+	return price, zone, found
+}
+
+// waitForSpotRequest waits for the spot request to be fulfilled and
+// returns the ID of the instance it launched.
+func waitForSpotRequest(ec2conn *ec2.EC2, opts waitOpts, requestId string) (string, error) {
+	log.Printf("Waiting up to %s for spot request %s", opts.Timeout, requestId)
+	instanceId := ""
+	err := waitFor(opts, "the spot request to be fulfilled", func() (bool, error) {
+		resp, err := ec2conn.DescribeSpotRequests([]string{requestId}, ec2.NewFilter())
+		if err != nil {
+			return false, err
+		}
+
+		if len(resp.SpotRequestResults) > 0 {
+			result := resp.SpotRequestResults[0]
+			switch result.State {
+			case "active":
+				if result.InstanceId != "" {
+					instanceId = result.InstanceId
+					return true, nil
+				}
+			case "open":
+			default:
+				return false, fmt.Errorf(
+					"spot request is %s: %s", result.State, result.Status.Message)
+			}
+
+			log.Printf("Spot request status: %s", result.Status.Code)
+		}
+
+		return false, nil
+	})
+
+	return instanceId, err
+}
+
+// spotError checks if the spot instance of a build was terminated because
+// it was outbid. If so, it returns an error saying so, since that is the
+// cause of any other error seen afterwards. Otherwise the original error
+// is returned.
+func spotError(state map[string]interface{}, original error) error {
+	requestId, ok := state["spotRequestId"].(string)
+	if !ok {
+		return original
+	}
+
+	ec2conn := state["ec2"].(*ec2.EC2)
+	var resp *ec2.SpotRequestsResp
+	err := retryThrottled(func() (err error) {
+		resp, err = ec2conn.DescribeSpotRequests([]string{requestId}, ec2.NewFilter())
+		return
+	})
+	if err != nil || len(resp.SpotRequestResults) == 0 {
+		return original
+	}
+
+	code := resp.SpotRequestResults[0].Status.Code
+	for _, outbid := range spotOutbidCodes {
+		if code == outbid {
+			return errors.New(
+				"The spot instance was terminated because the spot price rose " +
+					"above spot_price. Try again later or with a higher spot_price.")
+		}
+	}
+
+	return original
+}
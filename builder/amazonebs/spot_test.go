@@ -0,0 +1,52 @@
+package amazonebs
+
+import (
+	"github.com/mitchellh/goamz/ec2"
+	"testing"
+)
+
+func TestChooseSpotPrice(t *testing.T) {
+	history := []ec2.SpotPriceHistory{
+		{AvailabilityZone: "us-east-1a", SpotPrice: "0.05"},
+		{AvailabilityZone: "us-east-1b", SpotPrice: "0.03"},
+		{AvailabilityZone: "us-east-1c", SpotPrice: "bad"},
+		{AvailabilityZone: "us-east-1d", SpotPrice: "0.04"},
+	}
+
+	price, zone, ok := chooseSpotPrice(history, false)
+	if !ok {
+		t.Fatal("should find a price")
+	}
+
+	if price != 0.03 || zone != "us-east-1b" {
+		t.Fatalf("bad: %f %s", price, zone)
+	}
+
+	// In a subnet, the zone is the subnet's, so no zone is chosen
+	price, zone, ok = chooseSpotPrice(history, true)
+	if !ok {
+		t.Fatal("should find a price")
+	}
+
+	if price != 0.05 || zone != "" {
+		t.Fatalf("bad: %f %s", price, zone)
+	}
+
+	if _, _, ok := chooseSpotPrice(nil, false); ok {
+		t.Fatal("should not find a price")
+	}
+}
+
+func TestSpotBid(t *testing.T) {
+	cases := map[float64]string{
+		0.05:   "0.06",
+		0.031:  "0.0372",
+		0.0301: "0.0362",
+	}
+
+	for price, expected := range cases {
+		if bid := spotBid(price); bid != expected {
+			t.Fatalf("bad bid for %f: %s", price, bid)
+		}
+	}
+}
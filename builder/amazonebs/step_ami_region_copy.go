@@ -0,0 +1,115 @@
+package amazonebs
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"sort"
+	"sync"
+)
+
+// stepAMIRegionCopy copies the created AMI into each of the ami_regions,
+// waiting for all of the copies in parallel.
+type stepAMIRegionCopy struct{}
+
+func (s *stepAMIRegionCopy) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config config
+	var ec2conn *ec2.EC2
+	var amis map[string]string
+	var amiName string
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ec2", &ec2conn, "amis", &amis, "amiName", &amiName, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	if len(config.AMIRegions) == 0 {
+		return multistep.ActionContinue
+	}
+
+	sourceId := amis[config.Region]
+	ui.Say(fmt.Sprintf("Copying AMI (%s) to other regions...", sourceId))
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+	for _, region := range config.AMIRegions {
+		wg.Add(1)
+		ui.Message(fmt.Sprintf("Copying to: %s", region))
+
+		go func(region string) {
+			defer wg.Done()
+			id, err := amiRegionCopy(
+				ec2conn, config.waitOpts(ui, state), amiName, sourceId, config.Region, region)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			// A copy that failed to become available may still exist
+			if id != "" {
+				amis[region] = id
+			}
+
+			if err != nil {
+				errs[region] = err
+			}
+		}(region)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		regions := make([]string, 0, len(errs))
+		for region := range errs {
+			regions = append(regions, region)
+		}
+		sort.Strings(regions)
+
+		for _, region := range regions {
+			ui.Error(fmt.Sprintf("Error copying AMI to %s: %s", region, errs[region]))
+		}
+
+		// No artifact is returned, so say which AMIs are left for the
+		// user to deregister.
+		ui.Error(fmt.Sprintf(
+			"%s\n\nThese are left behind, and must be deregistered by hand along\n"+
+				"with their snapshots.", (&artifact{amis: amis}).String()))
+
+		state["error"] = fmt.Errorf(
+			"Error copying AMI to %d of %d regions.", len(errs), len(config.AMIRegions))
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepAMIRegionCopy) Cleanup(map[string]interface{}) {
+	// No cleanup...
+}
+
+// amiRegionCopy copies the AMI with the given ID from the source region
+// to the target region, and waits for the copy to become available.
+func amiRegionCopy(ec2conn *ec2.EC2, opts waitOpts, name, imageId, source, target string) (string, error) {
+	regionconn := ec2.New(ec2conn.Auth, aws.Regions[target])
+	var resp *ec2.CopyImageResp
+	err := retryThrottled(func() (err error) {
+		resp, err = regionconn.CopyImage(&ec2.CopyImage{
+			SourceRegion:  source,
+			SourceImageId: imageId,
+			Name:          name,
+		})
+		return
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := waitForImage(regionconn, opts, resp.ImageId); err != nil {
+		return resp.ImageId, err
+	}
+
+	return resp.ImageId, nil
+}
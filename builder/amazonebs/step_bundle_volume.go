@@ -0,0 +1,102 @@
+package amazonebs
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"path"
+	"text/template"
+)
+
+type bundleCmdData struct {
+	AccountId    string
+	Architecture string
+	CertPath     string
+	Destination  string
+	KeyPath      string
+	Prefix       string
+	PrivatePath  string
+}
+
+// stepBundleVolume bundles the volume of the instance with the AMI tools
+// in the instance, using bundle_vol_command.
+type stepBundleVolume struct{}
+
+func (s *stepBundleVolume) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var comm packer.Communicator
+	var config config
+	var ec2conn *ec2.EC2
+	var instance *ec2.Instance
+	var ui packer.Ui
+	var x509RemoteCertPath string
+	var x509RemoteKeyPath string
+	if err := bag.Values("communicator", &comm, "config", &config, "ec2", &ec2conn, "instance", &instance, "ui", &ui, "x509RemoteCertPath", &x509RemoteCertPath, "x509RemoteKeyPath", &x509RemoteKeyPath); err != nil {
+		return bag.Halt(err)
+	}
+
+	// The bundle has to be made for the architecture of the source AMI
+	var imageResp *ec2.ImagesResp
+	err := retryThrottled(func() (err error) {
+		imageResp, err = ec2conn.Images([]string{instance.ImageId}, ec2.NewFilter())
+		return
+	})
+	if err == nil && len(imageResp.Images) == 0 {
+		err = fmt.Errorf("source AMI %s not found", instance.ImageId)
+	}
+
+	if err != nil {
+		err := fmt.Errorf("Error finding the architecture of the source AMI: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	prefix := processAMIName(config, config.BundlePrefix)
+	tData := bundleCmdData{
+		AccountId:    config.AccountId,
+		Architecture: imageResp.Images[0].Architecture,
+		CertPath:     x509RemoteCertPath,
+		Destination:  config.BundleDestination,
+		KeyPath:      x509RemoteKeyPath,
+		Prefix:       prefix,
+		PrivatePath:  config.X509UploadPath,
+	}
+
+	var command bytes.Buffer
+	t := template.Must(template.New("bundle").Parse(config.BundleVolCommand))
+	t.Execute(&command, tData)
+
+	ui.Say("Bundling the volume...")
+	if err := runRemoteCommand(comm, ui, command.String()); err != nil {
+		err := fmt.Errorf("Error bundling volume: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state["bundleManifestPath"] = path.Join(config.BundleDestination, prefix+".manifest.xml")
+	state["bundlePrefix"] = prefix
+
+	return multistep.ActionContinue
+}
+
+func (s *stepBundleVolume) Cleanup(map[string]interface{}) {}
+
+// runRemoteCommand runs the command with the communicator, showing its
+// output, and returns an error if it doesn't exit successfully.
+func runRemoteCommand(comm packer.Communicator, ui packer.Ui, command string) error {
+	cmd := &packer.RemoteCmd{Command: command}
+	if err := cmd.StartWithUi(comm, ui); err != nil {
+		return err
+	}
+
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf("command exited with non-zero status %d", cmd.ExitStatus)
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/mitchellh/goamz/ec2"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/communicator/ssh"
 	"github.com/mitchellh/packer/packer"
 	"log"
@@ -18,15 +19,20 @@ type stepConnectSSH struct {
 }
 
 func (s *stepConnectSSH) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(config)
-	instance := state["instance"].(*ec2.Instance)
-	privateKey := state["privateKey"].(string)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var config config
+	var instance *ec2.Instance
+	var privateKey string
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "instance", &instance, "privateKey", &privateKey, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
 	// Build the keyring for authentication. This stores the private key
-	// we'll use to authenticate.
+	// we'll use to authenticate, which is only encrypted if it is the
+	// user's own.
 	keyring := &ssh.SimpleKeychain{}
-	err := keyring.AddPEMKey(privateKey)
+	err := keyring.AddPEMKeyPassword(privateKey, config.SSHKeyPassphrase)
 	if err != nil {
 		err := fmt.Errorf("Error setting up SSH config: %s", err)
 		state["error"] = err
@@ -42,6 +48,15 @@ func (s *stepConnectSSH) Run(state map[string]interface{}) multistep.StepAction
 		},
 	}
 
+	// Instances in a VPC may only have an IP address, or may only be
+	// reachable on their private IP from within the VPC.
+	host := instance.DNSName
+	if config.SSHPrivateIp {
+		host = instance.PrivateIPAddress
+	} else if host == "" {
+		host = instance.IPAddress
+	}
+
 	// Start trying to connect to SSH
 	connected := make(chan bool, 1)
 	connectQuit := make(chan bool, 1)
@@ -64,8 +79,8 @@ func (s *stepConnectSSH) Run(state map[string]interface{}) multistep.StepAction
 			attempts += 1
 			log.Printf(
 				"Opening TCP conn for SSH to %s:%d (attempt %d)",
-				instance.DNSName, config.SSHPort, attempts)
-			s.conn, err = net.Dial("tcp", fmt.Sprintf("%s:%d", instance.DNSName, config.SSHPort))
+				host, config.SSHPort, attempts)
+			s.conn, err = net.Dial("tcp", fmt.Sprintf("%s:%d", host, config.SSHPort))
 			if err == nil {
 				break
 			}
@@ -88,7 +103,7 @@ ConnectWaitLoop:
 			// We connected. Just break the loop.
 			break ConnectWaitLoop
 		case <-timeout:
-			err := errors.New("Timeout waiting for SSH to become available.")
+			err := spotError(state, errors.New("Timeout waiting for SSH to become available."))
 			state["error"] = err
 			ui.Error(err.Error())
 			return multistep.ActionHalt
@@ -106,7 +121,7 @@ ConnectWaitLoop:
 	}
 
 	if err != nil {
-		err := fmt.Errorf("Error connecting to SSH: %s", err)
+		err := spotError(state, fmt.Errorf("Error connecting to SSH: %s", err))
 		state["error"] = err
 		ui.Error(err.Error())
 		return multistep.ActionHalt
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/mitchellh/goamz/ec2"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
 	"strconv"
@@ -19,29 +20,31 @@ type amiNameData struct {
 }
 
 func (s *stepCreateAMI) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(config)
-	ec2conn := state["ec2"].(*ec2.EC2)
-	instance := state["instance"].(*ec2.Instance)
-	ui := state["ui"].(packer.Ui)
-
-	// Parse the name of the AMI
-	amiNameBuf := new(bytes.Buffer)
-	tData := amiNameData{
-		strconv.FormatInt(time.Now().UTC().Unix(), 10),
+	bag := common.StateBag(state)
+	var config config
+	var ec2conn *ec2.EC2
+	var instance *ec2.Instance
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ec2", &ec2conn, "instance", &instance, "ui", &ui); err != nil {
+		return bag.Halt(err)
 	}
 
-	t := template.Must(template.New("ami").Parse(config.AMIName))
-	t.Execute(amiNameBuf, tData)
-	amiName := amiNameBuf.String()
+	// Parse the name of the AMI
+	amiName := processAMIName(config, config.AMIName)
 
 	// Create the image
 	ui.Say(fmt.Sprintf("Creating the AMI: %s", amiName))
 	createOpts := &ec2.CreateImage{
-		InstanceId: instance.InstanceId,
-		Name:       amiName,
+		InstanceId:   instance.InstanceId,
+		Name:         amiName,
+		BlockDevices: buildBlockDevices(config.AMIBlockDevices),
 	}
 
-	createResp, err := ec2conn.CreateImage(createOpts)
+	var createResp *ec2.CreateImageResp
+	err := retryThrottled(func() (err error) {
+		createResp, err = ec2conn.CreateImage(createOpts)
+		return
+	})
 	if err != nil {
 		err := fmt.Errorf("Error creating AMI: %s", err)
 		state["error"] = err
@@ -57,28 +60,82 @@ func (s *stepCreateAMI) Run(state map[string]interface{}) multistep.StepAction {
 
 	// Wait for the image to become ready
 	ui.Say("Waiting for AMI to become ready...")
-	for {
-		imageResp, err := ec2conn.Images([]string{createResp.ImageId}, ec2.NewFilter())
-		if err != nil {
-			err := fmt.Errorf("Error querying images: %s", err)
-			state["error"] = err
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
-
-		if imageResp.Images[0].State == "available" {
-			break
-		}
-
-		log.Printf("Image in state %s, sleeping 2s before checking again",
-			imageResp.Images[0].State)
-
-		time.Sleep(2 * time.Second)
+	if err := waitForImage(ec2conn, config.waitOpts(ui, state), createResp.ImageId); err != nil {
+		err := fmt.Errorf("Error querying images: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
 	}
 
+	state["amiName"] = amiName
+
 	return multistep.ActionContinue
 }
 
 func (s *stepCreateAMI) Cleanup(map[string]interface{}) {
 	// No cleanup...
 }
+
+// processAMIName renders a template that may use the amiNameData and the
+// configuration template functions, such as ami_name, which Prepare has
+// already verified parses.
+func processAMIName(c config, value string) string {
+	buf := new(bytes.Buffer)
+	tData := amiNameData{
+		strconv.FormatInt(time.Now().UTC().Unix(), 10),
+	}
+
+	tpl := common.NewConfigTemplate(c.PackerBuildName, c.PackerTemplateTimestamp)
+	t := template.Must(template.New("ami").Funcs(tpl.Funcs()).Parse(value))
+	t.Execute(buf, tData)
+	return buf.String()
+}
+
+// imageSnapshotIds returns the IDs of the snapshots backing the image with
+// the given ID.
+func imageSnapshotIds(ec2conn *ec2.EC2, imageId string) ([]string, error) {
+	var imageResp *ec2.ImagesResp
+	err := retryThrottled(func() (err error) {
+		imageResp, err = ec2conn.Images([]string{imageId}, ec2.NewFilter())
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+	if len(imageResp.Images) > 0 {
+		for _, device := range imageResp.Images[0].BlockDevices {
+			if device.SnapshotId != "" {
+				ids = append(ids, device.SnapshotId)
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// waitForImage waits for the image with the given ID to become available.
+func waitForImage(ec2conn *ec2.EC2, opts waitOpts, imageId string) error {
+	desc := fmt.Sprintf("AMI %s to become available", imageId)
+	return waitFor(opts, desc, func() (bool, error) {
+		imageResp, err := ec2conn.Images([]string{imageId}, ec2.NewFilter())
+		if err != nil {
+			return false, err
+		}
+
+		if len(imageResp.Images) > 0 {
+			switch imageResp.Images[0].State {
+			case "available":
+				return true, nil
+			case "failed":
+				return false, fmt.Errorf("image %s failed: %s", imageId, imageResp.Images[0].StateReason)
+			}
+
+			log.Printf("Image in state %s, waiting %s before checking again",
+				imageResp.Images[0].State, opts.Interval)
+		}
+
+		return false, nil
+	})
+}
@@ -0,0 +1,77 @@
+package amazonebs
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"sort"
+)
+
+// stepCreateTags tags the created AMIs, and the snapshots backing them,
+// with the configured tags. Failing to tag is only an error if
+// tags_required is set.
+type stepCreateTags struct{}
+
+func (s *stepCreateTags) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config config
+	var ec2conn *ec2.EC2
+	var amis map[string]string
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ec2", &ec2conn, "amis", &amis, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	if len(config.Tags) == 0 {
+		return multistep.ActionContinue
+	}
+
+	keys := make([]string, 0, len(config.Tags))
+	for k := range config.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]ec2.Tag, len(keys))
+	for i, k := range keys {
+		tags[i] = ec2.Tag{Key: k, Value: config.Tags[k]}
+	}
+
+	for region, ami := range amis {
+		ui.Say(fmt.Sprintf("Adding tags to AMI (%s)...", ami))
+		regionconn := ec2.New(ec2conn.Auth, aws.Regions[region])
+
+		// Tag the snapshots along with the AMI so they can be found
+		// by the same lifecycle policies.
+		resourceIds := []string{ami}
+		snapshotIds, err := imageSnapshotIds(regionconn, ami)
+		if err != nil {
+			log.Printf("Error looking up snapshots of AMI, only tagging the AMI: %s", err)
+		}
+		resourceIds = append(resourceIds, snapshotIds...)
+
+		err = retryThrottled(func() error {
+			_, err := regionconn.CreateTags(resourceIds, tags)
+			return err
+		})
+		if err != nil {
+			err := fmt.Errorf("Error adding tags to AMI (%s): %s", ami, err)
+			ui.Error(err.Error())
+
+			if config.TagsRequired {
+				state["error"] = err
+				return multistep.ActionHalt
+			}
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateTags) Cleanup(map[string]interface{}) {
+	// No cleanup...
+}
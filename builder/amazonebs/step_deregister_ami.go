@@ -0,0 +1,104 @@
+package amazonebs
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+)
+
+// stepDeregisterAMI deregisters any existing AMI with the name of the AMI
+// we're about to create, in the region and in each of the ami_regions, and
+// deletes the snapshots backing it, when the build is forced. Otherwise
+// creating the AMI or copying it would fail on the name.
+type stepDeregisterAMI struct{}
+
+func (s *stepDeregisterAMI) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config config
+	var ec2conn *ec2.EC2
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ec2", &ec2conn, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	if !config.PackerForce {
+		return multistep.ActionContinue
+	}
+
+	amiName := processAMIName(config, config.AMIName)
+
+	// The copies in ami_regions get the same name, so they would fail on
+	// it too.
+	regions := append([]string{config.Region}, config.AMIRegions...)
+	for _, region := range regions {
+		regionconn := ec2conn
+		if region != config.Region {
+			regionconn = ec2.New(ec2conn.Auth, aws.Regions[region])
+		}
+
+		if err := deregisterAMIs(regionconn, ui, amiName); err != nil {
+			err := fmt.Errorf("Error deregistering existing AMI in %s: %s", region, err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepDeregisterAMI) Cleanup(map[string]interface{}) {
+	// No cleanup...
+}
+
+// deregisterAMIs deregisters the AMIs with the given name in the region of
+// the connection, and deletes the snapshots backing them.
+func deregisterAMIs(ec2conn *ec2.EC2, ui packer.Ui, amiName string) error {
+	filter := ec2.NewFilter()
+	filter.Add("name", amiName)
+
+	var imagesResp *ec2.ImagesResp
+	err := retryThrottled(func() (err error) {
+		imagesResp, err = ec2conn.Images(nil, filter)
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("Error looking up existing AMIs: %s", err)
+	}
+
+	for _, image := range imagesResp.Images {
+		ui.Say(fmt.Sprintf("Deregistering existing AMI %s (%s)...", image.Id, amiName))
+		err := retryThrottled(func() error {
+			_, err := ec2conn.DeregisterImage(image.Id)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		snapshotIds := make([]string, 0, len(image.BlockDevices))
+		for _, device := range image.BlockDevices {
+			if device.SnapshotId != "" {
+				snapshotIds = append(snapshotIds, device.SnapshotId)
+			}
+		}
+
+		if len(snapshotIds) == 0 {
+			continue
+		}
+
+		ui.Say(fmt.Sprintf("Deleting the snapshots of AMI %s...", image.Id))
+		err = retryThrottled(func() error {
+			_, err := ec2conn.DeleteSnapshots(snapshotIds)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Error deleting snapshots of existing AMI: %s", err)
+		}
+	}
+
+	return nil
+}
@@ -1,27 +1,56 @@
 package amazonebs
 
 import (
-	"cgl.tideland.biz/identifier"
-	"encoding/hex"
 	"fmt"
 	"github.com/mitchellh/goamz/ec2"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
 	"log"
 )
 
 type stepKeyPair struct {
+	Debug        bool
+	DebugKeyPath string
+
 	keyName string
 }
 
 func (s *stepKeyPair) Run(state map[string]interface{}) multistep.StepAction {
-	ec2conn := state["ec2"].(*ec2.EC2)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var config config
+	var ec2conn *ec2.EC2
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ec2", &ec2conn, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	// Use the existing key pair if one is given
+	if config.SSHKeyPairName != "" {
+		ui.Say(fmt.Sprintf("Using existing keypair: %s", config.SSHKeyPairName))
+		privateKey, err := ioutil.ReadFile(config.SSHPrivateKeyFile)
+		if err != nil {
+			err := fmt.Errorf("Error reading ssh_private_key_file: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		state["keyPair"] = config.SSHKeyPairName
+		state["privateKey"] = string(privateKey)
+
+		return multistep.ActionContinue
+	}
 
 	ui.Say("Creating temporary keypair for this instance...")
-	keyName := fmt.Sprintf("packer %s", hex.EncodeToString(identifier.NewUUID().Raw()))
+	keyName := config.TemporaryKeyPairName
 	log.Printf("temporary keypair name: %s", keyName)
-	keyResp, err := ec2conn.CreateKeyPair(keyName)
+	var keyResp *ec2.CreateKeyPairResp
+	err := retryThrottled(func() (err error) {
+		keyResp, err = ec2conn.CreateKeyPair(keyName)
+		return
+	})
 	if err != nil {
 		err := fmt.Errorf("Error creating temporary keypair: %s", err)
 		state["error"] = err
@@ -32,6 +61,18 @@ func (s *stepKeyPair) Run(state map[string]interface{}) multistep.StepAction {
 	// Set the keyname so we know to delete it later
 	s.keyName = keyName
 
+	// If we're in debug mode, output the private key to the working
+	// directory so the machine can be inspected over SSH.
+	if s.Debug {
+		ui.Message(fmt.Sprintf("Saving key for debug purposes: %s", s.DebugKeyPath))
+		if err := ioutil.WriteFile(s.DebugKeyPath, []byte(keyResp.KeyMaterial), 0600); err != nil {
+			err := fmt.Errorf("Error saving debug key: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	// Set some state data for use in future steps
 	state["keyPair"] = keyName
 	state["privateKey"] = keyResp.KeyMaterial
@@ -45,11 +86,19 @@ func (s *stepKeyPair) Cleanup(state map[string]interface{}) {
 		return
 	}
 
-	ec2conn := state["ec2"].(*ec2.EC2)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var ec2conn *ec2.EC2
+	var ui packer.Ui
+	if err := bag.Values("ec2", &ec2conn, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
 
 	ui.Say("Deleting temporary keypair...")
-	_, err := ec2conn.DeleteKeyPair(s.keyName)
+	err := retryThrottled(func() error {
+		_, err := ec2conn.DeleteKeyPair(s.keyName)
+		return err
+	})
 	if err != nil {
 		ui.Error(fmt.Sprintf(
 			"Error cleaning up keypair. Please delete the key manually: %s", s.keyName))
@@ -0,0 +1,98 @@
+package amazonebs
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"sort"
+)
+
+// stepModifyAMIAttributes shares the created AMIs in every region with the
+// ami_users and ami_groups, and the snapshots backing them with the
+// snapshot_users.
+type stepModifyAMIAttributes struct{}
+
+func (s *stepModifyAMIAttributes) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config config
+	var ec2conn *ec2.EC2
+	var amis map[string]string
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ec2", &ec2conn, "amis", &amis, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	if len(config.AMIUsers) == 0 && len(config.AMIGroups) == 0 &&
+		len(config.SnapshotUsers) == 0 {
+		return multistep.ActionContinue
+	}
+
+	regions := make([]string, 0, len(amis))
+	for region := range amis {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	for _, region := range regions {
+		ami := amis[region]
+		regionconn := ec2.New(ec2conn.Auth, aws.Regions[region])
+
+		if len(config.AMIUsers) > 0 || len(config.AMIGroups) > 0 {
+			ui.Say(fmt.Sprintf("Modifying launch permissions of AMI (%s)...", ami))
+			err := retryThrottled(func() error {
+				_, err := regionconn.ModifyImageAttribute(ami, &ec2.ModifyImageAttribute{
+					AddUsers:  config.AMIUsers,
+					AddGroups: config.AMIGroups,
+				})
+				return err
+			})
+			if err != nil {
+				err := fmt.Errorf("Error modifying launch permissions of AMI (%s): %s", ami, err)
+				if len(config.AMIGroups) > 0 {
+					err = fmt.Errorf(
+						"%s\n\nAMIs backed by snapshots that can't be shared, such as "+
+							"encrypted snapshots, can't be made public.", err)
+				}
+
+				state["error"] = err
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+
+		if len(config.SnapshotUsers) > 0 {
+			snapshotIds, err := imageSnapshotIds(regionconn, ami)
+			if err != nil {
+				err := fmt.Errorf("Error looking up snapshots of AMI (%s): %s", ami, err)
+				state["error"] = err
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+
+			for _, id := range snapshotIds {
+				ui.Say(fmt.Sprintf("Modifying create volume permissions of snapshot (%s)...", id))
+				err := retryThrottled(func() error {
+					_, err := regionconn.ModifySnapshotAttribute(id, &ec2.ModifySnapshotAttribute{
+						AddUsers: config.SnapshotUsers,
+					})
+					return err
+				})
+				if err != nil {
+					err := fmt.Errorf("Error modifying permissions of snapshot (%s): %s", id, err)
+					state["error"] = err
+					ui.Error(err.Error())
+					return multistep.ActionHalt
+				}
+			}
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepModifyAMIAttributes) Cleanup(map[string]interface{}) {
+	// No cleanup...
+}
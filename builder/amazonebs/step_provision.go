@@ -2,6 +2,7 @@ package amazonebs
 
 import (
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
 )
@@ -9,13 +10,17 @@ import (
 type stepProvision struct{}
 
 func (*stepProvision) Run(state map[string]interface{}) multistep.StepAction {
-	comm := state["communicator"].(packer.Communicator)
-	hook := state["hook"].(packer.Hook)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var comm packer.Communicator
+	var hook packer.Hook
+	var ui packer.Ui
+	if err := bag.Values("communicator", &comm, "hook", &hook, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
 	log.Println("Running the provision hook")
 	if err := hook.Run(packer.HookProvision, ui, comm, nil); err != nil {
-		state["error"] = err
+		state["error"] = spotError(state, err)
 		return multistep.ActionHalt
 	}
 
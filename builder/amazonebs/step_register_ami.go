@@ -0,0 +1,66 @@
+package amazonebs
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"path"
+)
+
+// stepRegisterAMI registers the uploaded bundle as an AMI.
+type stepRegisterAMI struct{}
+
+func (s *stepRegisterAMI) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config config
+	var ec2conn *ec2.EC2
+	var manifestPath string
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ec2", &ec2conn, "bundleManifestPath", &manifestPath, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	amiName := processAMIName(config, config.AMIName)
+
+	ui.Say(fmt.Sprintf("Registering the AMI: %s", amiName))
+	registerOpts := &ec2.RegisterImage{
+		ImageLocation: path.Join(config.S3Bucket, path.Base(manifestPath)),
+		Name:          amiName,
+		BlockDevices:  buildBlockDevices(config.AMIBlockDevices),
+	}
+
+	var registerResp *ec2.RegisterImageResp
+	err := retryThrottled(func() (err error) {
+		registerResp, err = ec2conn.RegisterImage(registerOpts)
+		return
+	})
+	if err != nil {
+		err := fmt.Errorf("Error registering AMI: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Set the AMI ID in the state
+	ui.Say(fmt.Sprintf("AMI: %s", registerResp.ImageId))
+	amis := make(map[string]string)
+	amis[config.Region] = registerResp.ImageId
+	state["amis"] = amis
+
+	// Wait for the image to become ready
+	ui.Say("Waiting for AMI to become ready...")
+	if err := waitForImage(ec2conn, config.waitOpts(ui, state), registerResp.ImageId); err != nil {
+		err := fmt.Errorf("Error querying images: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state["amiName"] = amiName
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRegisterAMI) Cleanup(map[string]interface{}) {}
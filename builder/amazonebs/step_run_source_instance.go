@@ -1,47 +1,187 @@
 package amazonebs
 
 import (
+	"errors"
 	"fmt"
 	"github.com/mitchellh/goamz/ec2"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
 	"log"
+	"strings"
+	"time"
 )
 
+// The time to wait before retrying a launch that failed because the IAM
+// instance profile isn't usable yet.
+var iamProfileRetryDelay = 10 * time.Second
+
 type stepRunSourceInstance struct {
-	instance *ec2.Instance
+	instance      *ec2.Instance
+	spotRequestId string
 }
 
 func (s *stepRunSourceInstance) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(config)
-	ec2conn := state["ec2"].(*ec2.EC2)
-	keyName := state["keyPair"].(string)
-	securityGroupId := state["securityGroupId"].(string)
-	ui := state["ui"].(packer.Ui)
-
-	runOpts := &ec2.RunInstances{
-		KeyName:        keyName,
-		ImageId:        config.SourceAmi,
-		InstanceType:   config.InstanceType,
-		MinCount:       0,
-		MaxCount:       0,
-		SecurityGroups: []ec2.SecurityGroup{ec2.SecurityGroup{Id: securityGroupId}},
+	bag := common.StateBag(state)
+	var config config
+	var ec2conn *ec2.EC2
+	var keyName string
+	var securityGroupIds []string
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ec2", &ec2conn, "keyPair", &keyName, "securityGroupIds", &securityGroupIds, "ui", &ui); err != nil {
+		return bag.Halt(err)
 	}
 
-	ui.Say("Launching a source AWS instance...")
-	runResp, err := ec2conn.RunInstances(runOpts)
-	if err != nil {
-		err := fmt.Errorf("Error launching source instance: %s", err)
-		state["error"] = err
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	securityGroups := make([]ec2.SecurityGroup, len(securityGroupIds))
+	for i, id := range securityGroupIds {
+		securityGroups[i] = ec2.SecurityGroup{Id: id}
+	}
+
+	// The user data is base64 encoded by goamz when launching
+	userData := []byte(config.UserData)
+	if config.UserDataFile != "" {
+		var err error
+		userData, err = ioutil.ReadFile(config.UserDataFile)
+		if err == nil && len(userData) > maxUserDataSize {
+			err = fmt.Errorf("must be at most %d bytes", maxUserDataSize)
+		}
+
+		if err != nil {
+			err := fmt.Errorf("Error reading user_data_file: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if config.SpotPrice == "" {
+		runOpts := &ec2.RunInstances{
+			KeyName:            keyName,
+			ImageId:            config.SourceAmi,
+			InstanceType:       config.InstanceType,
+			UserData:           userData,
+			MinCount:           0,
+			MaxCount:           0,
+			SecurityGroups:     securityGroups,
+			IamInstanceProfile: config.IamInstanceProfile,
+			SubnetId:           config.SubnetId,
+			BlockDevices:       buildBlockDevices(config.LaunchBlockDevices),
+
+			AssociatePublicIpAddress: config.AssociatePublicIpAddress,
+		}
+
+		ui.Say("Launching a source AWS instance...")
+		var runResp *ec2.RunInstancesResp
+		err := retryIamProfile(ui, config.IamInstanceProfile, func() error {
+			return retryThrottled(func() (err error) {
+				runResp, err = ec2conn.RunInstances(runOpts)
+				return
+			})
+		})
+		if err != nil {
+			err := fmt.Errorf("Error launching source instance: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		s.instance = &runResp.Instances[0]
+	} else {
+		spotPrice := config.SpotPrice
+		availZone := ""
+		if spotPrice == "auto" {
+			ui.Say("Finding the current spot price...")
+			var err error
+			spotPrice, availZone, err = spotPriceAuto(
+				ec2conn, config.InstanceType, config.SpotPriceAutoProduct, config.SubnetId != "")
+			if err != nil {
+				err := fmt.Errorf("Error finding spot price: %s", err)
+				state["error"] = err
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+
+		spotOpts := &ec2.RequestSpotInstances{
+			SpotPrice:          spotPrice,
+			InstanceCount:      1,
+			Type:               "one-time",
+			KeyName:            keyName,
+			ImageId:            config.SourceAmi,
+			InstanceType:       config.InstanceType,
+			UserData:           userData,
+			SecurityGroups:     securityGroups,
+			AvailZone:          availZone,
+			IamInstanceProfile: config.IamInstanceProfile,
+			SubnetId:           config.SubnetId,
+			BlockDevices:       buildBlockDevices(config.LaunchBlockDevices),
+
+			AssociatePublicIpAddress: config.AssociatePublicIpAddress,
+		}
+
+		ui.Say(fmt.Sprintf("Requesting a source AWS spot instance at %s...", spotPrice))
+		var spotResp *ec2.RequestSpotInstancesResp
+		err := retryIamProfile(ui, config.IamInstanceProfile, func() error {
+			return retryThrottled(func() (err error) {
+				spotResp, err = ec2conn.RequestSpotInstances(spotOpts)
+				return
+			})
+		})
+		if err != nil {
+			err := fmt.Errorf("Error requesting spot instance: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		if len(spotResp.SpotRequestResults) == 0 {
+			err := errors.New("Error requesting spot instance: no spot request was created")
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		s.spotRequestId = spotResp.SpotRequestResults[0].SpotRequestId
+		state["spotRequestId"] = s.spotRequestId
+		log.Printf("spot request id: %s", s.spotRequestId)
+
+		ui.Say("Waiting for the spot request to be fulfilled...")
+		requestWait := config.waitOpts(ui, state)
+		requestWait.Timeout = config.SpotRequestTimeout
+		instanceId, err := waitForSpotRequest(ec2conn, requestWait, s.spotRequestId)
+		if err != nil {
+			err := fmt.Errorf("Error waiting for spot request: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		var instanceResp *ec2.InstancesResp
+		err = retryThrottled(func() (err error) {
+			instanceResp, err = ec2conn.Instances([]string{instanceId}, ec2.NewFilter())
+			return
+		})
+		if err == nil && (len(instanceResp.Reservations) == 0 || len(instanceResp.Reservations[0].Instances) == 0) {
+			err = fmt.Errorf("instance %s not found", instanceId)
+		}
+
+		if err != nil {
+			err := fmt.Errorf("Error finding spot instance: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		s.instance = &instanceResp.Reservations[0].Instances[0]
 	}
 
-	s.instance = &runResp.Instances[0]
 	log.Printf("instance id: %s", s.instance.InstanceId)
 
 	ui.Say("Waiting for instance to become ready...")
-	s.instance, err = waitForState(ec2conn, s.instance, []string{"pending"}, "running")
+	var err error
+	s.instance, err = waitForState(
+		ec2conn, config.waitOpts(ui, state), s.instance, []string{"pending"}, "running")
 	if err != nil {
 		err := fmt.Errorf("Error waiting for instance to become ready: %s", err)
 		state["error"] = err
@@ -55,19 +195,97 @@ func (s *stepRunSourceInstance) Run(state map[string]interface{}) multistep.Step
 }
 
 func (s *stepRunSourceInstance) Cleanup(state map[string]interface{}) {
-	if s.instance == nil {
+	bag := common.StateBag(state)
+	var config config
+	var ec2conn *ec2.EC2
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ec2", &ec2conn, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
 		return
 	}
 
-	ec2conn := state["ec2"].(*ec2.EC2)
-	ui := state["ui"].(packer.Ui)
+	if s.spotRequestId != "" {
+		ui.Say("Cancelling the spot request...")
+		err := retryThrottled(func() error {
+			_, err := ec2conn.CancelSpotRequests([]string{s.spotRequestId})
+			return err
+		})
+		if err != nil {
+			ui.Error(fmt.Sprintf(
+				"Error cancelling spot request, may still be around: %s", err))
+		}
+
+		// The request may have been fulfilled after we stopped waiting
+		// for it, in which case its instance still has to be terminated.
+		if s.instance == nil {
+			var resp *ec2.SpotRequestsResp
+			err := retryThrottled(func() (err error) {
+				resp, err = ec2conn.DescribeSpotRequests([]string{s.spotRequestId}, ec2.NewFilter())
+				return
+			})
+			if err == nil && len(resp.SpotRequestResults) > 0 {
+				if instanceId := resp.SpotRequestResults[0].InstanceId; instanceId != "" {
+					s.instance = &ec2.Instance{
+						InstanceId: instanceId,
+						State:      ec2.InstanceState{Name: "pending"},
+					}
+				}
+			}
+		}
+	}
+
+	if s.instance == nil {
+		return
+	}
 
 	ui.Say("Terminating the source AWS instance...")
-	if _, err := ec2conn.TerminateInstances([]string{s.instance.InstanceId}); err != nil {
+	err := retryThrottled(func() error {
+		_, err := ec2conn.TerminateInstances([]string{s.instance.InstanceId})
+		return err
+	})
+	if err != nil {
 		ui.Error(fmt.Sprintf("Error terminating instance, may still be around: %s", err))
 		return
 	}
 
 	pending := []string{"pending", "running", "shutting-down", "stopped", "stopping"}
-	waitForState(ec2conn, s.instance, pending, "terminated")
+	waitForState(ec2conn, config.waitOpts(ui, nil), s.instance, pending, "terminated")
+}
+
+// retryIamProfile calls launch, retrying it once if it fails because of the
+// IAM instance profile. IAM is eventually consistent, so a profile that was
+// just created may not be usable by EC2 for a few seconds. If it still
+// fails, the error says that the profile is the problem.
+func retryIamProfile(ui packer.Ui, profile string, launch func() error) error {
+	err := launch()
+	if err == nil || !iamProfileError(profile, err) {
+		return err
+	}
+
+	ui.Message(fmt.Sprintf(
+		"The IAM instance profile isn't usable yet. Retrying in %s...", iamProfileRetryDelay))
+	time.Sleep(iamProfileRetryDelay)
+
+	if err := launch(); err != nil {
+		if iamProfileError(profile, err) {
+			return fmt.Errorf(
+				"IAM instance profile '%s' doesn't exist or can't be used: %s", profile, err)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// iamProfileError returns whether the launch error is EC2 rejecting the
+// IAM instance profile.
+func iamProfileError(profile string, err error) bool {
+	if profile == "" {
+		return false
+	}
+
+	ec2err, ok := err.(*ec2.Error)
+	return ok && ec2err.Code == "InvalidParameterValue" &&
+		strings.Contains(strings.ToLower(ec2err.Message), "iaminstanceprofile")
 }
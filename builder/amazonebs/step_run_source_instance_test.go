@@ -0,0 +1,71 @@
+package amazonebs
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/packer/packer"
+	"testing"
+)
+
+func init() {
+	iamProfileRetryDelay = 0
+}
+
+func TestRetryIamProfile(t *testing.T) {
+	ui := &packer.ReaderWriterUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+
+	profileErr := &ec2.Error{
+		Code:    "InvalidParameterValue",
+		Message: "Value (foo) for parameter iamInstanceProfile.name is invalid.",
+	}
+
+	// Succeeds on the retry
+	calls := 0
+	err := retryIamProfile(ui, "foo", func() error {
+		calls++
+		if calls == 1 {
+			return profileErr
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if calls != 2 {
+		t.Fatalf("bad calls: %d", calls)
+	}
+
+	// Other errors aren't retried
+	calls = 0
+	err = retryIamProfile(ui, "foo", func() error {
+		calls++
+		return errors.New("foo")
+	})
+	if err == nil || err.Error() != "foo" {
+		t.Fatalf("bad: %s", err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("bad calls: %d", calls)
+	}
+
+	// Fails again, and says the profile is the problem
+	calls = 0
+	err = retryIamProfile(ui, "foo", func() error {
+		calls++
+		return profileErr
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if calls != 2 {
+		t.Fatalf("bad calls: %d", calls)
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"github.com/mitchellh/goamz/ec2"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
+	"time"
 )
 
 type stepSecurityGroup struct {
@@ -15,16 +17,37 @@ type stepSecurityGroup struct {
 }
 
 func (s *stepSecurityGroup) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(config)
-	ec2conn := state["ec2"].(*ec2.EC2)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var config config
+	var ec2conn *ec2.EC2
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ec2", &ec2conn, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	if len(config.SecurityGroupIds) > 0 {
+		log.Printf("Using security groups: %v", config.SecurityGroupIds)
+		state["securityGroupIds"] = config.SecurityGroupIds
+		return multistep.ActionContinue
+	}
 
 	// Create the group
 	ui.Say("Creating temporary security group for this instance...")
 	groupName := fmt.Sprintf("packer %s", hex.EncodeToString(identifier.NewUUID().Raw()))
 	log.Printf("Temporary group name: %s", groupName)
-	groupResp, err := ec2conn.CreateSecurityGroup(groupName, "Temporary group for Packer")
+	group := ec2.SecurityGroup{
+		Name:        groupName,
+		Description: "Temporary group for Packer",
+		VpcId:       config.VpcId,
+	}
+	var groupResp *ec2.CreateSecurityGroupResp
+	err := retryThrottled(func() (err error) {
+		groupResp, err = ec2conn.CreateSecurityGroup(group)
+		return
+	})
 	if err != nil {
+		err := fmt.Errorf("Error creating temporary security group: %s", err)
+		state["error"] = err
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
@@ -43,7 +66,11 @@ func (s *stepSecurityGroup) Run(state map[string]interface{}) multistep.StepActi
 	}
 
 	ui.Say("Authorizing SSH access on the temporary security group...")
-	if _, err := ec2conn.AuthorizeSecurityGroup(groupResp.SecurityGroup, perms); err != nil {
+	err = retryThrottled(func() error {
+		_, err := ec2conn.AuthorizeSecurityGroup(groupResp.SecurityGroup, perms)
+		return err
+	})
+	if err != nil {
 		err := fmt.Errorf("Error creating temporary security group: %s", err)
 		state["error"] = err
 		ui.Error(err.Error())
@@ -51,7 +78,7 @@ func (s *stepSecurityGroup) Run(state map[string]interface{}) multistep.StepActi
 	}
 
 	// Set some state data for use in future steps
-	state["securityGroupId"] = s.groupId
+	state["securityGroupIds"] = []string{s.groupId}
 
 	return multistep.ActionContinue
 }
@@ -61,14 +88,37 @@ func (s *stepSecurityGroup) Cleanup(state map[string]interface{}) {
 		return
 	}
 
-	ec2conn := state["ec2"].(*ec2.EC2)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var ec2conn *ec2.EC2
+	var ui packer.Ui
+	if err := bag.Values("ec2", &ec2conn, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
 
 	ui.Say("Deleting temporary security group...")
-	_, err := ec2conn.DeleteSecurityGroup(ec2.SecurityGroup{Id: s.groupId})
-	if err != nil {
-		log.Printf("Error deleting security group: %s", err)
-		ui.Error(fmt.Sprintf(
-			"Error cleaning up security group. Please delete the group manually: %s", s.groupId))
+
+	// The group can't be deleted until EC2 notices the instance using it
+	// is gone, which can take a little while after it is terminated.
+	var err error
+	for i := 0; i < 5; i++ {
+		err = retryThrottled(func() error {
+			_, err := ec2conn.DeleteSecurityGroup(ec2.SecurityGroup{Id: s.groupId})
+			return err
+		})
+		if err == nil {
+			return
+		}
+
+		if ec2err, ok := err.(*ec2.Error); !ok || ec2err.Code != "DependencyViolation" {
+			break
+		}
+
+		log.Printf("Security group still in use, retrying: %s", err)
+		time.Sleep(5 * time.Second)
 	}
+
+	log.Printf("Error deleting security group: %s", err)
+	ui.Error(fmt.Sprintf(
+		"Error cleaning up security group. Please delete the group manually: %s", s.groupId))
 }
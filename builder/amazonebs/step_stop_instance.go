@@ -4,19 +4,28 @@ import (
 	"fmt"
 	"github.com/mitchellh/goamz/ec2"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 )
 
 type stepStopInstance struct{}
 
 func (s *stepStopInstance) Run(state map[string]interface{}) multistep.StepAction {
-	ec2conn := state["ec2"].(*ec2.EC2)
-	instance := state["instance"].(*ec2.Instance)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var config config
+	var ec2conn *ec2.EC2
+	var instance *ec2.Instance
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ec2", &ec2conn, "instance", &instance, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
 	// Stop the instance so we can create an AMI from it
 	ui.Say("Stopping the source instance...")
-	_, err := ec2conn.StopInstances(instance.InstanceId)
+	err := retryThrottled(func() error {
+		_, err := ec2conn.StopInstances(instance.InstanceId)
+		return err
+	})
 	if err != nil {
 		err := fmt.Errorf("Error stopping instance: %s", err)
 		state["error"] = err
@@ -26,9 +35,9 @@ func (s *stepStopInstance) Run(state map[string]interface{}) multistep.StepActio
 
 	// Wait for the instance to actual stop
 	ui.Say("Waiting for the instance to stop...")
-	instance, err = waitForState(ec2conn, instance, []string{"running", "stopping"}, "stopped")
+	instance, err = waitForState(ec2conn, config.waitOpts(ui, state), instance, []string{"running", "stopping"}, "stopped")
 	if err != nil {
-		err := fmt.Errorf("Error waiting for instance to stop: %s", err)
+		err := spotError(state, fmt.Errorf("Error waiting for instance to stop: %s", err))
 		state["error"] = err
 		ui.Error(err.Error())
 		return multistep.ActionHalt
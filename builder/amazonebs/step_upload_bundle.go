@@ -0,0 +1,105 @@
+package amazonebs
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"text/template"
+)
+
+type uploadCmdData struct {
+	AccessKey       string
+	BucketName      string
+	BundleDirectory string
+	ManifestPath    string
+	SecretKey       string
+	Token           string
+}
+
+// stepUploadBundle uploads the bundle to S3 with the AMI tools in the
+// instance, using bundle_upload_command. If the build fails afterwards,
+// the bundle is deleted from S3 again.
+type stepUploadBundle struct {
+	uploaded bool
+}
+
+func (s *stepUploadBundle) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var comm packer.Communicator
+	var config config
+	var manifestPath string
+	var ui packer.Ui
+	if err := bag.Values("communicator", &comm, "config", &config, "bundleManifestPath", &manifestPath, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	tData := uploadCmdData{
+		AccessKey:       config.AccessKey,
+		BucketName:      config.S3Bucket,
+		BundleDirectory: config.BundleDestination,
+		ManifestPath:    manifestPath,
+		SecretKey:       config.SecretKey,
+		Token:           config.Token,
+	}
+
+	var command bytes.Buffer
+	t := template.Must(template.New("upload").Parse(config.BundleUploadCommand))
+	t.Execute(&command, tData)
+
+	// Even a failed upload may have left some parts of the bundle in S3
+	s.uploaded = true
+
+	ui.Say("Uploading the bundle...")
+	if err := runRemoteCommand(comm, ui, command.String()); err != nil {
+		err := fmt.Errorf("Error uploading bundle: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepUploadBundle) Cleanup(state map[string]interface{}) {
+	if !s.uploaded {
+		return
+	}
+
+	_, cancelled := state[multistep.StateCancelled]
+	_, halted := state[multistep.StateHalted]
+	if !cancelled && !halted {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var config config
+	var ec2conn *ec2.EC2
+	var prefix string
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ec2", &ec2conn, "bundlePrefix", &prefix, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	ui.Say("Deleting the bundle from S3...")
+	bucket := s3.New(ec2conn.Auth, ec2conn.Region).Bucket(config.S3Bucket)
+	resp, err := bucket.List(prefix, "", "", 1000)
+	if err != nil {
+		ui.Error(fmt.Sprintf(
+			"Error listing the bundle in S3. Please delete it manually: %s", err))
+		return
+	}
+
+	for _, key := range resp.Contents {
+		log.Printf("Deleting from S3: %s", key.Key)
+		if err := bucket.Del(key.Key); err != nil {
+			ui.Error(fmt.Sprintf(
+				"Error deleting %s from S3. Please delete it manually: %s", key.Key, err))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package amazonebs
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"os"
+	"path"
+)
+
+// stepUploadX509Cert uploads the X509 certificate and private key that
+// the AMI tools need to bundle the volume.
+type stepUploadX509Cert struct{}
+
+func (s *stepUploadX509Cert) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var comm packer.Communicator
+	var config config
+	var ui packer.Ui
+	if err := bag.Values("communicator", &comm, "config", &config, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	x509RemoteCertPath := path.Join(config.X509UploadPath, "cert.pem")
+	x509RemoteKeyPath := path.Join(config.X509UploadPath, "key.pem")
+	files := map[string]string{
+		config.X509CertPath: x509RemoteCertPath,
+		config.X509KeyPath:  x509RemoteKeyPath,
+	}
+
+	ui.Say("Uploading X509 certificate...")
+	for src, dst := range files {
+		f, err := os.Open(src)
+		if err != nil {
+			err := fmt.Errorf("Error opening X509 file: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		err = comm.Upload(dst, f)
+		f.Close()
+		if err != nil {
+			err := fmt.Errorf("Error uploading X509 file: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	state["x509RemoteCertPath"] = x509RemoteCertPath
+	state["x509RemoteKeyPath"] = x509RemoteKeyPath
+
+	return multistep.ActionContinue
+}
+
+func (s *stepUploadX509Cert) Cleanup(map[string]interface{}) {}
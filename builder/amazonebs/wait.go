@@ -0,0 +1,105 @@
+package amazonebs
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"time"
+)
+
+// How often a waiter reports that it's still waiting, so that long waits
+// don't look like a hang.
+var waitProgressInterval = 1 * time.Minute
+
+// The delay before the first retry of a throttled API call. The delay is
+// doubled for each further retry.
+var throttleRetryDelay = 1 * time.Second
+
+// The number of times a throttled API call is retried before giving up.
+const throttleRetries = 6
+
+// waitOpts controls how often and how long a waiter polls, and what it is
+// reported as while waiting. If State is set, the waiter stops as soon as
+// the build is cancelled.
+type waitOpts struct {
+	Interval time.Duration
+	Timeout  time.Duration
+	Ui       packer.Ui
+	State    map[string]interface{}
+}
+
+// waitOpts returns the options to poll the state of resources with, as
+// configured by state_poll_interval and state_timeout. Cleanup passes a
+// nil state so that its waits aren't cut short by the cancellation that
+// is being cleaned up after.
+func (c *config) waitOpts(ui packer.Ui, state map[string]interface{}) waitOpts {
+	return waitOpts{
+		Interval: c.StatePollInterval,
+		Timeout:  c.StateTimeout,
+		Ui:       ui,
+		State:    state,
+	}
+}
+
+// waitFor calls refresh every interval until it returns true or an error,
+// or until the timeout passes. The description of what is being waited for
+// is used in the progress messages and the timeout error.
+func waitFor(opts waitOpts, desc string, refresh func() (bool, error)) error {
+	start := time.Now()
+	lastReport := start
+	timeoutCh := time.After(opts.Timeout)
+	for {
+		var done bool
+		err := retryThrottled(func() (err error) {
+			done, err = refresh()
+			return
+		})
+		if err != nil {
+			return err
+		}
+
+		if done {
+			return nil
+		}
+
+		select {
+		case <-timeoutCh:
+			return fmt.Errorf("timeout after %s waiting for %s", opts.Timeout, desc)
+		case <-time.After(opts.Interval):
+		}
+
+		if _, ok := opts.State[multistep.StateCancelled]; ok {
+			return fmt.Errorf("interrupted while waiting for %s", desc)
+		}
+
+		if opts.Ui != nil && time.Since(lastReport) >= waitProgressInterval {
+			lastReport = time.Now()
+			opts.Ui.Message(fmt.Sprintf("Still waiting for %s (%dm elapsed)...",
+				desc, time.Since(start)/time.Minute))
+		}
+	}
+}
+
+// retryThrottled calls f, retrying it with an exponential backoff while it
+// fails because EC2 is throttling the API requests.
+func retryThrottled(f func() error) error {
+	delay := throttleRetryDelay
+	for i := 0; ; i++ {
+		err := f()
+		if err == nil || i == throttleRetries || !throttleError(err) {
+			return err
+		}
+
+		log.Printf("Request throttled, retrying in %s: %s", delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// throttleError returns whether the error is EC2 throttling the requests.
+func throttleError(err error) bool {
+	ec2err, ok := err.(*ec2.Error)
+	return ok && (ec2err.Code == "RequestLimitExceeded" || ec2err.Code == "Throttling")
+}
@@ -0,0 +1,122 @@
+package amazonebs
+
+import (
+	"errors"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"testing"
+	"time"
+)
+
+func TestWaitFor(t *testing.T) {
+	opts := waitOpts{Interval: time.Millisecond, Timeout: time.Second}
+
+	calls := 0
+	err := waitFor(opts, "foo", func() (bool, error) {
+		calls++
+		return calls == 3, nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("bad: %d", calls)
+	}
+}
+
+func TestWaitFor_Error(t *testing.T) {
+	opts := waitOpts{Interval: time.Millisecond, Timeout: time.Second}
+
+	err := waitFor(opts, "foo", func() (bool, error) {
+		return false, errors.New("bar")
+	})
+	if err == nil || err.Error() != "bar" {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestWaitFor_Timeout(t *testing.T) {
+	opts := waitOpts{Interval: time.Millisecond, Timeout: 10 * time.Millisecond}
+
+	err := waitFor(opts, "foo", func() (bool, error) {
+		return false, nil
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestWaitFor_Cancelled(t *testing.T) {
+	state := make(map[string]interface{})
+	opts := waitOpts{Interval: time.Millisecond, Timeout: time.Second, State: state}
+
+	calls := 0
+	err := waitFor(opts, "foo", func() (bool, error) {
+		calls++
+		if calls == 2 {
+			state[multistep.StateCancelled] = true
+		}
+
+		return false, nil
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if calls != 2 {
+		t.Fatalf("bad: %d", calls)
+	}
+}
+
+func TestRetryThrottled(t *testing.T) {
+	oldDelay := throttleRetryDelay
+	defer func() { throttleRetryDelay = oldDelay }()
+	throttleRetryDelay = time.Millisecond
+
+	// Throttled calls are retried
+	calls := 0
+	err := retryThrottled(func() error {
+		calls++
+		if calls < 3 {
+			return &ec2.Error{Code: "RequestLimitExceeded"}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("bad: %d", calls)
+	}
+
+	// Other errors aren't
+	calls = 0
+	err = retryThrottled(func() error {
+		calls++
+		return &ec2.Error{Code: "InvalidParameterValue"}
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if calls != 1 {
+		t.Fatalf("bad: %d", calls)
+	}
+
+	// Retrying stops eventually
+	calls = 0
+	err = retryThrottled(func() error {
+		calls++
+		return &ec2.Error{Code: "Throttling"}
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if calls != throttleRetries+1 {
+		t.Fatalf("bad: %d", calls)
+	}
+}
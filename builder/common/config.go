@@ -0,0 +1,47 @@
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// DownloadableURL processes a URL that may also be a file path and returns
+// a completely valid URL. For example, the original URL might be "local/file.iso"
+// which isn't a valid URL. DownloadableURL will return "file:///local/file.iso"
+func DownloadableURL(original string) (string, error) {
+	url, err := url.Parse(original)
+	if err != nil {
+		return "", err
+	}
+
+	if url.Scheme == "" {
+		url.Scheme = "file"
+	}
+
+	if url.Scheme == "file" {
+		if _, err := os.Stat(url.Path); err != nil {
+			return "", fmt.Errorf("points to bad file: %s", err)
+		}
+	}
+
+	// Make sure it is lowercased
+	url.Scheme = strings.ToLower(url.Scheme)
+
+	// Verify that the scheme is something we support in our common downloader.
+	supported := []string{"file", "http", "https"}
+	found := false
+	for _, s := range supported {
+		if url.Scheme == s {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return "", fmt.Errorf("Unsupported URL scheme: %s", url.Scheme)
+	}
+
+	return url.String(), nil
+}
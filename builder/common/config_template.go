@@ -0,0 +1,64 @@
+package common
+
+import (
+	"bytes"
+	"cgl.tideland.biz/identifier"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// ConfigTemplate processes configuration values that may contain template
+// functions, such as "{{timestamp}}", "{{build_name}}", "{{uuid}}" and
+// "{{env "HOME"}}". The timestamp is the time the Packer template was
+// loaded, so every value of every build gets the same one. Every use of
+// uuid gets a new random UUID.
+type ConfigTemplate struct {
+	BuildName string
+	Timestamp time.Time
+}
+
+// NewConfigTemplate returns a ConfigTemplate for the build with the given
+// name and template timestamp in unix seconds, which is the value of the
+// "packer_template_timestamp" configuration key. If the timestamp is zero,
+// such as when the component isn't run from a template, the current time
+// is used.
+func NewConfigTemplate(buildName string, timestamp int64) *ConfigTemplate {
+	t := time.Now().UTC()
+	if timestamp != 0 {
+		t = time.Unix(timestamp, 0).UTC()
+	}
+
+	return &ConfigTemplate{
+		BuildName: buildName,
+		Timestamp: t,
+	}
+}
+
+// Funcs returns the template functions, for configuration values that are
+// rendered with their own template data.
+func (t *ConfigTemplate) Funcs() template.FuncMap {
+	return template.FuncMap{
+		"build_name": func() string { return t.BuildName },
+		"env":        os.Getenv,
+		"timestamp":  func() string { return strconv.FormatInt(t.Timestamp.Unix(), 10) },
+		"uuid":       func() string { return hex.EncodeToString(identifier.NewUUID().Raw()) },
+	}
+}
+
+// Process renders the given configuration value.
+func (t *ConfigTemplate) Process(value string) (string, error) {
+	tpl, err := template.New("config").Funcs(t.Funcs()).Parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	var result bytes.Buffer
+	if err := tpl.Execute(&result, nil); err != nil {
+		return "", err
+	}
+
+	return result.String(), nil
+}
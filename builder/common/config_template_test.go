@@ -0,0 +1,65 @@
+package common
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigTemplateProcess(t *testing.T) {
+	tpl := &ConfigTemplate{
+		BuildName: "foo",
+		Timestamp: time.Unix(1373000000, 0),
+	}
+
+	result, err := tpl.Process("packer-{{build_name}}-{{timestamp}}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result != "packer-foo-1373000000" {
+		t.Fatalf("bad: %s", result)
+	}
+
+	// Every uuid is different
+	result, err = tpl.Process("{{uuid}} {{uuid}}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	parts := strings.Split(result, " ")
+	if len(parts) != 2 || parts[0] == "" || parts[0] == parts[1] {
+		t.Fatalf("bad: %s", result)
+	}
+
+	// Environment variables can be read
+	os.Setenv("PACKER_TEST_ENV", "bar")
+	defer os.Setenv("PACKER_TEST_ENV", "")
+	result, err = tpl.Process(`{{env "PACKER_TEST_ENV"}}`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result != "bar" {
+		t.Fatalf("bad: %s", result)
+	}
+
+	// Unknown functions are an error
+	if _, err := tpl.Process("{{nope}}"); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestNewConfigTemplate(t *testing.T) {
+	tpl := NewConfigTemplate("foo", 1373000000)
+	if tpl.Timestamp.Unix() != 1373000000 {
+		t.Fatalf("bad: %s", tpl.Timestamp)
+	}
+
+	// Without a template timestamp, it is the current time
+	tpl = NewConfigTemplate("foo", 0)
+	if time.Since(tpl.Timestamp) > time.Minute {
+		t.Fatalf("bad: %s", tpl.Timestamp)
+	}
+}
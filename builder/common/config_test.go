@@ -0,0 +1,71 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDownloadableURL(t *testing.T) {
+	// Invalid URL: has hex code in host
+	_, err := DownloadableURL("http://what%20.com")
+	if err == nil {
+		t.Fatal("expected err")
+	}
+
+	// Invalid: unsupported scheme
+	_, err = DownloadableURL("ftp://host.com/path")
+	if err == nil {
+		t.Fatal("expected err")
+	}
+
+	// Valid: http
+	u, err := DownloadableURL("HTTP://packer.io/path")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if u != "http://packer.io/path" {
+		t.Fatalf("bad: %s", u)
+	}
+
+	// No path
+	u, err = DownloadableURL("HTTP://packer.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if u != "http://packer.io" {
+		t.Fatalf("bad: %s", u)
+	}
+}
+
+func TestDownloadableURL_FilePaths(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("tempfile err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+	tf.Close()
+
+	// Test that a bad file fails
+	_, err = DownloadableURL("i/dont/exist")
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	_, err = DownloadableURL("file:i/dont/exist")
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test a path to a file that exists
+	u, err := DownloadableURL(tf.Name())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if u != "file://"+tf.Name() {
+		t.Fatalf("unexpected url: %s", u)
+	}
+}
@@ -2,6 +2,10 @@ package common
 
 import (
 	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -40,6 +44,23 @@ type DownloadConfig struct {
 	Checksum []byte
 }
 
+// HashForType returns the Hash implementation for the given string
+// type, or nil if the type is not supported.
+func HashForType(t string) hash.Hash {
+	switch t {
+	case "md5":
+		return md5.New()
+	case "sha1":
+		return sha1.New()
+	case "sha256":
+		return sha256.New()
+	case "sha512":
+		return sha512.New()
+	default:
+		return nil
+	}
+}
+
 // A DownloadClient helps download, verify checksums, etc.
 type DownloadClient struct {
 	config     *DownloadConfig
@@ -51,7 +72,8 @@ type DownloadClient struct {
 func NewDownloadClient(c *DownloadConfig) *DownloadClient {
 	if c.DownloaderMap == nil {
 		c.DownloaderMap = map[string]Downloader{
-			"http": new(HTTPDownloader),
+			"http":  new(HTTPDownloader),
+			"https": new(HTTPDownloader),
 		}
 	}
 
@@ -136,16 +158,30 @@ func (d *DownloadClient) VerifyChecksum(path string) (bool, error) {
 		return false, errors.New("Checksum or Hash isn't set on download.")
 	}
 
-	f, err := os.Open(path)
+	log.Printf("Verifying checksum of %s", path)
+	checksum, err := ChecksumFile(d.config.Hash, path)
 	if err != nil {
 		return false, err
 	}
+
+	return bytes.Compare(checksum, d.config.Checksum) == 0, nil
+}
+
+// ChecksumFile returns the checksum of the file at the given path using
+// the given hash implementation, which is reset first.
+func ChecksumFile(h hash.Hash, path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
 	defer f.Close()
 
-	log.Printf("Verifying checksum of %s", path)
-	d.config.Hash.Reset()
-	io.Copy(d.config.Hash, f)
-	return bytes.Compare(d.config.Hash.Sum(nil), d.config.Checksum) == 0, nil
+	h.Reset()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
 }
 
 // HTTPDownloader is an implementation of Downloader that downloads
@@ -164,6 +200,11 @@ func (d *HTTPDownloader) Download(dst io.Writer, src *url.URL) error {
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP error: %s", resp.Status)
+	}
 
 	d.progress = 0
 	d.total = uint(resp.ContentLength)
@@ -40,3 +40,63 @@ func TestDownloadClient_VerifyChecksum(t *testing.T) {
 		t.Fatal("didn't verify")
 	}
 }
+
+func TestHashForType(t *testing.T) {
+	if h := HashForType("md5"); h == nil {
+		t.Fatalf("md5 hash is nil")
+	} else {
+		h.Write([]byte("foo"))
+		result := h.Sum(nil)
+
+		expected := "acbd18db4cc2f85cedef654fccc4a4d8"
+		actual := hex.EncodeToString(result)
+		if actual != expected {
+			t.Fatalf("bad hash: %s", actual)
+		}
+	}
+
+	if h := HashForType("sha256"); h == nil {
+		t.Fatalf("sha256 hash is nil")
+	} else {
+		h.Write([]byte("foo"))
+		result := h.Sum(nil)
+
+		expected := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+		actual := hex.EncodeToString(result)
+		if actual != expected {
+			t.Fatalf("bad hash: %s", actual)
+		}
+	}
+
+	if HashForType("fake") != nil {
+		t.Fatalf("fake hash is not nil")
+	}
+}
+
+func TestChecksumFile(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("tempfile error: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tf.Write([]byte("hello"))
+	tf.Close()
+
+	// Use the hash twice to make sure it is reset in between
+	h := md5.New()
+	for i := 0; i < 2; i++ {
+		checksum, err := ChecksumFile(h, tf.Name())
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if hex.EncodeToString(checksum) != "5d41402abc4b2a76b9719d911017c592" {
+			t.Fatalf("bad: %x", checksum)
+		}
+	}
+
+	if _, err := ChecksumFile(h, tf.Name()+"-nope"); err == nil {
+		t.Fatal("should have error")
+	}
+}
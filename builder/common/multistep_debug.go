@@ -23,27 +23,32 @@ func MultistepDebugFn(ui packer.Ui) multistep.DebugPauseFn {
 		}
 
 		message := fmt.Sprintf(
-			"Pausing %s step '%s'. Press any key to continue.",
+			"Pausing %s step '%s'. Press enter to continue.",
 			locationString, name)
+		debugPause(ui, message, state)
+	}
+}
 
-		result := make(chan string, 1)
-		go func() {
-			line, err := ui.Ask(message)
-			if err != nil {
-				log.Printf("Error asking for input: %s", err)
-			}
+// debugPause asks the Ui to press enter, returning once it has been or
+// once the build is cancelled.
+func debugPause(ui packer.Ui, message string, state map[string]interface{}) {
+	result := make(chan string, 1)
+	go func() {
+		line, err := ui.Ask(message)
+		if err != nil {
+			log.Printf("Error asking for input: %s", err)
+		}
 
-			result <- line
-		}()
+		result <- line
+	}()
 
-		for {
-			select {
-			case <-result:
+	for {
+		select {
+		case <-result:
+			return
+		case <-time.After(100 * time.Millisecond):
+			if _, ok := state[multistep.StateCancelled]; ok {
 				return
-			case <-time.After(100 * time.Millisecond):
-				if _, ok := state[multistep.StateCancelled]; ok {
-					return
-				}
 			}
 		}
 	}
@@ -0,0 +1,191 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"reflect"
+	"strings"
+)
+
+// These are the values of the "packer_on_error" configuration key, which
+// decide what happens when a step of a build fails.
+const (
+	// OnErrorCleanup cleans up every step that ran, which is the default.
+	OnErrorCleanup = "cleanup"
+
+	// OnErrorAbort skips cleaning up every step, leaving the machine and
+	// everything else created as they were when the step failed.
+	OnErrorAbort = "abort"
+
+	// OnErrorAsk asks through the Ui whether to clean up, abort, or retry
+	// the step that failed.
+	OnErrorAsk = "ask"
+
+	// OnErrorRetry runs the step that failed again. It is only ever an
+	// answer when asking, rather than a setting of its own.
+	OnErrorRetry = "retry"
+)
+
+// onErrorAbortKey is the key in the state that is set when cleanup should
+// be skipped because of the on-error setting, or because a step failed in
+// debug mode.
+const onErrorAbortKey = "packer_on_error_abort"
+
+// NewRunner returns the multistep.Runner that a builder should use to run
+// its steps, based on the debug and on-error settings of the build. In
+// debug mode the steps pause for input before they run and before they are
+// cleaned up, and a failed build isn't cleaned up so that what it created
+// can be inspected, so the on-error setting isn't used. The steps are
+// timed either way, though not while they are paused.
+func NewRunner(steps []multistep.Step, debug bool, onError string, ui packer.Ui) multistep.Runner {
+	if debug {
+		for i, step := range TimeSteps(steps) {
+			steps[i] = &debugStep{
+				Step: step,
+				name: reflect.Indirect(reflect.ValueOf(steps[i])).Type().Name(),
+				ui:   ui,
+			}
+		}
+
+		return &multistep.BasicRunner{Steps: steps}
+	}
+
+	steps = TimeSteps(steps)
+	if onError != "" && onError != OnErrorCleanup {
+		for i, step := range steps {
+			steps[i] = &onErrorStep{
+				Step:    step,
+				name:    step.(*timedStep).name,
+				onError: onError,
+			}
+		}
+	}
+
+	return &multistep.BasicRunner{Steps: steps}
+}
+
+// onErrorStep is a multistep.Step that handles the failure of the step it
+// wraps according to the on-error setting.
+type onErrorStep struct {
+	multistep.Step
+	name    string
+	onError string
+}
+
+func (s *onErrorStep) Run(state map[string]interface{}) multistep.StepAction {
+	for {
+		action := s.Step.Run(state)
+		if action != multistep.ActionHalt {
+			return action
+		}
+
+		// An interrupted build is always cleaned up
+		if _, ok := state[multistep.StateCancelled]; ok {
+			return action
+		}
+
+		// Without a Ui there is no way to say or ask anything
+		ui, err := StateBag(state).Ui()
+		if err != nil {
+			return action
+		}
+
+		onError := s.onError
+		if onError == OnErrorAsk {
+			onError = s.ask(ui, state)
+		}
+
+		switch onError {
+		case OnErrorAbort:
+			ui.Error("Step failed, aborting without cleaning up as requested.")
+			state[onErrorAbortKey] = true
+			return action
+		case OnErrorRetry:
+			ui.Say(fmt.Sprintf("Retrying step: %s", s.name))
+			delete(state, "error")
+		default:
+			return action
+		}
+	}
+}
+
+func (s *onErrorStep) Cleanup(state map[string]interface{}) {
+	if _, ok := state[onErrorAbortKey]; ok {
+		log.Printf("Skipping cleanup of step because of on-error: %s", s.name)
+		return
+	}
+
+	s.Step.Cleanup(state)
+}
+
+// debugStep is a multistep.Step that pauses before the step it wraps runs
+// and before it is cleaned up. If the step fails, nothing is cleaned up,
+// unless the build was cancelled.
+type debugStep struct {
+	multistep.Step
+	name string
+	ui   packer.Ui
+}
+
+func (s *debugStep) Run(state map[string]interface{}) multistep.StepAction {
+	debugPause(s.ui, fmt.Sprintf(
+		"Pausing before step '%s'. Press enter to continue.", s.name), state)
+	if _, ok := state[multistep.StateCancelled]; ok {
+		return multistep.ActionHalt
+	}
+
+	action := s.Step.Run(state)
+	if action == multistep.ActionHalt {
+		if _, ok := state[multistep.StateCancelled]; !ok {
+			s.ui.Error("Step failed in debug mode, so the build won't be cleaned up.\n" +
+				"Everything it created is left for inspection and has to be removed by hand.")
+			state[onErrorAbortKey] = true
+		}
+	}
+
+	return action
+}
+
+func (s *debugStep) Cleanup(state map[string]interface{}) {
+	if _, ok := state[onErrorAbortKey]; ok {
+		log.Printf("Skipping cleanup of step in debug mode: %s", s.name)
+		return
+	}
+
+	debugPause(s.ui, fmt.Sprintf(
+		"Pausing before cleanup of step '%s'. Press enter to continue.", s.name), state)
+	s.Step.Cleanup(state)
+}
+
+// ask asks what to do about the failed step, returning OnErrorCleanup,
+// OnErrorAbort, or OnErrorRetry. If the Ui can't ask, the build is cleaned
+// up.
+func (s *onErrorStep) ask(ui packer.Ui, state map[string]interface{}) string {
+	message := fmt.Sprintf("Step '%s' failed", s.name)
+	if err, ok := state["error"].(error); ok {
+		message = fmt.Sprintf("%s with error: %s", message, err)
+	}
+
+	message += "\n[c] Clean up and exit, [a] abort without cleanup, or [r] retry step:"
+
+	for {
+		line, err := ui.Ask(message)
+		if err != nil {
+			log.Printf("Error asking for input, cleaning up: %s", err)
+			return OnErrorCleanup
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "c":
+			return OnErrorCleanup
+		case "a":
+			return OnErrorAbort
+		case "r":
+			return OnErrorRetry
+		}
+
+		ui.Say(fmt.Sprintf("Incorrect input: %q", line))
+	}
+}
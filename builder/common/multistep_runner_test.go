@@ -0,0 +1,141 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"strings"
+	"testing"
+)
+
+type testOnErrorStep struct {
+	failures int
+	runs     int
+	cleaned  bool
+}
+
+func (s *testOnErrorStep) Run(state map[string]interface{}) multistep.StepAction {
+	s.runs++
+	if s.runs <= s.failures {
+		state["error"] = errors.New("failed")
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *testOnErrorStep) Cleanup(map[string]interface{}) {
+	s.cleaned = true
+}
+
+func testOnErrorState(input string) map[string]interface{} {
+	return map[string]interface{}{
+		"ui": &packer.ReaderWriterUi{
+			Reader: bytes.NewBufferString(input),
+			Writer: new(bytes.Buffer),
+		},
+	}
+}
+
+func TestNewRunner_OnErrorCleanup(t *testing.T) {
+	step1 := new(testOnErrorStep)
+	step2 := &testOnErrorStep{failures: 1}
+	state := testOnErrorState("")
+
+	NewRunner([]multistep.Step{step1, step2}, false, OnErrorCleanup, nil).Run(state)
+	if !step1.cleaned || !step2.cleaned {
+		t.Fatal("steps should be cleaned up")
+	}
+}
+
+func TestNewRunner_OnErrorAbort(t *testing.T) {
+	step1 := new(testOnErrorStep)
+	step2 := &testOnErrorStep{failures: 1}
+	state := testOnErrorState("")
+
+	NewRunner([]multistep.Step{step1, step2}, false, OnErrorAbort, nil).Run(state)
+	if step1.cleaned || step2.cleaned {
+		t.Fatal("steps shouldn't be cleaned up")
+	}
+
+	if _, ok := state["error"]; !ok {
+		t.Fatal("should keep the error")
+	}
+}
+
+func TestNewRunner_OnErrorAskRetry(t *testing.T) {
+	step1 := &testOnErrorStep{failures: 1}
+	step2 := new(testOnErrorStep)
+	state := testOnErrorState("r\n")
+
+	NewRunner([]multistep.Step{step1, step2}, false, OnErrorAsk, nil).Run(state)
+	if step1.runs != 2 {
+		t.Fatalf("bad runs: %d", step1.runs)
+	}
+
+	if step2.runs != 1 {
+		t.Fatal("second step should run")
+	}
+
+	if _, ok := state["error"]; ok {
+		t.Fatal("should clear the error")
+	}
+}
+
+func TestNewRunner_OnErrorAskAbort(t *testing.T) {
+	step1 := &testOnErrorStep{failures: 1}
+	state := testOnErrorState("x\na\n")
+
+	NewRunner([]multistep.Step{step1}, false, OnErrorAsk, nil).Run(state)
+	if step1.runs != 1 {
+		t.Fatalf("bad runs: %d", step1.runs)
+	}
+
+	if step1.cleaned {
+		t.Fatal("step shouldn't be cleaned up")
+	}
+}
+
+func TestNewRunner_Debug(t *testing.T) {
+	step1 := new(testOnErrorStep)
+	step2 := new(testOnErrorStep)
+	state := testOnErrorState("\n\n\n\n")
+	ui := state["ui"].(*packer.ReaderWriterUi)
+
+	NewRunner([]multistep.Step{step1, step2}, true, OnErrorCleanup, ui).Run(state)
+	if step1.runs != 1 || step2.runs != 1 {
+		t.Fatal("steps should run")
+	}
+
+	if !step1.cleaned || !step2.cleaned {
+		t.Fatal("steps should be cleaned up")
+	}
+
+	out := ui.Writer.(*bytes.Buffer).String()
+	if !strings.Contains(out, "Pausing before step 'testOnErrorStep'") {
+		t.Fatalf("bad output: %s", out)
+	}
+
+	if !strings.Contains(out, "Pausing before cleanup of step 'testOnErrorStep'") {
+		t.Fatalf("bad output: %s", out)
+	}
+
+	// The steps are still timed
+	timings, _ := state["step_timings"].([]StepTiming)
+	if len(timings) != 2 {
+		t.Fatalf("bad: %#v", state["step_timings"])
+	}
+}
+
+func TestNewRunner_DebugFailure(t *testing.T) {
+	step1 := new(testOnErrorStep)
+	step2 := &testOnErrorStep{failures: 1}
+	state := testOnErrorState("\n\n")
+	ui := state["ui"].(packer.Ui)
+
+	NewRunner([]multistep.Step{step1, step2}, true, OnErrorCleanup, ui).Run(state)
+	if step1.cleaned || step2.cleaned {
+		t.Fatal("steps shouldn't be cleaned up")
+	}
+}
@@ -0,0 +1,155 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// specialKeys maps the special key codes that can be used in a boot
+// command to the scancodes that press and release them.
+var specialKeys = map[string][]string{
+	"<bs>":       []string{"0e", "8e"},
+	"<del>":      []string{"e0", "53", "e0", "d3"},
+	"<end>":      []string{"e0", "4f", "e0", "cf"},
+	"<enter>":    []string{"1c", "9c"},
+	"<esc>":      []string{"01", "81"},
+	"<f1>":       []string{"3b", "bb"},
+	"<f2>":       []string{"3c", "bc"},
+	"<f3>":       []string{"3d", "bd"},
+	"<f4>":       []string{"3e", "be"},
+	"<f5>":       []string{"3f", "bf"},
+	"<f6>":       []string{"40", "c0"},
+	"<f7>":       []string{"41", "c1"},
+	"<f8>":       []string{"42", "c2"},
+	"<f9>":       []string{"43", "c3"},
+	"<f10>":      []string{"44", "c4"},
+	"<f11>":      []string{"57", "d7"},
+	"<f12>":      []string{"58", "d8"},
+	"<home>":     []string{"e0", "47", "e0", "c7"},
+	"<insert>":   []string{"e0", "52", "e0", "d2"},
+	"<pageDown>": []string{"e0", "51", "e0", "d1"},
+	"<pageUp>":   []string{"e0", "49", "e0", "c9"},
+	"<return>":   []string{"1c", "9c"},
+	"<spacebar>": []string{"39", "b9"},
+	"<tab>":      []string{"0f", "8f"},
+	"<up>":       []string{"e0", "48", "e0", "c8"},
+	"<down>":     []string{"e0", "50", "e0", "d0"},
+	"<left>":     []string{"e0", "4b", "e0", "cb"},
+	"<right>":    []string{"e0", "4d", "e0", "cd"},
+}
+
+// waitKeys maps the special wait codes to the pseudo-scancode that the
+// typing step treats as an instruction to sleep.
+var waitKeys = map[string]string{
+	"<wait>":   "wait",
+	"<wait5>":  "wait5",
+	"<wait10>": "wait10",
+}
+
+// specialKeyRe matches anything that looks like a special key code.
+var specialKeyRe = regexp.MustCompile("<[a-zA-Z0-9]+>")
+
+// UnknownBootCommandKeys returns all the special key codes in the given
+// boot command that aren't recognized, so that typos can be caught before
+// the build rather than being typed literally into the VM.
+func UnknownBootCommandKeys(command string) []string {
+	result := make([]string, 0)
+	for _, code := range specialKeyRe.FindAllString(command, -1) {
+		if _, ok := specialKeys[code]; ok {
+			continue
+		}
+
+		if _, ok := waitKeys[code]; ok {
+			continue
+		}
+
+		result = append(result, code)
+	}
+
+	return result
+}
+
+// Scancodes converts the boot command to the PC keyboard scancodes, as
+// hex strings, that type it, for the builders that send scancodes rather
+// than key events. The wait codes become their pseudo-scancode in
+// waitKeys, such as "wait5", which the caller has to sleep for instead.
+func Scancodes(message string) []string {
+	shiftedChars := "~!@#$%^&*()_+{}|:\"<>?"
+
+	// Scancodes reference: http://www.win.tue.nl/~aeb/linux/kbd/scancodes-1.html
+	scancodeIndex := make(map[string]uint)
+	scancodeIndex["1234567890-="] = 0x02
+	scancodeIndex["!@#$%^&*()_+"] = 0x02
+	scancodeIndex["qwertyuiop[]"] = 0x10
+	scancodeIndex["QWERTYUIOP{}"] = 0x10
+	scancodeIndex["asdfghjkl;'`"] = 0x1e
+	scancodeIndex[`ASDFGHJKL:"~`] = 0x1e
+	scancodeIndex[`\zxcvbnm,./`] = 0x2b
+	scancodeIndex["|ZXCVBNM<>?"] = 0x2b
+	scancodeIndex[" "] = 0x39
+
+	scancodeMap := make(map[rune]uint)
+	for chars, start := range scancodeIndex {
+		var i uint = 0
+		for len(chars) > 0 {
+			r, size := utf8.DecodeRuneInString(chars)
+			chars = chars[size:]
+			scancodeMap[r] = start + i
+			i += 1
+		}
+	}
+
+	result := make([]string, 0, len(message)*2)
+	for len(message) > 0 {
+		var scancode []string
+
+		for waitCode, waitValue := range waitKeys {
+			if strings.HasPrefix(message, waitCode) {
+				log.Printf("Special code %s found, will sleep at this point.", waitCode)
+				scancode = []string{waitValue}
+				message = message[len(waitCode):]
+				break
+			}
+		}
+
+		if scancode == nil {
+			for specialCode, specialValue := range specialKeys {
+				if strings.HasPrefix(message, specialCode) {
+					log.Printf("Special code '%s' found, replacing with: %s", specialCode, specialValue)
+					scancode = specialValue
+					message = message[len(specialCode):]
+					break
+				}
+			}
+		}
+
+		if scancode == nil {
+			r, size := utf8.DecodeRuneInString(message)
+			message = message[size:]
+			scancodeInt := scancodeMap[r]
+			keyShift := unicode.IsUpper(r) || strings.ContainsRune(shiftedChars, r)
+
+			scancode = make([]string, 0, 4)
+			if keyShift {
+				scancode = append(scancode, "2a")
+			}
+
+			scancode = append(scancode, fmt.Sprintf("%02x", scancodeInt))
+
+			if keyShift {
+				scancode = append(scancode, "aa")
+			}
+
+			scancode = append(scancode, fmt.Sprintf("%02x", scancodeInt+0x80))
+			log.Printf("Sending char '%c', code '%v', shift %v", r, scancode, keyShift)
+		}
+
+		result = append(result, scancode...)
+	}
+
+	return result
+}
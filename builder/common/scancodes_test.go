@@ -0,0 +1,21 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScancodes(t *testing.T) {
+	result := Scancodes("Aa<enter><wait5>")
+	expected := []string{"2a", "1e", "aa", "9e", "1e", "9e", "1c", "9c", "wait5"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestUnknownBootCommandKeys(t *testing.T) {
+	result := UnknownBootCommandKeys("foo<enter><wait10><nope>")
+	if !reflect.DeepEqual(result, []string{"<nope>"}) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
@@ -0,0 +1,31 @@
+package common
+
+import (
+	"log"
+	"time"
+)
+
+// The time to wait between checks of whether the machine is still running.
+var shutdownPollInterval = 1 * time.Second
+
+// WaitForShutdown polls isRunning until the machine is no longer running,
+// returning false if it is still running once the timeout has passed.
+// Errors checking the machine are logged and polling continues, since they
+// don't mean the machine stopped.
+func WaitForShutdown(isRunning func() (bool, error), timeout time.Duration) bool {
+	shutdownTimer := time.After(timeout)
+	for {
+		running, err := isRunning()
+		if err != nil {
+			log.Printf("Error checking if machine is running: %s", err)
+		} else if !running {
+			return true
+		}
+
+		select {
+		case <-shutdownTimer:
+			return false
+		case <-time.After(shutdownPollInterval):
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func init() {
+	shutdownPollInterval = 10 * time.Millisecond
+}
+
+func TestWaitForShutdown(t *testing.T) {
+	calls := 0
+	isRunning := func() (bool, error) {
+		calls++
+		return calls < 3, nil
+	}
+
+	if !WaitForShutdown(isRunning, time.Second) {
+		t.Fatal("should've shut down")
+	}
+
+	if calls != 3 {
+		t.Fatalf("bad calls: %d", calls)
+	}
+}
+
+func TestWaitForShutdown_error(t *testing.T) {
+	calls := 0
+	isRunning := func() (bool, error) {
+		calls++
+		if calls == 1 {
+			return false, errors.New("foo")
+		}
+
+		return false, nil
+	}
+
+	if !WaitForShutdown(isRunning, time.Second) {
+		t.Fatal("should've shut down")
+	}
+
+	if calls != 2 {
+		t.Fatalf("errors shouldn't count as shut down: %d calls", calls)
+	}
+}
+
+func TestWaitForShutdown_timeout(t *testing.T) {
+	isRunning := func() (bool, error) {
+		return true, nil
+	}
+
+	if WaitForShutdown(isRunning, 50*time.Millisecond) {
+		t.Fatal("should've timed out")
+	}
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	gossh "code.google.com/p/go.crypto/ssh"
+	"errors"
+	"fmt"
+	"github.com/mitchellh/packer/communicator/ssh"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+)
+
+// SSHKeychain returns a keychain holding the PEM encoded private key in
+// the file at the given path, decrypted with the passphrase if the key is
+// encrypted. Builders call this while preparing their configuration, so
+// that a key that can't be used is reported before anything is created.
+func SSHKeychain(path string, passphrase string) (*ssh.SimpleKeychain, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keychain := new(ssh.SimpleKeychain)
+	if err := keychain.AddPEMKeyPassword(string(contents), passphrase); err != nil {
+		return nil, err
+	}
+
+	return keychain, nil
+}
+
+// SSHAgent connects to the SSH agent listening on the socket named by
+// SSH_AUTH_SOCK, and checks that it answers by listing its identities.
+// The connection should be closed once the SSH connection that uses the
+// agent has been made, since the agent isn't forwarded to the machine.
+func SSHAgent() (*gossh.AgentClient, net.Conn, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil, errors.New("SSH_AUTH_SOCK isn't set, so there is no SSH agent to use")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error connecting to the SSH agent at %s: %s", socket, err)
+	}
+
+	agent := gossh.NewAgentClient(conn)
+	keys, err := agent.RequestIdentities()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("Error listing the identities of the SSH agent at %s: %s", socket, err)
+	}
+
+	log.Printf("SSH agent at %s has %d identities", socket, len(keys))
+	return agent, conn, nil
+}
+
+// SSHAuth returns the methods to authenticate an SSH connection with, in
+// the order that they are tried: the private key file, if one is given,
+// then the identities of the agent, if there is one, and then the
+// password. The client tries the next method whenever the server rejects
+// one, so the password is still used if no key is accepted. The password
+// is left out if a key or agent is given without one.
+func SSHAuth(password string, keyFile string, passphrase string, agent *gossh.AgentClient) ([]gossh.ClientAuth, error) {
+	auth := make([]gossh.ClientAuth, 0, 4)
+	if keyFile != "" {
+		keychain, err := SSHKeychain(keyFile, passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		auth = append(auth, gossh.ClientAuthKeyring(keychain))
+	}
+
+	if agent != nil {
+		auth = append(auth, gossh.ClientAuthAgent(agent))
+	}
+
+	if password != "" || len(auth) == 0 {
+		auth = append(auth,
+			gossh.ClientAuthPassword(ssh.Password(password)),
+			gossh.ClientAuthKeyboardInteractive(ssh.PasswordKeyboardInteractive(password)))
+	}
+
+	return auth, nil
+}
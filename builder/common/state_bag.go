@@ -0,0 +1,132 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"reflect"
+)
+
+// StateBag gives checked access to the state that multistep passes to the
+// steps of a builder. It is the same map as the state, so a step can wrap
+// the state it is given with StateBag(state) and anything it puts is seen
+// by every other step. Steps that index the state directly keep working.
+//
+// Unlike a type assertion, which panics with an opaque runtime error, the
+// methods of StateBag return an error naming the key if it is missing or
+// holds the wrong type, which the step can halt with.
+type StateBag map[string]interface{}
+
+// Get returns the value of the key, or nil if it isn't set.
+func (b StateBag) Get(key string) interface{} {
+	return b[key]
+}
+
+// GetOk returns the value of the key and whether it is set.
+func (b StateBag) GetOk(key string) (interface{}, bool) {
+	v, ok := b[key]
+	return v, ok
+}
+
+// Put sets the value of the key.
+func (b StateBag) Put(key string, value interface{}) {
+	b[key] = value
+}
+
+// Values reads keys of the state into variables. The arguments are pairs
+// of a key and a pointer to the variable to set, such as:
+//
+//     var driver Driver
+//     var ui packer.Ui
+//     err := bag.Values("driver", &driver, "ui", &ui)
+//
+// An error is returned if any key is missing or its value can't be
+// assigned to the variable, or if the arguments aren't such pairs.
+func (b StateBag) Values(pairs ...interface{}) error {
+	if len(pairs)%2 != 0 {
+		return fmt.Errorf(
+			"Internal error: reading the build state needs pairs of keys and pointers, got %d arguments",
+			len(pairs))
+	}
+
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return fmt.Errorf(
+				"Internal error: build state key %d is a %T, not a string", i/2, pairs[i])
+		}
+
+		if err := b.value(key, pairs[i+1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Communicator returns the "communicator" in the state.
+func (b StateBag) Communicator() (packer.Communicator, error) {
+	var result packer.Communicator
+	err := b.value("communicator", &result)
+	return result, err
+}
+
+// Ui returns the "ui" in the state.
+func (b StateBag) Ui() (packer.Ui, error) {
+	var result packer.Ui
+	err := b.value("ui", &result)
+	return result, err
+}
+
+// VMName returns the "vmName" in the state.
+func (b StateBag) VMName() (string, error) {
+	var result string
+	err := b.value("vmName", &result)
+	return result, err
+}
+
+// Error returns the "error" in the state, or nil if no step has failed.
+func (b StateBag) Error() error {
+	err, _ := b["error"].(error)
+	return err
+}
+
+// Halt records the error as the "error" in the state and shows it on the
+// Ui, if there is one. It returns multistep.ActionHalt so that a step can
+// return the result directly.
+func (b StateBag) Halt(err error) multistep.StepAction {
+	b["error"] = err
+	if ui, ok := b["ui"].(packer.Ui); ok {
+		ui.Error(err.Error())
+	} else {
+		log.Printf("Step halted: %s", err)
+	}
+
+	return multistep.ActionHalt
+}
+
+func (b StateBag) value(key string, ptr interface{}) error {
+	ptrValue := reflect.ValueOf(ptr)
+	if ptrValue.Kind() != reflect.Ptr || ptrValue.IsNil() {
+		return fmt.Errorf(
+			"Internal error: '%s' can't be read from the build state into a %T", key, ptr)
+	}
+
+	target := ptrValue.Elem()
+
+	raw, ok := b[key]
+	if !ok || raw == nil {
+		return fmt.Errorf("Internal error: '%s' is missing from the build state", key)
+	}
+
+	value := reflect.ValueOf(raw)
+	if !value.Type().AssignableTo(target.Type()) {
+		return fmt.Errorf(
+			"Internal error: '%s' in the build state is a %T, not a %s",
+			key, raw, target.Type())
+	}
+
+	target.Set(value)
+	return nil
+}
@@ -0,0 +1,127 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"strings"
+	"testing"
+)
+
+func TestStateBag(t *testing.T) {
+	state := make(map[string]interface{})
+	bag := StateBag(state)
+
+	bag.Put("foo", "bar")
+	if state["foo"] != "bar" {
+		t.Fatalf("bad: %#v", state)
+	}
+
+	if bag.Get("foo") != "bar" {
+		t.Fatalf("bad: %#v", bag.Get("foo"))
+	}
+
+	if _, ok := bag.GetOk("nope"); ok {
+		t.Fatal("should not be set")
+	}
+}
+
+func TestStateBag_Values(t *testing.T) {
+	ui := &packer.ReaderWriterUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+
+	bag := StateBag(map[string]interface{}{
+		"ui":     ui,
+		"vmName": "foo",
+	})
+
+	var resultUi packer.Ui
+	var vmName string
+	if err := bag.Values("ui", &resultUi, "vmName", &vmName); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if resultUi != ui || vmName != "foo" {
+		t.Fatalf("bad: %#v %#v", resultUi, vmName)
+	}
+
+	// Missing key
+	var comm packer.Communicator
+	err := bag.Values("communicator", &comm)
+	if err == nil || !strings.Contains(err.Error(), "communicator") {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	// Wrong type
+	var port uint
+	err = bag.Values("vmName", &port)
+	if err == nil || !strings.Contains(err.Error(), "vmName") {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	// Bad arguments
+	if err := bag.Values("vmName"); err == nil {
+		t.Fatal("should error without a pointer")
+	}
+
+	if err := bag.Values(42, &vmName); err == nil {
+		t.Fatal("should error with a key that isn't a string")
+	}
+
+	if err := bag.Values("vmName", vmName); err == nil {
+		t.Fatal("should error with a value that isn't a pointer")
+	}
+}
+
+func TestStateBag_Accessors(t *testing.T) {
+	bag := StateBag(map[string]interface{}{"vmName": "foo"})
+
+	if name, err := bag.VMName(); err != nil || name != "foo" {
+		t.Fatalf("bad: %#v %#v", name, err)
+	}
+
+	if _, err := bag.Ui(); err == nil {
+		t.Fatal("should error without a Ui")
+	}
+
+	if _, err := bag.Communicator(); err == nil {
+		t.Fatal("should error without a communicator")
+	}
+
+	if bag.Error() != nil {
+		t.Fatal("should have no error")
+	}
+}
+
+func TestStateBag_Halt(t *testing.T) {
+	out := new(bytes.Buffer)
+	bag := StateBag(map[string]interface{}{
+		"ui": &packer.ReaderWriterUi{
+			Reader: new(bytes.Buffer),
+			Writer: out,
+		},
+	})
+
+	err := errors.New("failed")
+	if action := bag.Halt(err); action != multistep.ActionHalt {
+		t.Fatalf("bad: %#v", action)
+	}
+
+	if bag.Error() != err {
+		t.Fatalf("bad: %#v", bag.Error())
+	}
+
+	if !strings.Contains(out.String(), "failed") {
+		t.Fatalf("bad: %s", out.String())
+	}
+
+	// Halting works without a Ui too
+	bag = StateBag(make(map[string]interface{}))
+	bag.Halt(err)
+	if bag.Error() != err {
+		t.Fatalf("bad: %#v", bag.Error())
+	}
+}
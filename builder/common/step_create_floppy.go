@@ -0,0 +1,214 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mitchellh/go-fs"
+	"github.com/mitchellh/go-fs/fat"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// floppySize is the size of a 1.44MB floppy. Of its sectors, FAT12 takes
+// the boot sector, two FATs of 9 sectors and up to 32 sectors for the root
+// directory, which leaves floppyCapacity for files. Every file takes up
+// whole clusters of floppyClusterSize.
+const (
+	floppySize        = 1474560
+	floppyClusterSize = 512
+	floppyCapacity    = floppySize - (1+2*9+32)*floppyClusterSize
+)
+
+// StepCreateFloppy will create a floppy disk with the given files.
+// The floppy disk doesn't support sub-directories. Only files at the
+// root level are supported.
+//
+// Uses:
+//   ui packer.Ui
+//
+// Produces:
+//   floppy_path string - The path to the floppy, or nothing if no files
+//     were given.
+type StepCreateFloppy struct {
+	Files []string
+
+	floppyPath string
+}
+
+func (s *StepCreateFloppy) Run(state map[string]interface{}) multistep.StepAction {
+	if len(s.Files) == 0 {
+		log.Println("No floppy files specified. Floppy disk will not be made.")
+		return multistep.ActionContinue
+	}
+
+	bag := StateBag(state)
+	var ui packer.Ui
+	if err := bag.Values("ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+	ui.Say("Creating floppy disk...")
+
+	// Expand any globs so we know exactly what is going on the floppy
+	files, err := s.expandFiles()
+	if err != nil {
+		state["error"] = fmt.Errorf("Error finding floppy files: %s", err)
+		return multistep.ActionHalt
+	}
+
+	// Verify that all the files fit before we do anything so that we
+	// never create a corrupt image.
+	var total int64
+	for _, filename := range files {
+		info, err := os.Stat(filename)
+		if err != nil {
+			state["error"] = fmt.Errorf("Error reading floppy file: %s", err)
+			return multistep.ActionHalt
+		}
+
+		if info.IsDir() {
+			state["error"] = fmt.Errorf(
+				"Floppy file is a directory, only files are supported: %s", filename)
+			return multistep.ActionHalt
+		}
+
+		total += floppyClusters(info.Size()) * floppyClusterSize
+		if total > floppyCapacity {
+			state["error"] = fmt.Errorf(
+				"Floppy files exceed the capacity of a 1.44MB floppy at: %s", filename)
+			return multistep.ActionHalt
+		}
+	}
+
+	// Create a temporary file to be our floppy drive
+	floppyF, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		state["error"] = fmt.Errorf("Error creating temporary file for floppy: %s", err)
+		return multistep.ActionHalt
+	}
+	defer floppyF.Close()
+
+	// Set the path so we can remove it later
+	s.floppyPath = floppyF.Name()
+
+	log.Printf("Floppy path: %s", floppyF.Name())
+
+	// Set the size of the file to be a floppy sized
+	if err := floppyF.Truncate(floppySize); err != nil {
+		state["error"] = fmt.Errorf("Error creating floppy: %s", err)
+		return multistep.ActionHalt
+	}
+
+	// BlockDevice backed by the file for our filesystem
+	log.Println("Initializing block device backed by temporary file")
+	device, err := fs.NewFileDisk(floppyF)
+	if err != nil {
+		state["error"] = fmt.Errorf("Error creating floppy: %s", err)
+		return multistep.ActionHalt
+	}
+
+	// Format the block device so it contains a valid FAT filesystem
+	log.Println("Formatting the block device with a FAT filesystem...")
+	formatConfig := &fat.SuperFloppyConfig{
+		FATType: fat.FAT12,
+		Label:   "packer",
+		OEMName: "packer",
+	}
+	if err := fat.FormatSuperFloppy(device, formatConfig); err != nil {
+		state["error"] = fmt.Errorf("Error creating floppy: %s", err)
+		return multistep.ActionHalt
+	}
+
+	// The actual FAT filesystem
+	log.Println("Initializing FAT filesystem on block device")
+	fatFs, err := fat.New(device)
+	if err != nil {
+		state["error"] = fmt.Errorf("Error creating floppy: %s", err)
+		return multistep.ActionHalt
+	}
+
+	// Get the root directory to the filesystem
+	log.Println("Reading the root directory from the filesystem")
+	rootDir, err := fatFs.RootDir()
+	if err != nil {
+		state["error"] = fmt.Errorf("Error creating floppy: %s", err)
+		return multistep.ActionHalt
+	}
+
+	// Go over each file and copy it.
+	for _, filename := range files {
+		ui.Message(fmt.Sprintf("Copying: %s", filepath.Base(filename)))
+		if err := s.addSingleFile(rootDir, filename); err != nil {
+			state["error"] = fmt.Errorf("Error adding file to floppy: %s", err)
+			return multistep.ActionHalt
+		}
+	}
+
+	// Set the path to the floppy so it can be used later
+	state["floppy_path"] = s.floppyPath
+
+	return multistep.ActionContinue
+}
+
+func (s *StepCreateFloppy) Cleanup(map[string]interface{}) {
+	if s.floppyPath != "" {
+		log.Printf("Deleting floppy disk: %s", s.floppyPath)
+		os.Remove(s.floppyPath)
+	}
+}
+
+// floppyClusters returns the number of clusters a file of the given size
+// takes up on the floppy.
+func floppyClusters(size int64) int64 {
+	return (size + floppyClusterSize - 1) / floppyClusterSize
+}
+
+func (s *StepCreateFloppy) addSingleFile(dir fs.Directory, src string) error {
+	log.Printf("Adding file to floppy: %s", src)
+
+	inputF, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer inputF.Close()
+
+	entry, err := dir.AddFile(filepath.Base(src))
+	if err != nil {
+		return fmt.Errorf("%s: %s", src, err)
+	}
+
+	fatFile, err := entry.File()
+	if err != nil {
+		return fmt.Errorf("%s: %s", src, err)
+	}
+
+	if _, err := io.Copy(fatFile, inputF); err != nil {
+		return fmt.Errorf("%s: %s", src, err)
+	}
+
+	return nil
+}
+
+// expandFiles expands any glob patterns in the list of files. Patterns
+// that match nothing are returned as-is so that a missing file results
+// in a clear error.
+func (s *StepCreateFloppy) expandFiles() ([]string, error) {
+	result := make([]string, 0, len(s.Files))
+	for _, pattern := range s.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", pattern, err)
+		}
+
+		if len(matches) == 0 {
+			matches = []string{pattern}
+		}
+
+		result = append(result, matches...)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"github.com/mitchellh/multistep"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStepCreateFloppy_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCreateFloppy)
+}
+
+func TestStepCreateFloppy_tooLarge(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// Two files that fit byte-wise, but not once rounded up to clusters
+	files := []string{filepath.Join(td, "foo"), filepath.Join(td, "bar")}
+	sizes := []int64{floppyCapacity - floppyClusterSize + 1, floppyClusterSize / 2}
+	for i, path := range files {
+		if err := ioutil.WriteFile(path, make([]byte, sizes[i]), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	state := testStepState()
+	step := &StepCreateFloppy{Files: files}
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	err = state["error"].(error)
+	if !strings.Contains(err.Error(), "exceed the capacity") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestFloppyClusters(t *testing.T) {
+	cases := map[int64]int64{
+		0:                     0,
+		1:                     1,
+		floppyClusterSize:     1,
+		floppyClusterSize + 1: 2,
+	}
+
+	for size, expected := range cases {
+		if actual := floppyClusters(size); actual != expected {
+			t.Fatalf("bad clusters for %d: %d", size, actual)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package common
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StepDownloadISO downloads the ISO into the cache, trying each of the
+// URLs in order until one of them works. The ISO is verified against the
+// checksum, unless the checksum type is "none". If a target path is given,
+// the ISO is downloaded there instead, unless a valid ISO is already there.
+//
+// Uses:
+//   cache packer.Cache
+//   ui    packer.Ui
+//
+// Produces:
+//   iso_path string
+type StepDownloadISO struct {
+	Checksum     string
+	ChecksumType string
+	TargetPath   string
+	Urls         []string
+}
+
+func (s *StepDownloadISO) Run(state map[string]interface{}) multistep.StepAction {
+	bag := StateBag(state)
+	var cache packer.Cache
+	var ui packer.Ui
+	if err := bag.Values("cache", &cache, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	var checksum []byte
+	var err error
+	if s.ChecksumType == "none" {
+		ui.Say("WARNING: iso_checksum_type is 'none'. The downloaded ISO will not\n" +
+			"be verified and may be corrupt or tampered with.")
+	} else {
+		checksum, err = hex.DecodeString(s.Checksum)
+		if err != nil {
+			state["error"] = fmt.Errorf("Error parsing checksum: %s", err)
+			return multistep.ActionHalt
+		}
+	}
+
+	var isoPath, targetPath string
+	if s.TargetPath != "" {
+		// If the ISO is already at the target path and valid, then we
+		// don't need to download anything at all.
+		verifyConfig := &DownloadConfig{
+			TargetPath: s.TargetPath,
+			Hash:       HashForType(s.ChecksumType),
+			Checksum:   checksum,
+		}
+
+		verifyClient := NewDownloadClient(verifyConfig)
+		if ok, _ := verifyClient.VerifyChecksum(s.TargetPath); ok {
+			ui.Say(fmt.Sprintf("Using existing ISO: %s", s.TargetPath))
+			isoPath = s.TargetPath
+		} else {
+			// Download into a temporary file next to the target and rename
+			// it into place once done, so that concurrent builds using the
+			// same target path never see a partially downloaded file.
+			if err := os.MkdirAll(filepath.Dir(s.TargetPath), 0755); err != nil {
+				err := fmt.Errorf("Error creating directory for ISO: %s", err)
+				state["error"] = err
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+
+			tf, err := ioutil.TempFile(filepath.Dir(s.TargetPath), ".packer-iso")
+			if err != nil {
+				err := fmt.Errorf("Error creating temporary file for ISO: %s", err)
+				state["error"] = err
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+			tf.Close()
+			defer os.Remove(tf.Name())
+
+			targetPath = tf.Name()
+		}
+	} else {
+		// The cache is keyed by the checksum if we have one so that the
+		// same ISO served from different mirrors is only downloaded once.
+		cacheKey := s.Urls[0]
+		if s.ChecksumType != "none" {
+			cacheKey = s.Checksum
+		}
+
+		log.Printf("Acquiring lock to download the ISO.")
+		targetPath = cache.Lock(cacheKey)
+		defer cache.Unlock(cacheKey)
+	}
+
+	// Try each of the URLs in order until one of them works. The download
+	// client verifies any existing file in the cache first, so a cached
+	// ISO matching the checksum will return on the first URL.
+	for i := 0; isoPath == "" && i < len(s.Urls); i++ {
+		url := s.Urls[i]
+		downloadConfig := &DownloadConfig{
+			Url:        url,
+			TargetPath: targetPath,
+			CopyFile:   false,
+			Hash:       HashForType(s.ChecksumType),
+			Checksum:   checksum,
+		}
+
+		ui.Say(fmt.Sprintf("Copying or downloading ISO from: %s", url))
+		ui.Message("Progress will be reported periodically.")
+		isoPath, err = s.download(NewDownloadClient(downloadConfig), ui, state)
+		if _, ok := state[multistep.StateCancelled]; ok {
+			return multistep.ActionHalt
+		}
+
+		if err != nil {
+			isoPath = ""
+			ui.Error(fmt.Sprintf("Error downloading ISO from %s: %s", url, err))
+			if i < len(s.Urls)-1 {
+				ui.Message("Trying the next URL...")
+			}
+		}
+	}
+
+	if err != nil {
+		err := fmt.Errorf("Error downloading ISO: all URLs failed, last error: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Move a freshly downloaded ISO into the target path
+	if s.TargetPath != "" && isoPath == targetPath {
+		log.Printf("Moving downloaded ISO to: %s", s.TargetPath)
+		if err := os.Rename(targetPath, s.TargetPath); err != nil {
+			err := fmt.Errorf("Error moving ISO to iso_target_path: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		isoPath = s.TargetPath
+	}
+
+	log.Printf("Path to ISO on disk: %s", isoPath)
+	state["iso_path"] = isoPath
+
+	return multistep.ActionContinue
+}
+
+func (s *StepDownloadISO) Cleanup(map[string]interface{}) {}
+
+// download runs the download client, reporting progress to the UI and
+// watching for interrupts while the download is in progress.
+func (s *StepDownloadISO) download(download *DownloadClient, ui packer.Ui, state map[string]interface{}) (string, error) {
+	var path string
+	downloadCompleteCh := make(chan error, 1)
+	go func() {
+		var err error
+		path, err = download.Get()
+		downloadCompleteCh <- err
+	}()
+
+	progressTicker := time.NewTicker(5 * time.Second)
+	defer progressTicker.Stop()
+
+	for {
+		select {
+		case err := <-downloadCompleteCh:
+			return path, err
+		case <-progressTicker.C:
+			ui.Message(fmt.Sprintf("Download progress: %d%%", download.PercentProgress()))
+		case <-time.After(1 * time.Second):
+			if _, ok := state[multistep.StateCancelled]; ok {
+				ui.Say("Interrupt received. Cancelling download...")
+				return "", errors.New("interrupted")
+			}
+		}
+	}
+}
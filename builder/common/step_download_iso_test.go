@@ -0,0 +1,45 @@
+package common
+
+import (
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStepDownloadISO_impl(t *testing.T) {
+	var _ multistep.Step = new(StepDownloadISO)
+}
+
+func TestStepDownloadISO_existingTargetPath(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	target := filepath.Join(td, "foo.iso")
+	if err := ioutil.WriteFile(target, []byte("foo"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The URL can't be downloaded, so the ISO at the target must be used
+	state := testStepState()
+	state["cache"] = &packer.FileCache{CacheDir: td}
+	step := &StepDownloadISO{
+		Checksum:     "acbd18db4cc2f85cedef654fccc4a4d8",
+		ChecksumType: "md5",
+		TargetPath:   target,
+		Urls:         []string{"http://127.0.0.1:0/foo.iso"},
+	}
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v %s", action, state["error"])
+	}
+
+	if state["iso_path"] != target {
+		t.Fatalf("bad: %#v", state["iso_path"])
+	}
+}
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+)
+
+// StepHTTPServer creates and runs the HTTP server that is serving the
+// files in the directory given by the 'http_directory' configuration
+// parameter in the template. If no directory is given, no server is
+// started and the port is zero.
+//
+// The port is picked at random from the range, so builders must seed
+// math/rand before running this step, as they all do at the top of Run.
+// Without that, every build would start with the same port.
+//
+// Uses:
+//   ui packer.Ui
+//
+// Produces:
+//   http_port uint - The port the HTTP server started on.
+type StepHTTPServer struct {
+	Dir     string
+	PortMin uint
+	PortMax uint
+
+	l net.Listener
+}
+
+func (s *StepHTTPServer) Run(state map[string]interface{}) multistep.StepAction {
+	bag := StateBag(state)
+	var ui packer.Ui
+	if err := bag.Values("ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	var httpPort uint = 0
+	if s.Dir == "" {
+		state["http_port"] = httpPort
+		return multistep.ActionContinue
+	}
+
+	// Find an available TCP port for our HTTP server. We start at a random
+	// offset into the range so that parallel builds, which seeded math/rand
+	// differently, are unlikely to pick the same port, and the listener is
+	// held open so that once we have a port nobody else can take it.
+	var httpAddr string
+	portRange := int(s.PortMax-s.PortMin) + 1
+	start := rand.Intn(portRange)
+	for i := 0; i < portRange; i++ {
+		var err error
+
+		httpPort = s.PortMin + uint((start+i)%portRange)
+		httpAddr = fmt.Sprintf(":%d", httpPort)
+		log.Printf("Trying port: %d", httpPort)
+		s.l, err = net.Listen("tcp", httpAddr)
+		if err == nil {
+			break
+		}
+	}
+
+	if s.l == nil {
+		err := fmt.Errorf(
+			"Error starting HTTP server: no open port found between %d and %d",
+			s.PortMin, s.PortMax)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Starting HTTP server on port %d", httpPort))
+
+	// Start the HTTP server and run it in the background
+	fileServer := http.FileServer(http.Dir(s.Dir))
+	server := &http.Server{Addr: httpAddr, Handler: fileServer}
+	go server.Serve(s.l)
+
+	// Save the address into the state so it can be accessed in the future
+	state["http_port"] = httpPort
+
+	return multistep.ActionContinue
+}
+
+func (s *StepHTTPServer) Cleanup(map[string]interface{}) {
+	if s.l != nil {
+		// Close the listener so that the HTTP server stops
+		s.l.Close()
+	}
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStepHTTPServer_impl(t *testing.T) {
+	var _ multistep.Step = new(StepHTTPServer)
+}
+
+func TestStepHTTPServer(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	if err := ioutil.WriteFile(filepath.Join(td, "ks.cfg"), []byte("foo"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testStepState()
+	step := &StepHTTPServer{Dir: td, PortMin: 8000, PortMax: 9000}
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	defer step.Cleanup(state)
+
+	port := state["http_port"].(uint)
+	if port < 8000 || port > 9000 {
+		t.Fatalf("bad port: %d", port)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/ks.cfg", port))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(data) != "foo" {
+		t.Fatalf("bad: %s", data)
+	}
+}
+
+func TestStepHTTPServer_noDir(t *testing.T) {
+	state := testStepState()
+	step := new(StepHTTPServer)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if state["http_port"].(uint) != 0 {
+		t.Fatalf("bad: %#v", state["http_port"])
+	}
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"os"
+)
+
+// StepPrepareOutputDir creates the output directory of the build, first
+// deleting an existing one if the build is forced. The directory is
+// deleted again if the build is cancelled or fails.
+//
+// Uses:
+//   ui packer.Ui
+type StepPrepareOutputDir struct {
+	Dir   string
+	Force bool
+}
+
+func (s *StepPrepareOutputDir) Run(state map[string]interface{}) multistep.StepAction {
+	bag := StateBag(state)
+	var ui packer.Ui
+	if err := bag.Values("ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	if _, err := os.Stat(s.Dir); err == nil && s.Force {
+		ui.Say("Deleting previous output directory...")
+		if err := os.RemoveAll(s.Dir); err != nil {
+			err := fmt.Errorf("Error deleting output directory: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		state["error"] = err
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepPrepareOutputDir) Cleanup(state map[string]interface{}) {
+	_, cancelled := state[multistep.StateCancelled]
+	_, halted := state[multistep.StateHalted]
+
+	if cancelled || halted {
+		bag := StateBag(state)
+		var ui packer.Ui
+		if err := bag.Values("ui", &ui); err != nil {
+			log.Printf("Skipping cleanup: %s", err)
+			return
+		}
+
+		ui.Say("Deleting output directory...")
+		os.RemoveAll(s.Dir)
+	}
+}
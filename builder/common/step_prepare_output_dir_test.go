@@ -0,0 +1,70 @@
+package common
+
+import (
+	"bytes"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testStepState() map[string]interface{} {
+	return map[string]interface{}{
+		"ui": &packer.ReaderWriterUi{
+			Reader: new(bytes.Buffer),
+			Writer: new(bytes.Buffer),
+		},
+	}
+}
+
+func TestStepPrepareOutputDir_impl(t *testing.T) {
+	var _ multistep.Step = new(StepPrepareOutputDir)
+}
+
+func TestStepPrepareOutputDir(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	dir := filepath.Join(td, "output")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	leftover := filepath.Join(dir, "leftover")
+	if err := ioutil.WriteFile(leftover, []byte("foo"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A forced build deletes the existing directory
+	state := testStepState()
+	step := &StepPrepareOutputDir{Dir: dir, Force: true}
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("should exist: %s", err)
+	}
+
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Fatal("leftover should be deleted")
+	}
+
+	// A successful build keeps the directory
+	step.Cleanup(state)
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("should exist: %s", err)
+	}
+
+	// A failed build deletes it
+	state[multistep.StateHalted] = true
+	step.Cleanup(state)
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatal("should be deleted")
+	}
+}
@@ -0,0 +1,89 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"os"
+	"time"
+)
+
+// StepTiming is how long a single step of a build took to run.
+type StepTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
+// TimeSteps wraps the given steps so that each one logs when it starts
+// and finishes, and records how long it ran as a StepTiming appended to
+// "step_timings" in the state. In debug mode, NewRunner wraps the timed
+// steps to pause between them, naming each pause after the step that was
+// timed rather than the wrapper.
+func TimeSteps(steps []multistep.Step) []multistep.Step {
+	result := make([]multistep.Step, len(steps))
+	for i, step := range steps {
+		result[i] = &timedStep{
+			Step: step,
+			name: fmt.Sprintf("%T", step),
+		}
+	}
+
+	return result
+}
+
+// ReportStepTimings outputs a table of the "step_timings" in the state to
+// the Ui, if logging is enabled with PACKER_LOG.
+func ReportStepTimings(ui packer.Ui, state map[string]interface{}) {
+	if os.Getenv("PACKER_LOG") == "" {
+		return
+	}
+
+	timings, ok := state["step_timings"].([]StepTiming)
+	if !ok || len(timings) == 0 {
+		return
+	}
+
+	width := 0
+	var total time.Duration
+	for _, timing := range timings {
+		if len(timing.Name) > width {
+			width = len(timing.Name)
+		}
+
+		total += timing.Duration
+	}
+
+	ui.Say("Step timings:")
+	for _, timing := range timings {
+		ui.Message(fmt.Sprintf("%-*s  %s", width, timing.Name, timing.Duration))
+	}
+
+	ui.Message(fmt.Sprintf("%-*s  %s", width, "Total", total))
+}
+
+// timedStep is a multistep.Step that times the step it wraps.
+type timedStep struct {
+	multistep.Step
+	name string
+}
+
+func (s *timedStep) Run(state map[string]interface{}) multistep.StepAction {
+	log.Printf("Running step: %s", s.name)
+	start := time.Now()
+	action := s.Step.Run(state)
+	duration := time.Now().Sub(start)
+	log.Printf("Finished step: %s (%s)", s.name, duration)
+
+	timings, _ := state["step_timings"].([]StepTiming)
+	state["step_timings"] = append(timings, StepTiming{s.name, duration})
+
+	return action
+}
+
+func (s *timedStep) Cleanup(state map[string]interface{}) {
+	log.Printf("Cleaning up step: %s", s.name)
+	start := time.Now()
+	s.Step.Cleanup(state)
+	log.Printf("Finished cleaning up step: %s (%s)", s.name, time.Now().Sub(start))
+}
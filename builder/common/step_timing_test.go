@@ -0,0 +1,88 @@
+package common
+
+import (
+	"bytes"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"os"
+	"strings"
+	"testing"
+)
+
+type testTimingStep struct {
+	ran, cleaned bool
+}
+
+func (s *testTimingStep) Run(map[string]interface{}) multistep.StepAction {
+	s.ran = true
+	return multistep.ActionContinue
+}
+
+func (s *testTimingStep) Cleanup(map[string]interface{}) {
+	s.cleaned = true
+}
+
+func TestTimeSteps(t *testing.T) {
+	step1 := new(testTimingStep)
+	step2 := new(testTimingStep)
+	steps := TimeSteps([]multistep.Step{step1, step2})
+
+	state := make(map[string]interface{})
+	for _, step := range steps {
+		if action := step.Run(state); action != multistep.ActionContinue {
+			t.Fatalf("bad action: %#v", action)
+		}
+	}
+
+	for _, step := range steps {
+		step.Cleanup(state)
+	}
+
+	if !step1.ran || !step2.ran || !step1.cleaned || !step2.cleaned {
+		t.Fatal("steps should run and clean up")
+	}
+
+	timings, ok := state["step_timings"].([]StepTiming)
+	if !ok {
+		t.Fatalf("bad timings: %#v", state["step_timings"])
+	}
+
+	if len(timings) != 2 {
+		t.Fatalf("bad timings: %#v", timings)
+	}
+
+	if timings[0].Name != "*common.testTimingStep" {
+		t.Fatalf("bad name: %s", timings[0].Name)
+	}
+}
+
+func TestReportStepTimings(t *testing.T) {
+	state := map[string]interface{}{
+		"step_timings": []StepTiming{{"*foo.stepBar", 0}},
+	}
+
+	out := new(bytes.Buffer)
+	ui := &packer.ReaderWriterUi{
+		Reader: new(bytes.Buffer),
+		Writer: out,
+	}
+
+	defer os.Setenv("PACKER_LOG", os.Getenv("PACKER_LOG"))
+
+	// Nothing without logging enabled
+	os.Setenv("PACKER_LOG", "")
+	ReportStepTimings(ui, state)
+	if out.Len() > 0 {
+		t.Fatalf("bad output: %s", out.String())
+	}
+
+	os.Setenv("PACKER_LOG", "1")
+	ReportStepTimings(ui, state)
+	if !strings.Contains(out.String(), "*foo.stepBar") {
+		t.Fatalf("bad output: %s", out.String())
+	}
+
+	if !strings.Contains(out.String(), "Total") {
+		t.Fatalf("bad output: %s", out.String())
+	}
+}
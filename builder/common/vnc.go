@@ -0,0 +1,83 @@
+package common
+
+import (
+	"github.com/mitchellh/go-vnc"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The keysym of the left shift key, held down to type shifted characters.
+const vncKeyLeftShift uint32 = 0xFFE1
+
+// VNCSendString types the string over the VNC connection, a key at a time,
+// for the boot commands of the builders that type them over VNC. The
+// special codes <enter>, <esc>, <return> and <tab> press those keys, and
+// <wait>, <wait5> and <wait10> pause for 1, 5 and 10 seconds.
+func VNCSendString(c *vnc.ClientConn, original string) {
+	special := make(map[string]uint32)
+	special["<enter>"] = 0xFF0D
+	special["<esc>"] = 0xFF1B
+	special["<return>"] = 0xFF0D
+	special["<tab>"] = 0xFF09
+
+	shiftedChars := "~!@#$%^&*()_+{}|:\"<>?"
+
+	// TODO(mitchellh): Ripe for optimizations of some point, perhaps.
+	for len(original) > 0 {
+		var keyCode uint32
+		keyShift := false
+
+		if strings.HasPrefix(original, "<wait>") {
+			log.Printf("Special code '<wait>' found, sleeping one second")
+			time.Sleep(1 * time.Second)
+			original = original[len("<wait>"):]
+			continue
+		}
+
+		if strings.HasPrefix(original, "<wait5>") {
+			log.Printf("Special code '<wait5>' found, sleeping 5 seconds")
+			time.Sleep(5 * time.Second)
+			original = original[len("<wait5>"):]
+			continue
+		}
+
+		if strings.HasPrefix(original, "<wait10>") {
+			log.Printf("Special code '<wait10>' found, sleeping 10 seconds")
+			time.Sleep(10 * time.Second)
+			original = original[len("<wait10>"):]
+			continue
+		}
+
+		for specialCode, specialValue := range special {
+			if strings.HasPrefix(original, specialCode) {
+				log.Printf("Special code '%s' found, replacing with: %d", specialCode, specialValue)
+				keyCode = specialValue
+				original = original[len(specialCode):]
+				break
+			}
+		}
+
+		if keyCode == 0 {
+			r, size := utf8.DecodeRuneInString(original)
+			original = original[size:]
+			keyCode = uint32(r)
+			keyShift = unicode.IsUpper(r) || strings.ContainsRune(shiftedChars, r)
+
+			log.Printf("Sending char '%c', code %d, shift %v", r, keyCode, keyShift)
+		}
+
+		if keyShift {
+			c.KeyEvent(vncKeyLeftShift, true)
+		}
+
+		c.KeyEvent(keyCode, true)
+		c.KeyEvent(keyCode, false)
+
+		if keyShift {
+			c.KeyEvent(vncKeyLeftShift, false)
+		}
+	}
+}
@@ -1,6 +1,6 @@
 // All of the methods used to communicate with the digital_ocean API
-// are here. Their API is on a path to V2, so just plain JSON is used
-// in place of a proper client library for now.
+// are here. Just plain JSON is used in place of a proper client library
+// for now. This file has the V1 API, and api_v2.go has the V2 API.
 
 package digitalocean
 
@@ -21,6 +21,7 @@ const DIGITALOCEAN_API_URL = "https://api.digitalocean.com"
 type Image struct {
 	Id           uint
 	Name         string
+	Slug         string
 	Distribution string
 }
 
@@ -28,6 +29,51 @@ type ImagesResp struct {
 	Images []Image
 }
 
+type Region struct {
+	Id   uint
+	Name string
+	Slug string
+}
+
+type RegionsResp struct {
+	Regions []Region
+}
+
+type Size struct {
+	Id   uint
+	Name string
+	Slug string
+}
+
+type SizesResp struct {
+	Sizes []Size
+}
+
+type Droplet struct {
+	Id               uint
+	Status           string
+	IPAddress        string `mapstructure:"ip_address"`
+	PrivateIPAddress string `mapstructure:"private_ip_address"`
+}
+
+// Client is a client of either version of the DigitalOcean API. The
+// region, size and image of a droplet are IDs with the V1 API, and slugs
+// with the V2 API, though the V2 API takes image IDs too.
+type Client interface {
+	CreateKey(name string, pub string) (uint, error)
+	DestroyKey(id uint) error
+	CreateDroplet(name string, size string, image string, region string, keyId uint, privateNetworking bool, ipv6 bool) (uint, error)
+	DestroyDroplet(id uint) error
+	PowerOffDroplet(id uint) error
+	CreateSnapshot(id uint, name string) error
+	Images() ([]Image, error)
+	Regions() ([]Region, error)
+	Sizes() ([]Size, error)
+	DestroyImage(id uint) error
+	Droplet(id uint) (*Droplet, error)
+	DropletStatus(id uint) (string, string, error)
+}
+
 type DigitalOceanClient struct {
 	// The http client for communicating
 	client *http.Client
@@ -77,10 +123,10 @@ func (d DigitalOceanClient) DestroyKey(id uint) error {
 }
 
 // Creates a droplet and returns it's id
-func (d DigitalOceanClient) CreateDroplet(name string, size uint, image uint, region uint, keyId uint) (uint, error) {
+func (d DigitalOceanClient) CreateDroplet(name string, size string, image string, region string, keyId uint, privateNetworking bool, ipv6 bool) (uint, error) {
 	params := fmt.Sprintf(
-		"name=%v&image_id=%v&size_id=%v&region_id=%v&ssh_key_ids=%v",
-		name, image, size, region, keyId)
+		"name=%v&image_id=%v&size_id=%v&region_id=%v&ssh_key_ids=%v&private_networking=%v&ipv6=%v",
+		name, image, size, region, keyId, privateNetworking, ipv6)
 
 	body, err := NewRequest(d, "droplets/new", params)
 	if err != nil {
@@ -134,6 +180,36 @@ func (d DigitalOceanClient) Images() ([]Image, error) {
 	return result.Images, nil
 }
 
+// Returns all available regions.
+func (d DigitalOceanClient) Regions() ([]Region, error) {
+	resp, err := NewRequest(d, "regions", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var result RegionsResp
+	if err := mapstructure.Decode(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Regions, nil
+}
+
+// Returns all available droplet sizes.
+func (d DigitalOceanClient) Sizes() ([]Size, error) {
+	resp, err := NewRequest(d, "sizes", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var result SizesResp
+	if err := mapstructure.Decode(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Sizes, nil
+}
+
 // Destroys an image by its ID.
 func (d DigitalOceanClient) DestroyImage(id uint) error {
 	path := fmt.Sprintf("images/%d/destroy", id)
@@ -141,26 +217,31 @@ func (d DigitalOceanClient) DestroyImage(id uint) error {
 	return err
 }
 
-// Returns DO's string representation of status "off" "new" "active" etc.
-func (d DigitalOceanClient) DropletStatus(id uint) (string, string, error) {
+// Returns the droplet with the given ID.
+func (d DigitalOceanClient) Droplet(id uint) (*Droplet, error) {
 	path := fmt.Sprintf("droplets/%v", id)
 
 	body, err := NewRequest(d, path, "")
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	var ip string
+	var droplet Droplet
+	if err := mapstructure.Decode(body["droplet"], &droplet); err != nil {
+		return nil, err
+	}
 
-	// Read the droplet's "status"
-	droplet := body["droplet"].(map[string]interface{})
-	status := droplet["status"].(string)
+	return &droplet, nil
+}
 
-	if droplet["ip_address"] != nil {
-		ip = droplet["ip_address"].(string)
+// Returns DO's string representation of status "off" "new" "active" etc.
+func (d DigitalOceanClient) DropletStatus(id uint) (string, string, error) {
+	droplet, err := d.Droplet(id)
+	if err != nil {
+		return "", "", err
 	}
 
-	return ip, status, err
+	return droplet.IPAddress, droplet.Status, nil
 }
 
 // Sends an api request and returns a generic map[string]interface of
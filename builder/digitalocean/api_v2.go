@@ -0,0 +1,259 @@
+// The methods to communicate with the V2 DigitalOcean API, which is
+// authenticated with a single API token rather than a client ID and
+// API key.
+
+package digitalocean
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const DIGITALOCEAN_API_V2_URL = "https://api.digitalocean.com/v2"
+
+type DigitalOceanClientV2 struct {
+	// The http client for communicating
+	client *http.Client
+
+	// The base URL of the API
+	BaseURL string
+
+	// Credentials
+	APIToken string
+}
+
+// Creates a new client for communicating with the V2 API
+func NewClientV2(token string) *DigitalOceanClientV2 {
+	return &DigitalOceanClientV2{
+		client:   http.DefaultClient,
+		BaseURL:  DIGITALOCEAN_API_V2_URL,
+		APIToken: token,
+	}
+}
+
+// Creates an SSH Key and returns it's id
+func (d DigitalOceanClientV2) CreateKey(name string, pub string) (uint, error) {
+	params := map[string]interface{}{
+		"name":       name,
+		"public_key": pub,
+	}
+
+	var resp struct {
+		SSHKey struct {
+			Id uint
+		} `mapstructure:"ssh_key"`
+	}
+	if err := d.request("POST", "account/keys", params, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.SSHKey.Id, nil
+}
+
+// Destroys an SSH key
+func (d DigitalOceanClientV2) DestroyKey(id uint) error {
+	path := fmt.Sprintf("account/keys/%v", id)
+	return d.request("DELETE", path, nil, nil)
+}
+
+// Creates a droplet and returns it's id
+func (d DigitalOceanClientV2) CreateDroplet(name string, size string, image string, region string, keyId uint, privateNetworking bool, ipv6 bool) (uint, error) {
+	params := map[string]interface{}{
+		"name":               name,
+		"size":               size,
+		"image":              image,
+		"region":             region,
+		"ssh_keys":           []uint{keyId},
+		"private_networking": privateNetworking,
+		"ipv6":               ipv6,
+	}
+
+	// Images can be given by ID as well as by slug
+	if id, err := strconv.ParseUint(image, 10, 0); err == nil {
+		params["image"] = id
+	}
+
+	var resp struct {
+		Droplet struct {
+			Id uint
+		}
+	}
+	if err := d.request("POST", "droplets", params, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Droplet.Id, nil
+}
+
+// Destroys a droplet
+func (d DigitalOceanClientV2) DestroyDroplet(id uint) error {
+	path := fmt.Sprintf("droplets/%v", id)
+	return d.request("DELETE", path, nil, nil)
+}
+
+// Powers off a droplet
+func (d DigitalOceanClientV2) PowerOffDroplet(id uint) error {
+	path := fmt.Sprintf("droplets/%v/actions", id)
+	params := map[string]interface{}{"type": "power_off"}
+	return d.request("POST", path, params, nil)
+}
+
+// Creates a snaphot of a droplet by it's ID
+func (d DigitalOceanClientV2) CreateSnapshot(id uint, name string) error {
+	path := fmt.Sprintf("droplets/%v/actions", id)
+	params := map[string]interface{}{
+		"type": "snapshot",
+		"name": name,
+	}
+	return d.request("POST", path, params, nil)
+}
+
+// Returns all available images.
+func (d DigitalOceanClientV2) Images() ([]Image, error) {
+	var resp ImagesResp
+	if err := d.request("GET", "images?per_page=200", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Images, nil
+}
+
+// Returns all available regions. Regions only have slugs in the V2 API.
+func (d DigitalOceanClientV2) Regions() ([]Region, error) {
+	var resp RegionsResp
+	if err := d.request("GET", "regions", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Regions, nil
+}
+
+// Returns all available droplet sizes. Sizes only have slugs in the V2 API.
+func (d DigitalOceanClientV2) Sizes() ([]Size, error) {
+	var resp SizesResp
+	if err := d.request("GET", "sizes", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Sizes, nil
+}
+
+// Destroys an image by its ID.
+func (d DigitalOceanClientV2) DestroyImage(id uint) error {
+	path := fmt.Sprintf("images/%d", id)
+	return d.request("DELETE", path, nil, nil)
+}
+
+// Returns the droplet with the given ID.
+func (d DigitalOceanClientV2) Droplet(id uint) (*Droplet, error) {
+	path := fmt.Sprintf("droplets/%v", id)
+
+	var resp struct {
+		Droplet struct {
+			Id       uint
+			Status   string
+			Networks struct {
+				V4 []struct {
+					IPAddress string `mapstructure:"ip_address"`
+					Type      string
+				}
+			}
+		}
+	}
+	if err := d.request("GET", path, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	droplet := &Droplet{
+		Id:     resp.Droplet.Id,
+		Status: resp.Droplet.Status,
+	}
+
+	for _, network := range resp.Droplet.Networks.V4 {
+		switch network.Type {
+		case "public":
+			droplet.IPAddress = network.IPAddress
+		case "private":
+			droplet.PrivateIPAddress = network.IPAddress
+		}
+	}
+
+	return droplet, nil
+}
+
+// Returns DO's string representation of status "off" "new" "active" etc.
+func (d DigitalOceanClientV2) DropletStatus(id uint) (string, string, error) {
+	droplet, err := d.Droplet(id)
+	if err != nil {
+		return "", "", err
+	}
+
+	return droplet.IPAddress, droplet.Status, nil
+}
+
+// request sends an API request with the params as the JSON body, and
+// decodes the JSON response into result, if it isn't nil. Error responses
+// are returned as errors with the body, since it explains what went wrong.
+func (d DigitalOceanClientV2) request(method string, path string, params map[string]interface{}, result interface{}) error {
+	var body bytes.Buffer
+	if params != nil {
+		if err := json.NewEncoder(&body).Encode(params); err != nil {
+			return err
+		}
+	}
+
+	url := fmt.Sprintf("%s/%s", d.BaseURL, path)
+	req, err := http.NewRequest(method, url, &body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+d.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	log.Printf("sending new request to digitalocean: %s %s", method, url)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("response from digitalocean: %s", respBody)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errResp struct {
+			Id      string
+			Message string
+		}
+		if err := json.Unmarshal(respBody, &errResp); err == nil && errResp.Message != "" {
+			return fmt.Errorf("Received bad response (HTTP %v) from DigitalOcean: %s (%s)",
+				resp.StatusCode, errResp.Message, errResp.Id)
+		}
+
+		return fmt.Errorf("Received bad response (HTTP %v) from DigitalOcean: %s",
+			resp.StatusCode, respBody)
+	}
+
+	if result == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(respBody, &decoded); err != nil {
+		return fmt.Errorf("Failed to decode JSON response (HTTP %v) from DigitalOcean: %s",
+			resp.StatusCode, respBody)
+	}
+
+	return mapstructure.Decode(decoded, result)
+}
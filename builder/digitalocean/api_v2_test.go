@@ -0,0 +1,91 @@
+package digitalocean
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testClientV2(handler http.HandlerFunc) (*DigitalOceanClientV2, *httptest.Server) {
+	ts := httptest.NewServer(handler)
+	client := NewClientV2("token")
+	client.BaseURL = ts.URL
+	return client, ts
+}
+
+func TestClientV2_Impl(t *testing.T) {
+	var raw interface{}
+	raw = NewClientV2("token")
+	if _, ok := raw.(Client); !ok {
+		t.Fatalf("DigitalOceanClientV2 should be a Client")
+	}
+}
+
+func TestClientV2_CreateDroplet(t *testing.T) {
+	client, ts := testClientV2(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("bad auth: %s", r.Header.Get("Authorization"))
+		}
+
+		if r.Method != "POST" || r.URL.Path != "/droplets" {
+			t.Errorf("bad request: %s %s", r.Method, r.URL.Path)
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"image":42`) {
+			t.Errorf("bad body: %s", body)
+		}
+
+		w.WriteHeader(202)
+		fmt.Fprint(w, `{"droplet": {"id": 123}}`)
+	})
+	defer ts.Close()
+
+	id, err := client.CreateDroplet("foo", "512mb", "42", "nyc1", 1, false, false)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if id != 123 {
+		t.Fatalf("bad: %d", id)
+	}
+}
+
+func TestClientV2_Droplet(t *testing.T) {
+	client, ts := testClientV2(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"droplet": {"id": 123, "status": "active", "networks": {"v4": [
+			{"ip_address": "10.0.0.1", "type": "private"},
+			{"ip_address": "1.2.3.4", "type": "public"}]}}}`)
+	})
+	defer ts.Close()
+
+	droplet, err := client.Droplet(123)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if droplet.Status != "active" || droplet.IPAddress != "1.2.3.4" ||
+		droplet.PrivateIPAddress != "10.0.0.1" {
+		t.Fatalf("bad: %#v", droplet)
+	}
+}
+
+func TestClientV2_Error(t *testing.T) {
+	client, ts := testClientV2(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(422)
+		fmt.Fprint(w, `{"id": "unprocessable_entity", "message": "Region is not available"}`)
+	})
+	defer ts.Close()
+
+	err := client.DestroyDroplet(123)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if !strings.Contains(err.Error(), "Region is not available") {
+		t.Fatalf("bad: %s", err)
+	}
+}
@@ -13,7 +13,7 @@ type Artifact struct {
 	snapshotId uint
 
 	// The client for making API calls
-	client *DigitalOceanClient
+	client Client
 }
 
 func (*Artifact) BuilderId() string {
@@ -26,7 +26,7 @@ func (*Artifact) Files() []string {
 }
 
 func (a *Artifact) Id() string {
-	return a.snapshotName
+	return fmt.Sprintf("%s:%d", a.snapshotName, a.snapshotId)
 }
 
 func (a *Artifact) String() string {
@@ -13,6 +13,15 @@ func TestArtifact_Impl(t *testing.T) {
 	}
 }
 
+func TestArtifactId(t *testing.T) {
+	a := &Artifact{"packer-foobar", 42, nil}
+	expected := "packer-foobar:42"
+
+	if a.Id() != expected {
+		t.Fatalf("artifact ID should match: %v", expected)
+	}
+}
+
 func TestArtifactString(t *testing.T) {
 	a := &Artifact{"packer-foobar", 42, nil}
 	expected := "A snapshot was created: packer-foobar"
@@ -5,6 +5,8 @@ package digitalocean
 
 import (
 	"bytes"
+	"cgl.tideland.biz/identifier"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/mitchellh/mapstructure"
@@ -12,6 +14,7 @@ import (
 	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
+	"os"
 	"strconv"
 	"text/template"
 	"time"
@@ -20,6 +23,15 @@ import (
 // The unique id for the builder
 const BuilderId = "pearkes.digitalocean"
 
+// The IDs of the regions that support private networking. Droplets in
+// other regions can still be created with private_networking, but that
+// is likely to fail.
+var privateNetworkingRegions = map[uint]bool{
+	4: true, // New York 2
+	5: true, // Amsterdam 2
+	6: true, // Singapore 1
+}
+
 type snapshotNameData struct {
 	CreateTime string
 }
@@ -28,20 +40,41 @@ type snapshotNameData struct {
 // to use while communicating with DO and describes the image
 // you are creating
 type config struct {
+	// The credentials of the V1 API
 	ClientID string `mapstructure:"client_id"`
 	APIKey   string `mapstructure:"api_key"`
-	RegionID uint   `mapstructure:"region_id"`
-	SizeID   uint   `mapstructure:"size_id"`
-	ImageID  uint   `mapstructure:"image_id"`
 
-	SnapshotName string
+	// The credentials of the V2 API, which is used if they're given
+	APIToken string `mapstructure:"api_token"`
+
+	RegionID uint `mapstructure:"region_id"`
+	SizeID   uint `mapstructure:"size_id"`
+	ImageID  uint `mapstructure:"image_id"`
+
+	// The region, size and image by ID or by slug. Slugs are resolved
+	// to IDs when the build starts.
+	Region string `mapstructure:"region"`
+	Size   string `mapstructure:"size"`
+	Image  string `mapstructure:"image"`
+
+	DropletName string `mapstructure:"droplet_name"`
+
+	PrivateNetworking bool `mapstructure:"private_networking"`
+	IPv6              bool `mapstructure:"ipv6"`
+
+	SnapshotNameForceUnique bool `mapstructure:"snapshot_name_force_unique"`
+
 	SSHUsername  string `mapstructure:"ssh_username"`
 	SSHPort      uint   `mapstructure:"ssh_port"`
 	SSHTimeout   time.Duration
 	EventDelay   time.Duration
 	StateTimeout time.Duration
 
-	PackerDebug bool `mapstructure:"packer_debug"`
+	PackerBuildName         string `mapstructure:"packer_build_name"`
+	PackerDebug             bool   `mapstructure:"packer_debug"`
+	PackerForce             bool   `mapstructure:"packer_force"`
+	PackerOnError           string `mapstructure:"packer_on_error"`
+	PackerTemplateTimestamp int64  `mapstructure:"packer_template_timestamp"`
 
 	RawSnapshotName string `mapstructure:"snapshot_name"`
 	RawSSHTimeout   string `mapstructure:"ssh_timeout"`
@@ -50,8 +83,9 @@ type config struct {
 }
 
 type Builder struct {
-	config config
-	runner multistep.Runner
+	config   config
+	warnings []string
+	runner   multistep.Runner
 }
 
 func (b *Builder) Prepare(raws ...interface{}) error {
@@ -62,19 +96,79 @@ func (b *Builder) Prepare(raws ...interface{}) error {
 		}
 	}
 
+	// A list of errors on the configuration
+	errs := make([]error, 0)
+	b.warnings = make([]string, 0)
+
+	if b.config.APIToken == "" {
+		b.config.APIToken = os.Getenv("DIGITALOCEAN_API_TOKEN")
+	}
+
+	v2 := b.config.APIToken != ""
+	if v2 && (b.config.ClientID != "" || b.config.APIKey != "") {
+		b.warnings = append(b.warnings,
+			"Both V1 (client_id and api_key) and V2 (api_token) credentials are "+
+				"given. The V2 API is used.")
+	}
+
+	// The region, size and image can be given by ID or slug in the new
+	// keys, or by ID in the deprecated *_id keys, but not both ways.
+	ids := []struct {
+		key   string
+		value *string
+		id    *uint
+	}{
+		{"region", &b.config.Region, &b.config.RegionID},
+		{"size", &b.config.Size, &b.config.SizeID},
+		{"image", &b.config.Image, &b.config.ImageID},
+	}
+
+	for _, v := range ids {
+		if *v.id != 0 {
+			if *v.value != "" {
+				errs = append(errs, fmt.Errorf("Only one of %s or %s_id can be specified", v.key, v.key))
+				continue
+			}
+
+			b.warnings = append(b.warnings, fmt.Sprintf(
+				"%s_id is deprecated. Use %s, which can be an ID or a slug.", v.key, v.key))
+		}
+
+		// Numeric values are IDs, which don't have to be resolved
+		if id, err := strconv.ParseUint(*v.value, 10, 0); err == nil {
+			*v.id = uint(id)
+			*v.value = ""
+		}
+	}
+
 	// Optional configuration with defaults
 	//
-	if b.config.RegionID == 0 {
+	if v2 {
+		// The V2 API only has slugs for regions and sizes
+		if b.config.RegionID == 0 && b.config.Region == "" {
+			b.config.Region = "nyc1"
+		}
+
+		if b.config.SizeID == 0 && b.config.Size == "" {
+			b.config.Size = "512mb"
+		}
+
+		if b.config.ImageID == 0 && b.config.Image == "" {
+			b.config.Image = "ubuntu-12-04-x64"
+		}
+	}
+
+	if b.config.RegionID == 0 && b.config.Region == "" {
 		// Default to Region "New York"
 		b.config.RegionID = 1
 	}
 
-	if b.config.SizeID == 0 {
+	if b.config.SizeID == 0 && b.config.Size == "" {
 		// Default to 512mb, the smallest droplet size
 		b.config.SizeID = 66
 	}
 
-	if b.config.ImageID == 0 {
+	if b.config.ImageID == 0 && b.config.Image == "" {
 		// Default to base image "Ubuntu 12.04 x64 Server (id: 284203)"
 		b.config.ImageID = 284203
 	}
@@ -90,6 +184,12 @@ func (b *Builder) Prepare(raws ...interface{}) error {
 		b.config.SSHPort = 22
 	}
 
+	if b.config.DropletName == "" {
+		// Some random droplet name as it's temporary
+		b.config.DropletName = fmt.Sprintf("packer-%s",
+			hex.EncodeToString(identifier.NewUUID().Raw()))
+	}
+
 	if b.config.RawSnapshotName == "" {
 		// Default to packer-{{ unix timestamp (utc) }}
 		b.config.RawSnapshotName = "packer-{{.CreateTime}}"
@@ -112,17 +212,24 @@ func (b *Builder) Prepare(raws ...interface{}) error {
 		b.config.RawStateTimeout = "6m"
 	}
 
-	// A list of errors on the configuration
-	errs := make([]error, 0)
-
 	// Required configurations that will display errors if not set
 	//
-	if b.config.ClientID == "" {
-		errs = append(errs, errors.New("a client_id must be specified"))
-	}
+	if v2 {
+		if b.config.RegionID != 0 {
+			errs = append(errs, errors.New("region must be a slug, such as nyc1, with api_token"))
+		}
+
+		if b.config.SizeID != 0 {
+			errs = append(errs, errors.New("size must be a slug, such as 512mb, with api_token"))
+		}
+	} else {
+		if b.config.ClientID == "" {
+			errs = append(errs, errors.New("a client_id or api_token must be specified"))
+		}
 
-	if b.config.APIKey == "" {
-		errs = append(errs, errors.New("an api_key must be specified"))
+		if b.config.APIKey == "" {
+			errs = append(errs, errors.New("an api_key or api_token must be specified"))
+		}
 	}
 
 	sshTimeout, err := time.ParseDuration(b.config.RawSSHTimeout)
@@ -143,30 +250,38 @@ func (b *Builder) Prepare(raws ...interface{}) error {
 	}
 	b.config.StateTimeout = stateTimeout
 
-	// Parse the name of the snapshot
-	snapNameBuf := new(bytes.Buffer)
-	tData := snapshotNameData{
-		strconv.FormatInt(time.Now().UTC().Unix(), 10),
-	}
-	t, err := template.New("snapshot").Parse(b.config.RawSnapshotName)
+	// The name of the snapshot is only rendered when the snapshot is made,
+	// so just check that it parses.
+	tpl := common.NewConfigTemplate(b.config.PackerBuildName, b.config.PackerTemplateTimestamp)
+	_, err = template.New("snapshot").Funcs(tpl.Funcs()).Parse(b.config.RawSnapshotName)
 	if err != nil {
 		errs = append(errs, fmt.Errorf("Failed parsing snapshot_name: %s", err))
-	} else {
-		t.Execute(snapNameBuf, tData)
-		b.config.SnapshotName = snapNameBuf.String()
 	}
 
 	if len(errs) > 0 {
 		return &packer.MultiError{errs}
 	}
 
+	if warning := privateNetworkingWarning(b.config); warning != "" {
+		log.Printf("Warning: %s", warning)
+	}
+
+	for _, warning := range b.warnings {
+		log.Printf("Warning: %s", warning)
+	}
+
 	log.Printf("Config: %+v", b.config)
 	return nil
 }
 
 func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
 	// Initialize the DO API client
-	client := DigitalOceanClient{}.New(b.config.ClientID, b.config.APIKey)
+	var client Client
+	if b.config.APIToken != "" {
+		client = NewClientV2(b.config.APIToken)
+	} else {
+		client = DigitalOceanClient{}.New(b.config.ClientID, b.config.APIKey)
+	}
 
 	// Set up the state
 	state := make(map[string]interface{})
@@ -175,9 +290,17 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	state["hook"] = hook
 	state["ui"] = ui
 
+	for _, warning := range b.warnings {
+		ui.Message(fmt.Sprintf("Warning: %s", warning))
+	}
+
 	// Build the steps
 	steps := []multistep.Step{
-		new(stepCreateSSHKey),
+		new(stepResolveIds),
+		&stepCreateSSHKey{
+			Debug:        b.config.PackerDebug,
+			DebugKeyPath: fmt.Sprintf("do_%s.pem", b.config.PackerBuildName),
+		},
 		new(stepCreateDroplet),
 		new(stepDropletInfo),
 		new(stepConnectSSH),
@@ -187,16 +310,10 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	}
 
 	// Run the steps
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewRunner(steps, b.config.PackerDebug, b.config.PackerOnError, ui)
 
 	b.runner.Run(state)
+	common.ReportStepTimings(ui, state)
 
 	// If there was an error, return that
 	if rawErr, ok := state["error"]; ok {
@@ -208,15 +325,54 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		return nil, nil
 	}
 
+	bag := common.StateBag(state)
+	var snapshotName string
+	var snapshotId uint
+	if err := bag.Values("snapshot_name", &snapshotName, "snapshot_image_id", &snapshotId); err != nil {
+		return nil, err
+	}
+
 	artifact := &Artifact{
-		snapshotName: state["snapshot_name"].(string),
-		snapshotId:   state["snapshot_image_id"].(uint),
+		snapshotName: snapshotName,
+		snapshotId:   snapshotId,
 		client:       client,
 	}
 
 	return artifact, nil
 }
 
+// privateNetworkingWarning returns a warning if private networking is
+// enabled in a region that isn't known to support it.
+func privateNetworkingWarning(c config) string {
+	if !c.PrivateNetworking || c.RegionID == 0 || privateNetworkingRegions[c.RegionID] {
+		return ""
+	}
+
+	return fmt.Sprintf(
+		"Region %d may not support private networking. If creating the "+
+			"droplet fails, try another region.", c.RegionID)
+}
+
+// processSnapshotName renders the snapshot_name template.
+func processSnapshotName(c config) (string, error) {
+	tpl := common.NewConfigTemplate(c.PackerBuildName, c.PackerTemplateTimestamp)
+	t, err := template.New("snapshot").Funcs(tpl.Funcs()).Parse(c.RawSnapshotName)
+	if err != nil {
+		return "", err
+	}
+
+	tData := snapshotNameData{
+		strconv.FormatInt(tpl.Timestamp.Unix(), 10),
+	}
+
+	var result bytes.Buffer
+	if err := t.Execute(&result, tData); err != nil {
+		return "", err
+	}
+
+	return result.String(), nil
+}
+
 func (b *Builder) Cancel() {
 	if b.runner != nil {
 		log.Println("Cancelling the step runner...")
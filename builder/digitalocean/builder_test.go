@@ -2,10 +2,17 @@ package digitalocean
 
 import (
 	"github.com/mitchellh/packer/packer"
+	"os"
 	"strconv"
+	"strings"
 	"testing"
 )
 
+func init() {
+	// Clear out the API token env var so it doesn't affect our tests.
+	os.Setenv("DIGITALOCEAN_API_TOKEN", "")
+}
+
 func testConfig() map[string]interface{} {
 	return map[string]interface{}{
 		"client_id": "foo",
@@ -57,6 +64,65 @@ func TestBuilderPrepare_APIKey(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_APIToken(t *testing.T) {
+	var b Builder
+	config := map[string]interface{}{
+		"api_token": "foo",
+	}
+
+	// Test good
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.Region != "nyc1" || b.config.Size != "512mb" {
+		t.Fatalf("bad: %s %s", b.config.Region, b.config.Size)
+	}
+
+	if len(b.warnings) != 0 {
+		t.Fatalf("bad: %#v", b.warnings)
+	}
+
+	// Test with V1 credentials too
+	config["client_id"] = "foo"
+	config["api_key"] = "bar"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if len(b.warnings) != 1 {
+		t.Fatalf("bad: %#v", b.warnings)
+	}
+
+	// Test with a region ID
+	config["region"] = "1"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test env
+	delete(config, "api_token")
+	delete(config, "client_id")
+	delete(config, "api_key")
+	delete(config, "region")
+	os.Setenv("DIGITALOCEAN_API_TOKEN", "foo")
+	defer os.Setenv("DIGITALOCEAN_API_TOKEN", "")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.APIToken != "foo" {
+		t.Fatalf("bad: %s", b.config.APIToken)
+	}
+}
+
 func TestBuilderPrepare_ClientID(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -108,6 +174,106 @@ func TestBuilderPrepare_RegionID(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_PrivateNetworking(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.PrivateNetworking || b.config.IPv6 {
+		t.Fatal("should be disabled by default")
+	}
+
+	if privateNetworkingWarning(b.config) != "" {
+		t.Fatal("should not warn")
+	}
+
+	// Test in a region without private networking
+	config["private_networking"] = true
+	config["ipv6"] = true
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if !b.config.PrivateNetworking || !b.config.IPv6 {
+		t.Fatal("should be enabled")
+	}
+
+	if privateNetworkingWarning(b.config) == "" {
+		t.Fatal("should warn")
+	}
+
+	// Test in a region with private networking
+	config["region_id"] = 4
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if privateNetworkingWarning(b.config) != "" {
+		t.Fatal("should not warn")
+	}
+}
+
+func TestBuilderPrepare_Region(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test an ID
+	config["region"] = "2"
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.RegionID != 2 || b.config.Region != "" {
+		t.Errorf("invalid: %d %s", b.config.RegionID, b.config.Region)
+	}
+
+	if len(b.warnings) != 0 {
+		t.Fatalf("bad: %#v", b.warnings)
+	}
+
+	// Test a slug
+	config["region"] = "nyc1"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.RegionID != 0 || b.config.Region != "nyc1" {
+		t.Errorf("invalid: %d %s", b.config.RegionID, b.config.Region)
+	}
+
+	// Test with region_id
+	config["region_id"] = 2
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test region_id alone is deprecated
+	delete(config, "region")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if len(b.warnings) != 1 {
+		t.Fatalf("bad: %#v", b.warnings)
+	}
+}
+
 func TestBuilderPrepare_SizeID(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -162,6 +328,33 @@ func TestBuilderPrepare_ImageID(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_DropletName(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if !strings.HasPrefix(b.config.DropletName, "packer-") {
+		t.Errorf("invalid: %s", b.config.DropletName)
+	}
+
+	// Test set
+	config["droplet_name"] = "foo"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.DropletName != "foo" {
+		t.Errorf("invalid: %s", b.config.DropletName)
+	}
+}
+
 func TestBuilderPrepare_SSHUsername(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -315,9 +508,59 @@ func TestBuilderPrepare_SnapshotName(t *testing.T) {
 		t.Fatalf("should not have error: %s", err)
 	}
 
-	_, err = strconv.ParseInt(b.config.SnapshotName, 0, 0)
+	name, err := processSnapshotName(b.config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, err = strconv.ParseInt(name, 0, 0)
 	if err != nil {
 		t.Fatalf("failed to parse int in template: %s", err)
 	}
 
+	// Test set with template functions
+	config["snapshot_name"] = "packer-{{timestamp}}-{{uuid}}"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	name, err = processSnapshotName(b.config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !strings.HasPrefix(name, "packer-") || len(name) < len("packer-1373000000-")+32 {
+		t.Fatalf("bad: %s", name)
+	}
+
+	// Test bad
+	config["snapshot_name"] = "{{nope}}"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestUniqueSnapshotName(t *testing.T) {
+	images := []Image{
+		Image{Id: 1, Name: "foo"},
+		Image{Id: 2, Name: "foo-2"},
+	}
+
+	name, err := uniqueSnapshotName("bar", images, false)
+	if err != nil || name != "bar" {
+		t.Fatalf("bad: %s %s", name, err)
+	}
+
+	if _, err := uniqueSnapshotName("foo", images, false); err == nil {
+		t.Fatal("should have error")
+	}
+
+	name, err = uniqueSnapshotName("foo", images, true)
+	if err != nil || name != "foo-3" {
+		t.Fatalf("bad: %s %s", name, err)
+	}
 }
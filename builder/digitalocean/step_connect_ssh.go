@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/communicator/ssh"
 	"github.com/mitchellh/packer/packer"
 	"log"
@@ -17,9 +18,13 @@ type stepConnectSSH struct {
 }
 
 func (s *stepConnectSSH) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(config)
-	privateKey := state["privateKey"].(string)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var config config
+	var privateKey string
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "privateKey", &privateKey, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 	ipAddress := state["droplet_ip"]
 
 	// Build the keyring for authentication. This stores the private key
@@ -1,12 +1,12 @@
 package digitalocean
 
 import (
-	"cgl.tideland.biz/identifier"
-	"encoding/hex"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
+	"strconv"
 	"time"
 )
 
@@ -15,18 +15,25 @@ type stepCreateDroplet struct {
 }
 
 func (s *stepCreateDroplet) Run(state map[string]interface{}) multistep.StepAction {
-	client := state["client"].(*DigitalOceanClient)
-	ui := state["ui"].(packer.Ui)
-	c := state["config"].(config)
-	sshKeyId := state["ssh_key_id"].(uint)
+	bag := common.StateBag(state)
+	var client Client
+	var ui packer.Ui
+	var c config
+	var sshKeyId uint
+	if err := bag.Values("client", &client, "ui", &ui, "config", &c, "ssh_key_id", &sshKeyId); err != nil {
+		return bag.Halt(err)
+	}
 
-	ui.Say("Creating droplet...")
+	if warning := privateNetworkingWarning(c); warning != "" {
+		ui.Message(fmt.Sprintf("Warning: %s", warning))
+	}
 
-	// Some random droplet name as it's temporary
-	name := fmt.Sprintf("packer-%s", hex.EncodeToString(identifier.NewUUID().Raw()))
+	ui.Say("Creating droplet...")
 
 	// Create the droplet based on configuration
-	dropletId, err := client.CreateDroplet(name, c.SizeID, c.ImageID, c.RegionID, sshKeyId)
+	dropletId, err := client.CreateDroplet(c.DropletName, idOrSlug(c.Size, c.SizeID),
+		idOrSlug(c.Image, c.ImageID), idOrSlug(c.Region, c.RegionID), sshKeyId,
+		c.PrivateNetworking, c.IPv6)
 	if err != nil {
 		err := fmt.Errorf("Error creating droplet: %s", err)
 		state["error"] = err
@@ -43,15 +50,29 @@ func (s *stepCreateDroplet) Run(state map[string]interface{}) multistep.StepActi
 	return multistep.ActionContinue
 }
 
+// idOrSlug returns the slug if there is one, and the ID otherwise.
+func idOrSlug(slug string, id uint) string {
+	if slug != "" {
+		return slug
+	}
+
+	return strconv.FormatUint(uint64(id), 10)
+}
+
 func (s *stepCreateDroplet) Cleanup(state map[string]interface{}) {
 	// If the dropletid isn't there, we probably never created it
 	if s.dropletId == 0 {
 		return
 	}
 
-	client := state["client"].(*DigitalOceanClient)
-	ui := state["ui"].(packer.Ui)
-	c := state["config"].(config)
+	bag := common.StateBag(state)
+	var client Client
+	var ui packer.Ui
+	var c config
+	if err := bag.Values("client", &client, "ui", &ui, "config", &c); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
 
 	// Destroy the droplet we just created
 	ui.Say("Destroying droplet...")
@@ -62,13 +83,15 @@ func (s *stepCreateDroplet) Cleanup(state map[string]interface{}) {
 	log.Printf("Sleeping for %v, event_delay", c.RawEventDelay)
 	time.Sleep(c.EventDelay)
 
-	err := client.DestroyDroplet(s.dropletId)
-
-	curlstr := fmt.Sprintf("curl '%v/droplets/%v/destroy?client_id=%v&api_key=%v'",
-		DIGITALOCEAN_API_URL, s.dropletId, c.ClientID, c.APIKey)
+	// The droplet is locked while an event such as a power off or a
+	// snapshot is pending, so keep trying for a while.
+	err := retryLocked(destroyDropletTimeout, func() error {
+		return client.DestroyDroplet(s.dropletId)
+	})
 
 	if err != nil {
 		ui.Error(fmt.Sprintf(
-			"Error destroying droplet. Please destroy it manually: %v", curlstr))
+			"Error destroying droplet %v (%s). Please destroy it manually: %s",
+			s.dropletId, c.DropletName, err))
 	}
 }
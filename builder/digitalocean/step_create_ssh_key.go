@@ -10,17 +10,26 @@ import (
 	"encoding/pem"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
 	"log"
 )
 
 type stepCreateSSHKey struct {
+	Debug        bool
+	DebugKeyPath string
+
 	keyId uint
 }
 
 func (s *stepCreateSSHKey) Run(state map[string]interface{}) multistep.StepAction {
-	client := state["client"].(*DigitalOceanClient)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var client Client
+	var ui packer.Ui
+	if err := bag.Values("client", &client, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
 	ui.Say("Creating temporary ssh key for droplet...")
 
@@ -35,7 +44,8 @@ func (s *stepCreateSSHKey) Run(state map[string]interface{}) multistep.StepActio
 	}
 
 	// Set the private key in the statebag for later
-	state["privateKey"] = string(pem.EncodeToMemory(&priv_blk))
+	privateKey := string(pem.EncodeToMemory(&priv_blk))
+	state["privateKey"] = privateKey
 
 	// Marshal the public key into SSH compatible format
 	pub := priv.PublicKey
@@ -56,6 +66,18 @@ func (s *stepCreateSSHKey) Run(state map[string]interface{}) multistep.StepActio
 	// We use this to check cleanup
 	s.keyId = keyId
 
+	// If we're in debug mode, output the private key to the working
+	// directory so the machine can be inspected over SSH.
+	if s.Debug {
+		ui.Message(fmt.Sprintf("Saving key for debug purposes: %s", s.DebugKeyPath))
+		if err := ioutil.WriteFile(s.DebugKeyPath, []byte(privateKey), 0600); err != nil {
+			err := fmt.Errorf("Error saving debug key: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	log.Printf("temporary ssh key name: %s", name)
 
 	// Remember some state for the future
@@ -70,9 +92,14 @@ func (s *stepCreateSSHKey) Cleanup(state map[string]interface{}) {
 		return
 	}
 
-	client := state["client"].(*DigitalOceanClient)
-	ui := state["ui"].(packer.Ui)
-	c := state["config"].(config)
+	bag := common.StateBag(state)
+	var client Client
+	var ui packer.Ui
+	var c config
+	if err := bag.Values("client", &client, "ui", &ui, "config", &c); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
 
 	ui.Say("Deleting temporary ssh key...")
 	err := client.DestroyKey(s.keyId)
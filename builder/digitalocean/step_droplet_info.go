@@ -3,20 +3,25 @@ package digitalocean
 import (
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 )
 
 type stepDropletInfo struct{}
 
 func (s *stepDropletInfo) Run(state map[string]interface{}) multistep.StepAction {
-	client := state["client"].(*DigitalOceanClient)
-	ui := state["ui"].(packer.Ui)
-	c := state["config"].(config)
-	dropletId := state["droplet_id"].(uint)
+	bag := common.StateBag(state)
+	var client Client
+	var ui packer.Ui
+	var c config
+	var dropletId uint
+	if err := bag.Values("client", &client, "ui", &ui, "config", &c, "droplet_id", &dropletId); err != nil {
+		return bag.Halt(err)
+	}
 
 	ui.Say("Waiting for droplet to become active...")
 
-	err := waitForDropletState("active", dropletId, client, c)
+	err := waitForDropletState("active", dropletId, client, c, state)
 	if err != nil {
 		err := fmt.Errorf("Error waiting for droplet to become active: %s", err)
 		state["error"] = err
@@ -24,8 +29,8 @@ func (s *stepDropletInfo) Run(state map[string]interface{}) multistep.StepAction
 		return multistep.ActionHalt
 	}
 
-	// Set the IP on the state for later
-	ip, _, err := client.DropletStatus(dropletId)
+	// Set the IPs on the state for later
+	droplet, err := client.Droplet(dropletId)
 	if err != nil {
 		err := fmt.Errorf("Error retrieving droplet ID: %s", err)
 		state["error"] = err
@@ -33,7 +38,8 @@ func (s *stepDropletInfo) Run(state map[string]interface{}) multistep.StepAction
 		return multistep.ActionHalt
 	}
 
-	state["droplet_ip"] = ip
+	state["droplet_ip"] = droplet.IPAddress
+	state["droplet_private_ip"] = droplet.PrivateIPAddress
 
 	return multistep.ActionContinue
 }
@@ -3,6 +3,7 @@ package digitalocean
 import (
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
 	"time"
@@ -11,10 +12,14 @@ import (
 type stepPowerOff struct{}
 
 func (s *stepPowerOff) Run(state map[string]interface{}) multistep.StepAction {
-	client := state["client"].(*DigitalOceanClient)
-	c := state["config"].(config)
-	ui := state["ui"].(packer.Ui)
-	dropletId := state["droplet_id"].(uint)
+	bag := common.StateBag(state)
+	var client Client
+	var c config
+	var ui packer.Ui
+	var dropletId uint
+	if err := bag.Values("client", &client, "config", &c, "ui", &ui, "droplet_id", &dropletId); err != nil {
+		return bag.Halt(err)
+	}
 
 	// Sleep arbitrarily before sending power off request
 	// Otherwise we get "pending event" errors, even though there isn't
@@ -34,7 +39,7 @@ func (s *stepPowerOff) Run(state map[string]interface{}) multistep.StepAction {
 
 	ui.Say("Waiting for droplet to power off...")
 
-	err = waitForDropletState("off", dropletId, client, c)
+	err = waitForDropletState("off", dropletId, client, c, state)
 	if err != nil {
 		err := fmt.Errorf("Error waiting for droplet to become 'off': %s", err)
 		state["error"] = err
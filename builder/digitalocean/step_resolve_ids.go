@@ -0,0 +1,125 @@
+package digitalocean
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"sort"
+	"strings"
+)
+
+// stepResolveIds looks up the IDs of the region, size and image that were
+// given by slug, and stores them in the config for the later steps. This
+// is only needed with the V1 API.
+type stepResolveIds struct{}
+
+func (s *stepResolveIds) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var client Client
+	var ui packer.Ui
+	var c config
+	if err := bag.Values("client", &client, "ui", &ui, "config", &c); err != nil {
+		return bag.Halt(err)
+	}
+
+	// The V2 API takes the slugs themselves
+	if c.APIToken != "" {
+		return multistep.ActionContinue
+	}
+
+	if c.Region == "" && c.Size == "" && c.Image == "" {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Looking up the IDs of the region, size, and image...")
+
+	if c.Region != "" {
+		regions, err := client.Regions()
+		if err == nil {
+			slugs := make(map[string]uint)
+			for _, region := range regions {
+				slugs[region.Slug] = region.Id
+			}
+
+			c.RegionID, err = resolveSlug("region", c.Region, slugs)
+			c.Region = ""
+		}
+
+		if err != nil {
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if c.Size != "" {
+		sizes, err := client.Sizes()
+		if err == nil {
+			slugs := make(map[string]uint)
+			for _, size := range sizes {
+				slugs[size.Slug] = size.Id
+			}
+
+			c.SizeID, err = resolveSlug("size", c.Size, slugs)
+			c.Size = ""
+		}
+
+		if err != nil {
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if c.Image != "" {
+		images, err := client.Images()
+		if err == nil {
+			// Private images don't have slugs, so they're found by name
+			slugs := make(map[string]uint)
+			for _, image := range images {
+				if image.Slug != "" {
+					slugs[image.Slug] = image.Id
+				} else {
+					slugs[image.Name] = image.Id
+				}
+			}
+
+			c.ImageID, err = resolveSlug("image", c.Image, slugs)
+			c.Image = ""
+		}
+
+		if err != nil {
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	state["config"] = c
+
+	return multistep.ActionContinue
+}
+
+func (s *stepResolveIds) Cleanup(state map[string]interface{}) {
+	// no cleanup
+}
+
+// resolveSlug returns the ID of the slug, or an error listing the valid
+// slugs if it isn't one of them.
+func resolveSlug(key string, slug string, slugs map[string]uint) (uint, error) {
+	if id, ok := slugs[slug]; ok && slug != "" {
+		return id, nil
+	}
+
+	valid := make([]string, 0, len(slugs))
+	for s := range slugs {
+		if s != "" {
+			valid = append(valid, s)
+		}
+	}
+	sort.Strings(valid)
+
+	return 0, fmt.Errorf("Unknown %s %s. Valid values are: %s",
+		key, slug, strings.Join(valid, ", "))
+}
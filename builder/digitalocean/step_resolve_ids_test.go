@@ -0,0 +1,28 @@
+package digitalocean
+
+import (
+	"testing"
+)
+
+func TestResolveSlug(t *testing.T) {
+	slugs := map[string]uint{"nyc1": 1, "ams1": 2}
+
+	id, err := resolveSlug("region", "ams1", slugs)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if id != 2 {
+		t.Fatalf("bad: %d", id)
+	}
+
+	_, err = resolveSlug("region", "nope", slugs)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	expected := "Unknown region nope. Valid values are: ams1, nyc1"
+	if err.Error() != expected {
+		t.Fatalf("bad: %s", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
 )
@@ -11,13 +12,62 @@ import (
 type stepSnapshot struct{}
 
 func (s *stepSnapshot) Run(state map[string]interface{}) multistep.StepAction {
-	client := state["client"].(*DigitalOceanClient)
-	ui := state["ui"].(packer.Ui)
-	c := state["config"].(config)
-	dropletId := state["droplet_id"].(uint)
+	bag := common.StateBag(state)
+	var client Client
+	var ui packer.Ui
+	var c config
+	var dropletId uint
+	if err := bag.Values("client", &client, "ui", &ui, "config", &c, "droplet_id", &dropletId); err != nil {
+		return bag.Halt(err)
+	}
+
+	snapshotName, err := processSnapshotName(c)
+	if err != nil {
+		err := fmt.Errorf("Error processing snapshot_name: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Deploy tooling finds snapshots by name, so the name must be unique
+	images, err := client.Images()
+	if err != nil {
+		err := fmt.Errorf("Error listing existing snapshots: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// When forced, replace the snapshots that already have the name
+	if c.PackerForce {
+		remaining := make([]Image, 0, len(images))
+		for _, image := range images {
+			if image.Name != snapshotName {
+				remaining = append(remaining, image)
+				continue
+			}
+
+			ui.Say(fmt.Sprintf("Destroying existing snapshot: %s (%d)", image.Name, image.Id))
+			if err := client.DestroyImage(image.Id); err != nil {
+				err := fmt.Errorf("Error destroying existing snapshot: %s", err)
+				state["error"] = err
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+
+		images = remaining
+	}
 
-	ui.Say(fmt.Sprintf("Creating snapshot: %v", c.SnapshotName))
-	err := client.CreateSnapshot(dropletId, c.SnapshotName)
+	snapshotName, err = uniqueSnapshotName(snapshotName, images, c.SnapshotNameForceUnique)
+	if err != nil {
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Creating snapshot: %v", snapshotName))
+	err = client.CreateSnapshot(dropletId, snapshotName)
 	if err != nil {
 		err := fmt.Errorf("Error creating snapshot: %s", err)
 		state["error"] = err
@@ -26,7 +76,7 @@ func (s *stepSnapshot) Run(state map[string]interface{}) multistep.StepAction {
 	}
 
 	ui.Say("Waiting for snapshot to complete...")
-	err = waitForDropletState("active", dropletId, client, c)
+	err = waitForDropletState("active", dropletId, client, c, state)
 	if err != nil {
 		err := fmt.Errorf("Error waiting for snapshot to complete: %s", err)
 		state["error"] = err
@@ -34,8 +84,8 @@ func (s *stepSnapshot) Run(state map[string]interface{}) multistep.StepAction {
 		return multistep.ActionHalt
 	}
 
-	log.Printf("Looking up snapshot ID for snapshot: %s", c.SnapshotName)
-	images, err := client.Images()
+	log.Printf("Looking up snapshot ID for snapshot: %s", snapshotName)
+	images, err = client.Images()
 	if err != nil {
 		err := fmt.Errorf("Error looking up snapshot ID: %s", err)
 		state["error"] = err
@@ -45,7 +95,7 @@ func (s *stepSnapshot) Run(state map[string]interface{}) multistep.StepAction {
 
 	var imageId uint
 	for _, image := range images {
-		if image.Name == c.SnapshotName {
+		if image.Name == snapshotName {
 			imageId = image.Id
 			break
 		}
@@ -61,7 +111,7 @@ func (s *stepSnapshot) Run(state map[string]interface{}) multistep.StepAction {
 	log.Printf("Snapshot image ID: %d", imageId)
 
 	state["snapshot_image_id"] = imageId
-	state["snapshot_name"] = c.SnapshotName
+	state["snapshot_name"] = snapshotName
 
 	return multistep.ActionContinue
 }
@@ -69,3 +119,30 @@ func (s *stepSnapshot) Run(state map[string]interface{}) multistep.StepAction {
 func (s *stepSnapshot) Cleanup(state map[string]interface{}) {
 	// no cleanup
 }
+
+// uniqueSnapshotName returns an error if an image with the name already
+// exists, unless forceUnique is set, in which case a numeric suffix is
+// appended to the name to make it unique.
+func uniqueSnapshotName(name string, images []Image, forceUnique bool) (string, error) {
+	names := make(map[string]bool)
+	for _, image := range images {
+		names[image.Name] = true
+	}
+
+	if !names[name] {
+		return name, nil
+	}
+
+	if !forceUnique {
+		return "", fmt.Errorf(
+			"A snapshot named %s already exists. Change snapshot_name or set "+
+				"snapshot_name_force_unique.", name)
+	}
+
+	for i := 2; ; i++ {
+		unique := fmt.Sprintf("%s-%d", name, i)
+		if !names[unique] {
+			return unique, nil
+		}
+	}
+}
@@ -1,56 +1,74 @@
 package digitalocean
 
 import (
-	"errors"
+	"fmt"
+	"github.com/mitchellh/multistep"
 	"log"
+	"strings"
 	"time"
 )
 
-// waitForState simply blocks until the droplet is in
-// a state we expect, while eventually timing out.
-func waitForDropletState(desiredState string, dropletId uint, client *DigitalOceanClient, c config) error {
-	active := make(chan bool, 1)
+// The time to keep retrying to destroy a droplet that is locked.
+var destroyDropletTimeout = 2 * time.Minute
 
-	go func() {
-		attempts := 0
-		for {
-			attempts += 1
+// The time to wait between attempts of a request that failed because
+// the droplet is locked.
+var lockedRetryInterval = 10 * time.Second
 
-			log.Printf("Checking droplet status... (attempt: %d)", attempts)
-
-			_, status, err := client.DropletStatus(dropletId)
-
-			if err != nil {
-				log.Println(err)
-				break
-			}
+// retryLocked calls f until it doesn't fail because the droplet is locked
+// by a pending event, or until the timeout passes. The last error is
+// returned.
+func retryLocked(timeout time.Duration, f func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := f()
+		if err == nil || !isLockedError(err) || time.Now().After(deadline) {
+			return err
+		}
 
-			if status == desiredState {
-				break
-			}
+		log.Printf("Droplet is locked, retrying in %s: %s", lockedRetryInterval, err)
+		time.Sleep(lockedRetryInterval)
+	}
+}
 
-			// Wait 3 seconds in between
-			time.Sleep(3 * time.Second)
-		}
+// isLockedError returns true if the error is DigitalOcean rejecting a
+// request because an event is still pending on the droplet.
+func isLockedError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "locked") || strings.Contains(msg, "pending event")
+}
 
-		active <- true
-	}()
+// The time to wait between checks of the status of a droplet.
+var dropletStatePollInterval = 3 * time.Second
 
+// waitForDropletState blocks until the droplet is in the desired state,
+// returning an error if the timeout passes or the build is cancelled
+// while waiting.
+func waitForDropletState(desiredState string, dropletId uint, client Client, c config, state map[string]interface{}) error {
 	log.Printf("Waiting for up to %s for droplet to become %s", c.RawStateTimeout, desiredState)
 	timeout := time.After(c.StateTimeout)
-
-ActiveWaitLoop:
+	attempts := 0
 	for {
+		attempts += 1
+
+		log.Printf("Checking droplet status... (attempt: %d)", attempts)
+		_, status, err := client.DropletStatus(dropletId)
+		if err != nil {
+			return err
+		}
+
+		if status == desiredState {
+			return nil
+		}
+
 		select {
-		case <-active:
-			// We connected. Just break the loop.
-			break ActiveWaitLoop
 		case <-timeout:
-			err := errors.New("Timeout while waiting to for droplet to become active")
-			return err
+			return fmt.Errorf("Timeout while waiting for droplet to become %s", desiredState)
+		case <-time.After(dropletStatePollInterval):
 		}
-	}
 
-	// If we got this far, there were no errors
-	return nil
+		if _, ok := state[multistep.StateCancelled]; ok {
+			return fmt.Errorf("Interrupted while waiting for droplet to become %s", desiredState)
+		}
+	}
 }
@@ -0,0 +1,114 @@
+package digitalocean
+
+import (
+	"errors"
+	"github.com/mitchellh/multistep"
+	"testing"
+	"time"
+)
+
+func TestRetryLocked(t *testing.T) {
+	old := lockedRetryInterval
+	lockedRetryInterval = time.Millisecond
+	defer func() { lockedRetryInterval = old }()
+
+	attempts := 0
+	err := retryLocked(time.Minute, func() error {
+		attempts += 1
+		if attempts < 3 {
+			return errors.New("Droplet is currently locked")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if attempts != 3 {
+		t.Fatalf("bad: %d", attempts)
+	}
+
+	// Other errors aren't retried
+	attempts = 0
+	err = retryLocked(time.Minute, func() error {
+		attempts += 1
+		return errors.New("Not found")
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if attempts != 1 {
+		t.Fatalf("bad: %d", attempts)
+	}
+
+	// It gives up after the timeout
+	err = retryLocked(0, func() error {
+		return errors.New("There is a pending event on this droplet")
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+// statusClient is a Client that reports the droplet statuses in order,
+// repeating the last one.
+type statusClient struct {
+	Client
+	statuses []string
+	calls    int
+}
+
+func (c *statusClient) DropletStatus(id uint) (string, string, error) {
+	c.calls += 1
+	status := c.statuses[0]
+	if len(c.statuses) > 1 {
+		c.statuses = c.statuses[1:]
+	}
+
+	return "", status, nil
+}
+
+func TestWaitForDropletState(t *testing.T) {
+	old := dropletStatePollInterval
+	dropletStatePollInterval = time.Millisecond
+	defer func() { dropletStatePollInterval = old }()
+
+	c := config{StateTimeout: time.Minute}
+	client := &statusClient{statuses: []string{"new", "new", "active"}}
+	err := waitForDropletState("active", 1, client, c, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if client.calls != 3 {
+		t.Fatalf("bad: %d", client.calls)
+	}
+
+	// It gives up after the timeout
+	c.StateTimeout = 0
+	client = &statusClient{statuses: []string{"new"}}
+	err = waitForDropletState("active", 1, client, c, nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestWaitForDropletState_Cancelled(t *testing.T) {
+	old := dropletStatePollInterval
+	dropletStatePollInterval = time.Millisecond
+	defer func() { dropletStatePollInterval = old }()
+
+	state := map[string]interface{}{multistep.StateCancelled: true}
+	c := config{StateTimeout: time.Minute}
+	client := &statusClient{statuses: []string{"new"}}
+	err := waitForDropletState("active", 1, client, c, state)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if client.calls != 1 {
+		t.Fatalf("bad: %d", client.calls)
+	}
+}
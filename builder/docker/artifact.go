@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+)
+
+// ExportArtifact is the result of a Docker build that exported the
+// container, namely the tarball of its filesystem.
+type ExportArtifact struct {
+	path string
+}
+
+func (*ExportArtifact) BuilderId() string {
+	return ExportBuilderId
+}
+
+func (a *ExportArtifact) Files() []string {
+	return []string{a.path}
+}
+
+func (*ExportArtifact) Id() string {
+	return "Container"
+}
+
+func (a *ExportArtifact) String() string {
+	return fmt.Sprintf("Exported Docker file: %s", a.path)
+}
+
+func (a *ExportArtifact) Destroy() error {
+	return os.Remove(a.path)
+}
+
+// ImageArtifact is the result of a Docker build that committed the
+// container, namely the new image.
+type ImageArtifact struct {
+	id     string
+	driver Driver
+}
+
+func (*ImageArtifact) BuilderId() string {
+	return CommitBuilderId
+}
+
+func (*ImageArtifact) Files() []string {
+	return nil
+}
+
+func (a *ImageArtifact) Id() string {
+	return a.id
+}
+
+func (a *ImageArtifact) String() string {
+	return fmt.Sprintf("Committed Docker image: %s", a.id)
+}
+
+func (a *ImageArtifact) Destroy() error {
+	return a.driver.DeleteImage(a.id)
+}
@@ -0,0 +1,30 @@
+package docker
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"testing"
+)
+
+func TestExportArtifact_Impl(t *testing.T) {
+	var _ packer.Artifact = new(ExportArtifact)
+}
+
+func TestImageArtifact_Impl(t *testing.T) {
+	var _ packer.Artifact = new(ImageArtifact)
+}
+
+func TestImageArtifact_Destroy(t *testing.T) {
+	driver := new(driverMock)
+	a := &ImageArtifact{id: "foo", driver: driver}
+	if err := a.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if driver.DeleteImageId != "foo" {
+		t.Fatalf("bad: %s", driver.DeleteImageId)
+	}
+}
+
+func TestCommunicator_Impl(t *testing.T) {
+	var _ packer.Communicator = new(Communicator)
+}
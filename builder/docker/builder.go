@@ -0,0 +1,161 @@
+// The docker package contains a packer.Builder implementation that builds
+// Docker images by provisioning a container from a base image.
+
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const BuilderId = "mitchellh.docker"
+
+// The IDs of the artifacts of the builder. Committed images have their
+// own ID since they are an image rather than a file.
+const (
+	ExportBuilderId = BuilderId
+	CommitBuilderId = "mitchellh.docker.commit"
+)
+
+type Builder struct {
+	config config
+	driver Driver
+	runner multistep.Runner
+}
+
+type config struct {
+	Commit     bool   `mapstructure:"commit"`
+	ExportPath string `mapstructure:"export_path"`
+	Image      string `mapstructure:"image"`
+
+	PackerBuildName string `mapstructure:"packer_build_name"`
+	PackerDebug     bool   `mapstructure:"packer_debug"`
+	PackerForce     bool   `mapstructure:"packer_force"`
+	PackerOnError   string `mapstructure:"packer_on_error"`
+}
+
+func (b *Builder) Prepare(raws ...interface{}) error {
+	var err error
+
+	for _, raw := range raws {
+		err := mapstructure.Decode(raw, &b.config)
+		if err != nil {
+			return err
+		}
+	}
+
+	errs := make([]error, 0)
+
+	if b.config.Image == "" {
+		errs = append(errs, errors.New("An image must be specified."))
+	}
+
+	if b.config.ExportPath == "" && !b.config.Commit {
+		errs = append(errs, errors.New("One of export_path or commit must be specified."))
+	} else if b.config.ExportPath != "" && b.config.Commit {
+		errs = append(errs, errors.New("Only one of export_path or commit may be specified."))
+	}
+
+	if b.config.ExportPath != "" {
+		// Relative paths are relative to the directory Packer is run from
+		b.config.ExportPath, err = filepath.Abs(b.config.ExportPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("export_path is invalid: %s", err))
+		} else if _, err := os.Stat(b.config.ExportPath); err == nil && !b.config.PackerForce {
+			errs = append(errs, errors.New("export_path already exists. It must not exist, unless the build is forced."))
+		}
+	}
+
+	b.driver, err = newDriver()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed creating Docker driver: %s", err))
+	}
+
+	if len(errs) > 0 {
+		return &packer.MultiError{errs}
+	}
+
+	return nil
+}
+
+func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	steps := []multistep.Step{
+		new(stepPull),
+		new(stepRunContainer),
+		new(stepProvision),
+		new(stepCommit),
+		new(stepExport),
+	}
+
+	// Setup the state bag
+	state := make(map[string]interface{})
+	state["cache"] = cache
+	state["config"] = &b.config
+	state["driver"] = b.driver
+	state["hook"] = hook
+	state["ui"] = ui
+
+	// Run
+	b.runner = common.NewRunner(steps, b.config.PackerDebug, b.config.PackerOnError, ui)
+
+	b.runner.Run(state)
+	common.ReportStepTimings(ui, state)
+
+	// If there was an error, return that
+	if rawErr, ok := state["error"]; ok {
+		return nil, rawErr.(error)
+	}
+
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state[multistep.StateCancelled]; ok {
+		return nil, errors.New("Build was cancelled.")
+	}
+
+	if _, ok := state[multistep.StateHalted]; ok {
+		return nil, errors.New("Build was halted.")
+	}
+
+	if b.config.Commit {
+		var imageId string
+		if err := common.StateBag(state).Values("image_id", &imageId); err != nil {
+			return nil, err
+		}
+
+		return &ImageArtifact{
+			id:     imageId,
+			driver: b.driver,
+		}, nil
+	}
+
+	return &ExportArtifact{path: b.config.ExportPath}, nil
+}
+
+func (b *Builder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
+
+func newDriver() (Driver, error) {
+	dockerPath, err := exec.LookPath("docker")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Docker path: %s", dockerPath)
+	driver := &DockerDriver{DockerPath: dockerPath}
+	if err := driver.Verify(); err != nil {
+		return nil, err
+	}
+
+	return driver, nil
+}
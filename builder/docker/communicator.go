@@ -0,0 +1,137 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"github.com/mitchellh/packer/packer"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Communicator is a packer.Communicator that runs commands in a running
+// container with "docker exec" and copies files with "docker cp".
+type Communicator struct {
+	// The ID of the container to communicate with.
+	ContainerId string
+
+	// The path to the "docker" binary, the same one the driver uses. If
+	// this is empty, "docker" is looked up on the PATH.
+	DockerPath string
+}
+
+func (c *Communicator) Start(remote *packer.RemoteCmd) error {
+	args := []string{"exec"}
+	if remote.Stdin != nil {
+		args = append(args, "-i")
+	}
+	args = append(args, c.ContainerId, "/bin/sh", "-c", remote.Command)
+
+	log.Printf("Executing in container %s: %s", c.ContainerId, remote.Command)
+	cmd := exec.Command(c.dockerPath(), args...)
+	cmd.Stdin = remote.Stdin
+	cmd.Stdout = remote.Stdout
+	cmd.Stderr = remote.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		exitStatus := 0
+		if err := cmd.Wait(); err != nil {
+			exitStatus = 1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+					exitStatus = status.ExitStatus()
+				}
+			}
+
+			log.Printf("Command in container exited with error: %s", err)
+		}
+
+		remote.ExitStatus = exitStatus
+		remote.Exited = true
+	}()
+
+	return nil
+}
+
+func (c *Communicator) Upload(dst string, r io.Reader) error {
+	// "docker cp" can only copy files, so the contents are written to a
+	// temporary file first. It is made readable by everyone since docker
+	// keeps the mode of the file, the same as a new file would get with
+	// the SSH communicator.
+	tf, err := ioutil.TempFile("", "packer-docker")
+	if err != nil {
+		return fmt.Errorf("Error creating temporary file for upload: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	_, err = io.Copy(tf, r)
+	tf.Close()
+	if err != nil {
+		return fmt.Errorf("Error writing temporary file for upload: %s", err)
+	}
+
+	if err := os.Chmod(tf.Name(), 0644); err != nil {
+		return fmt.Errorf("Error setting mode of upload: %s", err)
+	}
+
+	return c.docker(nil, "cp", tf.Name(), fmt.Sprintf("%s:%s", c.ContainerId, dst))
+}
+
+func (c *Communicator) Download(src string, w io.Writer) error {
+	// Copying to "-" writes a tarball of the path to stdout, which only
+	// contains the file itself for a file.
+	var buf bytes.Buffer
+	if err := c.docker(&buf, "cp", fmt.Sprintf("%s:%s", c.ContainerId, src), "-"); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(&buf)
+	hdr, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("Error reading download of %s: %s", src, err)
+	}
+
+	if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+		return fmt.Errorf("Can't download %s: not a regular file", src)
+	}
+
+	_, err = io.Copy(w, tr)
+	return err
+}
+
+// docker executes the given docker command, writing its output to the
+// given writer if there is one.
+func (c *Communicator) docker(stdout io.Writer, args ...string) error {
+	var stderr bytes.Buffer
+
+	log.Printf("Executing docker: %#v", args)
+	cmd := exec.Command(c.dockerPath(), args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("Docker error: %s", strings.TrimSpace(stderr.String()))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// dockerPath returns the path of the docker binary to run.
+func (c *Communicator) dockerPath() string {
+	if c.DockerPath == "" {
+		return "docker"
+	}
+
+	return c.DockerPath
+}
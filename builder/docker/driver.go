@@ -0,0 +1,114 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// A driver is able to talk to Docker and perform certain operations
+// with it.
+type Driver interface {
+	// Commit commits the container with the given ID as a new image,
+	// returning the ID of the image.
+	Commit(string) (string, error)
+
+	// DeleteImage deletes the image with the given ID.
+	DeleteImage(string) error
+
+	// Export exports the filesystem of the container with the given ID
+	// as a tarball, writing it to the given writer.
+	Export(string, io.Writer) error
+
+	// Pull pulls the given image from the registry.
+	Pull(string) error
+
+	// StartContainer starts a container from the given image that keeps
+	// running until it is stopped, returning the ID of the container.
+	StartContainer(string) (string, error)
+
+	// StopContainer forcefully stops and removes the container with the
+	// given ID.
+	StopContainer(string) error
+
+	// Verify checks to make sure that this driver should function
+	// properly. If there is any indication the driver can't function,
+	// this will return an error.
+	Verify() error
+}
+
+// The command the container is started with. It only has to keep the
+// container running so commands can be executed in it.
+var keepAliveCommand = []string{"/bin/sh", "-c", "while true; do sleep 1; done"}
+
+type DockerDriver struct {
+	// This is the path to the "docker" binary.
+	DockerPath string
+}
+
+func (d *DockerDriver) Commit(id string) (string, error) {
+	return d.docker(nil, "commit", id)
+}
+
+func (d *DockerDriver) DeleteImage(id string) error {
+	_, err := d.docker(nil, "rmi", id)
+	return err
+}
+
+func (d *DockerDriver) Export(id string, dst io.Writer) error {
+	_, err := d.docker(dst, "export", id)
+	return err
+}
+
+func (d *DockerDriver) Pull(image string) error {
+	_, err := d.docker(nil, "pull", image)
+	return err
+}
+
+func (d *DockerDriver) StartContainer(image string) (string, error) {
+	args := append([]string{"run", "-d", image}, keepAliveCommand...)
+	return d.docker(nil, args...)
+}
+
+func (d *DockerDriver) StopContainer(id string) error {
+	if _, err := d.docker(nil, "kill", id); err != nil {
+		return err
+	}
+
+	_, err := d.docker(nil, "rm", id)
+	return err
+}
+
+func (d *DockerDriver) Verify() error {
+	return nil
+}
+
+// docker executes the given docker command, returning its trimmed
+// output. If stdout is given, the output is written there instead.
+func (d *DockerDriver) docker(stdout io.Writer, args ...string) (string, error) {
+	var stdoutBuf, stderr bytes.Buffer
+
+	log.Printf("Executing docker: %#v", args)
+	cmd := exec.Command(d.DockerPath, args...)
+	cmd.Stdout = &stdoutBuf
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	stdoutString := strings.TrimSpace(stdoutBuf.String())
+	stderrString := strings.TrimSpace(stderr.String())
+
+	if _, ok := err.(*exec.ExitError); ok {
+		err = fmt.Errorf("Docker error: %s", stderrString)
+	}
+
+	log.Printf("stdout: %s", stdoutString)
+	log.Printf("stderr: %s", stderrString)
+
+	return stdoutString, err
+}
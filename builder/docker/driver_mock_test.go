@@ -0,0 +1,82 @@
+package docker
+
+import (
+	"io"
+)
+
+// driverMock is a Driver that records the calls made to it and returns
+// canned results, for testing steps without Docker.
+type driverMock struct {
+	CommitCalled bool
+	CommitId     string
+	CommitResult string
+	CommitErr    error
+
+	DeleteImageCalled bool
+	DeleteImageId     string
+	DeleteImageErr    error
+
+	ExportCalled bool
+	ExportId     string
+	ExportData   string
+	ExportErr    error
+
+	PullCalled bool
+	PullImage  string
+	PullErr    error
+
+	StartContainerCalled bool
+	StartContainerImage  string
+	StartContainerResult string
+	StartContainerErr    error
+
+	StopContainerCalled bool
+	StopContainerId     string
+	StopContainerErr    error
+}
+
+func (d *driverMock) Commit(id string) (string, error) {
+	d.CommitCalled = true
+	d.CommitId = id
+	return d.CommitResult, d.CommitErr
+}
+
+func (d *driverMock) DeleteImage(id string) error {
+	d.DeleteImageCalled = true
+	d.DeleteImageId = id
+	return d.DeleteImageErr
+}
+
+func (d *driverMock) Export(id string, dst io.Writer) error {
+	d.ExportCalled = true
+	d.ExportId = id
+
+	if d.ExportErr != nil {
+		return d.ExportErr
+	}
+
+	_, err := io.WriteString(dst, d.ExportData)
+	return err
+}
+
+func (d *driverMock) Pull(image string) error {
+	d.PullCalled = true
+	d.PullImage = image
+	return d.PullErr
+}
+
+func (d *driverMock) StartContainer(image string) (string, error) {
+	d.StartContainerCalled = true
+	d.StartContainerImage = image
+	return d.StartContainerResult, d.StartContainerErr
+}
+
+func (d *driverMock) StopContainer(id string) error {
+	d.StopContainerCalled = true
+	d.StopContainerId = id
+	return d.StopContainerErr
+}
+
+func (d *driverMock) Verify() error {
+	return nil
+}
@@ -0,0 +1,51 @@
+package docker
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+)
+
+// This step commits the container to a new image if "commit" is set.
+//
+// Uses:
+//   config *config
+//   container_id string
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   image_id string - The ID of the new image.
+type stepCommit struct{}
+
+func (s *stepCommit) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var containerId string
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "container_id", &containerId, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	if !config.Commit {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Committing the container")
+	imageId, err := driver.Commit(containerId)
+	if err != nil {
+		err := fmt.Errorf("Error committing container: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state["image_id"] = imageId
+	ui.Message(fmt.Sprintf("Image ID: %s", imageId))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCommit) Cleanup(map[string]interface{}) {}
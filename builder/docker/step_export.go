@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"os"
+)
+
+// This step exports the filesystem of the container to a tarball if
+// "export_path" is set.
+//
+// Uses:
+//   config *config
+//   container_id string
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   <nothing>
+type stepExport struct{}
+
+func (s *stepExport) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var containerId string
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "container_id", &containerId, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	if config.ExportPath == "" {
+		return multistep.ActionContinue
+	}
+
+	ui.Say(fmt.Sprintf("Exporting the container to: %s", config.ExportPath))
+	f, err := os.Create(config.ExportPath)
+	if err != nil {
+		err := fmt.Errorf("Error creating export file: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	err = driver.Export(containerId, f)
+	f.Close()
+	if err != nil {
+		os.Remove(config.ExportPath)
+
+		err := fmt.Errorf("Error exporting container: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepExport) Cleanup(map[string]interface{}) {}
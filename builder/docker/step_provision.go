@@ -0,0 +1,37 @@
+package docker
+
+import (
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+type stepProvision struct{}
+
+func (*stepProvision) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var containerId string
+	var driver Driver
+	var hook packer.Hook
+	var ui packer.Ui
+	if err := bag.Values("container_id", &containerId, "driver", &driver, "hook", &hook, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	// Run the same docker binary as the driver
+	comm := &Communicator{ContainerId: containerId}
+	if driver, ok := driver.(*DockerDriver); ok {
+		comm.DockerPath = driver.DockerPath
+	}
+
+	log.Println("Running the provision hook")
+	if err := hook.Run(packer.HookProvision, ui, comm, nil); err != nil {
+		state["error"] = err
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (*stepProvision) Cleanup(map[string]interface{}) {}
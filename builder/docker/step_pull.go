@@ -0,0 +1,42 @@
+package docker
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+)
+
+// This step pulls the base image so the container runs the latest
+// version of it.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   <nothing>
+type stepPull struct{}
+
+func (s *stepPull) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say(fmt.Sprintf("Pulling Docker image: %s", config.Image))
+	if err := driver.Pull(config.Image); err != nil {
+		err := fmt.Errorf("Error pulling Docker image: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepPull) Cleanup(map[string]interface{}) {}
@@ -0,0 +1,70 @@
+package docker
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step starts the container that is provisioned.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   container_id string - The ID of the container.
+type stepRunContainer struct {
+	containerId string
+}
+
+func (s *stepRunContainer) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say("Starting a Docker container...")
+	containerId, err := driver.StartContainer(config.Image)
+	if err != nil {
+		err := fmt.Errorf("Error running container: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Save the container ID
+	s.containerId = containerId
+	state["container_id"] = containerId
+	ui.Message(fmt.Sprintf("Container ID: %s", containerId))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRunContainer) Cleanup(state map[string]interface{}) {
+	if s.containerId == "" {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	// The container is only needed while building, so it is always
+	// removed, whether the build worked or not.
+	ui.Say("Stopping and removing the Docker container...")
+	if err := driver.StopContainer(s.containerId); err != nil {
+		ui.Error(fmt.Sprintf(
+			"Error removing container. Please remove it manually: %s (%s)", s.containerId, err))
+	}
+}
@@ -0,0 +1,118 @@
+package docker
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testState(t *testing.T) map[string]interface{} {
+	state := make(map[string]interface{})
+	state["config"] = &config{Image: "ubuntu"}
+	state["container_id"] = "foo"
+	state["driver"] = new(driverMock)
+	state["ui"] = &packer.ReaderWriterUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	return state
+}
+
+func TestStepRunContainer(t *testing.T) {
+	state := testState(t)
+	delete(state, "container_id")
+	driver := state["driver"].(*driverMock)
+	driver.StartContainerResult = "foo"
+
+	step := new(stepRunContainer)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.StartContainerImage != "ubuntu" {
+		t.Fatalf("bad: %s", driver.StartContainerImage)
+	}
+
+	if state["container_id"].(string) != "foo" {
+		t.Fatalf("bad: %#v", state["container_id"])
+	}
+
+	// The container is always removed
+	step.Cleanup(state)
+	if driver.StopContainerId != "foo" {
+		t.Fatalf("bad: %s", driver.StopContainerId)
+	}
+}
+
+func TestStepCommit(t *testing.T) {
+	state := testState(t)
+	driver := state["driver"].(*driverMock)
+	driver.CommitResult = "bar"
+
+	// Nothing to do without commit
+	step := new(stepCommit)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.CommitCalled {
+		t.Fatal("should not commit")
+	}
+
+	state["config"].(*config).Commit = true
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.CommitId != "foo" {
+		t.Fatalf("bad: %s", driver.CommitId)
+	}
+
+	if state["image_id"].(string) != "bar" {
+		t.Fatalf("bad: %#v", state["image_id"])
+	}
+}
+
+func TestStepExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	state := testState(t)
+	config := state["config"].(*config)
+	config.ExportPath = filepath.Join(dir, "image.tar")
+	driver := state["driver"].(*driverMock)
+	driver.ExportData = "data"
+
+	step := new(stepExport)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	data, err := ioutil.ReadFile(config.ExportPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(data) != "data" {
+		t.Fatalf("bad: %s", data)
+	}
+
+	// A failed export doesn't leave a partial file behind
+	os.Remove(config.ExportPath)
+	driver.ExportErr = errors.New("foo")
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, err := os.Stat(config.ExportPath); err == nil {
+		t.Fatal("export file should be removed")
+	}
+}
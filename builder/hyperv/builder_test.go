@@ -0,0 +1,115 @@
+package hyperv
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"testing"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"iso_checksum":      "foo",
+		"iso_checksum_type": "md5",
+		"iso_url":           "http://www.google.com/",
+		"ssh_username":      "foo",
+
+		packer.BuildNameConfigKey: "foo",
+	}
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Error("Builder must implement builder.")
+	}
+}
+
+func TestBuilderPrepare_Defaults(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.Generation != 1 {
+		t.Errorf("bad generation: %d", b.config.Generation)
+	}
+
+	if b.config.OutputDir != "output-foo" {
+		t.Errorf("bad output dir: %s", b.config.OutputDir)
+	}
+
+	if b.config.RAMSize != 1024 {
+		t.Errorf("bad ram size: %d", b.config.RAMSize)
+	}
+
+	if b.config.SwitchName != "" {
+		t.Errorf("bad switch name: %s", b.config.SwitchName)
+	}
+
+	if b.config.VMName != "packer-foo" {
+		t.Errorf("bad vm name: %s", b.config.VMName)
+	}
+}
+
+func TestBuilderPrepare_BootCommand(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test an unknown special key
+	config["boot_command"] = []string{"<esc><foo><enter>"}
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test good
+	config["boot_command"] = []string{"<esc><wait5><enter>"}
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_Generation(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test bad
+	config["generation"] = 3
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test good
+	config["generation"] = 2
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_ISOUrl(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test both set
+	config["iso_urls"] = []string{"http://www.packer.io"}
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test neither set
+	delete(config, "iso_url")
+	delete(config, "iso_urls")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,335 @@
+package hyperv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// A driver is able to talk to Hyper-V and perform certain operations
+// with it.
+type Driver interface {
+	// CreateVM creates a new VM with the given name, storing its files in
+	// the given directory. The VM gets a new hard disk of the given size
+	// in megabytes and is attached to the given virtual switch.
+	CreateVM(name, path string, ramSize, diskSize uint, switchName string, generation uint, dynamicMemory bool) error
+
+	// DeleteVM deletes the VM with the given name. The files of the VM
+	// aren't deleted.
+	DeleteVM(string) error
+
+	// ExportVM exports the VM with the given name into the given directory.
+	ExportVM(string, string) error
+
+	// ExternalSwitch returns the name of the first external virtual switch.
+	ExternalSwitch() (string, error)
+
+	// HostIP returns the IPv4 address of the host on the given virtual
+	// switch.
+	HostIP(string) (string, error)
+
+	// IpAddress returns the IPv4 address of the VM with the given name,
+	// as reported by its integration services.
+	IpAddress(string) (string, error)
+
+	// Checks if the VM with the given name is running.
+	IsRunning(string) (bool, error)
+
+	// ListVMs returns the names of all the VMs on the host.
+	ListVMs() ([]string, error)
+
+	// MountDvdDrive inserts the ISO at the given path into the DVD drive
+	// of the VM with the given name, and boots from it.
+	MountDvdDrive(string, string) error
+
+	// Start starts the VM with the given name.
+	Start(string) error
+
+	// Stop stops a running machine, forcefully.
+	Stop(string) error
+
+	// TypeScanCodes types the given keyboard scancodes into the VM with
+	// the given name.
+	TypeScanCodes(string, []string) error
+
+	// UnmountDvdDrive ejects the ISO from the DVD drive of the VM with the
+	// given name.
+	UnmountDvdDrive(string) error
+
+	// Verify checks to make sure that this driver should function
+	// properly. If there is any indication the driver can't function,
+	// this will return an error.
+	Verify() error
+}
+
+type HypervPS4Driver struct {
+	// This is the path to the "powershell.exe" application.
+	PowershellPath string
+}
+
+func (d *HypervPS4Driver) CreateVM(name, path string, ramSize, diskSize uint, switchName string, generation uint, dynamicMemory bool) error {
+	script := `
+param([string]$vmName, [string]$path, [long]$ramSize, [long]$diskSize, [string]$switchName, [int]$generation, [string]$dynamicMemory)
+$vhdPath = Join-Path $path "$vmName.vhdx"
+New-VM -Name $vmName -Path $path -MemoryStartupBytes ($ramSize * 1MB) -NewVHDPath $vhdPath -NewVHDSizeBytes ($diskSize * 1MB) -SwitchName $switchName -Generation $generation | Out-Null
+Set-VMMemory -VMName $vmName -DynamicMemoryEnabled ([System.Convert]::ToBoolean($dynamicMemory))
+`
+
+	return d.powershell(script, name, path,
+		strconv.FormatUint(uint64(ramSize), 10),
+		strconv.FormatUint(uint64(diskSize), 10),
+		switchName,
+		strconv.FormatUint(uint64(generation), 10),
+		strconv.FormatBool(dynamicMemory))
+}
+
+func (d *HypervPS4Driver) DeleteVM(name string) error {
+	script := `
+param([string]$vmName)
+Remove-VM -Name $vmName -Force
+`
+
+	return d.powershell(script, name)
+}
+
+func (d *HypervPS4Driver) ExportVM(name, path string) error {
+	script := `
+param([string]$vmName, [string]$path)
+Export-VM -Name $vmName -Path $path
+`
+
+	return d.powershell(script, name, path)
+}
+
+func (d *HypervPS4Driver) ExternalSwitch() (string, error) {
+	script := `
+$switch = Get-VMSwitch -SwitchType External | Select-Object -First 1
+if ($switch -eq $null) {
+  throw "No external virtual switch found"
+}
+$switch.Name
+`
+
+	return d.powershellOutput(script)
+}
+
+func (d *HypervPS4Driver) HostIP(switchName string) (string, error) {
+	script := `
+param([string]$switchName)
+$address = Get-NetIPAddress -InterfaceAlias "vEthernet ($switchName)" -AddressFamily IPv4 | Select-Object -First 1
+$address.IPAddress
+`
+
+	ip, err := d.powershellOutput(script, switchName)
+	if err == nil && ip == "" {
+		err = fmt.Errorf("No IP address found for virtual switch %s", switchName)
+	}
+
+	return ip, err
+}
+
+func (d *HypervPS4Driver) IpAddress(name string) (string, error) {
+	script := `
+param([string]$vmName)
+$addresses = (Get-VMNetworkAdapter -VMName $vmName).IPAddresses
+$addresses | Where-Object { $_ -match '^\d+\.\d+\.\d+\.\d+$' } | Select-Object -First 1
+`
+
+	ip, err := d.powershellOutput(script, name)
+	if err == nil && ip == "" {
+		err = errors.New("VM has no IP address yet")
+	}
+
+	return ip, err
+}
+
+func (d *HypervPS4Driver) IsRunning(name string) (bool, error) {
+	script := `
+param([string]$vmName)
+(Get-VM -Name $vmName).State -eq [Microsoft.HyperV.PowerShell.VMState]::Running
+`
+
+	stdout, err := d.powershellOutput(script, name)
+	if err != nil {
+		return false, err
+	}
+
+	return stdout == "True", nil
+}
+
+func (d *HypervPS4Driver) ListVMs() ([]string, error) {
+	script := `
+Get-VM | ForEach-Object { $_.Name }
+`
+
+	stdout, err := d.powershellOutput(script)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0)
+	for _, line := range strings.Split(stdout, "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			result = append(result, name)
+		}
+	}
+
+	return result, nil
+}
+
+func (d *HypervPS4Driver) MountDvdDrive(name, path string) error {
+	script := `
+param([string]$vmName, [string]$path)
+$drive = Get-VMDvdDrive -VMName $vmName | Select-Object -First 1
+if ($drive -eq $null) {
+  Add-VMDvdDrive -VMName $vmName -Path $path
+  $drive = Get-VMDvdDrive -VMName $vmName | Select-Object -First 1
+} else {
+  Set-VMDvdDrive -VMName $vmName -ControllerNumber $drive.ControllerNumber -ControllerLocation $drive.ControllerLocation -Path $path
+}
+if ((Get-VM -Name $vmName).Generation -eq 2) {
+  Set-VMFirmware -VMName $vmName -FirstBootDevice $drive
+}
+`
+
+	return d.powershell(script, name, path)
+}
+
+func (d *HypervPS4Driver) Start(name string) error {
+	script := `
+param([string]$vmName)
+Start-VM -Name $vmName
+`
+
+	return d.powershell(script, name)
+}
+
+func (d *HypervPS4Driver) Stop(name string) error {
+	script := `
+param([string]$vmName)
+Stop-VM -Name $vmName -TurnOff -Force
+`
+
+	return d.powershell(script, name)
+}
+
+func (d *HypervPS4Driver) TypeScanCodes(name string, codes []string) error {
+	script := `
+param([string]$vmName, [string]$scanCodes)
+$vm = Get-WmiObject -Namespace root\virtualization\v2 -Class Msvm_ComputerSystem | Where-Object { $_.ElementName -eq $vmName }
+$keyboard = $vm.GetRelated("Msvm_Keyboard") | Select-Object -First 1
+$codes = [byte[]]($scanCodes -split ' ' | ForEach-Object { [Convert]::ToByte($_, 16) })
+$result = $keyboard.TypeScancodes($codes)
+if ($result.ReturnValue -ne 0) {
+  throw "Typing scancodes failed with return value $($result.ReturnValue)"
+}
+`
+
+	return d.powershell(script, name, strings.Join(codes, " "))
+}
+
+func (d *HypervPS4Driver) UnmountDvdDrive(name string) error {
+	script := `
+param([string]$vmName)
+Get-VMDvdDrive -VMName $vmName | Set-VMDvdDrive -Path $null
+`
+
+	return d.powershell(script, name)
+}
+
+func (d *HypervPS4Driver) Verify() error {
+	script := `
+Get-Command New-VM | Out-Null
+`
+
+	if err := d.powershell(script); err != nil {
+		return fmt.Errorf("Hyper-V PowerShell module not found: %s", err)
+	}
+
+	return nil
+}
+
+func (d *HypervPS4Driver) powershell(script string, params ...string) error {
+	_, err := d.powershellOutput(script, params...)
+	return err
+}
+
+// powershellOutput runs the given script with PowerShell and returns its
+// output. The parameters are passed to the param block of the script as
+// separate arguments, rather than being interpolated into the script
+// itself, so that they never need to be quoted.
+func (d *HypervPS4Driver) powershellOutput(script string, params ...string) (string, error) {
+	f, err := ioutil.TempFile("", "packer-hyperv")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+
+	// PowerShell only runs files with the .ps1 extension
+	scriptPath := f.Name() + ".ps1"
+	if err := os.Rename(f.Name(), scriptPath); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	defer os.Remove(scriptPath)
+
+	// Make every error terminate the script, so that it exits with a
+	// non-zero status rather than carrying on. The param block has to
+	// come before any other statement, so this goes after it.
+	script = strings.TrimSpace(script)
+	preamble := ""
+	if i := strings.Index(script, "\n"); i >= 0 && strings.HasPrefix(script, "param(") {
+		preamble, script = script[:i+1], script[i+1:]
+	}
+	script = preamble + `$ErrorActionPreference = "Stop"` + "\n" + script + "\n"
+
+	if err := ioutil.WriteFile(scriptPath, []byte(script), 0600); err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	args := []string{"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File", scriptPath}
+	args = append(args, params...)
+
+	log.Printf("Executing PowerShell: %#v", args)
+	cmd := exec.Command(d.PowershellPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+
+	stdoutString := strings.TrimSpace(stdout.String())
+	stderrString := strings.TrimSpace(stderr.String())
+
+	if _, ok := err.(*exec.ExitError); ok {
+		err = fmt.Errorf("PowerShell error: %s", parsePowershellError(stderrString))
+	}
+
+	log.Printf("stdout: %s", stdoutString)
+	log.Printf("stderr: %s", stderrString)
+
+	return stdoutString, err
+}
+
+// parsePowershellError extracts the message from an error written by
+// PowerShell, leaving out the position and category details that follow
+// it on the lines starting with "At " and "+".
+func parsePowershellError(output string) string {
+	message := make([]string, 0)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "At ") || strings.HasPrefix(line, "+") {
+			continue
+		}
+
+		message = append(message, line)
+	}
+
+	return strings.Join(message, " ")
+}
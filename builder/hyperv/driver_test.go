@@ -0,0 +1,24 @@
+package hyperv
+
+import (
+	"testing"
+)
+
+func TestHypervPS4Driver_impl(t *testing.T) {
+	var _ Driver = new(HypervPS4Driver)
+}
+
+func TestParsePowershellError(t *testing.T) {
+	output := `New-VM : The operation failed because the file was not found.
+At C:\Users\packer\AppData\Local\Temp\packer-hyperv123.ps1:4 char:1
++ New-VM -Name $vmName -Path $path
++ ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+    + CategoryInfo          : ObjectNotFound: (:) [New-VM], VirtualizationOperationFailedException
+    + FullyQualifiedErrorId : ObjectNotFound,Microsoft.HyperV.PowerShell.Commands.NewVMCommand
+`
+
+	expected := "New-VM : The operation failed because the file was not found."
+	if actual := parsePowershellError(output); actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
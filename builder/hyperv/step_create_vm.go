@@ -0,0 +1,149 @@
+package hyperv
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// This step creates the actual virtual machine. The files of the VM are
+// kept in a temporary directory until it is exported.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   switchName string - The name of the virtual switch of the VM
+//   vmName string - The name of the VM
+type stepCreateVM struct {
+	tempDir string
+	vmName  string
+}
+
+func (s *stepCreateVM) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	switchName := config.SwitchName
+	if switchName == "" {
+		var err error
+		switchName, err = driver.ExternalSwitch()
+		if err != nil {
+			err := fmt.Errorf("Error finding a virtual switch: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	tempDir, err := ioutil.TempDir("", "packer-hyperv")
+	if err != nil {
+		err := fmt.Errorf("Error creating temporary directory: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.tempDir = tempDir
+
+	if config.PackerForce {
+		if err := deleteExistingVM(driver, ui, config.VMName); err != nil {
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	ui.Say("Creating virtual machine...")
+	err = driver.CreateVM(config.VMName, tempDir, config.RAMSize, config.DiskSize,
+		switchName, config.Generation, config.EnableDynamicMemory)
+	if err != nil {
+		err := fmt.Errorf("Error creating VM: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.vmName = config.VMName
+
+	// Set the final name in the state bag so others can use it
+	state["switchName"] = switchName
+	state["vmName"] = s.vmName
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateVM) Cleanup(state map[string]interface{}) {
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	if s.vmName != "" {
+		ui.Say("Deleting virtual machine...")
+		if err := driver.DeleteVM(s.vmName); err != nil {
+			ui.Error(fmt.Sprintf("Error deleting virtual machine: %s", err))
+		}
+	}
+
+	if s.tempDir != "" {
+		if err := os.RemoveAll(s.tempDir); err != nil {
+			ui.Error(fmt.Sprintf("Error removing temporary directory: %s", err))
+		}
+	}
+}
+
+// deleteExistingVM stops and deletes the VM with the given name if it
+// exists. Forced builds use this to replace a VM left behind by an earlier
+// build, such as one that failed with -on-error=abort. Hyper-V doesn't
+// delete the files of a VM along with it, so the temporary directory of
+// the earlier build is left as it was.
+func deleteExistingVM(driver Driver, ui packer.Ui, name string) error {
+	names, err := driver.ListVMs()
+	if err != nil {
+		return fmt.Errorf("Error listing existing virtual machines: %s", err)
+	}
+
+	exists := false
+	for _, existing := range names {
+		if existing == name {
+			exists = true
+			break
+		}
+	}
+
+	if !exists {
+		return nil
+	}
+
+	running, err := driver.IsRunning(name)
+	if err != nil {
+		return fmt.Errorf("Error checking if existing virtual machine is running: %s", err)
+	}
+
+	ui.Say(fmt.Sprintf("Deleting existing virtual machine, since the build is forced: %s", name))
+	if running {
+		if err := driver.Stop(name); err != nil {
+			return fmt.Errorf("Error stopping existing virtual machine: %s", err)
+		}
+	}
+
+	if err := driver.DeleteVM(name); err != nil {
+		return fmt.Errorf("Error deleting existing virtual machine: %s", err)
+	}
+
+	return nil
+}
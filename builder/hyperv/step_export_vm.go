@@ -0,0 +1,53 @@
+package hyperv
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"path/filepath"
+)
+
+// This step exports the virtual machine into the output directory.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//   vmName string
+//
+// Produces:
+//   <nothing>
+type stepExportVM struct{}
+
+func (s *stepExportVM) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	// Hyper-V resolves paths itself, so they have to be absolute
+	outputDir, err := filepath.Abs(config.OutputDir)
+	if err != nil {
+		err := fmt.Errorf("Error exporting VM: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Exporting virtual machine...")
+	if err := driver.ExportVM(vmName, outputDir); err != nil {
+		err := fmt.Errorf("Error exporting VM: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepExportVM) Cleanup(state map[string]interface{}) {}
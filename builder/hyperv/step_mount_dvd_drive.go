@@ -0,0 +1,53 @@
+package hyperv
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"path/filepath"
+)
+
+// This step inserts the ISO into the DVD drive of the virtual machine and
+// makes the VM boot from it.
+//
+// Uses:
+//   driver Driver
+//   iso_path string
+//   ui     packer.Ui
+//   vmName string
+//
+// Produces:
+type stepMountDvdDrive struct{}
+
+func (s *stepMountDvdDrive) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var driver Driver
+	var isoPath string
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("driver", &driver, "iso_path", &isoPath, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	// Hyper-V resolves paths itself, so they have to be absolute
+	isoPath, err := filepath.Abs(isoPath)
+	if err != nil {
+		err := fmt.Errorf("Error mounting ISO: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Mounting ISO in the DVD drive...")
+	if err := driver.MountDvdDrive(vmName, isoPath); err != nil {
+		err := fmt.Errorf("Error mounting ISO: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepMountDvdDrive) Cleanup(state map[string]interface{}) {}
@@ -0,0 +1,30 @@
+package hyperv
+
+import (
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+type stepProvision struct{}
+
+func (*stepProvision) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var comm packer.Communicator
+	var hook packer.Hook
+	var ui packer.Ui
+	if err := bag.Values("communicator", &comm, "hook", &hook, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	log.Println("Running the provision hook")
+	if err := hook.Run(packer.HookProvision, ui, comm, nil); err != nil {
+		state["error"] = err
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (*stepProvision) Cleanup(map[string]interface{}) {}
@@ -0,0 +1,72 @@
+package hyperv
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"time"
+)
+
+// This step starts the virtual machine.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//   vmName string
+//
+// Produces:
+//   <nothing>
+type stepRun struct {
+	vmName string
+}
+
+func (s *stepRun) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say("Starting the virtual machine...")
+	if err := driver.Start(vmName); err != nil {
+		err := fmt.Errorf("Error starting VM: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.vmName = vmName
+
+	if int64(config.BootWait) > 0 {
+		ui.Say(fmt.Sprintf("Waiting %s for boot...", config.BootWait))
+		time.Sleep(config.BootWait)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRun) Cleanup(state map[string]interface{}) {
+	if s.vmName == "" {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	if running, _ := driver.IsRunning(s.vmName); running {
+		if err := driver.Stop(s.vmName); err != nil {
+			ui.Error(fmt.Sprintf("Error stopping VM: %s", err))
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package hyperv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step shuts down the machine. It first attempts to do so gracefully,
+// but ultimately forcefully shuts it down if that fails.
+//
+// Uses:
+//   communicator packer.Communicator
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//   vmName string
+//
+// Produces:
+//   <nothing>
+type stepShutdown struct{}
+
+func (s *stepShutdown) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var comm packer.Communicator
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("communicator", &comm, "config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	isRunning := func() (bool, error) {
+		return driver.IsRunning(vmName)
+	}
+
+	if config.ShutdownCommand != "" {
+		ui.Say("Gracefully halting virtual machine...")
+		log.Printf("Executing shutdown command: %s", config.ShutdownCommand)
+		cmd := &packer.RemoteCmd{Command: config.ShutdownCommand}
+		if err := comm.Start(cmd); err != nil {
+			err := fmt.Errorf("Failed to send shutdown command: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		// Wait for the machine to actually shut down
+		log.Printf("Waiting max %s for shutdown to complete", config.ShutdownTimeout)
+		if !common.WaitForShutdown(isRunning, config.ShutdownTimeout) {
+			err := errors.New("Timeout while waiting for machine to shut down.")
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	} else {
+		ui.Message("WARNING: No shutdown_command is set. Forcefully stopping\n" +
+			"the VM, which may leave the disk in a dirty state.")
+		if err := driver.Stop(vmName); err != nil {
+			err := fmt.Errorf("Error stopping VM: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	log.Println("VM shut down.")
+	return multistep.ActionContinue
+}
+
+func (s *stepShutdown) Cleanup(state map[string]interface{}) {}
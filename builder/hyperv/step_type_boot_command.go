@@ -0,0 +1,111 @@
+package hyperv
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"text/template"
+	"time"
+)
+
+type bootCommandTemplateData struct {
+	HTTPIP   string
+	HTTPPort uint
+	Name     string
+}
+
+// This step "types" the boot command into the VM through the keyboard of
+// the VM in the Hyper-V WMI interface.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   http_port int
+//   switchName string
+//   ui     packer.Ui
+//   vmName string
+//
+// Produces:
+//   <nothing>
+type stepTypeBootCommand struct{}
+
+func (s *stepTypeBootCommand) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var httpPort uint
+	var switchName string
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "http_port", &httpPort, "switchName", &switchName, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	// The guest reaches the HTTP server through the address of the host
+	// on the virtual switch it's connected to.
+	hostIP, err := driver.HostIP(switchName)
+	if err != nil {
+		err := fmt.Errorf("Error finding the host IP address: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	tplData := &bootCommandTemplateData{
+		hostIP,
+		httpPort,
+		config.VMName,
+	}
+
+	ui.Say("Typing the boot command...")
+	for _, command := range config.BootCommand {
+		var buf bytes.Buffer
+		t := template.Must(template.New("boot").Parse(command))
+		t.Execute(&buf, tplData)
+
+		// Running PowerShell is slow, so the scancodes in between the
+		// waits are all typed at once. The extra zero length wait at the
+		// end types whatever is left.
+		codes := make([]string, 0)
+		for _, code := range append(common.Scancodes(buf.String()), "wait0") {
+			var wait time.Duration
+			switch code {
+			case "wait":
+				wait = 1 * time.Second
+			case "wait5":
+				wait = 5 * time.Second
+			case "wait10":
+				wait = 10 * time.Second
+			case "wait0":
+			default:
+				codes = append(codes, code)
+				continue
+			}
+
+			// Since typing is sometimes so slow, we check for an interrupt
+			// in between each batch of keys.
+			if _, ok := state[multistep.StateCancelled]; ok {
+				return multistep.ActionHalt
+			}
+
+			if len(codes) > 0 {
+				if err := driver.TypeScanCodes(vmName, codes); err != nil {
+					err := fmt.Errorf("Error sending boot command: %s", err)
+					state["error"] = err
+					ui.Error(err.Error())
+					return multistep.ActionHalt
+				}
+
+				codes = make([]string, 0)
+			}
+
+			time.Sleep(wait)
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (*stepTypeBootCommand) Cleanup(map[string]interface{}) {}
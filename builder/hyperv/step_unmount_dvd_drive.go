@@ -0,0 +1,41 @@
+package hyperv
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+)
+
+// This step ejects the ISO from the DVD drive of the virtual machine, so
+// that the exported VM doesn't refer to it.
+//
+// Uses:
+//   driver Driver
+//   ui     packer.Ui
+//   vmName string
+//
+// Produces:
+type stepUnmountDvdDrive struct{}
+
+func (s *stepUnmountDvdDrive) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say("Unmounting ISO from the DVD drive...")
+	if err := driver.UnmountDvdDrive(vmName); err != nil {
+		err := fmt.Errorf("Error unmounting ISO: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepUnmountDvdDrive) Cleanup(state map[string]interface{}) {}
@@ -0,0 +1,57 @@
+package lxc
+
+import (
+	"fmt"
+	"github.com/mitchellh/packer/packer"
+	"os"
+	"path/filepath"
+)
+
+// Artifact is the result of running the LXC builder, namely the tarball
+// of the root filesystem of the container and its LXC config.
+type Artifact struct {
+	dir string
+	f   []string
+}
+
+// NewArtifact returns an LXC artifact containing the files in the given
+// directory.
+func NewArtifact(dir string) (packer.Artifact, error) {
+	files := make([]string, 0, 5)
+	visit := func(path string, info os.FileInfo, err error) error {
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+
+		return err
+	}
+
+	if err := filepath.Walk(dir, visit); err != nil {
+		return nil, err
+	}
+
+	return &Artifact{
+		dir: dir,
+		f:   files,
+	}, nil
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Files() []string {
+	return a.f
+}
+
+func (*Artifact) Id() string {
+	return "Container"
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("Container files in directory: %s", a.dir)
+}
+
+func (a *Artifact) Destroy() error {
+	return os.RemoveAll(a.dir)
+}
@@ -0,0 +1,14 @@
+package lxc
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"testing"
+)
+
+func TestArtifact_Impl(t *testing.T) {
+	var _ packer.Artifact = new(Artifact)
+}
+
+func TestCommunicator_Impl(t *testing.T) {
+	var _ packer.Communicator = new(Communicator)
+}
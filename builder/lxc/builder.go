@@ -0,0 +1,184 @@
+// The lxc package contains a packer.Builder implementation that builds
+// LXC system containers from a template and exports their filesystem.
+
+package lxc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const BuilderId = "mitchellh.lxc"
+
+// The directory LXC keeps its containers in.
+const defaultLXCPath = "/var/lib/lxc"
+
+// geteuid returns the effective user ID of Packer. It is a variable so
+// tests can pretend to run as another user.
+var geteuid = os.Geteuid
+
+type Builder struct {
+	config config
+	driver Driver
+	runner multistep.Runner
+}
+
+type config struct {
+	ConfigFile         string   `mapstructure:"config_file"`
+	ContainerName      string   `mapstructure:"container_name"`
+	OutputDir          string   `mapstructure:"output_directory"`
+	TemplateName       string   `mapstructure:"template_name"`
+	TemplateParameters []string `mapstructure:"template_parameters"`
+
+	PackerBuildName string `mapstructure:"packer_build_name"`
+	PackerDebug     bool   `mapstructure:"packer_debug"`
+	PackerForce     bool   `mapstructure:"packer_force"`
+	PackerOnError   string `mapstructure:"packer_on_error"`
+
+	RawInitTimeout string `mapstructure:"init_timeout"`
+
+	initTimeout time.Duration
+}
+
+func (b *Builder) Prepare(raws ...interface{}) error {
+	var err error
+
+	for _, raw := range raws {
+		err := mapstructure.Decode(raw, &b.config)
+		if err != nil {
+			return err
+		}
+	}
+
+	if b.config.ContainerName == "" {
+		b.config.ContainerName = fmt.Sprintf("packer-%s", b.config.PackerBuildName)
+	}
+
+	if b.config.OutputDir == "" {
+		b.config.OutputDir = fmt.Sprintf("output-%s", b.config.PackerBuildName)
+	}
+
+	if b.config.RawInitTimeout == "" {
+		b.config.RawInitTimeout = "20s"
+	}
+
+	errs := make([]error, 0)
+
+	// Creating and attaching to system containers needs root, and it is
+	// better to say so now than to fail halfway through a build.
+	if geteuid() != 0 {
+		errs = append(errs, errors.New("The lxc builder must be run as root."))
+	}
+
+	if b.config.ConfigFile == "" {
+		errs = append(errs, errors.New("A config_file must be specified."))
+	} else if _, err := os.Stat(b.config.ConfigFile); err != nil {
+		errs = append(errs, fmt.Errorf("config_file is invalid: %s", err))
+	}
+
+	if b.config.TemplateName == "" {
+		errs = append(errs, errors.New("A template_name must be specified."))
+	}
+
+	if _, err := os.Stat(b.config.OutputDir); err == nil && !b.config.PackerForce {
+		errs = append(errs, errors.New("Output directory already exists. It must not exist, unless the build is forced."))
+	}
+
+	b.config.initTimeout, err = time.ParseDuration(b.config.RawInitTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing init_timeout: %s", err))
+	}
+
+	b.driver, err = newDriver()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed creating LXC driver: %s", err))
+	}
+
+	if len(errs) > 0 {
+		return &packer.MultiError{errs}
+	}
+
+	return nil
+}
+
+func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	steps := []multistep.Step{
+		&common.StepPrepareOutputDir{
+			Dir:   b.config.OutputDir,
+			Force: b.config.PackerForce,
+		},
+		new(stepCreateContainer),
+		new(stepStartContainer),
+		new(stepProvision),
+		new(stepStopContainer),
+		new(stepExport),
+	}
+
+	// Setup the state bag
+	state := make(map[string]interface{})
+	state["cache"] = cache
+	state["config"] = &b.config
+	state["driver"] = b.driver
+	state["hook"] = hook
+	state["ui"] = ui
+
+	// Run
+	b.runner = common.NewRunner(steps, b.config.PackerDebug, b.config.PackerOnError, ui)
+
+	b.runner.Run(state)
+	common.ReportStepTimings(ui, state)
+
+	// If there was an error, return that
+	if rawErr, ok := state["error"]; ok {
+		return nil, rawErr.(error)
+	}
+
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state[multistep.StateCancelled]; ok {
+		return nil, errors.New("Build was cancelled.")
+	}
+
+	if _, ok := state[multistep.StateHalted]; ok {
+		return nil, errors.New("Build was halted.")
+	}
+
+	return NewArtifact(b.config.OutputDir)
+}
+
+func (b *Builder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
+
+func newDriver() (Driver, error) {
+	driver := &LXCDriver{LXCPath: defaultLXCPath}
+
+	// LXC is a set of commands rather than a single binary, so each one
+	// that is used has to be found, along with tar for the export.
+	commands := []string{
+		"lxc-attach", "lxc-create", "lxc-destroy",
+		"lxc-start", "lxc-stop", "lxc-wait", "tar",
+	}
+
+	for _, name := range commands {
+		if _, err := exec.LookPath(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := driver.Verify(); err != nil {
+		return nil, err
+	}
+
+	return driver, nil
+}
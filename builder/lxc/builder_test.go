@@ -0,0 +1,171 @@
+package lxc
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func testConfig(t *testing.T) map[string]interface{} {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tf.Close()
+
+	return map[string]interface{}{
+		"config_file":   tf.Name(),
+		"template_name": "ubuntu",
+	}
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Error("Builder must implement builder.")
+	}
+}
+
+func TestBuilderPrepare_Defaults(t *testing.T) {
+	var b Builder
+	config := testConfig(t)
+	defer os.Remove(config["config_file"].(string))
+	config["packer_build_name"] = "foo"
+
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.ContainerName != "packer-foo" {
+		t.Errorf("bad container name: %s", b.config.ContainerName)
+	}
+
+	if b.config.OutputDir != "output-foo" {
+		t.Errorf("bad output dir: %s", b.config.OutputDir)
+	}
+
+	if b.config.initTimeout.String() != "20s" {
+		t.Errorf("bad init timeout: %s", b.config.initTimeout)
+	}
+}
+
+func TestBuilderPrepare_ConfigFile(t *testing.T) {
+	var b Builder
+	config := testConfig(t)
+	defer os.Remove(config["config_file"].(string))
+
+	// Test good
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test missing
+	delete(config, "config_file")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test nonexistent
+	config["config_file"] = "/i/dont/exist"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_InitTimeout(t *testing.T) {
+	var b Builder
+	config := testConfig(t)
+	defer os.Remove(config["config_file"].(string))
+
+	// Test good
+	config["init_timeout"] = "1m"
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test bad
+	config["init_timeout"] = "bad"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_NonRoot(t *testing.T) {
+	defer func(f func() int) { geteuid = f }(geteuid)
+	geteuid = func() int { return 1000 }
+
+	var b Builder
+	config := testConfig(t)
+	defer os.Remove(config["config_file"].(string))
+
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_OutputDir(t *testing.T) {
+	var b Builder
+	config := testConfig(t)
+	defer os.Remove(config["config_file"].(string))
+
+	// Test with existing dir
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config["output_directory"] = dir
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test with a good one
+	config["output_directory"] = "i-hope-i-dont-exist"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_TemplateName(t *testing.T) {
+	var b Builder
+	config := testConfig(t)
+	defer os.Remove(config["config_file"].(string))
+
+	delete(config, "template_name")
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_TemplateParameters(t *testing.T) {
+	var b Builder
+	config := testConfig(t)
+	defer os.Remove(config["config_file"].(string))
+
+	config["template_parameters"] = []string{"-r", "precise"}
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if len(b.config.TemplateParameters) != 2 || b.config.TemplateParameters[1] != "precise" {
+		t.Fatalf("bad: %#v", b.config.TemplateParameters)
+	}
+}
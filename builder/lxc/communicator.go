@@ -0,0 +1,89 @@
+package lxc
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mitchellh/packer/packer"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Communicator is a packer.Communicator that runs commands in a running
+// container with "lxc-attach". Files are copied by running "cat" in the
+// container with its input or output redirected.
+type Communicator struct {
+	// The name of the container to communicate with.
+	ContainerName string
+}
+
+func (c *Communicator) Start(remote *packer.RemoteCmd) error {
+	log.Printf("Executing in container %s: %s", c.ContainerName, remote.Command)
+	cmd := c.command("/bin/sh", "-c", remote.Command)
+	cmd.Stdin = remote.Stdin
+	cmd.Stdout = remote.Stdout
+	cmd.Stderr = remote.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		exitStatus := 0
+		if err := cmd.Wait(); err != nil {
+			exitStatus = 1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+					exitStatus = status.ExitStatus()
+				}
+			}
+
+			log.Printf("Command in container exited with error: %s", err)
+		}
+
+		remote.ExitStatus = exitStatus
+		remote.Exited = true
+	}()
+
+	return nil
+}
+
+func (c *Communicator) Upload(dst string, r io.Reader) error {
+	// The destination is passed as an argument to the shell rather than
+	// in the command so it never has to be quoted.
+	log.Printf("Uploading to container %s: %s", c.ContainerName, dst)
+	return c.run(r, nil, "/bin/sh", "-c", `cat > "$0"`, dst)
+}
+
+func (c *Communicator) Download(src string, w io.Writer) error {
+	log.Printf("Downloading from container %s: %s", c.ContainerName, src)
+	return c.run(nil, w, "/bin/cat", src)
+}
+
+// command returns a command that runs the given program in the
+// container.
+func (c *Communicator) command(args ...string) *exec.Cmd {
+	args = append([]string{"-n", c.ContainerName, "--"}, args...)
+	return exec.Command("lxc-attach", args...)
+}
+
+// run runs the given program in the container, connecting its input and
+// output to the given reader and writer.
+func (c *Communicator) run(stdin io.Reader, stdout io.Writer, args ...string) error {
+	var stderr bytes.Buffer
+
+	cmd := c.command(args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("lxc-attach error: %s", strings.TrimSpace(stderr.String()))
+		}
+
+		return err
+	}
+
+	return nil
+}
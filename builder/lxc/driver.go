@@ -0,0 +1,118 @@
+package lxc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A driver is able to talk to LXC and perform certain operations with
+// it.
+type Driver interface {
+	// Create creates a container with the given name from an LXC
+	// template, using the given config file and passing the parameters
+	// to the template.
+	Create(name string, template string, configFile string, params []string) error
+
+	// Destroy destroys the container with the given name, stopping it
+	// first if it is running.
+	Destroy(string) error
+
+	// Export writes a gzipped tarball of the root filesystem of the
+	// stopped container with the given name to the writer.
+	Export(string, io.Writer) error
+
+	// ContainerDir returns the directory LXC keeps the container with
+	// the given name in, which holds its config and root filesystem.
+	ContainerDir(string) string
+
+	// Start starts the container with the given name in the background,
+	// waiting up to the given timeout for it to be running.
+	Start(string, time.Duration) error
+
+	// Stop stops the container with the given name.
+	Stop(string) error
+
+	// Verify checks to make sure that this driver should function
+	// properly. If there is any indication the driver can't function,
+	// this will return an error.
+	Verify() error
+}
+
+type LXCDriver struct {
+	// This is the directory LXC keeps its containers in.
+	LXCPath string
+}
+
+func (d *LXCDriver) Create(name string, template string, configFile string, params []string) error {
+	args := []string{"-n", name, "-t", template, "-f", configFile}
+	if len(params) > 0 {
+		args = append(args, "--")
+		args = append(args, params...)
+	}
+
+	return d.lxc(nil, "lxc-create", args...)
+}
+
+func (d *LXCDriver) ContainerDir(name string) string {
+	return filepath.Join(d.LXCPath, name)
+}
+
+func (d *LXCDriver) Destroy(name string) error {
+	return d.lxc(nil, "lxc-destroy", "-f", "-n", name)
+}
+
+func (d *LXCDriver) Export(name string, dst io.Writer) error {
+	// Numeric owners are kept so the files belong to the same users when
+	// the filesystem is unpacked on another host.
+	return d.lxc(dst, "tar", "-C", d.ContainerDir(name), "--numeric-owner", "-czf", "-", "rootfs")
+}
+
+func (d *LXCDriver) Start(name string, timeout time.Duration) error {
+	if err := d.lxc(nil, "lxc-start", "-d", "-n", name); err != nil {
+		return err
+	}
+
+	seconds := strconv.Itoa(int(timeout.Seconds()))
+	return d.lxc(nil, "lxc-wait", "-n", name, "-s", "RUNNING", "-t", seconds)
+}
+
+func (d *LXCDriver) Stop(name string) error {
+	return d.lxc(nil, "lxc-stop", "-n", name)
+}
+
+func (d *LXCDriver) Verify() error {
+	return nil
+}
+
+// lxc executes the given LXC command. If stdout is given, the output
+// of the command is written there.
+func (d *LXCDriver) lxc(stdout io.Writer, command string, args ...string) error {
+	var stdoutBuf, stderr bytes.Buffer
+
+	log.Printf("Executing %s: %#v", command, args)
+	cmd := exec.Command(command, args...)
+	cmd.Stdout = &stdoutBuf
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	stderrString := strings.TrimSpace(stderr.String())
+
+	if _, ok := err.(*exec.ExitError); ok {
+		err = fmt.Errorf("%s error: %s", command, stderrString)
+	}
+
+	log.Printf("stdout: %s", strings.TrimSpace(stdoutBuf.String()))
+	log.Printf("stderr: %s", stderrString)
+
+	return err
+}
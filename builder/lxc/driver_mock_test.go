@@ -0,0 +1,86 @@
+package lxc
+
+import (
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// driverMock is a Driver that records the calls made to it and returns
+// canned results, for testing steps without LXC.
+type driverMock struct {
+	CreateCalled     bool
+	CreateName       string
+	CreateTemplate   string
+	CreateConfigFile string
+	CreateParams     []string
+	CreateErr        error
+
+	DestroyCalled bool
+	DestroyName   string
+	DestroyErr    error
+
+	ExportCalled bool
+	ExportName   string
+	ExportData   string
+	ExportErr    error
+
+	LXCPath string
+
+	StartCalled  bool
+	StartName    string
+	StartTimeout time.Duration
+	StartErr     error
+
+	StopCalled bool
+	StopName   string
+	StopErr    error
+}
+
+func (d *driverMock) Create(name string, template string, configFile string, params []string) error {
+	d.CreateCalled = true
+	d.CreateName = name
+	d.CreateTemplate = template
+	d.CreateConfigFile = configFile
+	d.CreateParams = params
+	return d.CreateErr
+}
+
+func (d *driverMock) ContainerDir(name string) string {
+	return filepath.Join(d.LXCPath, name)
+}
+
+func (d *driverMock) Destroy(name string) error {
+	d.DestroyCalled = true
+	d.DestroyName = name
+	return d.DestroyErr
+}
+
+func (d *driverMock) Export(name string, dst io.Writer) error {
+	d.ExportCalled = true
+	d.ExportName = name
+
+	if d.ExportErr != nil {
+		return d.ExportErr
+	}
+
+	_, err := io.WriteString(dst, d.ExportData)
+	return err
+}
+
+func (d *driverMock) Start(name string, timeout time.Duration) error {
+	d.StartCalled = true
+	d.StartName = name
+	d.StartTimeout = timeout
+	return d.StartErr
+}
+
+func (d *driverMock) Stop(name string) error {
+	d.StopCalled = true
+	d.StopName = name
+	return d.StopErr
+}
+
+func (d *driverMock) Verify() error {
+	return nil
+}
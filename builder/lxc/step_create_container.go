@@ -0,0 +1,69 @@
+package lxc
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step creates the container from the LXC template.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   container_name string - The name of the container.
+type stepCreateContainer struct {
+	containerName string
+}
+
+func (s *stepCreateContainer) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	name := config.ContainerName
+	ui.Say(fmt.Sprintf("Creating container from template: %s", config.TemplateName))
+	err := driver.Create(name, config.TemplateName, config.ConfigFile, config.TemplateParameters)
+	if err != nil {
+		err := fmt.Errorf("Error creating container: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.containerName = name
+	state["container_name"] = name
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateContainer) Cleanup(state map[string]interface{}) {
+	if s.containerName == "" {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	// The container is only needed while building, since the artifact
+	// is a copy of it, so it is always destroyed.
+	ui.Say("Destroying the container...")
+	if err := driver.Destroy(s.containerName); err != nil {
+		ui.Error(fmt.Sprintf(
+			"Error destroying container. Please destroy it manually: %s (%s)", s.containerName, err))
+	}
+}
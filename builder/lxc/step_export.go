@@ -0,0 +1,83 @@
+package lxc
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// This step exports the root filesystem of the container as a tarball,
+// along with its LXC config, into the output directory.
+//
+// Uses:
+//   config *config
+//   container_name string
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   <nothing>
+type stepExport struct{}
+
+func (s *stepExport) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var name string
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "container_name", &name, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say("Exporting the container...")
+	rootfsPath := filepath.Join(config.OutputDir, "rootfs.tar.gz")
+	f, err := os.Create(rootfsPath)
+	if err != nil {
+		err := fmt.Errorf("Error creating export file: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	err = driver.Export(name, f)
+	f.Close()
+	if err != nil {
+		err := fmt.Errorf("Error exporting container: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	configPath := filepath.Join(driver.ContainerDir(name), "config")
+	if err := copyFile(filepath.Join(config.OutputDir, "lxc-config"), configPath); err != nil {
+		err := fmt.Errorf("Error copying container config: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepExport) Cleanup(map[string]interface{}) {}
+
+func copyFile(dst, src string) error {
+	srcF, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcF.Close()
+
+	dstF, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstF.Close()
+
+	_, err = io.Copy(dstF, srcF)
+	return err
+}
@@ -0,0 +1,32 @@
+package lxc
+
+import (
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+type stepProvision struct{}
+
+func (*stepProvision) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var name string
+	var hook packer.Hook
+	var ui packer.Ui
+	if err := bag.Values("container_name", &name, "hook", &hook, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	comm := &Communicator{ContainerName: name}
+
+	log.Println("Running the provision hook")
+	if err := hook.Run(packer.HookProvision, ui, comm, nil); err != nil {
+		state["error"] = err
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (*stepProvision) Cleanup(map[string]interface{}) {}
@@ -0,0 +1,43 @@
+package lxc
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+)
+
+// This step starts the container and waits for it to be running.
+//
+// Uses:
+//   config *config
+//   container_name string
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   <nothing>
+type stepStartContainer struct{}
+
+func (s *stepStartContainer) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var name string
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "container_name", &name, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say("Starting the container...")
+	if err := driver.Start(name, config.initTimeout); err != nil {
+		err := fmt.Errorf("Error starting container: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepStartContainer) Cleanup(map[string]interface{}) {}
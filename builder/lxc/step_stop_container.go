@@ -0,0 +1,42 @@
+package lxc
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+)
+
+// This step stops the container so its filesystem is no longer changing
+// when it is exported.
+//
+// Uses:
+//   container_name string
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   <nothing>
+type stepStopContainer struct{}
+
+func (s *stepStopContainer) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var name string
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("container_name", &name, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say("Stopping the container...")
+	if err := driver.Stop(name); err != nil {
+		err := fmt.Errorf("Error stopping container: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepStopContainer) Cleanup(map[string]interface{}) {}
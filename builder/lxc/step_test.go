@@ -0,0 +1,178 @@
+package lxc
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testState(t *testing.T) map[string]interface{} {
+	state := make(map[string]interface{})
+	state["config"] = &config{
+		ConfigFile:         "lxc.conf",
+		ContainerName:      "foo",
+		TemplateName:       "ubuntu",
+		TemplateParameters: []string{"-r", "precise"},
+		initTimeout:        20 * time.Second,
+	}
+	state["container_name"] = "foo"
+	state["driver"] = new(driverMock)
+	state["ui"] = &packer.ReaderWriterUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	return state
+}
+
+func TestStepCreateContainer(t *testing.T) {
+	state := testState(t)
+	delete(state, "container_name")
+	driver := state["driver"].(*driverMock)
+
+	step := new(stepCreateContainer)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.CreateName != "foo" {
+		t.Fatalf("bad: %s", driver.CreateName)
+	}
+
+	if driver.CreateTemplate != "ubuntu" {
+		t.Fatalf("bad: %s", driver.CreateTemplate)
+	}
+
+	if driver.CreateConfigFile != "lxc.conf" {
+		t.Fatalf("bad: %s", driver.CreateConfigFile)
+	}
+
+	if len(driver.CreateParams) != 2 {
+		t.Fatalf("bad: %#v", driver.CreateParams)
+	}
+
+	if state["container_name"].(string) != "foo" {
+		t.Fatalf("bad: %#v", state["container_name"])
+	}
+
+	// The container is always destroyed
+	step.Cleanup(state)
+	if driver.DestroyName != "foo" {
+		t.Fatalf("bad: %s", driver.DestroyName)
+	}
+}
+
+func TestStepCreateContainer_Error(t *testing.T) {
+	state := testState(t)
+	delete(state, "container_name")
+	driver := state["driver"].(*driverMock)
+	driver.CreateErr = errors.New("foo")
+
+	step := new(stepCreateContainer)
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state["error"]; !ok {
+		t.Fatal("should have error")
+	}
+
+	// Nothing was created, so nothing is destroyed
+	step.Cleanup(state)
+	if driver.DestroyCalled {
+		t.Fatal("should not destroy")
+	}
+}
+
+func TestStepStartContainer(t *testing.T) {
+	state := testState(t)
+	driver := state["driver"].(*driverMock)
+
+	step := new(stepStartContainer)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.StartName != "foo" {
+		t.Fatalf("bad: %s", driver.StartName)
+	}
+
+	if driver.StartTimeout != 20*time.Second {
+		t.Fatalf("bad: %s", driver.StartTimeout)
+	}
+}
+
+func TestStepStopContainer(t *testing.T) {
+	state := testState(t)
+	driver := state["driver"].(*driverMock)
+
+	step := new(stepStopContainer)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.StopName != "foo" {
+		t.Fatalf("bad: %s", driver.StopName)
+	}
+}
+
+func TestStepExport(t *testing.T) {
+	lxcPath, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(lxcPath)
+
+	if err := os.Mkdir(filepath.Join(lxcPath, "foo"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(lxcPath, "foo", "config"), []byte("config"), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	outputDir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	state := testState(t)
+	state["config"].(*config).OutputDir = outputDir
+	driver := state["driver"].(*driverMock)
+	driver.ExportData = "data"
+	driver.LXCPath = lxcPath
+
+	step := new(stepExport)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.ExportName != "foo" {
+		t.Fatalf("bad: %s", driver.ExportName)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(outputDir, "rootfs.tar.gz"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(data) != "data" {
+		t.Fatalf("bad: %s", data)
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(outputDir, "lxc-config"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(data) != "config" {
+		t.Fatalf("bad: %s", data)
+	}
+}
@@ -0,0 +1,151 @@
+// The null package contains a packer.Builder implementation that doesn't
+// create anything. It connects to an existing machine over SSH so that
+// the provisioners can be run against it.
+
+package null
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"time"
+)
+
+// The unique ID for this builder
+const BuilderId = "mitchellh.null"
+
+type config struct {
+	Host              string        `mapstructure:"host"`
+	Port              uint          `mapstructure:"port"`
+	SSHUsername       string        `mapstructure:"ssh_username"`
+	SSHPassword       string        `mapstructure:"ssh_password"`
+	SSHPrivateKeyFile string        `mapstructure:"ssh_private_key_file"`
+	SSHKeyPassphrase  string        `mapstructure:"ssh_key_passphrase"`
+	SSHAgentAuth      bool          `mapstructure:"ssh_agent_auth"`
+	SSHTimeout        time.Duration ``
+
+	PackerBuildName string `mapstructure:"packer_build_name"`
+	PackerDebug     bool   `mapstructure:"packer_debug"`
+	PackerForce     bool   `mapstructure:"packer_force"`
+	PackerOnError   string `mapstructure:"packer_on_error"`
+
+	RawSSHTimeout string `mapstructure:"ssh_timeout"`
+}
+
+type Builder struct {
+	config config
+	runner multistep.Runner
+}
+
+func (b *Builder) Prepare(raws ...interface{}) error {
+	var err error
+
+	for _, raw := range raws {
+		err := mapstructure.Decode(raw, &b.config)
+		if err != nil {
+			return err
+		}
+	}
+
+	if b.config.Port == 0 {
+		b.config.Port = 22
+	}
+
+	if b.config.RawSSHTimeout == "" {
+		b.config.RawSSHTimeout = "5m"
+	}
+
+	// Accumulate any errors
+	errs := make([]error, 0)
+
+	if b.config.Host == "" {
+		errs = append(errs, errors.New("A host must be specified"))
+	}
+
+	if b.config.SSHUsername == "" {
+		errs = append(errs, errors.New("An ssh_username must be specified"))
+	}
+
+	if b.config.SSHPassword == "" && b.config.SSHPrivateKeyFile == "" && !b.config.SSHAgentAuth {
+		errs = append(errs, errors.New("One of ssh_password, ssh_private_key_file or ssh_agent_auth must be specified"))
+	}
+
+	if b.config.SSHPrivateKeyFile != "" {
+		if _, err := common.SSHKeychain(b.config.SSHPrivateKeyFile, b.config.SSHKeyPassphrase); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_private_key_file is invalid: %s", err))
+		}
+	} else if b.config.SSHKeyPassphrase != "" {
+		errs = append(errs, errors.New("ssh_key_passphrase requires an ssh_private_key_file"))
+	}
+
+	if b.config.SSHAgentAuth {
+		if _, conn, err := common.SSHAgent(); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_agent_auth is set, but the agent can't be used: %s", err))
+		} else {
+			conn.Close()
+		}
+	}
+
+	b.config.SSHTimeout, err = time.ParseDuration(b.config.RawSSHTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing ssh_timeout: %s", err))
+	}
+
+	if len(errs) > 0 {
+		return &packer.MultiError{errs}
+	}
+
+	return nil
+}
+
+func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	if b.config.PackerForce {
+		ui.Say("The null builder has no artifacts to replace, so forcing the build does nothing.")
+	}
+
+	// Setup the state bag and initial state for the steps
+	state := make(map[string]interface{})
+	state["config"] = &b.config
+	state["hook"] = hook
+	state["ui"] = ui
+
+	// Build the steps
+	steps := []multistep.Step{
+		new(stepConnectSSH),
+		new(stepProvision),
+	}
+
+	// Run!
+	b.runner = common.NewRunner(steps, b.config.PackerDebug, b.config.PackerOnError, ui)
+
+	b.runner.Run(state)
+	common.ReportStepTimings(ui, state)
+
+	// If there was an error, return that
+	if rawErr, ok := state["error"]; ok {
+		return nil, rawErr.(error)
+	}
+
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state[multistep.StateCancelled]; ok {
+		return nil, errors.New("Build was cancelled.")
+	}
+
+	if _, ok := state[multistep.StateHalted]; ok {
+		return nil, errors.New("Build was halted.")
+	}
+
+	// Nothing was built, so there is no artifact.
+	return nil, nil
+}
+
+func (b *Builder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
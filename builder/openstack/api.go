@@ -0,0 +1,329 @@
+// The methods to communicate with the OpenStack APIs: Keystone for
+// authentication and Nova for everything else.
+
+package openstack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+)
+
+type Client struct {
+	// The http client for communicating
+	client *http.Client
+
+	// The URL of the compute (Nova) API, including the tenant
+	ComputeURL string
+
+	// The token to authenticate requests with
+	Token string
+}
+
+// Server is a server as returned by the compute API.
+type Server struct {
+	Id        string
+	Status    string
+	Addresses map[string][]struct {
+		Addr    string `json:"addr"`
+		Version int    `json:"version"`
+	}
+}
+
+// FloatingIP is a floating IP as returned by the compute API.
+type FloatingIP struct {
+	Id string
+	IP string `json:"ip"`
+}
+
+// Image is an image as returned by the compute API.
+type Image struct {
+	Id     string
+	Name   string
+	Status string
+}
+
+// ServerOpts are the options to create a server with.
+type ServerOpts struct {
+	Name             string
+	ImageRef         string
+	FlavorRef        string
+	KeyName          string
+	AvailabilityZone string
+	SecurityGroups   []string
+}
+
+// Authenticate gets a token from Keystone with the given credentials and
+// returns a client for the compute API of the given region. If the region
+// is empty, the first compute endpoint is used.
+func Authenticate(identityEndpoint, username, password, tenantName, region string) (*Client, error) {
+	params := map[string]interface{}{
+		"auth": map[string]interface{}{
+			"passwordCredentials": map[string]string{
+				"username": username,
+				"password": password,
+			},
+			"tenantName": tenantName,
+		},
+	}
+
+	var resp struct {
+		Access struct {
+			Token struct {
+				Id string `json:"id"`
+			} `json:"token"`
+			ServiceCatalog []struct {
+				Type      string `json:"type"`
+				Endpoints []struct {
+					Region    string `json:"region"`
+					PublicURL string `json:"publicURL"`
+				} `json:"endpoints"`
+			} `json:"serviceCatalog"`
+		} `json:"access"`
+	}
+
+	client := &Client{client: http.DefaultClient}
+	url := strings.TrimRight(identityEndpoint, "/") + "/tokens"
+	if _, err := client.request("POST", url, params, &resp); err != nil {
+		return nil, fmt.Errorf("Error authenticating: %s", err)
+	}
+
+	client.Token = resp.Access.Token.Id
+	for _, service := range resp.Access.ServiceCatalog {
+		if service.Type != "compute" {
+			continue
+		}
+
+		for _, endpoint := range service.Endpoints {
+			if region == "" || endpoint.Region == region {
+				client.ComputeURL = strings.TrimRight(endpoint.PublicURL, "/")
+				return client, nil
+			}
+		}
+	}
+
+	if region != "" {
+		return nil, fmt.Errorf("No compute endpoint found in region: %s", region)
+	}
+
+	return nil, fmt.Errorf("No compute endpoint found")
+}
+
+// Creates a key pair and returns the private key of it
+func (c *Client) CreateKeyPair(name string) (string, error) {
+	params := map[string]interface{}{
+		"keypair": map[string]string{"name": name},
+	}
+
+	var resp struct {
+		KeyPair struct {
+			PrivateKey string `json:"private_key"`
+		} `json:"keypair"`
+	}
+	if _, err := c.compute("POST", "os-keypairs", params, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.KeyPair.PrivateKey, nil
+}
+
+// Deletes a key pair
+func (c *Client) DeleteKeyPair(name string) error {
+	_, err := c.compute("DELETE", "os-keypairs/"+name, nil, nil)
+	return err
+}
+
+// Creates a server and returns its ID
+func (c *Client) CreateServer(opts *ServerOpts) (string, error) {
+	server := map[string]interface{}{
+		"name":      opts.Name,
+		"imageRef":  opts.ImageRef,
+		"flavorRef": opts.FlavorRef,
+		"key_name":  opts.KeyName,
+	}
+
+	if opts.AvailabilityZone != "" {
+		server["availability_zone"] = opts.AvailabilityZone
+	}
+
+	if len(opts.SecurityGroups) > 0 {
+		groups := make([]map[string]string, len(opts.SecurityGroups))
+		for i, name := range opts.SecurityGroups {
+			groups[i] = map[string]string{"name": name}
+		}
+		server["security_groups"] = groups
+	}
+
+	var resp struct {
+		Server struct {
+			Id string `json:"id"`
+		} `json:"server"`
+	}
+	params := map[string]interface{}{"server": server}
+	if _, err := c.compute("POST", "servers", params, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.Server.Id, nil
+}
+
+// Returns the server with the given ID
+func (c *Client) Server(id string) (*Server, error) {
+	var resp struct {
+		Server *Server `json:"server"`
+	}
+	if _, err := c.compute("GET", "servers/"+id, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Server, nil
+}
+
+// Deletes a server
+func (c *Client) DeleteServer(id string) error {
+	_, err := c.compute("DELETE", "servers/"+id, nil, nil)
+	return err
+}
+
+// Allocates a floating IP from the given pool
+func (c *Client) AllocateFloatingIP(pool string) (*FloatingIP, error) {
+	params := map[string]interface{}{"pool": pool}
+
+	var resp struct {
+		FloatingIP *FloatingIP `json:"floating_ip"`
+	}
+	if _, err := c.compute("POST", "os-floating-ips", params, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.FloatingIP, nil
+}
+
+// Associates the floating IP address with a server
+func (c *Client) AssociateFloatingIP(serverId, ip string) error {
+	params := map[string]interface{}{
+		"addFloatingIp": map[string]string{"address": ip},
+	}
+
+	_, err := c.compute("POST", fmt.Sprintf("servers/%s/action", serverId), params, nil)
+	return err
+}
+
+// Deallocates a floating IP, which also disassociates it from the
+// server it is associated with.
+func (c *Client) DeallocateFloatingIP(id string) error {
+	_, err := c.compute("DELETE", "os-floating-ips/"+id, nil, nil)
+	return err
+}
+
+// Creates an image of a server and returns the ID of the image
+func (c *Client) CreateImage(serverId, name string) (string, error) {
+	params := map[string]interface{}{
+		"createImage": map[string]string{"name": name},
+	}
+
+	// The ID of the new image is only given in the Location header
+	header, err := c.compute("POST", fmt.Sprintf("servers/%s/action", serverId), params, nil)
+	if err != nil {
+		return "", err
+	}
+
+	location := header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("No location of the image was returned")
+	}
+
+	return path.Base(location), nil
+}
+
+// Returns the image with the given ID
+func (c *Client) Image(id string) (*Image, error) {
+	var resp struct {
+		Image *Image `json:"image"`
+	}
+	if _, err := c.compute("GET", "images/"+id, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Image, nil
+}
+
+// Deletes an image
+func (c *Client) DeleteImage(id string) error {
+	_, err := c.compute("DELETE", "images/"+id, nil, nil)
+	return err
+}
+
+// compute sends a request to the given path of the compute API
+func (c *Client) compute(method, path string, params map[string]interface{}, result interface{}) (http.Header, error) {
+	return c.request(method, fmt.Sprintf("%s/%s", c.ComputeURL, path), params, result)
+}
+
+// request sends a request to the URL, decoding the JSON response into the
+// result if one is given. It returns the headers of the response.
+func (c *Client) request(method, url string, params map[string]interface{}, result interface{}) (http.Header, error) {
+	var body bytes.Buffer
+	if params != nil {
+		if err := json.NewEncoder(&body).Encode(params); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, url, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("X-Auth-Token", c.Token)
+	}
+
+	log.Printf("sending new request to openstack: %s %s", method, url)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("response from openstack: HTTP %v", resp.StatusCode)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Errors are an object with a single key of the kind of error,
+		// such as {"badRequest": {"message": "...", "code": 400}}
+		var errResp map[string]struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(respBody, &errResp); err == nil {
+			for _, e := range errResp {
+				if e.Message != "" {
+					return nil, fmt.Errorf("Received bad response (HTTP %v) from OpenStack: %s",
+						resp.StatusCode, e.Message)
+				}
+			}
+		}
+
+		return nil, fmt.Errorf("Received bad response (HTTP %v) from OpenStack: %s",
+			resp.StatusCode, respBody)
+	}
+
+	if result != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return nil, fmt.Errorf("Failed to decode JSON response (HTTP %v) from OpenStack: %s",
+				resp.StatusCode, respBody)
+		}
+	}
+
+	return resp.Header, nil
+}
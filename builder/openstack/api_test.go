@@ -0,0 +1,124 @@
+package openstack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testServer(handler http.HandlerFunc) (*Client, *httptest.Server) {
+	ts := httptest.NewServer(handler)
+	client := &Client{
+		client:     http.DefaultClient,
+		ComputeURL: ts.URL,
+		Token:      "token",
+	}
+
+	return client, ts
+}
+
+func TestAuthenticate(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v2.0/tokens" {
+			t.Errorf("bad request: %s %s", r.Method, r.URL.Path)
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"tenantName":"baz"`) {
+			t.Errorf("bad body: %s", body)
+		}
+
+		fmt.Fprintf(w, `{"access": {"token": {"id": "token"}, "serviceCatalog": [
+			{"type": "identity", "endpoints": [{"region": "one", "publicURL": "%[1]s/identity"}]},
+			{"type": "compute", "endpoints": [
+				{"region": "one", "publicURL": "%[1]s/one/"},
+				{"region": "two", "publicURL": "%[1]s/two/"}]}]}}`, ts.URL)
+	}))
+	defer ts.Close()
+
+	client, err := Authenticate(ts.URL+"/v2.0/", "foo", "bar", "baz", "two")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if client.Token != "token" {
+		t.Fatalf("bad: %s", client.Token)
+	}
+
+	if client.ComputeURL != ts.URL+"/two" {
+		t.Fatalf("bad: %s", client.ComputeURL)
+	}
+
+	// Test an unknown region
+	_, err = Authenticate(ts.URL+"/v2.0", "foo", "bar", "baz", "three")
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestClient_CreateImage(t *testing.T) {
+	client, ts := testServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "token" {
+			t.Errorf("bad token: %s", r.Header.Get("X-Auth-Token"))
+		}
+
+		if r.URL.Path != "/servers/foo/action" {
+			t.Errorf("bad path: %s", r.URL.Path)
+		}
+
+		w.Header().Set("Location", "http://example.com/v2/tenant/images/bar")
+		w.WriteHeader(202)
+	})
+	defer ts.Close()
+
+	id, err := client.CreateImage("foo", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if id != "bar" {
+		t.Fatalf("bad: %s", id)
+	}
+}
+
+func TestClient_Server(t *testing.T) {
+	client, ts := testServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"server": {"id": "foo", "status": "ACTIVE", "addresses": {
+			"private": [{"addr": "fe80::1", "version": 6}, {"addr": "10.0.0.2", "version": 4}]}}}`)
+	})
+	defer ts.Close()
+
+	server, err := client.Server("foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if server.Status != "ACTIVE" {
+		t.Fatalf("bad: %s", server.Status)
+	}
+
+	if ip := serverIPv4(server); ip != "10.0.0.2" {
+		t.Fatalf("bad: %s", ip)
+	}
+}
+
+func TestClient_Error(t *testing.T) {
+	client, ts := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		fmt.Fprint(w, `{"itemNotFound": {"message": "Image not found.", "code": 404}}`)
+	})
+	defer ts.Close()
+
+	_, err := client.Image("foo")
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if !strings.Contains(err.Error(), "Image not found.") {
+		t.Fatalf("bad: %s", err)
+	}
+}
@@ -0,0 +1,38 @@
+package openstack
+
+import (
+	"fmt"
+	"log"
+)
+
+// Artifact is an artifact implementation that contains a built image.
+type Artifact struct {
+	// The ID and name of the image
+	imageId   string
+	imageName string
+
+	// The client for making API calls
+	client *Client
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (*Artifact) Files() []string {
+	// We have no files
+	return nil
+}
+
+func (a *Artifact) Id() string {
+	return a.imageId
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("An image was created: %s (ID: %s)", a.imageName, a.imageId)
+}
+
+func (a *Artifact) Destroy() error {
+	log.Printf("Destroying image: %s", a.imageId)
+	return a.client.DeleteImage(a.imageId)
+}
@@ -0,0 +1,226 @@
+// The openstack package contains a packer.Builder implementation that
+// builds images for OpenStack clouds.
+
+package openstack
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"os"
+	"time"
+)
+
+// The unique ID for this builder
+const BuilderId = "mitchellh.openstack"
+
+type config struct {
+	// The credentials to authenticate with Keystone. They are read from
+	// the usual OS_* environment variables if they aren't given.
+	IdentityEndpoint string `mapstructure:"identity_endpoint"`
+	Password         string `mapstructure:"password"`
+	Region           string `mapstructure:"region"`
+	TenantName       string `mapstructure:"tenant_name"`
+	Username         string `mapstructure:"username"`
+
+	AvailabilityZone string        `mapstructure:"availability_zone"`
+	Flavor           string        `mapstructure:"flavor"`
+	FloatingIPPool   string        `mapstructure:"floating_ip_pool"`
+	ImageName        string        `mapstructure:"image_name"`
+	SecurityGroups   []string      `mapstructure:"security_groups"`
+	SourceImage      string        `mapstructure:"source_image"`
+	SSHPort          uint          `mapstructure:"ssh_port"`
+	SSHUsername      string        `mapstructure:"ssh_username"`
+	SSHTimeout       time.Duration ``
+	StateTimeout     time.Duration ``
+
+	PackerBuildName         string `mapstructure:"packer_build_name"`
+	PackerDebug             bool   `mapstructure:"packer_debug"`
+	PackerForce             bool   `mapstructure:"packer_force"`
+	PackerOnError           string `mapstructure:"packer_on_error"`
+	PackerTemplateTimestamp int64  `mapstructure:"packer_template_timestamp"`
+
+	RawSSHTimeout   string `mapstructure:"ssh_timeout"`
+	RawStateTimeout string `mapstructure:"state_timeout"`
+}
+
+type Builder struct {
+	config config
+	runner multistep.Runner
+}
+
+func (b *Builder) Prepare(raws ...interface{}) error {
+	var err error
+
+	for _, raw := range raws {
+		err := mapstructure.Decode(raw, &b.config)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Fall back to the environment variables the OpenStack command line
+	// clients use.
+	if b.config.IdentityEndpoint == "" {
+		b.config.IdentityEndpoint = os.Getenv("OS_AUTH_URL")
+	}
+
+	if b.config.Password == "" {
+		b.config.Password = os.Getenv("OS_PASSWORD")
+	}
+
+	if b.config.Region == "" {
+		b.config.Region = os.Getenv("OS_REGION_NAME")
+	}
+
+	if b.config.TenantName == "" {
+		b.config.TenantName = os.Getenv("OS_TENANT_NAME")
+	}
+
+	if b.config.Username == "" {
+		b.config.Username = os.Getenv("OS_USERNAME")
+	}
+
+	if b.config.ImageName == "" {
+		b.config.ImageName = "packer-{{timestamp}}"
+	}
+
+	if b.config.SSHPort == 0 {
+		b.config.SSHPort = 22
+	}
+
+	if b.config.SSHUsername == "" {
+		b.config.SSHUsername = "root"
+	}
+
+	if b.config.RawSSHTimeout == "" {
+		b.config.RawSSHTimeout = "5m"
+	}
+
+	if b.config.RawStateTimeout == "" {
+		b.config.RawStateTimeout = "10m"
+	}
+
+	// Accumulate any errors
+	errs := make([]error, 0)
+
+	tpl := common.NewConfigTemplate(b.config.PackerBuildName, b.config.PackerTemplateTimestamp)
+	b.config.ImageName, err = tpl.Process(b.config.ImageName)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error processing image_name: %s", err))
+	}
+
+	if b.config.IdentityEndpoint == "" {
+		errs = append(errs, errors.New("An identity_endpoint must be specified, or OS_AUTH_URL set"))
+	}
+
+	if b.config.Username == "" {
+		errs = append(errs, errors.New("A username must be specified, or OS_USERNAME set"))
+	}
+
+	if b.config.Password == "" {
+		errs = append(errs, errors.New("A password must be specified, or OS_PASSWORD set"))
+	}
+
+	if b.config.TenantName == "" {
+		errs = append(errs, errors.New("A tenant_name must be specified, or OS_TENANT_NAME set"))
+	}
+
+	if b.config.SourceImage == "" {
+		errs = append(errs, errors.New("A source_image must be specified"))
+	}
+
+	if b.config.Flavor == "" {
+		errs = append(errs, errors.New("A flavor must be specified"))
+	}
+
+	b.config.SSHTimeout, err = time.ParseDuration(b.config.RawSSHTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing ssh_timeout: %s", err))
+	}
+
+	b.config.StateTimeout, err = time.ParseDuration(b.config.RawStateTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing state_timeout: %s", err))
+	}
+
+	if len(errs) > 0 {
+		return &packer.MultiError{errs}
+	}
+
+	return nil
+}
+
+func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	if b.config.PackerForce {
+		ui.Say("Forcing the build isn't supported by the openstack builder and is ignored.")
+	}
+
+	ui.Say("Authenticating with OpenStack...")
+	client, err := Authenticate(b.config.IdentityEndpoint, b.config.Username,
+		b.config.Password, b.config.TenantName, b.config.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup the state bag and initial state for the steps
+	state := make(map[string]interface{})
+	state["client"] = client
+	state["config"] = &b.config
+	state["hook"] = hook
+	state["ui"] = ui
+
+	// Build the steps
+	steps := []multistep.Step{
+		&stepKeyPair{
+			Debug:        b.config.PackerDebug,
+			DebugKeyPath: fmt.Sprintf("os_%s.pem", b.config.PackerBuildName),
+		},
+		new(stepRunSourceServer),
+		new(stepAllocateIP),
+		new(stepConnectSSH),
+		new(stepProvision),
+		new(stepCreateImage),
+	}
+
+	// Run!
+	b.runner = common.NewRunner(steps, b.config.PackerDebug, b.config.PackerOnError, ui)
+
+	b.runner.Run(state)
+	common.ReportStepTimings(ui, state)
+
+	// If there was an error, return that
+	if rawErr, ok := state["error"]; ok {
+		return nil, rawErr.(error)
+	}
+
+	// If there is no image, just return
+	if _, ok := state["image"]; !ok {
+		return nil, nil
+	}
+
+	var imageId string
+	if err := common.StateBag(state).Values("image", &imageId); err != nil {
+		return nil, err
+	}
+
+	// Build the artifact and return it
+	artifact := &Artifact{
+		imageId:   imageId,
+		imageName: b.config.ImageName,
+		client:    client,
+	}
+
+	return artifact, nil
+}
+
+func (b *Builder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
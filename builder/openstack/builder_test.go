@@ -0,0 +1,131 @@
+package openstack
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"os"
+	"testing"
+)
+
+func init() {
+	// Clear out the OpenStack env vars so they don't affect our tests
+	os.Setenv("OS_AUTH_URL", "")
+	os.Setenv("OS_PASSWORD", "")
+	os.Setenv("OS_REGION_NAME", "")
+	os.Setenv("OS_TENANT_NAME", "")
+	os.Setenv("OS_USERNAME", "")
+}
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"identity_endpoint": "http://127.0.0.1:5000/v2.0",
+		"username":          "foo",
+		"password":          "bar",
+		"tenant_name":       "baz",
+		"source_image":      "abcd",
+		"flavor":            "2",
+	}
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Fatalf("Builder should be a builder")
+	}
+}
+
+func TestBuilderPrepare_Defaults(t *testing.T) {
+	var b Builder
+	err := b.Prepare(testConfig())
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.SSHUsername != "root" {
+		t.Errorf("invalid: %s", b.config.SSHUsername)
+	}
+
+	if b.config.RawStateTimeout != "10m" {
+		t.Errorf("invalid: %s", b.config.RawStateTimeout)
+	}
+
+	if b.config.ImageName == "" || b.config.ImageName == "packer-{{timestamp}}" {
+		t.Errorf("invalid: %s", b.config.ImageName)
+	}
+}
+
+func TestBuilderPrepare_Credentials(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test missing
+	delete(config, "username")
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test env
+	os.Setenv("OS_USERNAME", "env")
+	defer os.Setenv("OS_USERNAME", "")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.Username != "env" {
+		t.Errorf("invalid: %s", b.config.Username)
+	}
+
+	// Test the config wins over env
+	config["username"] = "foo"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.Username != "foo" {
+		t.Errorf("invalid: %s", b.config.Username)
+	}
+}
+
+func TestBuilderPrepare_SourceImageFlavor(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	delete(config, "source_image")
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	config["source_image"] = "abcd"
+	delete(config, "flavor")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_StateTimeout(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test with a bad value
+	config["state_timeout"] = "x"
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test with a good one
+	config["state_timeout"] = "5m"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
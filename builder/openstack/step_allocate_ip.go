@@ -0,0 +1,117 @@
+package openstack
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step finds the address to connect to the server with. If a
+// "floating_ip_pool" is set, a floating IP is allocated from it and
+// associated with the server, and otherwise the address of the server
+// itself is used.
+//
+// Uses:
+//   client *Client
+//   config *config
+//   server_id string
+//   ui     packer.Ui
+//
+// Produces:
+//   access_ip string - The address to connect to.
+type stepAllocateIP struct {
+	floatingIPId string
+}
+
+func (s *stepAllocateIP) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var client *Client
+	var config *config
+	var serverId string
+	var ui packer.Ui
+	if err := bag.Values("client", &client, "config", &config, "server_id", &serverId, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	if config.FloatingIPPool == "" {
+		server, err := client.Server(serverId)
+		if err == nil {
+			var ip string
+			if ip = serverIPv4(server); ip == "" {
+				err = errors.New("the server has no IPv4 address")
+			}
+
+			state["access_ip"] = ip
+		}
+
+		if err != nil {
+			err := fmt.Errorf("Error finding the address of the server: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		return multistep.ActionContinue
+	}
+
+	ui.Say(fmt.Sprintf("Allocating a floating IP from pool: %s", config.FloatingIPPool))
+	ip, err := client.AllocateFloatingIP(config.FloatingIPPool)
+	if err != nil {
+		err := fmt.Errorf("Error allocating floating IP: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// We use this in cleanup
+	s.floatingIPId = ip.Id
+
+	ui.Say(fmt.Sprintf("Associating floating IP %s with the server...", ip.IP))
+	if err := client.AssociateFloatingIP(serverId, ip.IP); err != nil {
+		err := fmt.Errorf("Error associating floating IP: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state["access_ip"] = ip.IP
+
+	return multistep.ActionContinue
+}
+
+func (s *stepAllocateIP) Cleanup(state map[string]interface{}) {
+	if s.floatingIPId == "" {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var client *Client
+	var ui packer.Ui
+	if err := bag.Values("client", &client, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	ui.Say("Deallocating the floating IP...")
+	if err := client.DeallocateFloatingIP(s.floatingIPId); err != nil {
+		ui.Error(fmt.Sprintf(
+			"Error deallocating floating IP. Please release it manually: %s (%s)", s.floatingIPId, err))
+	}
+}
+
+// serverIPv4 returns the first IPv4 address of the server, or an empty
+// string if it has none.
+func serverIPv4(server *Server) string {
+	for _, addresses := range server.Addresses {
+		for _, address := range addresses {
+			if address.Version == 4 {
+				return address.Addr
+			}
+		}
+	}
+
+	return ""
+}
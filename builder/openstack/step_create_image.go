@@ -0,0 +1,67 @@
+package openstack
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step creates an image of the server and waits for it to become
+// active.
+//
+// Uses:
+//   client *Client
+//   config *config
+//   server_id string
+//   ui     packer.Ui
+//
+// Produces:
+//   image string - The ID of the image.
+type stepCreateImage struct{}
+
+func (s *stepCreateImage) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var client *Client
+	var config *config
+	var serverId string
+	var ui packer.Ui
+	if err := bag.Values("client", &client, "config", &config, "server_id", &serverId, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say(fmt.Sprintf("Creating the image: %s", config.ImageName))
+	imageId, err := client.CreateImage(serverId, config.ImageName)
+	if err != nil {
+		err := fmt.Errorf("Error creating image: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Set the Image ID in the state
+	ui.Say(fmt.Sprintf("Image: %s", imageId))
+	state["image"] = imageId
+
+	ui.Say("Waiting for image to become ready...")
+	err = waitForState("image", "ACTIVE", []string{"SAVING", "QUEUED", "UNKNOWN"}, config.StateTimeout, state, func() (string, error) {
+		image, err := client.Image(imageId)
+		if err != nil {
+			return "", err
+		}
+
+		log.Printf("image status: %s", image.Status)
+		return image.Status, nil
+	})
+	if err != nil {
+		err := fmt.Errorf("Error waiting for image: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateImage) Cleanup(map[string]interface{}) {}
@@ -0,0 +1,89 @@
+package openstack
+
+import (
+	"cgl.tideland.biz/identifier"
+	"encoding/hex"
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"log"
+)
+
+// This step creates a temporary key pair for the server. OpenStack
+// generates the key, and only returns the private key once. In debug
+// mode the private key is also saved to DebugKeyPath.
+//
+// Produces:
+//   keyPair string - The name of the key pair.
+//   privateKey string - The private key of the key pair.
+type stepKeyPair struct {
+	Debug        bool
+	DebugKeyPath string
+
+	keyName string
+}
+
+func (s *stepKeyPair) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var client *Client
+	var ui packer.Ui
+	if err := bag.Values("client", &client, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say("Creating temporary keypair for this instance...")
+	keyName := fmt.Sprintf("packer-%s", hex.EncodeToString(identifier.NewUUID().Raw()))
+	log.Printf("temporary keypair name: %s", keyName)
+
+	privateKey, err := client.CreateKeyPair(keyName)
+	if err != nil {
+		err := fmt.Errorf("Error creating temporary keypair: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Set the keyname so we know to delete it later
+	s.keyName = keyName
+
+	// If we're in debug mode, output the private key to the working
+	// directory so the machine can be inspected over SSH.
+	if s.Debug {
+		ui.Message(fmt.Sprintf("Saving key for debug purposes: %s", s.DebugKeyPath))
+		if err := ioutil.WriteFile(s.DebugKeyPath, []byte(privateKey), 0600); err != nil {
+			err := fmt.Errorf("Error saving debug key: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	// Set some state data for use in future steps
+	state["keyPair"] = keyName
+	state["privateKey"] = privateKey
+
+	return multistep.ActionContinue
+}
+
+func (s *stepKeyPair) Cleanup(state map[string]interface{}) {
+	// If no key name is set, then we never created it, so just return
+	if s.keyName == "" {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var client *Client
+	var ui packer.Ui
+	if err := bag.Values("client", &client, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	ui.Say("Deleting temporary keypair...")
+	if err := client.DeleteKeyPair(s.keyName); err != nil {
+		ui.Error(fmt.Sprintf(
+			"Error cleaning up keypair. Please delete the key manually: %s", s.keyName))
+	}
+}
@@ -0,0 +1,99 @@
+package openstack
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step boots the server that is provisioned and then turned into
+// the image.
+//
+// Uses:
+//   client *Client
+//   config *config
+//   keyPair string
+//   ui     packer.Ui
+//
+// Produces:
+//   server_id string - The ID of the server.
+type stepRunSourceServer struct {
+	serverId string
+}
+
+func (s *stepRunSourceServer) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var client *Client
+	var config *config
+	var keyName string
+	var ui packer.Ui
+	if err := bag.Values("client", &client, "config", &config, "keyPair", &keyName, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	opts := &ServerOpts{
+		Name:             config.ImageName,
+		ImageRef:         config.SourceImage,
+		FlavorRef:        config.Flavor,
+		KeyName:          keyName,
+		AvailabilityZone: config.AvailabilityZone,
+		SecurityGroups:   config.SecurityGroups,
+	}
+
+	ui.Say("Launching server...")
+	serverId, err := client.CreateServer(opts)
+	if err != nil {
+		err := fmt.Errorf("Error launching source server: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// We use this in cleanup
+	s.serverId = serverId
+	log.Printf("server id: %s", serverId)
+
+	ui.Say(fmt.Sprintf("Waiting for server (%s) to become ready...", serverId))
+	err = waitForState("server", "ACTIVE", []string{"BUILD"}, config.StateTimeout, state, func() (string, error) {
+		server, err := client.Server(serverId)
+		if err != nil {
+			return "", err
+		}
+
+		return server.Status, nil
+	})
+	if err != nil {
+		err := fmt.Errorf("Error waiting for server (%s) to become ready: %s", serverId, err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state["server_id"] = serverId
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRunSourceServer) Cleanup(state map[string]interface{}) {
+	if s.serverId == "" {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var client *Client
+	var ui packer.Ui
+	if err := bag.Values("client", &client, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	// The server is deleted no matter how the build went, since the
+	// image has its own copy of the disk.
+	ui.Say("Terminating the source server...")
+	if err := client.DeleteServer(s.serverId); err != nil {
+		ui.Error(fmt.Sprintf(
+			"Error terminating server. Please terminate it manually: %s (%s)", s.serverId, err))
+	}
+}
@@ -0,0 +1,52 @@
+package openstack
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"log"
+	"time"
+)
+
+// The time to wait between checks of the state of a resource.
+var statePollInterval = 2 * time.Second
+
+// waitForState polls refresh until the resource is in the target state,
+// returning an error if the resource gets into a state that isn't one of
+// the pending ones, if the timeout passes or if the build is cancelled.
+func waitForState(desc string, target string, pending []string, timeout time.Duration, state map[string]interface{}, refresh func() (string, error)) error {
+	log.Printf("Waiting up to %s for %s to become %s", timeout, desc, target)
+	deadline := time.Now().Add(timeout)
+	for {
+		current, err := refresh()
+		if err != nil {
+			return err
+		}
+
+		if current == target {
+			return nil
+		}
+
+		found := false
+		for _, p := range pending {
+			if current == p {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("unexpected state '%s', wanted target '%s'", current, target)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout while waiting for %s to become %s", desc, target)
+		}
+
+		log.Printf("%s is %s, waiting for %s", desc, current, target)
+		time.Sleep(statePollInterval)
+
+		if _, ok := state[multistep.StateCancelled]; ok {
+			return fmt.Errorf("interrupted while waiting for %s to become %s", desc, target)
+		}
+	}
+}
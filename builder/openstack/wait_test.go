@@ -0,0 +1,60 @@
+package openstack
+
+import (
+	"github.com/mitchellh/multistep"
+	"testing"
+	"time"
+)
+
+func TestWaitForState(t *testing.T) {
+	old := statePollInterval
+	statePollInterval = time.Millisecond
+	defer func() { statePollInterval = old }()
+
+	states := []string{"BUILD", "BUILD", "ACTIVE"}
+	err := waitForState("server", "ACTIVE", []string{"BUILD"}, time.Minute, nil, func() (string, error) {
+		state := states[0]
+		states = states[1:]
+		return state, nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// An unexpected state is an error
+	err = waitForState("server", "ACTIVE", []string{"BUILD"}, time.Minute, nil, func() (string, error) {
+		return "ERROR", nil
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// So is the timeout
+	err = waitForState("server", "ACTIVE", []string{"BUILD"}, 0, nil, func() (string, error) {
+		return "BUILD", nil
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestWaitForState_Cancelled(t *testing.T) {
+	old := statePollInterval
+	statePollInterval = time.Millisecond
+	defer func() { statePollInterval = old }()
+
+	state := make(map[string]interface{})
+	attempts := 0
+	err := waitForState("server", "ACTIVE", []string{"BUILD"}, time.Minute, state, func() (string, error) {
+		attempts += 1
+		state[multistep.StateCancelled] = true
+		return "BUILD", nil
+	})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if attempts != 1 {
+		t.Fatalf("bad: %d", attempts)
+	}
+}
@@ -0,0 +1,399 @@
+// The parallels package contains a packer.Builder implementation that
+// builds Parallels Desktop virtual machines from an ISO.
+
+package parallels
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const BuilderId = "mitchellh.parallels"
+
+// These are the different valid mode values for "parallels_tools_mode"
+// which determine how Parallels Tools are delivered to the guest.
+const (
+	ParallelsToolsModeDisable string = "disable"
+	ParallelsToolsModeAttach         = "attach"
+	ParallelsToolsModeUpload         = "upload"
+)
+
+type Builder struct {
+	config config
+	driver Driver
+	runner multistep.Runner
+}
+
+type config struct {
+	BootCommand             []string      `mapstructure:"boot_command"`
+	BootWait                time.Duration ``
+	DiskSize                uint          `mapstructure:"disk_size"`
+	GuestOSType             string        `mapstructure:"guest_os_type"`
+	HTTPDir                 string        `mapstructure:"http_directory"`
+	HTTPPortMin             uint          `mapstructure:"http_port_min"`
+	HTTPPortMax             uint          `mapstructure:"http_port_max"`
+	ISOChecksum             string        `mapstructure:"iso_checksum"`
+	ISOChecksumType         string        `mapstructure:"iso_checksum_type"`
+	ISOUrls                 []string      `mapstructure:"iso_urls"`
+	OutputDir               string        `mapstructure:"output_directory"`
+	ParallelsToolsFlavor    string        `mapstructure:"parallels_tools_flavor"`
+	ParallelsToolsGuestPath string        `mapstructure:"parallels_tools_guest_path"`
+	ParallelsToolsMode      string        `mapstructure:"parallels_tools_mode"`
+	Prlctl                  [][]string    `mapstructure:"prlctl"`
+	ShutdownCommand         string        `mapstructure:"shutdown_command"`
+	ShutdownTimeout         time.Duration ``
+	SSHPassword             string        `mapstructure:"ssh_password"`
+	SSHPrivateKeyFile       string        `mapstructure:"ssh_private_key_file"`
+	SSHKeyPassphrase        string        `mapstructure:"ssh_key_passphrase"`
+	SSHAgentAuth            bool          `mapstructure:"ssh_agent_auth"`
+	SSHPort                 uint          `mapstructure:"ssh_port"`
+	SSHUser                 string        `mapstructure:"ssh_username"`
+	SSHWaitTimeout          time.Duration ``
+	VMName                  string        `mapstructure:"vm_name"`
+	VNCPortMin              uint          `mapstructure:"vnc_port_min"`
+	VNCPortMax              uint          `mapstructure:"vnc_port_max"`
+
+	PackerBuildName         string `mapstructure:"packer_build_name"`
+	PackerDebug             bool   `mapstructure:"packer_debug"`
+	PackerForce             bool   `mapstructure:"packer_force"`
+	PackerOnError           string `mapstructure:"packer_on_error"`
+	PackerTemplateTimestamp int64  `mapstructure:"packer_template_timestamp"`
+
+	RawBootWait        string `mapstructure:"boot_wait"`
+	RawSingleISOUrl    string `mapstructure:"iso_url"`
+	RawShutdownTimeout string `mapstructure:"shutdown_timeout"`
+	RawSSHWaitTimeout  string `mapstructure:"ssh_wait_timeout"`
+}
+
+func (b *Builder) Prepare(raws ...interface{}) error {
+	var err error
+
+	for _, raw := range raws {
+		err := mapstructure.Decode(raw, &b.config)
+		if err != nil {
+			return err
+		}
+	}
+
+	if b.config.DiskSize == 0 {
+		b.config.DiskSize = 40000
+	}
+
+	if b.config.GuestOSType == "" {
+		b.config.GuestOSType = "other"
+	}
+
+	if b.config.HTTPPortMin == 0 {
+		b.config.HTTPPortMin = 8000
+	}
+
+	if b.config.HTTPPortMax == 0 {
+		b.config.HTTPPortMax = 9000
+	}
+
+	if b.config.OutputDir == "" {
+		b.config.OutputDir = fmt.Sprintf("output-%s", b.config.PackerBuildName)
+	}
+
+	if b.config.ParallelsToolsGuestPath == "" {
+		b.config.ParallelsToolsGuestPath = "prl-tools-{{.Flavor}}.iso"
+	}
+
+	if b.config.ParallelsToolsMode == "" {
+		b.config.ParallelsToolsMode = ParallelsToolsModeUpload
+	}
+
+	if b.config.Prlctl == nil {
+		b.config.Prlctl = make([][]string, 0)
+	}
+
+	if b.config.RawBootWait == "" {
+		b.config.RawBootWait = "10s"
+	}
+
+	if b.config.RawShutdownTimeout == "" {
+		b.config.RawShutdownTimeout = "5m"
+	}
+
+	if b.config.RawSSHWaitTimeout == "" {
+		b.config.RawSSHWaitTimeout = "20m"
+	}
+
+	if b.config.SSHPort == 0 {
+		b.config.SSHPort = 22
+	}
+
+	if b.config.VMName == "" {
+		b.config.VMName = fmt.Sprintf("packer-%s", b.config.PackerBuildName)
+	}
+
+	if b.config.VNCPortMin == 0 {
+		b.config.VNCPortMin = 5900
+	}
+
+	if b.config.VNCPortMax == 0 {
+		b.config.VNCPortMax = 6000
+	}
+
+	errs := make([]error, 0)
+
+	tpl := common.NewConfigTemplate(b.config.PackerBuildName, b.config.PackerTemplateTimestamp)
+	b.config.OutputDir, err = tpl.Process(b.config.OutputDir)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error processing output_directory: %s", err))
+	}
+
+	b.config.VMName, err = tpl.Process(b.config.VMName)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error processing vm_name: %s", err))
+	}
+
+	for i, command := range b.config.BootCommand {
+		if _, err := template.New("boot").Parse(command); err != nil {
+			errs = append(errs, fmt.Errorf("Error parsing boot_command %d: %s", i+1, err))
+		}
+	}
+
+	if b.config.HTTPPortMin > b.config.HTTPPortMax {
+		errs = append(errs, errors.New("http_port_min must be less than http_port_max"))
+	}
+
+	if b.config.ISOChecksumType == "" {
+		errs = append(errs, errors.New("The iso_checksum_type must be specified."))
+	} else {
+		b.config.ISOChecksumType = strings.ToLower(b.config.ISOChecksumType)
+		if b.config.ISOChecksumType != "none" {
+			if h := common.HashForType(b.config.ISOChecksumType); h == nil {
+				errs = append(
+					errs,
+					fmt.Errorf("Unsupported checksum type: %s", b.config.ISOChecksumType))
+			}
+
+			if b.config.ISOChecksum == "" {
+				errs = append(errs, errors.New("Due to large file sizes, an iso_checksum is required"))
+			} else {
+				b.config.ISOChecksum = strings.ToLower(b.config.ISOChecksum)
+			}
+		}
+	}
+
+	if b.config.RawSingleISOUrl == "" && len(b.config.ISOUrls) == 0 {
+		errs = append(errs, errors.New("One of iso_url or iso_urls must be specified."))
+	} else if b.config.RawSingleISOUrl != "" && len(b.config.ISOUrls) > 0 {
+		errs = append(errs, errors.New("Only one of iso_url or iso_urls may be specified."))
+	} else if b.config.RawSingleISOUrl != "" {
+		b.config.ISOUrls = []string{b.config.RawSingleISOUrl}
+	}
+
+	for i, url := range b.config.ISOUrls {
+		b.config.ISOUrls[i], err = common.DownloadableURL(url)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to parse iso_url %d: %s", i+1, err))
+		}
+	}
+
+	if _, err := os.Stat(b.config.OutputDir); err == nil && !b.config.PackerForce {
+		errs = append(errs, errors.New("Output directory already exists. It must not exist, unless the build is forced."))
+	}
+
+	validMode := false
+	validModes := []string{
+		ParallelsToolsModeDisable,
+		ParallelsToolsModeAttach,
+		ParallelsToolsModeUpload,
+	}
+
+	for _, mode := range validModes {
+		if b.config.ParallelsToolsMode == mode {
+			validMode = true
+			break
+		}
+	}
+
+	if !validMode {
+		errs = append(errs,
+			fmt.Errorf("parallels_tools_mode is invalid. Must be one of: %v", validModes))
+	}
+
+	if b.config.ParallelsToolsMode != ParallelsToolsModeDisable {
+		validFlavors := []string{"lin", "mac", "other", "win"}
+		validFlavor := false
+		for _, flavor := range validFlavors {
+			if b.config.ParallelsToolsFlavor == flavor {
+				validFlavor = true
+				break
+			}
+		}
+
+		if b.config.ParallelsToolsFlavor == "" {
+			errs = append(errs, errors.New("A parallels_tools_flavor must be specified, unless parallels_tools_mode is disable."))
+		} else if !validFlavor {
+			errs = append(errs, fmt.Errorf(
+				"parallels_tools_flavor must be one of: %s", strings.Join(validFlavors, ", ")))
+		}
+	}
+
+	if _, err := template.New("path").Parse(b.config.ParallelsToolsGuestPath); err != nil {
+		errs = append(errs, fmt.Errorf("parallels_tools_guest_path invalid: %s", err))
+	}
+
+	b.config.BootWait, err = time.ParseDuration(b.config.RawBootWait)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing boot_wait: %s", err))
+	}
+
+	b.config.ShutdownTimeout, err = time.ParseDuration(b.config.RawShutdownTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing shutdown_timeout: %s", err))
+	}
+
+	if b.config.SSHUser == "" {
+		errs = append(errs, errors.New("An ssh_username must be specified."))
+	}
+
+	if b.config.SSHPrivateKeyFile != "" {
+		if _, err := common.SSHKeychain(b.config.SSHPrivateKeyFile, b.config.SSHKeyPassphrase); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_private_key_file is invalid: %s", err))
+		}
+	} else if b.config.SSHKeyPassphrase != "" {
+		errs = append(errs, errors.New("ssh_key_passphrase requires an ssh_private_key_file"))
+	}
+
+	if b.config.SSHAgentAuth {
+		if _, conn, err := common.SSHAgent(); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_agent_auth is set, but the agent can't be used: %s", err))
+		} else {
+			conn.Close()
+		}
+	}
+
+	b.config.SSHWaitTimeout, err = time.ParseDuration(b.config.RawSSHWaitTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing ssh_wait_timeout: %s", err))
+	}
+
+	if b.config.VNCPortMin > b.config.VNCPortMax {
+		errs = append(errs, errors.New("vnc_port_min must be less than vnc_port_max"))
+	}
+
+	errs = append(errs, validatePrlctl(b.config.Prlctl)...)
+
+	b.driver, err = newDriver()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed creating Parallels driver: %s", err))
+	}
+
+	if len(errs) > 0 {
+		return &packer.MultiError{errs}
+	}
+
+	return nil
+}
+
+func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	// Seed the random number generator
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	steps := []multistep.Step{
+		&common.StepDownloadISO{
+			Checksum:     b.config.ISOChecksum,
+			ChecksumType: b.config.ISOChecksumType,
+			Urls:         b.config.ISOUrls,
+		},
+		&common.StepPrepareOutputDir{
+			Dir:   b.config.OutputDir,
+			Force: b.config.PackerForce,
+		},
+		&common.StepHTTPServer{
+			Dir:     b.config.HTTPDir,
+			PortMin: b.config.HTTPPortMin,
+			PortMax: b.config.HTTPPortMax,
+		},
+		new(stepCreateVM),
+		new(stepResizeDisk),
+		new(stepAttachISO),
+		new(stepAttachParallelsTools),
+		new(stepConfigureVNC),
+		&stepPrlctl{commands: b.config.Prlctl},
+		new(stepRun),
+		new(stepTypeBootCommand),
+		new(stepWaitForSSH),
+		new(stepUploadParallelsTools),
+		new(stepProvision),
+		new(stepShutdown),
+		new(stepCompactDisk),
+	}
+
+	// Setup the state bag
+	state := make(map[string]interface{})
+	state["cache"] = cache
+	state["config"] = &b.config
+	state["driver"] = b.driver
+	state["hook"] = hook
+	state["ui"] = ui
+
+	// Run
+	b.runner = common.NewRunner(steps, b.config.PackerDebug, b.config.PackerOnError, ui)
+
+	b.runner.Run(state)
+	common.ReportStepTimings(ui, state)
+
+	// If there was an error, return that
+	if rawErr, ok := state["error"]; ok {
+		return nil, rawErr.(error)
+	}
+
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state[multistep.StateCancelled]; ok {
+		return nil, errors.New("Build was cancelled.")
+	}
+
+	if _, ok := state[multistep.StateHalted]; ok {
+		return nil, errors.New("Build was halted.")
+	}
+
+	return NewArtifact(b.config.OutputDir)
+}
+
+func (b *Builder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
+
+func newDriver() (Driver, error) {
+	prlctlPath, err := exec.LookPath("prlctl")
+	if err != nil {
+		return nil, err
+	}
+
+	prlDiskToolPath, err := exec.LookPath("prl_disk_tool")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("prlctl path: %s, prl_disk_tool path: %s", prlctlPath, prlDiskToolPath)
+	driver := &Parallels9Driver{
+		PrlctlPath:      prlctlPath,
+		PrlDiskToolPath: prlDiskToolPath,
+		AppPath:         "/Applications/Parallels Desktop.app",
+		DHCPLeasesPath:  "/Library/Preferences/Parallels/parallels_dhcp_leases",
+	}
+	if err := driver.Verify(); err != nil {
+		return nil, err
+	}
+
+	return driver, nil
+}
@@ -0,0 +1,171 @@
+package parallels
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"testing"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"iso_checksum":           "foo",
+		"iso_checksum_type":      "md5",
+		"iso_url":                "http://www.google.com/",
+		"parallels_tools_flavor": "lin",
+		"ssh_username":           "foo",
+
+		packer.BuildNameConfigKey: "foo",
+	}
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Error("Builder must implement builder.")
+	}
+}
+
+func TestBuilderPrepare_Defaults(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.GuestOSType != "other" {
+		t.Errorf("bad guest OS type: %s", b.config.GuestOSType)
+	}
+
+	if b.config.OutputDir != "output-foo" {
+		t.Errorf("bad output dir: %s", b.config.OutputDir)
+	}
+
+	if b.config.ParallelsToolsMode != ParallelsToolsModeUpload {
+		t.Errorf("bad parallels tools mode: %s", b.config.ParallelsToolsMode)
+	}
+
+	if b.config.SSHPort != 22 {
+		t.Errorf("bad ssh port: %d", b.config.SSHPort)
+	}
+
+	if b.config.VMName != "packer-foo" {
+		t.Errorf("bad vm name: %s", b.config.VMName)
+	}
+}
+
+func TestBuilderPrepare_ISOChecksumType(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test missing
+	delete(config, "iso_checksum_type")
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test none, which doesn't need a checksum
+	config["iso_checksum_type"] = "none"
+	delete(config, "iso_checksum")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_ISOUrl(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test both set
+	config["iso_urls"] = []string{"http://www.packer.io"}
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test neither set
+	delete(config, "iso_url")
+	delete(config, "iso_urls")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_ParallelsToolsMode(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test bad
+	config["parallels_tools_mode"] = "foo"
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test good
+	config["parallels_tools_mode"] = ParallelsToolsModeAttach
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_ParallelsToolsFlavor(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test bad
+	config["parallels_tools_flavor"] = "foo"
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test missing
+	delete(config, "parallels_tools_flavor")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test missing, but not needed
+	config["parallels_tools_mode"] = ParallelsToolsModeDisable
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_Prlctl(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test with a good one
+	config["prlctl"] = [][]string{
+		[]string{"set", "{{.Name}}", "--memsize", "1024"},
+	}
+
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test with an empty command
+	config["prlctl"] = [][]string{
+		[]string{},
+	}
+
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,223 @@
+package parallels
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A driver is able to talk to Parallels Desktop and perform certain
+// operations with it.
+type Driver interface {
+	// CompactDisk compacts the virtual disk at the given path, so that it
+	// only takes up as much space as the data within it.
+	CompactDisk(string) error
+
+	// DiskPath returns the path to the image of the first hard disk of
+	// the VM with the given name.
+	DiskPath(string) (string, error)
+
+	// IpAddress returns the IP address that the Parallels DHCP server has
+	// leased to the network adapter with the given MAC address.
+	IpAddress(string) (string, error)
+
+	// Checks if the VM with the given name is running.
+	IsRunning(string) (bool, error)
+
+	// ListVMs returns the names of all the VMs that are registered with
+	// Parallels Desktop.
+	ListVMs() ([]string, error)
+
+	// Mac returns the MAC address of the first network adapter of the VM
+	// with the given name.
+	Mac(string) (string, error)
+
+	// Prlctl executes the given prlctl command
+	Prlctl(...string) error
+
+	// PrlDiskTool executes the given prl_disk_tool command
+	PrlDiskTool(...string) error
+
+	// Stop stops a running machine, forcefully.
+	Stop(string) error
+
+	// ToolsIsoPath returns the path to the Parallels Tools ISO of the
+	// given flavor that ships with Parallels Desktop.
+	ToolsIsoPath(string) (string, error)
+
+	// Verify checks to make sure that this driver should function
+	// properly. If there is any indication the driver can't function,
+	// this will return an error.
+	Verify() error
+}
+
+type Parallels9Driver struct {
+	// This is the path to the "prlctl" application.
+	PrlctlPath string
+
+	// This is the path to the "prl_disk_tool" application.
+	PrlDiskToolPath string
+
+	// This is the path to the Parallels Desktop application bundle,
+	// which contains the Parallels Tools ISOs.
+	AppPath string
+
+	// This is the path to the file the Parallels DHCP server records
+	// its leases in.
+	DHCPLeasesPath string
+}
+
+func (d *Parallels9Driver) CompactDisk(path string) error {
+	return d.PrlDiskTool("compact", "--hdd", path)
+}
+
+func (d *Parallels9Driver) DiskPath(name string) (string, error) {
+	stdout, err := d.prlctlOutput("list", "--info", name)
+	if err != nil {
+		return "", err
+	}
+
+	re := regexp.MustCompile(`hdd0 \(\+\) .* image='([^']+)'`)
+	matches := re.FindStringSubmatch(stdout)
+	if matches == nil {
+		return "", fmt.Errorf("Couldn't find the hard disk of VM %s", name)
+	}
+
+	return matches[1], nil
+}
+
+func (d *Parallels9Driver) IpAddress(mac string) (string, error) {
+	f, err := os.Open(d.DHCPLeasesPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// The leases are lines such as the following, where the MAC address
+	// is the third field of the value. Later leases win, since they are
+	// the newer ones.
+	//
+	//   10.211.55.6="1385765260,1800,001c42f4f8a1,01001c42f4f8a1"
+	re := regexp.MustCompile(`^([0-9.]+)="[^,]*,[^,]*,([0-9a-fA-F]+),`)
+
+	ip := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		matches := re.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if matches != nil && strings.EqualFold(matches[2], mac) {
+			ip = matches[1]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if ip == "" {
+		return "", fmt.Errorf("IP address for MAC %s not found", mac)
+	}
+
+	log.Printf("IP address for MAC %s: %s", mac, ip)
+	return ip, nil
+}
+
+func (d *Parallels9Driver) IsRunning(name string) (bool, error) {
+	stdout, err := d.prlctlOutput("list", name, "--no-header", "--output", "status")
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(stdout) == "running", nil
+}
+
+func (d *Parallels9Driver) ListVMs() ([]string, error) {
+	stdout, err := d.prlctlOutput("list", "--all", "--no-header", "--output", "name")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0)
+	for _, line := range strings.Split(stdout, "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			result = append(result, name)
+		}
+	}
+
+	return result, nil
+}
+
+func (d *Parallels9Driver) Mac(name string) (string, error) {
+	stdout, err := d.prlctlOutput("list", "--info", name)
+	if err != nil {
+		return "", err
+	}
+
+	re := regexp.MustCompile(`net0 \(\+\) .* mac=([0-9a-fA-F]{12})`)
+	matches := re.FindStringSubmatch(stdout)
+	if matches == nil {
+		return "", fmt.Errorf("Couldn't find the MAC address of VM %s", name)
+	}
+
+	return matches[1], nil
+}
+
+func (d *Parallels9Driver) Prlctl(args ...string) error {
+	_, err := d.prlctlOutput(args...)
+	return err
+}
+
+func (d *Parallels9Driver) PrlDiskTool(args ...string) error {
+	_, err := d.run(d.PrlDiskToolPath, args...)
+	return err
+}
+
+func (d *Parallels9Driver) Stop(name string) error {
+	return d.Prlctl("stop", name, "--kill")
+}
+
+func (d *Parallels9Driver) ToolsIsoPath(flavor string) (string, error) {
+	path := filepath.Join(
+		d.AppPath, "Contents", "Resources", "Tools",
+		fmt.Sprintf("prl-tools-%s.iso", flavor))
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("Parallels Tools ISO not found: %s", err)
+	}
+
+	return path, nil
+}
+
+func (d *Parallels9Driver) Verify() error {
+	return nil
+}
+
+func (d *Parallels9Driver) prlctlOutput(args ...string) (string, error) {
+	return d.run(d.PrlctlPath, args...)
+}
+
+func (d *Parallels9Driver) run(path string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	log.Printf("Executing %s: %#v", filepath.Base(path), args)
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	stdoutString := strings.TrimSpace(stdout.String())
+	stderrString := strings.TrimSpace(stderr.String())
+
+	if _, ok := err.(*exec.ExitError); ok {
+		err = fmt.Errorf("%s error: %s", filepath.Base(path), stderrString)
+	}
+
+	log.Printf("stdout: %s", stdoutString)
+	log.Printf("stderr: %s", stderrString)
+
+	return stdout.String(), err
+}
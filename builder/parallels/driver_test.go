@@ -0,0 +1,41 @@
+package parallels
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestParallels9Driver_impl(t *testing.T) {
+	var _ Driver = new(Parallels9Driver)
+}
+
+func TestParallels9Driver_IpAddress(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tf.WriteString("[vnic0]\n")
+	tf.WriteString("10.211.55.5=\"1385765000,1800,001c42f4f8a1,01001c42f4f8a1\"\n")
+	tf.WriteString("10.211.55.6=\"1385765260,1800,001c4284f068,01001c4284f068\"\n")
+	tf.WriteString("10.211.55.7=\"1385765400,1800,001c42f4f8a1,01001c42f4f8a1\"\n")
+	tf.Close()
+
+	d := &Parallels9Driver{DHCPLeasesPath: tf.Name()}
+
+	// The most recent lease wins
+	ip, err := d.IpAddress("001C42F4F8A1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if ip != "10.211.55.7" {
+		t.Fatalf("bad: %s", ip)
+	}
+
+	if _, err := d.IpAddress("001c42000000"); err == nil {
+		t.Fatal("should have error")
+	}
+}
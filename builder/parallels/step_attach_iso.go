@@ -0,0 +1,78 @@
+package parallels
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step attaches the ISO to the virtual machine.
+//
+// Uses:
+//   driver Driver
+//   iso_path string
+//   ui     packer.Ui
+//   vmName string
+//
+// Produces:
+type stepAttachISO struct {
+	vmName string
+}
+
+func (s *stepAttachISO) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var driver Driver
+	var isoPath string
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("driver", &driver, "iso_path", &isoPath, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	// Attach the disk to the CD drive of the VM
+	ui.Say("Attaching ISO onto CD drive...")
+	command := []string{
+		"set", vmName,
+		"--device-set", "cdrom0",
+		"--image", isoPath,
+		"--enable", "--connect",
+	}
+	if err := driver.Prlctl(command...); err != nil {
+		err := fmt.Errorf("Error attaching ISO: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Track the VM name so that we can detach the ISO later
+	s.vmName = vmName
+
+	return multistep.ActionContinue
+}
+
+func (s *stepAttachISO) Cleanup(state map[string]interface{}) {
+	if s.vmName == "" {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	log.Println("Detaching ISO from the CD drive...")
+	command := []string{
+		"set", s.vmName,
+		"--device-set", "cdrom0",
+		"--disconnect",
+	}
+
+	if err := driver.Prlctl(command...); err != nil {
+		ui.Error(fmt.Sprintf("Error detaching ISO: %s", err))
+	}
+}
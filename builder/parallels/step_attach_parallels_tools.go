@@ -0,0 +1,88 @@
+package parallels
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step attaches the Parallels Tools as an inserted CD onto the
+// virtual machine, in a second CD drive.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui packer.Ui
+//   vmName string
+//
+// Produces:
+type stepAttachParallelsTools struct {
+	vmName string
+}
+
+func (s *stepAttachParallelsTools) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	// If we're not attaching the Parallels Tools then just return
+	if config.ParallelsToolsMode != ParallelsToolsModeAttach {
+		log.Println("Not attaching Parallels Tools since mode is not attach")
+		return multistep.ActionContinue
+	}
+
+	toolsPath, err := driver.ToolsIsoPath(config.ParallelsToolsFlavor)
+	if err != nil {
+		err := fmt.Errorf("Error finding Parallels Tools: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Attach the Parallels Tools to the computer
+	ui.Say("Attaching Parallels Tools ISO onto a new CD drive...")
+	command := []string{
+		"set", vmName,
+		"--device-add", "cdrom",
+		"--image", toolsPath,
+	}
+	if err := driver.Prlctl(command...); err != nil {
+		err := fmt.Errorf("Error attaching Parallels Tools: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Track the VM name so that we can remove the CD drive later
+	s.vmName = vmName
+
+	return multistep.ActionContinue
+}
+
+func (s *stepAttachParallelsTools) Cleanup(state map[string]interface{}) {
+	if s.vmName == "" {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	// The VM is created with a single CD drive, so the one we added is
+	// always the second one.
+	log.Println("Removing the Parallels Tools CD drive...")
+	if err := driver.Prlctl("set", s.vmName, "--device-del", "cdrom1"); err != nil {
+		ui.Error(fmt.Sprintf("Error removing Parallels Tools: %s", err))
+	}
+}
@@ -0,0 +1,42 @@
+package parallels
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+)
+
+// This step compacts the virtual disk for the VM, so that the artifact
+// only contains the space that is actually used.
+//
+// Uses:
+//   disk_path string
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   <nothing>
+type stepCompactDisk struct{}
+
+func (stepCompactDisk) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var diskPath string
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("disk_path", &diskPath, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say("Compacting the disk image")
+	if err := driver.CompactDisk(diskPath); err != nil {
+		err := fmt.Errorf("Error compacting disk: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (stepCompactDisk) Cleanup(map[string]interface{}) {}
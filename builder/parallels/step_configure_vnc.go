@@ -0,0 +1,86 @@
+package parallels
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"strconv"
+)
+
+// This step enables the VNC server of the VM on an available port, which
+// is used to type the boot command.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//   vmName string
+//
+// Produces:
+//   vnc_port uint - The port that VNC is configured to listen on.
+type stepConfigureVNC struct {
+	vmName string
+}
+
+func (s *stepConfigureVNC) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	msg := fmt.Sprintf("Looking for available port between %d and %d", config.VNCPortMin, config.VNCPortMax)
+	ui.Say(msg)
+	log.Println(msg)
+	vncPort, err := findOpenPort(config.VNCPortMin, config.VNCPortMax)
+	if err != nil {
+		err := fmt.Errorf("Error finding port for VNC: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Found available VNC port: %d", vncPort))
+
+	command := []string{
+		"set", vmName,
+		"--vnc-mode", "manual",
+		"--vnc-port", strconv.FormatUint(uint64(vncPort), 10),
+		"--vnc-nopasswd",
+	}
+	if err := driver.Prlctl(command...); err != nil {
+		err := fmt.Errorf("Error enabling VNC: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.vmName = vmName
+	state["vnc_port"] = vncPort
+
+	return multistep.ActionContinue
+}
+
+func (s *stepConfigureVNC) Cleanup(state map[string]interface{}) {
+	if s.vmName == "" {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	// Don't leave the VNC server enabled in the resulting VM
+	if err := driver.Prlctl("set", s.vmName, "--vnc-mode", "off"); err != nil {
+		ui.Error(fmt.Sprintf("Error disabling VNC: %s", err))
+	}
+}
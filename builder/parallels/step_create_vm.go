@@ -0,0 +1,143 @@
+package parallels
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step creates the actual virtual machine in the output directory.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   vmName string - The name of the VM
+type stepCreateVM struct {
+	vmName string
+}
+
+func (s *stepCreateVM) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	name := config.VMName
+
+	commands := make([][]string, 2)
+	commands[0] = []string{
+		"create", name,
+		"--distribution", config.GuestOSType,
+		"--dst", config.OutputDir,
+	}
+	commands[1] = []string{"set", name, "--device-bootorder", "hdd0 cdrom0"}
+
+	if config.PackerForce {
+		if err := deleteExistingVM(driver, ui, name); err != nil {
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	ui.Say("Creating virtual machine...")
+	for _, command := range commands {
+		err := driver.Prlctl(command...)
+		if err != nil {
+			err := fmt.Errorf("Error creating VM: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		// Set the VM name property on the first command
+		if s.vmName == "" {
+			s.vmName = name
+		}
+	}
+
+	// Set the final name in the state bag so others can use it
+	state["vmName"] = s.vmName
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateVM) Cleanup(state map[string]interface{}) {
+	if s.vmName == "" {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	// After a successful build the VM files in the output directory are
+	// the artifact, so the VM is only unregistered from Parallels.
+	_, cancelled := state[multistep.StateCancelled]
+	_, halted := state[multistep.StateHalted]
+	if !cancelled && !halted {
+		ui.Say("Unregistering virtual machine...")
+		if err := driver.Prlctl("unregister", s.vmName); err != nil {
+			ui.Error(fmt.Sprintf("Error unregistering virtual machine: %s", err))
+		}
+
+		return
+	}
+
+	ui.Say("Deleting virtual machine...")
+	if err := driver.Prlctl("delete", s.vmName); err != nil {
+		ui.Error(fmt.Sprintf("Error deleting virtual machine: %s", err))
+	}
+}
+
+// deleteExistingVM stops and deletes the VM with the given name if it is
+// registered. Forced builds use this to replace a VM left behind by an
+// earlier build, such as one that failed with -on-error=abort.
+func deleteExistingVM(driver Driver, ui packer.Ui, name string) error {
+	names, err := driver.ListVMs()
+	if err != nil {
+		return fmt.Errorf("Error listing existing virtual machines: %s", err)
+	}
+
+	exists := false
+	for _, existing := range names {
+		if existing == name {
+			exists = true
+			break
+		}
+	}
+
+	if !exists {
+		return nil
+	}
+
+	running, err := driver.IsRunning(name)
+	if err != nil {
+		return fmt.Errorf("Error checking if existing virtual machine is running: %s", err)
+	}
+
+	ui.Say(fmt.Sprintf("Deleting existing virtual machine, since the build is forced: %s", name))
+	if running {
+		if err := driver.Stop(name); err != nil {
+			return fmt.Errorf("Error stopping existing virtual machine: %s", err)
+		}
+	}
+
+	if err := driver.Prlctl("delete", name); err != nil {
+		return fmt.Errorf("Error deleting existing virtual machine: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package parallels
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"strings"
+	"text/template"
+)
+
+type commandTemplate struct {
+	Name string
+}
+
+// This step executes additional prlctl commands as specified by the
+// template.
+//
+// Uses:
+//   driver Driver
+//   ui     packer.Ui
+//   vmName string
+//
+// Produces:
+type stepPrlctl struct {
+	commands [][]string
+}
+
+func (s *stepPrlctl) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	if len(s.commands) > 0 {
+		ui.Say("Executing custom prlctl commands...")
+	}
+
+	tplData := &commandTemplate{
+		Name: vmName,
+	}
+
+	for _, originalCommand := range s.commands {
+		command := make([]string, len(originalCommand))
+		copy(command, originalCommand)
+
+		for i, arg := range command {
+			var buf bytes.Buffer
+			t := template.Must(template.New("arg").Parse(arg))
+			t.Execute(&buf, tplData)
+			command[i] = buf.String()
+		}
+
+		ui.Message(fmt.Sprintf("Executing: %s", strings.Join(command, " ")))
+		if err := driver.Prlctl(command...); err != nil {
+			err := fmt.Errorf("Error executing command: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepPrlctl) Cleanup(state map[string]interface{}) {}
+
+// validatePrlctl verifies that the given list of prlctl commands are
+// valid.
+func validatePrlctl(commands [][]string) []error {
+	errs := make([]error, 0)
+	for i, command := range commands {
+		if len(command) == 0 {
+			errs = append(errs, fmt.Errorf("prlctl command %d is empty", i+1))
+			continue
+		}
+
+		for _, arg := range command {
+			if _, err := template.New("arg").Parse(arg); err != nil {
+				errs = append(errs, fmt.Errorf("Error parsing prlctl command %d: %s", i+1, err))
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,55 @@
+package parallels
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+)
+
+// This step resizes the hard disk that was created along with the VM to
+// the configured size.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//   vmName string
+//
+// Produces:
+//   disk_path string - The path to the hard disk image of the VM.
+type stepResizeDisk struct{}
+
+func (s *stepResizeDisk) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	diskPath, err := driver.DiskPath(vmName)
+	if err != nil {
+		err := fmt.Errorf("Error finding hard drive: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Resizing hard drive...")
+	size := fmt.Sprintf("%dM", config.DiskSize)
+	if err := driver.PrlDiskTool("resize", "--hdd", diskPath, "--size", size); err != nil {
+		err := fmt.Errorf("Error resizing hard drive: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state["disk_path"] = diskPath
+
+	return multistep.ActionContinue
+}
+
+func (s *stepResizeDisk) Cleanup(state map[string]interface{}) {}
@@ -0,0 +1,89 @@
+package parallels
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mitchellh/go-vnc"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"net"
+	"strconv"
+	"text/template"
+)
+
+// The address of the host as the guest sees it on the default shared
+// network of Parallels Desktop.
+const hostIP = "10.211.55.2"
+
+type bootCommandTemplateData struct {
+	HTTPIP   string
+	HTTPPort uint
+	Name     string
+}
+
+// This step "types" the boot command into the VM over VNC.
+//
+// Uses:
+//   config *config
+//   http_port int
+//   ui     packer.Ui
+//   vnc_port uint
+//
+// Produces:
+//   <nothing>
+type stepTypeBootCommand struct{}
+
+func (s *stepTypeBootCommand) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var httpPort uint
+	var ui packer.Ui
+	var vncPort uint
+	if err := bag.Values("config", &config, "http_port", &httpPort, "ui", &ui, "vnc_port", &vncPort); err != nil {
+		return bag.Halt(err)
+	}
+
+	// Connect to VNC
+	ui.Say("Connecting to VM via VNC")
+	nc, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(vncPort))))
+	if err != nil {
+		err := fmt.Errorf("Error connecting to VNC: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer nc.Close()
+
+	// Share the display so anyone watching the console stays connected
+	c, err := vnc.Client(nc, &vnc.ClientConfig{Exclusive: false})
+	if err != nil {
+		err := fmt.Errorf("Error handshaking with VNC: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer c.Close()
+
+	log.Printf("Connected to VNC desktop: %s", c.DesktopName)
+
+	tplData := &bootCommandTemplateData{
+		hostIP,
+		httpPort,
+		config.VMName,
+	}
+
+	ui.Say("Typing the boot command over VNC...")
+	for _, command := range config.BootCommand {
+		var buf bytes.Buffer
+		t := template.Must(template.New("boot").Parse(command))
+		t.Execute(&buf, tplData)
+
+		common.VNCSendString(c, buf.String())
+	}
+
+	return multistep.ActionContinue
+}
+
+func (*stepTypeBootCommand) Cleanup(map[string]interface{}) {}
@@ -0,0 +1,77 @@
+package parallels
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"os"
+	"text/template"
+)
+
+type toolsPathTemplate struct {
+	Flavor string
+}
+
+// This step uploads the Parallels Tools ISO to the VM.
+//
+// Uses:
+//   communicator packer.Communicator
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+type stepUploadParallelsTools struct{}
+
+func (s *stepUploadParallelsTools) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var comm packer.Communicator
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("communicator", &comm, "config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	// If we're attaching then don't do this, since we attached.
+	if config.ParallelsToolsMode != ParallelsToolsModeUpload {
+		log.Println("Not uploading Parallels Tools since mode is not upload")
+		return multistep.ActionContinue
+	}
+
+	toolsPath, err := driver.ToolsIsoPath(config.ParallelsToolsFlavor)
+	if err != nil {
+		err := fmt.Errorf("Error finding Parallels Tools: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	f, err := os.Open(toolsPath)
+	if err != nil {
+		state["error"] = fmt.Errorf("Error opening Parallels Tools ISO: %s", err)
+		return multistep.ActionHalt
+	}
+	defer f.Close()
+
+	tplData := &toolsPathTemplate{
+		Flavor: config.ParallelsToolsFlavor,
+	}
+
+	var processedPath bytes.Buffer
+	t := template.Must(template.New("path").Parse(config.ParallelsToolsGuestPath))
+	t.Execute(&processedPath, tplData)
+
+	ui.Say("Uploading Parallels Tools ISO...")
+	if err := comm.Upload(processedPath.String(), f); err != nil {
+		state["error"] = fmt.Errorf("Error uploading Parallels Tools: %s", err)
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepUploadParallelsTools) Cleanup(state map[string]interface{}) {}
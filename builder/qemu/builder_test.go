@@ -0,0 +1,239 @@
+package qemu
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"testing"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"iso_checksum":      "foo",
+		"iso_checksum_type": "md5",
+		"iso_url":           "http://www.google.com/",
+		"ssh_username":      "foo",
+
+		packer.BuildNameConfigKey: "foo",
+	}
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Error("Builder must implement builder.")
+	}
+}
+
+func TestBuilderPrepare_Defaults(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.Accelerator != "kvm" {
+		t.Errorf("bad accelerator: %s", b.config.Accelerator)
+	}
+
+	if b.config.Format != "qcow2" {
+		t.Errorf("bad format: %s", b.config.Format)
+	}
+
+	if b.config.OutputDir != "output-foo" {
+		t.Errorf("bad output dir: %s", b.config.OutputDir)
+	}
+
+	if b.config.SSHHostPortMin != 2222 {
+		t.Errorf("bad min ssh host port: %d", b.config.SSHHostPortMin)
+	}
+
+	if b.config.SSHHostPortMax != 4444 {
+		t.Errorf("bad max ssh host port: %d", b.config.SSHHostPortMax)
+	}
+
+	if b.config.SSHPort != 22 {
+		t.Errorf("bad ssh port: %d", b.config.SSHPort)
+	}
+
+	if b.config.VMName != "packer-foo" {
+		t.Errorf("bad vm name: %s", b.config.VMName)
+	}
+}
+
+func TestBuilderPrepare_Accelerator(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test bad
+	config["accelerator"] = "foo"
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test good
+	config["accelerator"] = "tcg"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_DiskInterface(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test bad
+	config["disk_interface"] = "foo"
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test good
+	config["disk_interface"] = "ide"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_Format(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test bad
+	config["format"] = "vmdk"
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test good
+	config["format"] = "raw"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_ISOChecksumType(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test missing
+	delete(config, "iso_checksum_type")
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test none, which doesn't need a checksum
+	config["iso_checksum_type"] = "none"
+	delete(config, "iso_checksum")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test unknown
+	config["iso_checksum_type"] = "fake"
+	config["iso_checksum"] = "foo"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_ISOUrl(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test both set
+	config["iso_urls"] = []string{"http://www.packer.io"}
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test only iso_urls
+	delete(config, "iso_url")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if len(b.config.ISOUrls) != 1 || b.config.ISOUrls[0] != "http://www.packer.io" {
+		t.Fatalf("bad: %#v", b.config.ISOUrls)
+	}
+
+	// Test neither set
+	delete(config, "iso_urls")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_QemuArgs(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test with a good one
+	config["qemuargs"] = [][]string{
+		[]string{"-m", "1024M"},
+	}
+
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test with one that isn't a flag
+	config["qemuargs"] = [][]string{
+		[]string{"1024M"},
+	}
+
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_VNCPort(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Bad, below the first display
+	config["vnc_port_min"] = 5000
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Bad, min greater than max
+	config["vnc_port_min"] = 6001
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Good
+	config["vnc_port_min"] = 5910
+	config["vnc_port_max"] = 5920
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
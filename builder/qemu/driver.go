@@ -0,0 +1,155 @@
+package qemu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A driver is able to talk to Qemu and perform certain operations with it.
+type Driver interface {
+	// Qemu starts the virtual machine process with the given arguments.
+	// Only one virtual machine can be running per driver.
+	Qemu(...string) error
+
+	// QemuImg executes the given qemu-img command.
+	QemuImg(...string) error
+
+	// IsRunning returns true if the virtual machine process is running.
+	IsRunning() bool
+
+	// Stop kills the virtual machine process, if it is running, and
+	// waits for it to exit.
+	Stop() error
+
+	// Verify checks to make sure that this driver should function
+	// properly. If there is any indication the driver can't function,
+	// this will return an error.
+	Verify() error
+}
+
+// The time to wait after starting Qemu to see if it exits right away,
+// which is how bad arguments are reported.
+var qemuStartDelay = 1 * time.Second
+
+type QemuDriver struct {
+	// This is the path to the Qemu binary of the system to emulate, such
+	// as "qemu-system-x86_64".
+	QemuPath string
+
+	// This is the path to the "qemu-img" binary.
+	QemuImgPath string
+
+	lock    sync.Mutex
+	vmCmd   *exec.Cmd
+	vmEndCh chan struct{}
+}
+
+func (d *QemuDriver) Qemu(args ...string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.vmCmd != nil {
+		return errors.New("Qemu is already running")
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	log.Printf("Executing %s: %#v", d.QemuPath, args)
+	cmd := exec.Command(d.QemuPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Error starting VM: %s", err)
+	}
+
+	endCh := make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		log.Printf("Qemu exited (%v). stdout: %s", err, strings.TrimSpace(stdout.String()))
+		log.Printf("Qemu stderr: %s", strings.TrimSpace(stderr.String()))
+		close(endCh)
+	}()
+
+	// Qemu exits right away if it doesn't like its arguments, so give it
+	// a moment and report that as an error.
+	select {
+	case <-endCh:
+		return fmt.Errorf("Qemu exited right away: %s", strings.TrimSpace(stderr.String()))
+	case <-time.After(qemuStartDelay):
+	}
+
+	d.vmCmd = cmd
+	d.vmEndCh = endCh
+	return nil
+}
+
+func (d *QemuDriver) QemuImg(args ...string) error {
+	var stdout, stderr bytes.Buffer
+
+	log.Printf("Executing qemu-img: %#v", args)
+	cmd := exec.Command(d.QemuImgPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	stdoutString := strings.TrimSpace(stdout.String())
+	stderrString := strings.TrimSpace(stderr.String())
+
+	if _, ok := err.(*exec.ExitError); ok {
+		err = fmt.Errorf("qemu-img error: %s", stderrString)
+	}
+
+	log.Printf("stdout: %s", stdoutString)
+	log.Printf("stderr: %s", stderrString)
+
+	return err
+}
+
+func (d *QemuDriver) IsRunning() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.vmCmd == nil {
+		return false
+	}
+
+	select {
+	case <-d.vmEndCh:
+		return false
+	default:
+		return true
+	}
+}
+
+func (d *QemuDriver) Stop() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.vmCmd == nil {
+		return nil
+	}
+
+	select {
+	case <-d.vmEndCh:
+	default:
+		if err := d.vmCmd.Process.Kill(); err != nil {
+			return err
+		}
+
+		<-d.vmEndCh
+	}
+
+	d.vmCmd = nil
+	d.vmEndCh = nil
+	return nil
+}
+
+func (d *QemuDriver) Verify() error {
+	return nil
+}
@@ -0,0 +1,48 @@
+package qemu
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQemuDriver_Impl(t *testing.T) {
+	var _ Driver = new(QemuDriver)
+}
+
+func TestQemuDriver_QemuStop(t *testing.T) {
+	old := qemuStartDelay
+	qemuStartDelay = 10 * time.Millisecond
+	defer func() { qemuStartDelay = old }()
+
+	d := &QemuDriver{QemuPath: "/bin/sh"}
+	if err := d.Qemu("-c", "sleep 30"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !d.IsRunning() {
+		t.Fatal("should be running")
+	}
+
+	if err := d.Qemu("-c", "sleep 30"); err == nil {
+		t.Fatal("should not start twice")
+	}
+
+	if err := d.Stop(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if d.IsRunning() {
+		t.Fatal("should not be running")
+	}
+}
+
+func TestQemuDriver_QemuExit(t *testing.T) {
+	d := &QemuDriver{QemuPath: "/bin/sh"}
+	if err := d.Qemu("-c", "echo bad arguments >&2; exit 1"); err == nil {
+		t.Fatal("should have error")
+	}
+
+	if d.IsRunning() {
+		t.Fatal("should not be running")
+	}
+}
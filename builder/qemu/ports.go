@@ -0,0 +1,27 @@
+package qemu
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+)
+
+// findOpenPort finds a port between min and max, inclusive, that nothing
+// is listening on yet. The port is only free at the time it is checked,
+// since Qemu has to be able to listen on it itself.
+func findOpenPort(min, max uint) (uint, error) {
+	portRange := int(max-min) + 1
+	start := rand.Intn(portRange)
+	for i := 0; i < portRange; i++ {
+		port := min + uint((start+i)%portRange)
+		log.Printf("Trying port: %d", port)
+		l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err == nil {
+			l.Close()
+			return port, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no open port found between %d and %d", min, max)
+}
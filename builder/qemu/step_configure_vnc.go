@@ -0,0 +1,47 @@
+package qemu
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step finds a port for the VNC display of the VM, which is used to
+// type the boot command.
+//
+// Uses:
+//   config *config
+//   ui     packer.Ui
+//
+// Produces:
+//   vnc_port uint - The port that VNC is configured to listen on.
+type stepConfigureVNC struct{}
+
+func (stepConfigureVNC) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	msg := fmt.Sprintf("Looking for available port between %d and %d", config.VNCPortMin, config.VNCPortMax)
+	ui.Say(msg)
+	log.Println(msg)
+	vncPort, err := findOpenPort(config.VNCPortMin, config.VNCPortMax)
+	if err != nil {
+		err := fmt.Errorf("Error finding port for VNC: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Found available VNC port: %d", vncPort))
+	state["vnc_port"] = vncPort
+
+	return multistep.ActionContinue
+}
+
+func (stepConfigureVNC) Cleanup(map[string]interface{}) {}
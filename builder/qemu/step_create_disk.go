@@ -0,0 +1,56 @@
+package qemu
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"path/filepath"
+	"strings"
+)
+
+// This step creates the virtual disk that will be used as the
+// hard drive for the virtual machine.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   disk_path string - The path to the disk image.
+type stepCreateDisk struct{}
+
+func (s *stepCreateDisk) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	path := filepath.Join(config.OutputDir, fmt.Sprintf("%s.%s", config.VMName,
+		strings.ToLower(config.Format)))
+
+	command := []string{
+		"create",
+		"-f", config.Format,
+		path,
+		fmt.Sprintf("%vM", config.DiskSize),
+	}
+
+	ui.Say("Creating hard drive...")
+	if err := driver.QemuImg(command...); err != nil {
+		err := fmt.Errorf("Error creating hard drive: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state["disk_path"] = path
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateDisk) Cleanup(state map[string]interface{}) {}
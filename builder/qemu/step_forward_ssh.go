@@ -0,0 +1,47 @@
+package qemu
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step finds a host port to forward to the SSH port of the guest
+// over the user mode network of Qemu.
+//
+// Uses:
+//   config *config
+//   ui     packer.Ui
+//
+// Produces:
+//   sshHostPort uint - The host port that is forwarded to SSH.
+type stepForwardSSH struct{}
+
+func (s *stepForwardSSH) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	log.Printf("Looking for available SSH port between %d and %d", config.SSHHostPortMin, config.SSHHostPortMax)
+	sshHostPort, err := findOpenPort(config.SSHHostPortMin, config.SSHHostPortMax)
+	if err != nil {
+		err := fmt.Errorf("Error finding port for SSH: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Found port for SSH (host port %d)", sshHostPort))
+
+	// Save the port we're using so that future steps can use it
+	state["sshHostPort"] = sshHostPort
+
+	return multistep.ActionContinue
+}
+
+func (s *stepForwardSSH) Cleanup(state map[string]interface{}) {}
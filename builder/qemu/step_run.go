@@ -0,0 +1,135 @@
+package qemu
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"time"
+)
+
+// This step starts the virtual machine.
+//
+// Uses:
+//   config *config
+//   disk_path string
+//   driver Driver
+//   iso_path string
+//   sshHostPort uint
+//   ui     packer.Ui
+//   vnc_port uint
+//
+// Produces:
+//   <nothing>
+type stepRun struct{}
+
+func (s *stepRun) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vncPort uint
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vnc_port", &vncPort); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say("Starting the virtual machine...")
+	if config.Headless == true {
+		ui.Message("WARNING: The VM will be started in headless mode, as configured.\n" +
+			"In headless mode, errors during the boot sequence or OS setup\n" +
+			"won't be easily visible. Use at your own discretion.")
+	}
+
+	ui.Message(fmt.Sprintf(
+		"The console of the VM can be viewed by connecting with a VNC\n"+
+			"client to 127.0.0.1:%d", vncPort))
+
+	args, err := qemuArgs(state)
+	if err != nil {
+		return bag.Halt(err)
+	}
+
+	if err := driver.Qemu(args...); err != nil {
+		err := fmt.Errorf("Error starting VM: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if int64(config.BootWait) > 0 {
+		ui.Say(fmt.Sprintf("Waiting %s for boot...", config.BootWait))
+		time.Sleep(config.BootWait)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRun) Cleanup(state map[string]interface{}) {
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	// Make sure the Qemu process doesn't outlive the build, no matter
+	// how it ended.
+	if driver.IsRunning() {
+		if err := driver.Stop(); err != nil {
+			ui.Error(fmt.Sprintf("Error shutting down VM: %s", err))
+		}
+	}
+}
+
+// qemuArgs builds the arguments to start Qemu with. Any flag given in
+// "qemuargs" replaces all the default arguments for that flag, and flags
+// without a default are added after the defaults.
+func qemuArgs(state map[string]interface{}) ([]string, error) {
+	bag := common.StateBag(state)
+	var config *config
+	var diskPath string
+	var isoPath string
+	var sshHostPort uint
+	var vncPort uint
+	if err := bag.Values("config", &config, "disk_path", &diskPath, "iso_path", &isoPath, "sshHostPort", &sshHostPort, "vnc_port", &vncPort); err != nil {
+		return nil, err
+	}
+
+	display := "sdl"
+	if config.Headless {
+		display = "none"
+	}
+
+	defaults := [][]string{
+		{"-name", config.VMName},
+		{"-machine", fmt.Sprintf("type=pc,accel=%s", config.Accelerator)},
+		{"-display", display},
+		{"-netdev", fmt.Sprintf("user,id=user.0,hostfwd=tcp::%d-:%d", sshHostPort, config.SSHPort)},
+		{"-device", fmt.Sprintf("%s,netdev=user.0", config.NetDevice)},
+		{"-drive", fmt.Sprintf("file=%s,if=%s,format=%s", diskPath, config.DiskInterface, config.Format)},
+		{"-cdrom", isoPath},
+		{"-boot", "once=d"},
+		{"-m", "512M"},
+		{"-vnc", fmt.Sprintf("127.0.0.1:%d", vncPort-5900)},
+	}
+
+	overridden := make(map[string]bool)
+	for _, arg := range config.QemuArgs {
+		overridden[arg[0]] = true
+	}
+
+	args := make([]string, 0, 2*(len(defaults)+len(config.QemuArgs)))
+	for _, arg := range defaults {
+		if !overridden[arg[0]] {
+			args = append(args, arg...)
+		}
+	}
+
+	for _, arg := range config.QemuArgs {
+		args = append(args, arg...)
+	}
+
+	return args, nil
+}
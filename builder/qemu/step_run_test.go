@@ -0,0 +1,98 @@
+package qemu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testQemuArgsState(c *config) map[string]interface{} {
+	return map[string]interface{}{
+		"config":      c,
+		"disk_path":   "output/packer.qcow2",
+		"iso_path":    "image.iso",
+		"sshHostPort": uint(2222),
+		"vnc_port":    uint(5901),
+	}
+}
+
+func TestQemuArgs(t *testing.T) {
+	c := &config{
+		Accelerator:   "kvm",
+		DiskInterface: "virtio",
+		Format:        "qcow2",
+		Headless:      true,
+		NetDevice:     "virtio-net",
+		SSHPort:       22,
+		VMName:        "packer",
+	}
+
+	expected := []string{
+		"-name", "packer",
+		"-machine", "type=pc,accel=kvm",
+		"-display", "none",
+		"-netdev", "user,id=user.0,hostfwd=tcp::2222-:22",
+		"-device", "virtio-net,netdev=user.0",
+		"-drive", "file=output/packer.qcow2,if=virtio,format=qcow2",
+		"-cdrom", "image.iso",
+		"-boot", "once=d",
+		"-m", "512M",
+		"-vnc", "127.0.0.1:1",
+	}
+
+	args, err := qemuArgs(testQemuArgsState(c))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
+
+func TestQemuArgs_QemuArgs(t *testing.T) {
+	c := &config{
+		Accelerator:   "tcg",
+		DiskInterface: "ide",
+		Format:        "raw",
+		NetDevice:     "e1000",
+		SSHPort:       22,
+		VMName:        "packer",
+		QemuArgs: [][]string{
+			{"-m", "1024M"},
+			{"-smp", "2"},
+			{"-boot", "order=cd"},
+		},
+	}
+
+	expected := []string{
+		"-name", "packer",
+		"-machine", "type=pc,accel=tcg",
+		"-display", "sdl",
+		"-netdev", "user,id=user.0,hostfwd=tcp::2222-:22",
+		"-device", "e1000,netdev=user.0",
+		"-drive", "file=output/packer.qcow2,if=ide,format=raw",
+		"-cdrom", "image.iso",
+		"-vnc", "127.0.0.1:1",
+		"-m", "1024M",
+		"-smp", "2",
+		"-boot", "order=cd",
+	}
+
+	args, err := qemuArgs(testQemuArgsState(c))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
+
+func TestQemuArgs_MissingState(t *testing.T) {
+	state := testQemuArgsState(new(config))
+	delete(state, "disk_path")
+
+	if _, err := qemuArgs(state); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -2,7 +2,9 @@ package virtualbox
 
 import (
 	"fmt"
+	"github.com/mitchellh/packer/packer"
 	"os"
+	"path/filepath"
 )
 
 // Artifact is the result of running the VirtualBox builder, namely a set
@@ -12,6 +14,28 @@ type Artifact struct {
 	f   []string
 }
 
+// NewArtifact returns a VirtualBox artifact containing the files
+// in the given directory.
+func NewArtifact(dir string) (packer.Artifact, error) {
+	files := make([]string, 0, 5)
+	visit := func(path string, info os.FileInfo, err error) error {
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+
+		return err
+	}
+
+	if err := filepath.Walk(dir, visit); err != nil {
+		return nil, err
+	}
+
+	return &Artifact{
+		dir: dir,
+		f:   files,
+	}, nil
+}
+
 func (*Artifact) BuilderId() string {
 	return BuilderId
 }
@@ -31,3 +55,46 @@ func (a *Artifact) String() string {
 func (a *Artifact) Destroy() error {
 	return os.RemoveAll(a.dir)
 }
+
+// VMArtifact is the result of a VirtualBox build that skipped the export,
+// namely the files of the machine as VirtualBox itself stores them. It has
+// its own builder ID since it contains no OVF.
+type VMArtifact struct {
+	name string
+	f    []string
+}
+
+// NewVMArtifact returns a VirtualBox artifact for the VM with the given
+// name, made up of the given files.
+func NewVMArtifact(name string, files []string) packer.Artifact {
+	return &VMArtifact{
+		name: name,
+		f:    files,
+	}
+}
+
+func (*VMArtifact) BuilderId() string {
+	return VMBuilderId
+}
+
+func (a *VMArtifact) Files() []string {
+	return a.f
+}
+
+func (a *VMArtifact) Id() string {
+	return a.name
+}
+
+func (a *VMArtifact) String() string {
+	return fmt.Sprintf("VM files for virtual machine: %s", a.name)
+}
+
+func (a *VMArtifact) Destroy() error {
+	for _, path := range a.f {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package virtualbox
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArtifact_Impl(t *testing.T) {
+	var raw interface{}
+	raw = &Artifact{}
+	if _, ok := raw.(packer.Artifact); !ok {
+		t.Fatal("Artifact must be a proper artifact")
+	}
+}
+
+func TestVMArtifact_Impl(t *testing.T) {
+	var raw interface{}
+	raw = &VMArtifact{}
+	if _, ok := raw.(packer.Artifact); !ok {
+		t.Fatal("VMArtifact must be a proper artifact")
+	}
+}
+
+func TestVMArtifact(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	vbox := filepath.Join(td, "foo.vbox")
+	vdi := filepath.Join(td, "foo.vdi")
+	for _, path := range []string{vbox, vdi} {
+		if err := ioutil.WriteFile(path, []byte("foo"), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	a := NewVMArtifact("foo", []string{vbox, vdi})
+	if a.BuilderId() != VMBuilderId {
+		t.Fatalf("bad: %s", a.BuilderId())
+	}
+
+	if a.Id() != "foo" {
+		t.Fatalf("bad: %s", a.Id())
+	}
+
+	if len(a.Files()) != 2 || a.Files()[0] != vbox || a.Files()[1] != vdi {
+		t.Fatalf("bad: %#v", a.Files())
+	}
+
+	if a.String() != "VM files for virtual machine: foo" {
+		t.Fatalf("bad: %s", a.String())
+	}
+
+	// Files that are already gone are not an error
+	if err := os.Remove(vdi); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := a.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := os.Stat(vbox); !os.IsNotExist(err) {
+		t.Fatalf("file should be removed: %s", vbox)
+	}
+}
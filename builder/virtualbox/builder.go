@@ -7,17 +7,30 @@ import (
 	"github.com/mitchellh/multistep"
 	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"io"
 	"log"
-	"net/url"
+	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"text/template"
 	"time"
 )
 
 const BuilderId = "mitchellh.virtualbox"
 
+// VMBuilderId is the ID of artifacts from builds that skipped the export.
+const VMBuilderId = "mitchellh.virtualbox.vm"
+
+// These are the different valid mode values for "guest_additions_mode" which
+// determine how guest additions are delivered to the guest.
+const (
+	GuestAdditionsModeDisable string = "disable"
+	GuestAdditionsModeAttach         = "attach"
+	GuestAdditionsModeUpload         = "upload"
+)
+
 type Builder struct {
 	config config
 	driver Driver
@@ -25,41 +38,87 @@ type Builder struct {
 }
 
 type config struct {
-	BootCommand        []string      `mapstructure:"boot_command"`
-	BootWait           time.Duration ``
-	DiskSize           uint          `mapstructure:"disk_size"`
-	GuestAdditionsPath string        `mapstructure:"guest_additions_path"`
-	GuestOSType        string        `mapstructure:"guest_os_type"`
-	Headless           bool          `mapstructure:"headless"`
-	HTTPDir            string        `mapstructure:"http_directory"`
-	HTTPPortMin        uint          `mapstructure:"http_port_min"`
-	HTTPPortMax        uint          `mapstructure:"http_port_max"`
-	ISOMD5             string        `mapstructure:"iso_md5"`
-	ISOUrl             string        `mapstructure:"iso_url"`
-	OutputDir          string        `mapstructure:"output_directory"`
-	ShutdownCommand    string        `mapstructure:"shutdown_command"`
-	ShutdownTimeout    time.Duration ``
-	SSHHostPortMin     uint          `mapstructure:"ssh_host_port_min"`
-	SSHHostPortMax     uint          `mapstructure:"ssh_host_port_max"`
-	SSHPassword        string        `mapstructure:"ssh_password"`
-	SSHPort            uint          `mapstructure:"ssh_port"`
-	SSHUser            string        `mapstructure:"ssh_username"`
-	SSHWaitTimeout     time.Duration ``
-	VBoxVersionFile    string        `mapstructure:"virtualbox_version_file"`
-	VBoxManage         [][]string    `mapstructure:"vboxmanage"`
-	VMName             string        `mapstructure:"vm_name"`
-
-	PackerBuildName string `mapstructure:"packer_build_name"`
-	PackerDebug     bool   `mapstructure:"packer_debug"`
-
-	RawBootWait        string `mapstructure:"boot_wait"`
-	RawShutdownTimeout string `mapstructure:"shutdown_timeout"`
-	RawSSHWaitTimeout  string `mapstructure:"ssh_wait_timeout"`
+	AdditionalDiskSize   []uint           `mapstructure:"disk_additional_size"`
+	BootCommand          []string         `mapstructure:"boot_command"`
+	BootWait             time.Duration    ``
+	DiskSize             uint             `mapstructure:"disk_size"`
+	ExportManifest       bool             `mapstructure:"export_manifest"`
+	Firmware             string           `mapstructure:"firmware"`
+	FloppyFiles          []string         `mapstructure:"floppy_files"`
+	ForceDeleteOutput    bool             `mapstructure:"force_delete_output"`
+	Format               string           `mapstructure:"format"`
+	GuestAdditionsMode   string           `mapstructure:"guest_additions_mode"`
+	GuestAdditionsPath   string           `mapstructure:"guest_additions_path"`
+	GuestAdditionsURL    string           `mapstructure:"guest_additions_url"`
+	GuestAdditionsSHA256 string           `mapstructure:"guest_additions_sha256"`
+	GuestOSType          string           `mapstructure:"guest_os_type"`
+	HardDriveInterface   string           `mapstructure:"hard_drive_interface"`
+	Headless             bool             `mapstructure:"headless"`
+	HTTPDir              string           `mapstructure:"http_directory"`
+	HTTPPortMin          uint             `mapstructure:"http_port_min"`
+	HTTPPortMax          uint             `mapstructure:"http_port_max"`
+	ISOChecksum          string           `mapstructure:"iso_checksum"`
+	ISOChecksumType      string           `mapstructure:"iso_checksum_type"`
+	ISOMD5               string           `mapstructure:"iso_md5"`
+	ISOTargetPath        string           `mapstructure:"iso_target_path"`
+	ISOUrls              []string         `mapstructure:"iso_urls"`
+	KeepAttachedISO      bool             `mapstructure:"keep_attached_iso"`
+	KeepRegistered       bool             `mapstructure:"keep_registered"`
+	KeepSSHForwarding    bool             `mapstructure:"keep_ssh_forwarding"`
+	NetworkAdapters      []networkAdapter `mapstructure:"network_adapters"`
+	NICType              string           `mapstructure:"nic_type"`
+	OutputDir            string           `mapstructure:"output_directory"`
+	PostShutdownDelay    time.Duration    ``
+	RTCTimeBase          string           `mapstructure:"rtc_time_base"`
+	ShutdownCommand      string           `mapstructure:"shutdown_command"`
+	ShutdownTimeout      time.Duration    ``
+	ShutdownValidCodes   []int            `mapstructure:"shutdown_command_valid_exit_codes"`
+	SkipExport           bool             `mapstructure:"skip_export"`
+	SkipOSTypeCheck      bool             `mapstructure:"skip_os_type_check"`
+	Sound                bool             `mapstructure:"sound"`
+	SourcePath           string           `mapstructure:"source_path"`
+	SourceSnapshot       string           `mapstructure:"source_snapshot"`
+	SourceVM             string           `mapstructure:"source_vm"`
+	SSHHostPortMin       uint             `mapstructure:"ssh_host_port_min"`
+	SSHHostPortMax       uint             `mapstructure:"ssh_host_port_max"`
+	SSHPassword          string           `mapstructure:"ssh_password"`
+	SSHPrivateKeyFile    string           `mapstructure:"ssh_private_key_file"`
+	SSHKeyPassphrase     string           `mapstructure:"ssh_key_passphrase"`
+	SSHAgentAuth         bool             `mapstructure:"ssh_agent_auth"`
+	SSHPort              uint             `mapstructure:"ssh_port"`
+	SSHUser              string           `mapstructure:"ssh_username"`
+	SSHWaitTimeout       time.Duration    ``
+	USB                  bool             `mapstructure:"usb"`
+	VBoxVersionFile      *string          `mapstructure:"virtualbox_version_file"`
+	VBoxVersionMin       string           `mapstructure:"virtualbox_version_min"`
+	VBoxManage           [][]string       `mapstructure:"vboxmanage"`
+	VBoxManagePost       [][]string       `mapstructure:"vboxmanage_post"`
+	VMName               string           `mapstructure:"vm_name"`
+	VRDPBindAddress      string           `mapstructure:"vrdp_bind_address"`
+	VRDPPortMin          uint             `mapstructure:"vrdp_port_min"`
+	VRDPPortMax          uint             `mapstructure:"vrdp_port_max"`
+
+	PackerBuildName         string `mapstructure:"packer_build_name"`
+	PackerDebug             bool   `mapstructure:"packer_debug"`
+	PackerForce             bool   `mapstructure:"packer_force"`
+	PackerOnError           string `mapstructure:"packer_on_error"`
+	PackerTemplatePath      string `mapstructure:"packer_template_path"`
+	PackerTemplateTimestamp int64  `mapstructure:"packer_template_timestamp"`
+
+	RawBootWait          string `mapstructure:"boot_wait"`
+	RawPostShutdownDelay string `mapstructure:"post_shutdown_delay"`
+	RawSingleISOUrl      string `mapstructure:"iso_url"`
+	RawShutdownTimeout   string `mapstructure:"shutdown_timeout"`
+	RawSSHWaitTimeout    string `mapstructure:"ssh_wait_timeout"`
 }
 
 func (b *Builder) Prepare(raws ...interface{}) error {
 	var err error
 
+	// Start from scratch, since much of the config is derived from other
+	// keys, such as iso_urls from iso_url, and shouldn't carry over into
+	// a later Prepare.
+	b.config = config{}
 	for _, raw := range raws {
 		err := mapstructure.Decode(raw, &b.config)
 		if err != nil {
@@ -71,14 +130,30 @@ func (b *Builder) Prepare(raws ...interface{}) error {
 		b.config.DiskSize = 40000
 	}
 
-	if b.config.GuestAdditionsPath == "" {
-		b.config.GuestAdditionsPath = "VBoxGuestAdditions.iso"
+	if b.config.Firmware == "" {
+		b.config.Firmware = "bios"
+	}
+
+	if b.config.FloppyFiles == nil {
+		b.config.FloppyFiles = make([]string, 0)
 	}
 
 	if b.config.GuestOSType == "" {
 		b.config.GuestOSType = "Other"
 	}
 
+	if b.config.HardDriveInterface == "" {
+		b.config.HardDriveInterface = "ide"
+	}
+
+	if b.config.RTCTimeBase == "" {
+		b.config.RTCTimeBase = "local"
+	}
+
+	if b.config.NetworkAdapters == nil {
+		b.config.NetworkAdapters = make([]networkAdapter, 0)
+	}
+
 	if b.config.HTTPPortMin == 0 {
 		b.config.HTTPPortMin = 8000
 	}
@@ -87,148 +162,397 @@ func (b *Builder) Prepare(raws ...interface{}) error {
 		b.config.HTTPPortMax = 9000
 	}
 
-	if b.config.OutputDir == "" {
-		b.config.OutputDir = fmt.Sprintf("output-%s", b.config.PackerBuildName)
-	}
+	errs := b.config.prepareCommon()
 
-	if b.config.RawBootWait == "" {
-		b.config.RawBootWait = "10s"
-	}
+	for i, command := range b.config.BootCommand {
+		if _, err := template.New("boot").Parse(command); err != nil {
+			errs = append(errs, fmt.Errorf("Error parsing boot_command %d: %s", i+1, err))
+		}
 
-	if b.config.SSHHostPortMin == 0 {
-		b.config.SSHHostPortMin = 2222
+		for _, code := range common.UnknownBootCommandKeys(command) {
+			errs = append(errs, fmt.Errorf("Unknown special key in boot_command %d: %s", i+1, code))
+		}
 	}
 
-	if b.config.SSHHostPortMax == 0 {
-		b.config.SSHHostPortMax = 4444
+	for i, file := range b.config.FloppyFiles {
+		if _, err := filepath.Match(file, ""); err != nil {
+			errs = append(errs, fmt.Errorf("Bad pattern in floppy_files %d: %s", i+1, err))
+		}
 	}
 
-	if b.config.SSHPort == 0 {
-		b.config.SSHPort = 22
+	if b.config.Firmware != "bios" && b.config.Firmware != "efi" {
+		errs = append(errs, errors.New("firmware must be 'bios' or 'efi'"))
 	}
 
-	if b.config.VBoxManage == nil {
-		b.config.VBoxManage = make([][]string, 0)
+	if b.config.HardDriveInterface != "ide" &&
+		b.config.HardDriveInterface != "sata" &&
+		b.config.HardDriveInterface != "scsi" {
+		errs = append(
+			errs, errors.New("hard_drive_interface can only be ide, sata, or scsi"))
 	}
 
-	if b.config.VBoxVersionFile == "" {
-		b.config.VBoxVersionFile = ".vbox_version"
-	}
+	if max, ok := maxAdditionalDisks[b.config.HardDriveInterface]; ok {
+		if b.config.HardDriveInterface == "ide" && b.config.GuestAdditionsMode == GuestAdditionsModeAttach {
+			max = 0
+		}
 
-	if b.config.VMName == "" {
-		b.config.VMName = fmt.Sprintf("packer-%s", b.config.PackerBuildName)
+		if len(b.config.AdditionalDiskSize) > max {
+			errs = append(errs, fmt.Errorf(
+				"disk_additional_size can have at most %d disks for hard_drive_interface %s",
+				max, b.config.HardDriveInterface))
+		}
 	}
 
-	errs := make([]error, 0)
-
 	if b.config.HTTPPortMin > b.config.HTTPPortMax {
 		errs = append(errs, errors.New("http_port_min must be less than http_port_max"))
 	}
 
-	if b.config.ISOMD5 == "" {
-		errs = append(errs, errors.New("Due to large file sizes, an iso_md5 is required"))
-	} else {
-		b.config.ISOMD5 = strings.ToLower(b.config.ISOMD5)
-	}
+	if b.config.SourceVM != "" {
+		if b.config.SourceSnapshot == "" {
+			errs = append(errs, errors.New("A source_snapshot must be specified with source_vm."))
+		}
 
-	if b.config.ISOUrl == "" {
-		errs = append(errs, errors.New("An iso_url must be specified."))
+		if b.config.RawSingleISOUrl != "" || len(b.config.ISOUrls) > 0 {
+			errs = append(errs, errors.New("source_vm can't be used with iso_url or iso_urls."))
+		}
 	} else {
-		url, err := url.Parse(b.config.ISOUrl)
-		if err != nil {
-			errs = append(errs, fmt.Errorf("iso_url is not a valid URL: %s", err))
-		} else {
-			if url.Scheme == "" {
-				url.Scheme = "file"
-			}
+		// iso_md5 is the older way of specifying an md5 checksum, so we
+		// translate it into iso_checksum if it is set.
+		if b.config.ISOMD5 != "" {
+			b.config.ISOMD5 = strings.ToLower(b.config.ISOMD5)
+
+			if b.config.ISOChecksum == "" {
+				b.config.ISOChecksum = b.config.ISOMD5
 
-			if url.Scheme == "file" {
-				if _, err := os.Stat(url.Path); err != nil {
-					errs = append(errs, fmt.Errorf("iso_url points to bad file: %s", err))
+				if b.config.ISOChecksumType == "" {
+					b.config.ISOChecksumType = "md5"
 				}
-			} else {
-				supportedSchemes := []string{"file", "http", "https"}
-				scheme := strings.ToLower(url.Scheme)
-
-				found := false
-				for _, supported := range supportedSchemes {
-					if scheme == supported {
-						found = true
-						break
-					}
+			}
+		}
+
+		if b.config.ISOChecksumType == "" {
+			errs = append(errs, errors.New("The iso_checksum_type must be specified."))
+		} else {
+			b.config.ISOChecksumType = strings.ToLower(b.config.ISOChecksumType)
+			if b.config.ISOChecksumType != "none" {
+				if h := common.HashForType(b.config.ISOChecksumType); h == nil {
+					errs = append(
+						errs,
+						fmt.Errorf("Unsupported checksum type: %s", b.config.ISOChecksumType))
 				}
 
-				if !found {
-					errs = append(errs, fmt.Errorf("Unsupported URL scheme in iso_url: %s", scheme))
+				if b.config.ISOChecksum == "" {
+					errs = append(errs, errors.New("Due to large file sizes, an iso_checksum is required"))
+				} else {
+					b.config.ISOChecksum = strings.ToLower(b.config.ISOChecksum)
 				}
 			}
 		}
 
-		if len(errs) == 0 {
-			// Put the URL back together since we may have modified it
-			b.config.ISOUrl = url.String()
+		if b.config.RawSingleISOUrl == "" && len(b.config.ISOUrls) == 0 {
+			errs = append(errs, errors.New("One of iso_url or iso_urls must be specified."))
+		} else if b.config.RawSingleISOUrl != "" && len(b.config.ISOUrls) > 0 {
+			errs = append(errs, errors.New("Only one of iso_url or iso_urls may be specified."))
+		} else if b.config.RawSingleISOUrl != "" {
+			b.config.ISOUrls = []string{b.config.RawSingleISOUrl}
+		}
+
+		for i, url := range b.config.ISOUrls {
+			b.config.ISOUrls[i], err = common.DownloadableURL(url)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("Failed to parse iso_url %d: %s", i+1, err))
+			}
+		}
+
+		if b.config.ISOTargetPath != "" {
+			// Relative paths are relative to the directory of the template,
+			// or the directory Packer is run from without a template.
+			if !filepath.IsAbs(b.config.ISOTargetPath) && b.config.PackerTemplatePath != "" {
+				b.config.ISOTargetPath = filepath.Join(
+					filepath.Dir(b.config.PackerTemplatePath), b.config.ISOTargetPath)
+			}
+
+			b.config.ISOTargetPath, err = filepath.Abs(b.config.ISOTargetPath)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("iso_target_path is invalid: %s", err))
+			}
 		}
 	}
 
-	if _, err := os.Stat(b.config.OutputDir); err == nil {
-		errs = append(errs, errors.New("Output directory already exists. It must not exist."))
+	if b.config.RTCTimeBase != "local" && b.config.RTCTimeBase != "utc" {
+		errs = append(errs, errors.New("rtc_time_base must be 'local' or 'utc'"))
+	}
+
+	if nicType, ok := nicTypeAliases[b.config.NICType]; ok {
+		b.config.NICType = nicType
 	}
 
-	b.config.BootWait, err = time.ParseDuration(b.config.RawBootWait)
+	errs = append(errs, validateNetworkAdapters(b.config.NICType, b.config.NetworkAdapters)...)
+
+	b.driver, err = newDriver()
 	if err != nil {
-		errs = append(errs, fmt.Errorf("Failed parsing boot_wait: %s", err))
+		errs = append(errs, fmt.Errorf("Failed creating VirtualBox driver: %s", err))
+	} else {
+		errs = append(errs, validateHostOnlyNetworks(b.driver, b.config.NetworkAdapters)...)
+	}
+
+	if len(errs) > 0 {
+		return &packer.MultiError{errs}
+	}
+
+	return nil
+}
+
+// prepareCommon sets the defaults of the configuration shared by the ISO
+// and OVF builders, and validates it, returning any errors.
+func (c *config) prepareCommon() []error {
+	var err error
+
+	if c.Format == "" {
+		c.Format = "ovf"
+	}
+
+	if c.GuestAdditionsMode == "" {
+		c.GuestAdditionsMode = GuestAdditionsModeUpload
+	}
+
+	if c.GuestAdditionsPath == "" {
+		c.GuestAdditionsPath = "VBoxGuestAdditions.iso"
+	}
+
+	if c.OutputDir == "" {
+		c.OutputDir = fmt.Sprintf("output-%s", c.PackerBuildName)
+	}
+
+	if c.RawBootWait == "" {
+		c.RawBootWait = "10s"
+	}
+
+	if c.RawPostShutdownDelay == "" {
+		c.RawPostShutdownDelay = "0s"
+	}
+
+	if c.RawShutdownTimeout == "" {
+		c.RawShutdownTimeout = "5m"
+	}
+
+	if c.ShutdownValidCodes == nil {
+		c.ShutdownValidCodes = []int{0}
+	}
+
+	if c.RawSSHWaitTimeout == "" {
+		c.RawSSHWaitTimeout = "20m"
+	}
+
+	if c.SSHHostPortMin == 0 {
+		c.SSHHostPortMin = 2222
+	}
+
+	if c.SSHHostPortMax == 0 {
+		c.SSHHostPortMax = 4444
+	}
+
+	if c.VRDPBindAddress == "" {
+		c.VRDPBindAddress = "127.0.0.1"
+	}
+
+	if c.VRDPPortMin == 0 {
+		c.VRDPPortMin = 5900
+	}
+
+	if c.VRDPPortMax == 0 {
+		c.VRDPPortMax = 6000
 	}
 
-	if b.config.RawShutdownTimeout == "" {
-		b.config.RawShutdownTimeout = "5m"
+	if c.SSHPort == 0 {
+		c.SSHPort = 22
 	}
 
-	if b.config.RawSSHWaitTimeout == "" {
-		b.config.RawSSHWaitTimeout = "20m"
+	if c.VBoxManage == nil {
+		c.VBoxManage = make([][]string, 0)
 	}
 
-	b.config.ShutdownTimeout, err = time.ParseDuration(b.config.RawShutdownTimeout)
+	if c.VBoxManagePost == nil {
+		c.VBoxManagePost = make([][]string, 0)
+	}
+
+	// An empty virtualbox_version_file disables the upload, so only
+	// default it if it wasn't specified at all.
+	if c.VBoxVersionFile == nil {
+		versionFile := ".vbox_version"
+		c.VBoxVersionFile = &versionFile
+	}
+
+	if c.VBoxVersionMin == "" {
+		c.VBoxVersionMin = "4.1.0"
+	}
+
+	if c.VMName == "" {
+		c.VMName = fmt.Sprintf("packer-%s", c.PackerBuildName)
+	}
+
+	errs := make([]error, 0)
+
+	tpl := common.NewConfigTemplate(c.PackerBuildName, c.PackerTemplateTimestamp)
+	c.OutputDir, err = tpl.Process(c.OutputDir)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error processing output_directory: %s", err))
+	}
+
+	c.VMName, err = tpl.Process(c.VMName)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error processing vm_name: %s", err))
+	}
+
+	validMode := false
+	validModes := []string{
+		GuestAdditionsModeDisable,
+		GuestAdditionsModeAttach,
+		GuestAdditionsModeUpload,
+	}
+
+	for _, mode := range validModes {
+		if c.GuestAdditionsMode == mode {
+			validMode = true
+			break
+		}
+	}
+
+	if !validMode {
+		errs = append(errs,
+			fmt.Errorf("guest_additions_mode is invalid. Must be one of: %v", validModes))
+	}
+
+	if c.Format != "ovf" && c.Format != "ova" {
+		errs = append(errs, errors.New("invalid format, only 'ovf' or 'ova' are allowed"))
+	}
+
+	if c.GuestAdditionsSHA256 != "" {
+		c.GuestAdditionsSHA256 = strings.ToLower(c.GuestAdditionsSHA256)
+	}
+
+	if c.GuestAdditionsURL != "" {
+		c.GuestAdditionsURL, err = common.DownloadableURL(c.GuestAdditionsURL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("guest_additions_url: %s", err))
+		}
+	}
+
+	// Forcing the build is the same as deleting the output directory
+	if c.PackerForce {
+		c.ForceDeleteOutput = true
+	}
+
+	if !c.ForceDeleteOutput {
+		if err := validateOutputDir(c.OutputDir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	c.BootWait, err = time.ParseDuration(c.RawBootWait)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing boot_wait: %s", err))
+	} else if c.BootWait < 0 {
+		errs = append(errs, errors.New("boot_wait must not be negative"))
+	}
+
+	c.PostShutdownDelay, err = time.ParseDuration(c.RawPostShutdownDelay)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing post_shutdown_delay: %s", err))
+	} else if c.PostShutdownDelay < 0 {
+		errs = append(errs, errors.New("post_shutdown_delay must not be negative"))
+	}
+
+	c.ShutdownTimeout, err = time.ParseDuration(c.RawShutdownTimeout)
 	if err != nil {
 		errs = append(errs, fmt.Errorf("Failed parsing shutdown_timeout: %s", err))
 	}
 
-	if b.config.SSHHostPortMin > b.config.SSHHostPortMax {
+	if c.SSHHostPortMin > c.SSHHostPortMax {
 		errs = append(errs, errors.New("ssh_host_port_min must be less than ssh_host_port_max"))
 	}
 
-	if b.config.SSHUser == "" {
+	if c.SSHHostPortMin < 1 || c.SSHHostPortMax > 65535 {
+		errs = append(errs, errors.New("ssh_host_port_min and ssh_host_port_max must be between 1 and 65535"))
+	}
+
+	if c.VRDPPortMin > c.VRDPPortMax {
+		errs = append(errs, errors.New("vrdp_port_min must be less than vrdp_port_max"))
+	}
+
+	if c.VRDPPortMin < 1 || c.VRDPPortMax > 65535 {
+		errs = append(errs, errors.New("vrdp_port_min and vrdp_port_max must be between 1 and 65535"))
+	}
+
+	if c.SSHUser == "" {
 		errs = append(errs, errors.New("An ssh_username must be specified."))
 	}
 
-	b.config.SSHWaitTimeout, err = time.ParseDuration(b.config.RawSSHWaitTimeout)
-	if err != nil {
-		errs = append(errs, fmt.Errorf("Failed parsing ssh_wait_timeout: %s", err))
+	if c.SSHPrivateKeyFile != "" {
+		if _, err := common.SSHKeychain(c.SSHPrivateKeyFile, c.SSHKeyPassphrase); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_private_key_file is invalid: %s", err))
+		}
+	} else if c.SSHKeyPassphrase != "" {
+		errs = append(errs, errors.New("ssh_key_passphrase requires an ssh_private_key_file"))
 	}
 
-	b.driver, err = b.newDriver()
+	if c.SSHAgentAuth {
+		if _, conn, err := common.SSHAgent(); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_agent_auth is set, but the agent can't be used: %s", err))
+		} else {
+			conn.Close()
+		}
+	}
+
+	c.SSHWaitTimeout, err = time.ParseDuration(c.RawSSHWaitTimeout)
 	if err != nil {
-		errs = append(errs, fmt.Errorf("Failed creating VirtualBox driver: %s", err))
+		errs = append(errs, fmt.Errorf("Failed parsing ssh_wait_timeout: %s", err))
 	}
 
-	if len(errs) > 0 {
-		return &packer.MultiError{errs}
+	if _, err := parseVersion(c.VBoxVersionMin); err != nil {
+		errs = append(errs, fmt.Errorf("virtualbox_version_min is invalid: %s", err))
 	}
 
-	return nil
+	errs = append(errs, validateVBoxManage("vboxmanage", c.VBoxManage)...)
+	errs = append(errs, validateVBoxManage("vboxmanage_post", c.VBoxManagePost)...)
+	return errs
 }
 
 func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	// Seed the random number generator
+	rand.Seed(time.Now().UTC().UnixNano())
+
 	steps := []multistep.Step{
+		new(stepCheckVersion),
+		new(stepCheckOSType),
 		new(stepDownloadGuestAdditions),
-		new(stepDownloadISO),
-		new(stepPrepareOutputDir),
-		new(stepHTTPServer),
+		&common.StepDownloadISO{
+			Checksum:     b.config.ISOChecksum,
+			ChecksumType: b.config.ISOChecksumType,
+			TargetPath:   b.config.ISOTargetPath,
+			Urls:         b.config.ISOUrls,
+		},
+		new(stepCopyISO),
+		&common.StepPrepareOutputDir{
+			Dir:   b.config.OutputDir,
+			Force: b.config.ForceDeleteOutput,
+		},
+		&common.StepCreateFloppy{
+			Files: b.config.FloppyFiles,
+		},
+		&common.StepHTTPServer{
+			Dir:     b.config.HTTPDir,
+			PortMin: b.config.HTTPPortMin,
+			PortMax: b.config.HTTPPortMax,
+		},
 		new(stepSuppressMessages),
 		new(stepCreateVM),
 		new(stepCreateDisk),
 		new(stepAttachISO),
+		new(stepAttachGuestAdditions),
+		new(stepAttachFloppy),
 		new(stepForwardSSH),
-		new(stepVBoxManage),
+		&stepVBoxManage{commands: b.config.VBoxManage},
 		new(stepRun),
 		new(stepTypeBootCommand),
 		new(stepWaitForSSH),
@@ -236,9 +560,43 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		new(stepUploadGuestAdditions),
 		new(stepProvision),
 		new(stepShutdown),
+		new(stepRemoveDevices),
+		&stepVBoxManage{commands: b.config.VBoxManagePost},
 		new(stepExport),
 	}
 
+	// In snapshot mode the OS is already installed, so only the steps
+	// needed to provision and export the existing VM are run.
+	if b.config.SourceVM != "" {
+		steps = []multistep.Step{
+			new(stepCheckVersion),
+			new(stepDownloadGuestAdditions),
+			&common.StepPrepareOutputDir{
+				Dir:   b.config.OutputDir,
+				Force: b.config.ForceDeleteOutput,
+			},
+			new(stepSuppressMessages),
+			new(stepRestoreSnapshot),
+			new(stepAttachGuestAdditions),
+			new(stepForwardSSH),
+			&stepVBoxManage{commands: b.config.VBoxManage},
+			new(stepRun),
+			new(stepWaitForSSH),
+			new(stepUploadVersion),
+			new(stepUploadGuestAdditions),
+			new(stepProvision),
+			new(stepShutdown),
+			new(stepRemoveDevices),
+			&stepVBoxManage{commands: b.config.VBoxManagePost},
+			new(stepExport),
+		}
+	}
+
+	// Let the driver tell the user about any retried commands
+	if driver, ok := b.driver.(*VBox42Driver); ok {
+		driver.Ui = ui
+	}
+
 	// Setup the state bag
 	state := make(map[string]interface{})
 	state["cache"] = cache
@@ -248,16 +606,10 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	state["ui"] = ui
 
 	// Run
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewRunner(steps, b.config.PackerDebug, b.config.PackerOnError, ui)
 
 	b.runner.Run(state)
+	common.ReportStepTimings(ui, state)
 
 	// If there was an error, return that
 	if rawErr, ok := state["error"]; ok {
@@ -273,26 +625,17 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		return nil, errors.New("Build was halted.")
 	}
 
-	// Compile the artifact list
-	files := make([]string, 0, 5)
-	visit := func(path string, info os.FileInfo, err error) error {
-		if !info.IsDir() {
-			files = append(files, path)
+	if b.config.SkipExport {
+		var vmName string
+		var vmFiles []string
+		if err := common.StateBag(state).Values("vmName", &vmName, "vmFiles", &vmFiles); err != nil {
+			return nil, err
 		}
 
-		return err
-	}
-
-	if err := filepath.Walk(b.config.OutputDir, visit); err != nil {
-		return nil, err
-	}
-
-	artifact := &Artifact{
-		dir: b.config.OutputDir,
-		f:   files,
+		return NewVMArtifact(vmName, vmFiles), nil
 	}
 
-	return artifact, nil
+	return NewArtifact(b.config.OutputDir)
 }
 
 func (b *Builder) Cancel() {
@@ -302,17 +645,43 @@ func (b *Builder) Cancel() {
 	}
 }
 
-func (b *Builder) newDriver() (Driver, error) {
+func newDriver() (Driver, error) {
 	vboxmanagePath, err := exec.LookPath("VBoxManage")
 	if err != nil {
 		return nil, err
 	}
 
 	log.Printf("VBoxManage path: %s", vboxmanagePath)
-	driver := &VBox42Driver{vboxmanagePath}
+	driver := &VBox42Driver{
+		VBoxManagePath:   vboxmanagePath,
+		Retries:          vboxManageRetries,
+		RetryDelay:       vboxManageRetryDelay,
+		IsRunningTimeout: 1 * time.Minute,
+	}
 	if err := driver.Verify(); err != nil {
 		return nil, err
 	}
 
 	return driver, nil
 }
+
+// validateOutputDir checks that the output directory either doesn't exist
+// yet or is empty, so the build won't fail after already doing a lot of
+// work.
+func validateOutputDir(dir string) error {
+	f, err := os.Open(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Error checking output directory: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Readdirnames(1); err != io.EOF {
+		return fmt.Errorf(
+			"Output directory '%s' already exists and is not empty. "+
+				"Set force_delete_output to delete it.", dir)
+	}
+
+	return nil
+}
@@ -1,24 +1,51 @@
 package virtualbox
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"github.com/mitchellh/packer/packer"
+	"io"
 	"log"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
 // A driver is able to talk to VirtualBox and perform certain
 // operations with it.
 type Driver interface {
+	// Import imports the OVF or OVA at the given path as a new VM with
+	// the given name. Progress output from VirtualBox is sent line by
+	// line to the given function.
+	Import(string, string, func(string)) error
+
+	// ListHostOnlyNetworks returns the names of the host-only networks
+	// that are configured in VirtualBox.
+	ListHostOnlyNetworks() ([]string, error)
+
+	// ListOSTypes returns the IDs of all the guest OS types that this
+	// installation of VirtualBox supports.
+	ListOSTypes() ([]string, error)
+
 	// Checks if the VM with the given name is running.
 	IsRunning(string) (bool, error)
 
+	// ListVMs returns the names of all the VMs that are registered with
+	// VirtualBox.
+	ListVMs() ([]string, error)
+
 	// Stop stops a running machine, forcefully.
 	Stop(string) error
 
+	// StopViaACPI asks a running machine to shut itself down by pressing
+	// the ACPI power button. This returns once the request is sent, which
+	// doesn't mean the machine has stopped.
+	StopViaACPI(string) error
+
 	// SuppressMessages should do what needs to be done in order to
 	// suppress any annoying popups from VirtualBox.
 	SuppressMessages() error
@@ -26,6 +53,12 @@ type Driver interface {
 	// VBoxManage executes the given VBoxManage command
 	VBoxManage(...string) error
 
+	// VBoxManageStream executes the given VBoxManage command just like
+	// VBoxManage, but also sends each line of output, including progress,
+	// to the given function as it comes. This is meant for long running
+	// commands, so that the user can see that something is happening.
+	VBoxManageStream(func(string), ...string) error
+
 	// Verify checks to make sure that this driver should function
 	// properly. If there is any indication the driver can't function,
 	// this will return an error.
@@ -33,11 +66,52 @@ type Driver interface {
 
 	// Version reads the version of VirtualBox that is installed.
 	Version() (string, error)
+
+	// VMFiles returns the paths of the settings file and the hard disks
+	// of the VM with the given name.
+	VMFiles(string) ([]string, error)
+}
+
+// transientErrors are the errors from VBoxManage that usually go away if
+// the command is simply tried again a little later.
+var transientErrors = []string{
+	"VBOX_E_INVALID_OBJECT_STATE",
+	"is already locked for a session",
 }
 
+// The number of times a VBoxManage command that fails with a transient
+// error is retried, and the time to wait before the first retry.
+var (
+	vboxManageRetries    = 3
+	vboxManageRetryDelay = 2 * time.Second
+)
+
 type VBox42Driver struct {
 	// This is the path to the "VBoxManage" application.
 	VBoxManagePath string
+
+	// Retries is the number of times a VBoxManage command that fails with
+	// a transient error is retried, waiting RetryDelay before the first
+	// retry and twice as long before each one after that.
+	Retries    int
+	RetryDelay time.Duration
+
+	// IsRunningTimeout is how long checking if a VM is running may take
+	// before giving up. Zero means there is no deadline.
+	IsRunningTimeout time.Duration
+
+	// Ui, if set, is told about each retried command.
+	Ui packer.Ui
+}
+
+func (d *VBox42Driver) Import(path string, name string, output func(string)) error {
+	args := []string{
+		"import", path,
+		"--vsys", "0",
+		"--vmname", name,
+	}
+
+	return d.VBoxManageStream(output, args...)
 }
 
 func (d *VBox42Driver) IsRunning(name string) (bool, error) {
@@ -45,7 +119,7 @@ func (d *VBox42Driver) IsRunning(name string) (bool, error) {
 
 	cmd := exec.Command(d.VBoxManagePath, "showvminfo", name, "--machinereadable")
 	cmd.Stdout = &stdout
-	if err := cmd.Run(); err != nil {
+	if err := runWithTimeout(cmd, d.IsRunningTimeout); err != nil {
 		return false, err
 	}
 
@@ -64,6 +138,42 @@ func (d *VBox42Driver) IsRunning(name string) (bool, error) {
 	return false, nil
 }
 
+func (d *VBox42Driver) ListHostOnlyNetworks() ([]string, error) {
+	var stdout bytes.Buffer
+
+	cmd := exec.Command(d.VBoxManagePath, "list", "hostonlyifs")
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	return parseListField(stdout.String(), "Name:"), nil
+}
+
+func (d *VBox42Driver) ListVMs() ([]string, error) {
+	var stdout bytes.Buffer
+
+	cmd := exec.Command(d.VBoxManagePath, "list", "vms")
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	return parseVMNames(stdout.String()), nil
+}
+
+func (d *VBox42Driver) ListOSTypes() ([]string, error) {
+	var stdout bytes.Buffer
+
+	cmd := exec.Command(d.VBoxManagePath, "list", "ostypes")
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	return parseListField(stdout.String(), "ID:"), nil
+}
+
 func (d *VBox42Driver) Stop(name string) error {
 	if err := d.VBoxManage("controlvm", name, "poweroff"); err != nil {
 		return err
@@ -72,6 +182,14 @@ func (d *VBox42Driver) Stop(name string) error {
 	return nil
 }
 
+func (d *VBox42Driver) StopViaACPI(name string) error {
+	if err := d.VBoxManage("controlvm", name, "acpipowerbutton"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (d *VBox42Driver) SuppressMessages() error {
 	extraData := map[string]string{
 		"GUI/RegistrationData": "triesLeft=0",
@@ -90,6 +208,41 @@ func (d *VBox42Driver) SuppressMessages() error {
 }
 
 func (d *VBox42Driver) VBoxManage(args ...string) error {
+	return d.retry(func() error {
+		return d.vboxManage(args...)
+	})
+}
+
+func (d *VBox42Driver) VBoxManageStream(output func(string), args ...string) error {
+	return d.retry(func() error {
+		return d.vboxManageStream(output, args...)
+	})
+}
+
+// retry runs the given function, retrying it with a backoff as long as it
+// fails with a transient error and there are retries left.
+func (d *VBox42Driver) retry(f func() error) error {
+	delay := d.RetryDelay
+	for i := 0; ; i++ {
+		err := f()
+		if err == nil || i >= d.Retries || !isTransientError(err) {
+			return err
+		}
+
+		message := fmt.Sprintf(
+			"VBoxManage failed with a transient error, retrying in %s: %s", delay, err)
+		log.Println(message)
+		if d.Ui != nil {
+			d.Ui.Message(message)
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// vboxManage executes the given VBoxManage command once.
+func (d *VBox42Driver) vboxManage(args ...string) error {
 	var stdout, stderr bytes.Buffer
 
 	log.Printf("Executing VBoxManage: %#v", args)
@@ -111,6 +264,57 @@ func (d *VBox42Driver) VBoxManage(args ...string) error {
 	return err
 }
 
+// vboxManageStream executes the given VBoxManage command once, sending
+// each line of output to the given function as it becomes available.
+func (d *VBox42Driver) vboxManageStream(output func(string), args ...string) error {
+	var stderr bytes.Buffer
+
+	log.Printf("Executing VBoxManage: %#v", args)
+	cmd := exec.Command(d.VBoxManagePath, args...)
+	stdoutR, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	stderrR, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	streamFunc := func(r io.Reader) {
+		defer wg.Done()
+
+		scanner := bufio.NewScanner(r)
+		scanner.Split(scanProgressLines)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			log.Printf("VBoxManage output: %s", line)
+			output(line)
+		}
+	}
+
+	wg.Add(2)
+	go streamFunc(stdoutR)
+	go streamFunc(io.TeeReader(stderrR, &stderr))
+	wg.Wait()
+
+	err = cmd.Wait()
+	if _, ok := err.(*exec.ExitError); ok {
+		err = fmt.Errorf("VBoxManage error: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	return err
+}
+
 func (d *VBox42Driver) Verify() error {
 	return nil
 }
@@ -135,3 +339,146 @@ func (d *VBox42Driver) Version() (string, error) {
 	log.Printf("VirtualBox version: %s", matches[0])
 	return matches[0], nil
 }
+
+func (d *VBox42Driver) VMFiles(name string) ([]string, error) {
+	var stdout bytes.Buffer
+
+	cmd := exec.Command(d.VBoxManagePath, "showvminfo", name, "--machinereadable")
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	return parseVMFiles(stdout.String()), nil
+}
+
+// isTransientError checks if the error from VBoxManage is one that is
+// likely to go away if the command is retried.
+func isTransientError(err error) bool {
+	for _, transient := range transientErrors {
+		if strings.Contains(err.Error(), transient) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runWithTimeout runs the command, killing it if it hasn't finished
+// once the timeout has passed. A zero timeout waits forever.
+func runWithTimeout(cmd *exec.Cmd, timeout time.Duration) error {
+	if timeout == 0 {
+		return cmd.Run()
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-doneCh:
+		return err
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		<-doneCh
+		return fmt.Errorf("VBoxManage didn't finish within %s", timeout)
+	}
+}
+
+// scanProgressLines is a bufio.SplitFunc that splits on newlines and
+// carriage returns, as well as after the "..." that VirtualBox prints
+// after each progress percentage, so that progress can be shown as
+// it happens.
+func scanProgressLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if j := bytes.Index(data[:i], []byte("...")); j >= 0 {
+			return j + 3, data[:j+3], nil
+		}
+
+		return i + 1, data[:i], nil
+	}
+
+	if j := bytes.Index(data, []byte("...")); j >= 0 {
+		return j + 3, data[:j+3], nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+// parseListField parses the output of a "VBoxManage list" command, such
+// as "list ostypes", and returns the values of the given field, such as
+// "ID:", for every entry.
+func parseListField(output string, field string) []string {
+	result := make([]string, 0)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, field) {
+			continue
+		}
+
+		value := strings.TrimSpace(line[len(field):])
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
+
+// parseVMNames parses the output of "VBoxManage list vms", where each VM
+// is a line with its quoted name followed by its UUID in braces, and
+// returns the names.
+func parseVMNames(output string) []string {
+	result := make([]string, 0)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		end := strings.LastIndex(line, `" {`)
+		if !strings.HasPrefix(line, `"`) || end < 1 {
+			continue
+		}
+
+		result = append(result, line[1:end])
+	}
+
+	return result
+}
+
+// parseVMFiles parses the output of "VBoxManage showvminfo --machinereadable"
+// and returns the path of the settings file along with the paths of any
+// attached hard disks. Other media, such as ISOs, are left out.
+func parseVMFiles(output string) []string {
+	result := make([]string, 0)
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.Trim(parts[0], `"`)
+		value := strings.Trim(parts[1], `"`)
+		if key == "CfgFile" {
+			result = append(result, value)
+			continue
+		}
+
+		switch strings.ToLower(filepath.Ext(value)) {
+		case ".vdi", ".vmdk", ".vhd":
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
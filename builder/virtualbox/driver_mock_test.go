@@ -0,0 +1,102 @@
+package virtualbox
+
+import (
+	"sync"
+)
+
+// driverMock is a Driver that records the calls made to it and returns
+// canned results, for testing steps without VirtualBox.
+type driverMock struct {
+	sync.Mutex
+
+	IsRunningCalled bool
+	IsRunningResult bool
+	IsRunningErr    error
+
+	ListVMsResult []string
+	ListVMsErr    error
+
+	StopCalled bool
+	StopErr    error
+
+	StopViaACPICalled bool
+	StopViaACPIErr    error
+
+	VBoxManageCalls [][]string
+	VBoxManageErr   error
+
+	VersionResult string
+	VersionErr    error
+
+	VMFilesResult []string
+	VMFilesErr    error
+}
+
+func (d *driverMock) Import(string, string, func(string)) error {
+	return nil
+}
+
+func (d *driverMock) ListHostOnlyNetworks() ([]string, error) {
+	return nil, nil
+}
+
+func (d *driverMock) ListOSTypes() ([]string, error) {
+	return nil, nil
+}
+
+func (d *driverMock) IsRunning(string) (bool, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.IsRunningCalled = true
+	return d.IsRunningResult, d.IsRunningErr
+}
+
+func (d *driverMock) ListVMs() ([]string, error) {
+	return d.ListVMsResult, d.ListVMsErr
+}
+
+func (d *driverMock) Stop(string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.StopCalled = true
+	d.IsRunningResult = false
+	return d.StopErr
+}
+
+func (d *driverMock) StopViaACPI(string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.StopViaACPICalled = true
+	return d.StopViaACPIErr
+}
+
+func (d *driverMock) SuppressMessages() error {
+	return nil
+}
+
+func (d *driverMock) VBoxManage(args ...string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.VBoxManageCalls = append(d.VBoxManageCalls, args)
+	return d.VBoxManageErr
+}
+
+func (d *driverMock) VBoxManageStream(output func(string), args ...string) error {
+	return d.VBoxManage(args...)
+}
+
+func (d *driverMock) Verify() error {
+	return nil
+}
+
+func (d *driverMock) Version() (string, error) {
+	return d.VersionResult, d.VersionErr
+}
+
+func (d *driverMock) VMFiles(string) ([]string, error) {
+	return d.VMFilesResult, d.VMFilesErr
+}
@@ -0,0 +1,239 @@
+package virtualbox
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testVBoxManage creates a fake VBoxManage that records the arguments it
+// is called with and then runs the given script, returning the driver
+// using it and the path to the file where the arguments are recorded.
+// Within the script, $DIR is the directory of the fake VBoxManage.
+func testVBoxManage(t *testing.T, script string) (*VBox42Driver, string) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	argsPath := filepath.Join(dir, "args")
+	script = "#!/bin/sh\necho \"$@\" >> " + argsPath + "\n" + strings.Replace(script, "$DIR", dir, -1)
+	path := filepath.Join(dir, "VBoxManage")
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &VBox42Driver{VBoxManagePath: path}, argsPath
+}
+
+func testVBoxManageArgs(t *testing.T, path string) []string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestVBox42Driver_impl(t *testing.T) {
+	var _ Driver = new(VBox42Driver)
+}
+
+func TestScanProgressLines(t *testing.T) {
+	input := "0%...10%...20%\r30%...\nSuccessfully imported the appliance.\n"
+	scanner := bufio.NewScanner(strings.NewReader(input))
+	scanner.Split(scanProgressLines)
+
+	result := make([]string, 0)
+	for scanner.Scan() {
+		result = append(result, scanner.Text())
+	}
+
+	expected := []string{
+		"0%...",
+		"10%...",
+		"20%",
+		"30%...",
+		"",
+		"Successfully imported the appliance.",
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestVBox42Driver_Stop(t *testing.T) {
+	d, argsPath := testVBoxManage(t, "")
+	defer os.RemoveAll(filepath.Dir(argsPath))
+
+	if err := d.Stop("foo"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{"controlvm foo poweroff"}
+	if args := testVBoxManageArgs(t, argsPath); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
+
+func TestVBox42Driver_StopViaACPI(t *testing.T) {
+	d, argsPath := testVBoxManage(t, "")
+	defer os.RemoveAll(filepath.Dir(argsPath))
+
+	if err := d.StopViaACPI("foo"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{"controlvm foo acpipowerbutton"}
+	if args := testVBoxManageArgs(t, argsPath); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
+
+func TestParseListField(t *testing.T) {
+	output := `ID:          Other
+Description: Other/Unknown
+
+ID:          Ubuntu_64
+Description: Ubuntu (64 bit)
+`
+
+	expected := []string{"Other", "Ubuntu_64"}
+	if result := parseListField(output, "ID:"); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	output = `Name:            vboxnet0
+GUID:            786f6276-656e-4074-8000-0a0027000000
+IPAddress:       192.168.56.1
+
+Name:            vboxnet1
+`
+
+	expected = []string{"vboxnet0", "vboxnet1"}
+	if result := parseListField(output, "Name:"); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestParseVMNames(t *testing.T) {
+	output := `"packer-foo" {3a3e8a6c-1a0f-4d4e-9e44-0c1a2b3c4d5e}
+"my "quoted" vm" {0f4ae2b1-8c6d-4b4e-a1f2-5e6d7c8b9a0f}
+`
+
+	expected := []string{"packer-foo", `my "quoted" vm`}
+	if result := parseVMNames(output); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestParseVMFiles(t *testing.T) {
+	output := `name="packer"
+CfgFile="/vms/packer/packer.vbox"
+"IDE Controller-0-0"="/output/packer.vdi"
+"IDE Controller-0-1"="/cache/ubuntu.iso"
+"IDE Controller-1-0"="none"
+"SATA Controller-1-0"="/output/packer-1.VMDK"
+`
+
+	expected := []string{
+		"/vms/packer/packer.vbox",
+		"/output/packer.vdi",
+		"/output/packer-1.VMDK",
+	}
+	if result := parseVMFiles(output); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestVBox42DriverVBoxManage_retry(t *testing.T) {
+	// Fails with a transient error the first two times it is run
+	driver, _ := testVBoxManage(t, `
+echo x >> $DIR/count
+if [ $(wc -l < $DIR/count) -le 2 ]; then
+  echo "error: VBOX_E_INVALID_OBJECT_STATE" >&2
+  exit 1
+fi
+`)
+
+	driver.Retries = 1
+	if err := driver.VBoxManage("foo"); err == nil {
+		t.Fatal("should have error")
+	}
+
+	os.Remove(filepath.Join(filepath.Dir(driver.VBoxManagePath), "count"))
+	driver.Retries = 2
+	if err := driver.VBoxManage("foo"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestVBox42DriverVBoxManage_nonTransient(t *testing.T) {
+	driver, _ := testVBoxManage(t, `
+echo x >> $DIR/count
+echo "error: something bad" >&2
+exit 1
+`)
+
+	driver.Retries = 5
+	if err := driver.VBoxManage("foo"); err == nil {
+		t.Fatal("should have error")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(filepath.Dir(driver.VBoxManagePath), "count"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(data) != "x\n" {
+		t.Fatalf("should only run once: %q", data)
+	}
+}
+
+func TestVBox42DriverIsRunning_timeout(t *testing.T) {
+	driver, _ := testVBoxManage(t, "exec sleep 10\n")
+	driver.IsRunningTimeout = 50 * time.Millisecond
+
+	if _, err := driver.IsRunning("foo"); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestVBox42DriverVBoxManageStream(t *testing.T) {
+	driver, _ := testVBoxManage(t, `
+printf "0%%...10%%...20%%\r100%%\n" >&2
+echo "Successfully exported 1 machine(s)."
+`)
+
+	var lock sync.Mutex
+	result := make([]string, 0)
+	output := func(line string) {
+		lock.Lock()
+		defer lock.Unlock()
+		result = append(result, line)
+	}
+
+	if err := driver.VBoxManageStream(output, "export", "foo"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sort.Strings(result)
+	expected := []string{
+		"0%...",
+		"10%...",
+		"100%",
+		"20%",
+		"Successfully exported 1 machine(s).",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
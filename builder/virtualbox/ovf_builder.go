@@ -0,0 +1,174 @@
+package virtualbox
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// OVFBuilder is a VirtualBox builder that starts from an existing OVF
+// or OVA rather than installing an operating system from an ISO. It uses
+// the same configuration structure and steps as the ISO builder.
+type OVFBuilder struct {
+	config config
+	driver Driver
+	runner multistep.Runner
+}
+
+// isoOnlyKeys are the configuration keys of the ISO builder that set up
+// a new VM, so they can't be applied to an imported one.
+var isoOnlyKeys = []string{
+	"boot_command",
+	"disk_additional_size",
+	"disk_size",
+	"firmware",
+	"floppy_files",
+	"guest_os_type",
+	"hard_drive_interface",
+	"http_directory",
+	"http_port_max",
+	"http_port_min",
+	"iso_checksum",
+	"iso_checksum_type",
+	"iso_md5",
+	"iso_target_path",
+	"iso_url",
+	"iso_urls",
+	"network_adapters",
+	"nic_type",
+	"rtc_time_base",
+	"skip_os_type_check",
+	"sound",
+	"source_snapshot",
+	"source_vm",
+	"usb",
+}
+
+func (b *OVFBuilder) Prepare(raws ...interface{}) error {
+	var err error
+
+	// Start from scratch, as the ISO builder does
+	b.config = config{}
+	errs := make([]error, 0)
+	for _, raw := range raws {
+		err := mapstructure.Decode(raw, &b.config)
+		if err != nil {
+			return err
+		}
+
+		if m, ok := raw.(map[string]interface{}); ok {
+			for _, key := range isoOnlyKeys {
+				if _, ok := m[key]; ok {
+					errs = append(errs, fmt.Errorf(
+						"%s can't be used with the virtualbox-ovf builder, since the VM is imported", key))
+				}
+			}
+		}
+	}
+
+	errs = append(errs, b.config.prepareCommon()...)
+
+	if b.config.SourcePath == "" {
+		errs = append(errs, errors.New("A source_path must be specified."))
+	} else if _, err := os.Stat(b.config.SourcePath); err != nil {
+		errs = append(errs, fmt.Errorf("source_path is invalid: %s", err))
+	}
+
+	b.driver, err = newDriver()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed creating VirtualBox driver: %s", err))
+	}
+
+	if len(errs) > 0 {
+		return &packer.MultiError{errs}
+	}
+
+	return nil
+}
+
+func (b *OVFBuilder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	// Seed the random number generator
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	steps := []multistep.Step{
+		new(stepCheckVersion),
+		new(stepDownloadGuestAdditions),
+		&common.StepPrepareOutputDir{
+			Dir:   b.config.OutputDir,
+			Force: b.config.ForceDeleteOutput,
+		},
+		new(stepSuppressMessages),
+		new(stepImport),
+		new(stepAttachGuestAdditions),
+		new(stepForwardSSH),
+		&stepVBoxManage{commands: b.config.VBoxManage},
+		new(stepRun),
+		new(stepWaitForSSH),
+		new(stepUploadVersion),
+		new(stepUploadGuestAdditions),
+		new(stepProvision),
+		new(stepShutdown),
+		new(stepRemoveDevices),
+		&stepVBoxManage{commands: b.config.VBoxManagePost},
+		new(stepExport),
+	}
+
+	// Let the driver tell the user about any retried commands
+	if driver, ok := b.driver.(*VBox42Driver); ok {
+		driver.Ui = ui
+	}
+
+	// Setup the state bag
+	state := make(map[string]interface{})
+	state["cache"] = cache
+	state["config"] = &b.config
+	state["driver"] = b.driver
+	state["hook"] = hook
+	state["ui"] = ui
+
+	// Run
+	b.runner = common.NewRunner(steps, b.config.PackerDebug, b.config.PackerOnError, ui)
+
+	b.runner.Run(state)
+	common.ReportStepTimings(ui, state)
+
+	// If there was an error, return that
+	if rawErr, ok := state["error"]; ok {
+		return nil, rawErr.(error)
+	}
+
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state[multistep.StateCancelled]; ok {
+		return nil, errors.New("Build was cancelled.")
+	}
+
+	if _, ok := state[multistep.StateHalted]; ok {
+		return nil, errors.New("Build was halted.")
+	}
+
+	if b.config.SkipExport {
+		var vmName string
+		var vmFiles []string
+		if err := common.StateBag(state).Values("vmName", &vmName, "vmFiles", &vmFiles); err != nil {
+			return nil, err
+		}
+
+		return NewVMArtifact(vmName, vmFiles), nil
+	}
+
+	return NewArtifact(b.config.OutputDir)
+}
+
+func (b *OVFBuilder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
@@ -0,0 +1,109 @@
+package virtualbox
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func testOVFConfig(t *testing.T) map[string]interface{} {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tf.Close()
+
+	return map[string]interface{}{
+		"source_path":  tf.Name(),
+		"ssh_username": "foo",
+
+		packer.BuildNameConfigKey: "foo",
+	}
+}
+
+func TestOVFBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &OVFBuilder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Error("OVFBuilder must implement builder.")
+	}
+}
+
+func TestOVFBuilderPrepare_Defaults(t *testing.T) {
+	var b OVFBuilder
+	config := testOVFConfig(t)
+	defer os.Remove(config["source_path"].(string))
+
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.OutputDir != "output-foo" {
+		t.Errorf("bad output dir: %s", b.config.OutputDir)
+	}
+
+	if b.config.VMName != "packer-foo" {
+		t.Errorf("bad vm name: %s", b.config.VMName)
+	}
+}
+
+func TestOVFBuilderPrepare_SourcePath(t *testing.T) {
+	var b OVFBuilder
+	config := testOVFConfig(t)
+	defer os.Remove(config["source_path"].(string))
+
+	// Test with a good one
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test with a missing one
+	delete(config, "source_path")
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test with a file that doesn't exist
+	config["source_path"] = "i/dont/exist.ovf"
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestOVFBuilderPrepare_VMName(t *testing.T) {
+	var b OVFBuilder
+	config := testOVFConfig(t)
+	defer os.Remove(config["source_path"].(string))
+
+	config["vm_name"] = "imported"
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.VMName != "imported" {
+		t.Fatalf("bad: %s", b.config.VMName)
+	}
+}
+
+func TestOVFBuilderPrepare_ISOOnlyKeys(t *testing.T) {
+	config := testOVFConfig(t)
+	defer os.Remove(config["source_path"].(string))
+
+	for _, key := range []string{"nic_type", "network_adapters", "sound", "usb", "firmware", "disk_additional_size"} {
+		var b OVFBuilder
+		config[key] = "foo"
+		err := b.Prepare(config)
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Fatalf("should have error for %s: %s", key, err)
+		}
+
+		delete(config, key)
+	}
+}
@@ -0,0 +1,56 @@
+package virtualbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStepAttachFloppy_copyFloppy(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// Temporary directories are created within td
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", td)
+
+	source := filepath.Join(td, "floppy")
+	if err := ioutil.WriteFile(source, []byte("foo"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	step := new(stepAttachFloppy)
+	path, err := step.copyFloppy(source)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(data) != "foo" || filepath.Ext(path) != ".vfd" {
+		t.Fatalf("bad: %s %s", path, data)
+	}
+
+	os.RemoveAll(filepath.Dir(path))
+
+	// A failed copy doesn't leave its temporary directory behind
+	if _, err := step.copyFloppy(filepath.Join(td, "nope")); err == nil {
+		t.Fatal("should have error")
+	}
+
+	entries, err := ioutil.ReadDir(td)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("bad: %d entries", len(entries))
+	}
+}
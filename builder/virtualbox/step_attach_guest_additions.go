@@ -0,0 +1,96 @@
+package virtualbox
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step attaches the VirtualBox guest additions as a inserted CD onto
+// the virtual machine.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   guest_additions_path string
+//   ui packer.Ui
+//   vmName string
+//
+// Produces:
+//   attachedGuestAdditionsPath string - The path of the attached guest
+//     additions, which is removed from the state once it has been detached.
+type stepAttachGuestAdditions struct{}
+
+func (s *stepAttachGuestAdditions) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	// If we're not attaching the guest additions then just return
+	if config.GuestAdditionsMode != GuestAdditionsModeAttach {
+		log.Println("Not attaching guest additions since mode is not attach")
+		return multistep.ActionContinue
+	}
+
+	// Get the guest additions path since we're doing it
+	var guestAdditionsPath string
+	if err := bag.Values("guest_additions_path", &guestAdditionsPath); err != nil {
+		return bag.Halt(err)
+	}
+
+	// Attach the guest additions to the computer
+	log.Println("Attaching guest additions ISO onto IDE controller...")
+	command := []string{
+		"storageattach", vmName,
+		"--storagectl", "IDE Controller",
+		"--port", "1",
+		"--device", "0",
+		"--type", "dvddrive",
+		"--medium", guestAdditionsPath,
+	}
+	if err := driver.VBoxManage(command...); err != nil {
+		err := fmt.Errorf("Error attaching guest additions: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Track the path so that we can unregister it from VirtualBox later
+	state["attachedGuestAdditionsPath"] = guestAdditionsPath
+
+	return multistep.ActionContinue
+}
+
+func (s *stepAttachGuestAdditions) Cleanup(state map[string]interface{}) {
+	if _, ok := state["attachedGuestAdditionsPath"]; !ok {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	command := []string{
+		"storageattach", vmName,
+		"--storagectl", "IDE Controller",
+		"--port", "1",
+		"--device", "0",
+		"--medium", "none",
+	}
+
+	if err := driver.VBoxManage(command...); err != nil {
+		ui.Error(fmt.Sprintf("Error removing guest additions: %s", err))
+	}
+}
@@ -3,23 +3,33 @@ package virtualbox
 import (
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"log"
 )
 
 // This step attaches the ISO to the virtual machine.
 //
 // Uses:
+//   driver Driver
+//   iso_path string
+//   ui packer.Ui
+//   vmName string
 //
 // Produces:
-type stepAttachISO struct {
-	diskPath string
-}
+//   attachedIsoPath string - The path of the attached ISO, which is
+//     removed from the state once it has been detached.
+type stepAttachISO struct{}
 
 func (s *stepAttachISO) Run(state map[string]interface{}) multistep.StepAction {
-	driver := state["driver"].(Driver)
-	isoPath := state["iso_path"].(string)
-	ui := state["ui"].(packer.Ui)
-	vmName := state["vmName"].(string)
+	bag := common.StateBag(state)
+	var driver Driver
+	var isoPath string
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("driver", &driver, "iso_path", &isoPath, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
 
 	// Attach the disk to the controller
 	command := []string{
@@ -38,19 +48,24 @@ func (s *stepAttachISO) Run(state map[string]interface{}) multistep.StepAction {
 	}
 
 	// Track the path so that we can unregister it from VirtualBox later
-	s.diskPath = isoPath
+	state["attachedIsoPath"] = isoPath
 
 	return multistep.ActionContinue
 }
 
 func (s *stepAttachISO) Cleanup(state map[string]interface{}) {
-	if s.diskPath == "" {
+	if _, ok := state["attachedIsoPath"]; !ok {
 		return
 	}
 
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
-	vmName := state["vmName"].(string)
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
 
 	command := []string{
 		"storageattach", vmName,
@@ -0,0 +1,144 @@
+package virtualbox
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"sort"
+	"strings"
+)
+
+// This step verifies that the configured guest OS type is one that
+// VirtualBox knows about, since a typo would otherwise silently result
+// in a VM with the wrong defaults. It also warns about OS types that
+// need EFI firmware when EFI isn't configured.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   <nothing>
+type stepCheckOSType struct{}
+
+func (s *stepCheckOSType) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	if config.Firmware != "efi" && efiOnlyOSType(config.GuestOSType) {
+		ui.Message(fmt.Sprintf(
+			"WARNING: The guest OS type %s usually requires EFI firmware,\n"+
+				"but firmware is set to %s. The VM may not boot.",
+			config.GuestOSType, config.Firmware))
+	}
+
+	if config.SkipOSTypeCheck {
+		log.Println("Skipping guest OS type check, as configured.")
+		return multistep.ActionContinue
+	}
+
+	osTypes, err := driver.ListOSTypes()
+	if err != nil {
+		err := fmt.Errorf("Error listing guest OS types: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	for _, osType := range osTypes {
+		if osType == config.GuestOSType {
+			return multistep.ActionContinue
+		}
+	}
+
+	message := fmt.Sprintf("Unknown guest_os_type: %s", config.GuestOSType)
+	if suggestions := closestOSTypes(config.GuestOSType, osTypes, 3); len(suggestions) > 0 {
+		message += fmt.Sprintf(". Did you mean: %s?", strings.Join(suggestions, ", "))
+	}
+
+	err = fmt.Errorf("%s\nSet skip_os_type_check to disable this check.", message)
+	state["error"] = err
+	ui.Error(err.Error())
+	return multistep.ActionHalt
+}
+
+func (s *stepCheckOSType) Cleanup(state map[string]interface{}) {}
+
+// efiOnlyOSType checks if the guest OS type is one that can only boot
+// with EFI firmware, which are the Mac OS X types.
+func efiOnlyOSType(osType string) bool {
+	return strings.HasPrefix(osType, "MacOS")
+}
+
+type osTypeDistance struct {
+	osType   string
+	distance int
+}
+
+type osTypeDistances []osTypeDistance
+
+func (d osTypeDistances) Len() int           { return len(d) }
+func (d osTypeDistances) Less(i, j int) bool { return d[i].distance < d[j].distance }
+func (d osTypeDistances) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// closestOSTypes returns up to max OS types that are closest to the given
+// OS type, ignoring case and any that are too different to be useful.
+func closestOSTypes(osType string, osTypes []string, max int) []string {
+	target := strings.ToLower(osType)
+	distances := make(osTypeDistances, 0, len(osTypes))
+	for _, candidate := range osTypes {
+		d := levenshtein(target, strings.ToLower(candidate))
+		if d <= len(target)/2+1 {
+			distances = append(distances, osTypeDistance{candidate, d})
+		}
+	}
+
+	sort.Stable(distances)
+
+	result := make([]string, 0, max)
+	for i := 0; i < len(distances) && i < max; i++ {
+		result = append(result, distances[i].osType)
+	}
+
+	return result
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
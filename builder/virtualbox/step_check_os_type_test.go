@@ -0,0 +1,49 @@
+package virtualbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClosestOSTypes(t *testing.T) {
+	osTypes := []string{"Other", "Ubuntu", "Ubuntu_64", "RedHat_64", "Windows7_64"}
+
+	result := closestOSTypes("Ubuntu64", osTypes, 3)
+	expected := []string{"Ubuntu_64", "Ubuntu"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	result = closestOSTypes("nothing-like-it", osTypes, 3)
+	if len(result) != 0 {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"ubuntu64", "ubuntu_64", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tc := range cases {
+		if d := levenshtein(tc.a, tc.b); d != tc.expected {
+			t.Fatalf("bad distance for %s/%s: %d", tc.a, tc.b, d)
+		}
+	}
+}
+
+func TestEFIOnlyOSType(t *testing.T) {
+	if !efiOnlyOSType("MacOS_64") {
+		t.Fatal("MacOS_64 should be EFI only")
+	}
+
+	if efiOnlyOSType("Ubuntu_64") {
+		t.Fatal("Ubuntu_64 should not be EFI only")
+	}
+}
@@ -0,0 +1,114 @@
+package virtualbox
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// This step verifies that the installed VirtualBox is at least the
+// minimum version required, since older versions fail in confusing ways
+// deep into the build.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   vboxVersion string - The version of VirtualBox that is installed.
+type stepCheckVersion struct{}
+
+func (s *stepCheckVersion) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	version, err := driver.Version()
+	if err != nil {
+		err := fmt.Errorf("Error reading VirtualBox version: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	log.Printf("Checking VirtualBox version %s against minimum %s", version, config.VBoxVersionMin)
+	result, err := compareVersions(version, config.VBoxVersionMin)
+	if err != nil {
+		err := fmt.Errorf("Error checking VirtualBox version: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if result < 0 {
+		err := fmt.Errorf(
+			"VirtualBox %s detected, %s or higher required",
+			version, config.VBoxVersionMin)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state["vboxVersion"] = version
+	return multistep.ActionContinue
+}
+
+func (s *stepCheckVersion) Cleanup(state map[string]interface{}) {}
+
+// compareVersions compares two dotted version strings such as "4.2.16",
+// returning -1, 0, or 1 if a is less than, equal to, or greater than b.
+// Missing components are treated as zero.
+func compareVersions(a, b string) (int, error) {
+	aParts, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+
+	bParts, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for len(aParts) < len(bParts) {
+		aParts = append(aParts, 0)
+	}
+
+	for len(bParts) < len(aParts) {
+		bParts = append(bParts, 0)
+	}
+
+	for i := range aParts {
+		if aParts[i] < bParts[i] {
+			return -1, nil
+		} else if aParts[i] > bParts[i] {
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// parseVersion parses a dotted version string into its numeric parts.
+func parseVersion(v string) ([]int, error) {
+	parts := strings.Split(v, ".")
+	result := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("Invalid version: %s", v)
+		}
+
+		result[i] = n
+	}
+
+	return result, nil
+}
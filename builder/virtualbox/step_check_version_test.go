@@ -0,0 +1,32 @@
+package virtualbox
+
+import (
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"4.2.16", "4.1.0", 1},
+		{"4.0.12", "4.1.0", -1},
+		{"4.1", "4.1.0", 0},
+		{"4.10.0", "4.9.9", 1},
+	}
+
+	for _, tc := range cases {
+		result, err := compareVersions(tc.a, tc.b)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if result != tc.expected {
+			t.Fatalf("bad result for %s/%s: %d", tc.a, tc.b, result)
+		}
+	}
+
+	if _, err := compareVersions("4.x", "4.1"); err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,70 @@
+package virtualbox
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// This step copies the ISO to a temporary file with a ".iso" extension.
+// VirtualBox is really dumb and can't figure out that the file is an ISO
+// unless it has one, and we can't modify the cache filenames.
+//
+// Uses:
+//   iso_path string
+//
+// Produces:
+//   iso_path string - The path to the copy.
+type stepCopyISO struct {
+	isoCopyDir string
+}
+
+func (s *stepCopyISO) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var isoPath string
+	if err := bag.Values("iso_path", &isoPath); err != nil {
+		return bag.Halt(err)
+	}
+
+	tempdir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		state["error"] = fmt.Errorf("Error copying ISO: %s", err)
+		return multistep.ActionHalt
+	}
+	s.isoCopyDir = tempdir
+
+	f, err := os.Create(filepath.Join(tempdir, "image.iso"))
+	if err != nil {
+		state["error"] = fmt.Errorf("Error copying ISO: %s", err)
+		return multistep.ActionHalt
+	}
+	defer f.Close()
+
+	sourceF, err := os.Open(isoPath)
+	if err != nil {
+		state["error"] = fmt.Errorf("Error copying ISO: %s", err)
+		return multistep.ActionHalt
+	}
+	defer sourceF.Close()
+
+	log.Printf("Copying ISO to temp location: %s", tempdir)
+	if _, err := io.Copy(f, sourceF); err != nil {
+		state["error"] = fmt.Errorf("Error copying ISO: %s", err)
+		return multistep.ActionHalt
+	}
+
+	state["iso_path"] = f.Name()
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCopyISO) Cleanup(map[string]interface{}) {
+	if s.isoCopyDir != "" {
+		os.RemoveAll(s.isoCopyDir)
+	}
+}
@@ -3,45 +3,93 @@ package virtualbox
 import (
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"log"
 	"path/filepath"
 	"strconv"
 	"strings"
 )
 
+// maxAdditionalDisks is the number of additional disks that can be
+// attached to each type of controller alongside the main hard drive. The
+// IDE controller only has one spare device once the main disk and the ISO
+// are attached, and it is taken by guest additions in attach mode.
+var maxAdditionalDisks = map[string]int{
+	"ide":  1,
+	"sata": 29,
+	"scsi": 15,
+}
+
 // This step creates the virtual disk that will be used as the
-// hard drive for the virtual machine.
-type stepCreateDisk struct{}
+// hard drive for the virtual machine, as well as any additional disks.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//   vmName string
+//
+// Produces:
+//   <nothing>
+type stepCreateDisk struct {
+	// unattached are the paths of disks that were created but aren't yet
+	// attached to the VM, and so won't be deleted along with it.
+	unattached []string
+}
 
 func (s *stepCreateDisk) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
-	vmName := state["vmName"].(string)
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
 
 	format := "VDI"
-	path := filepath.Join(config.OutputDir, fmt.Sprintf("%s.%s", config.VMName, strings.ToLower(format)))
-
-	command := []string{
-		"createhd",
-		"--filename", path,
-		"--size", strconv.FormatUint(uint64(config.DiskSize), 10),
-		"--format", format,
-		"--variant", "Standard",
+	paths := make([]string, 0, len(config.AdditionalDiskSize)+1)
+	sizes := make([]uint, 0, len(config.AdditionalDiskSize)+1)
+
+	paths = append(paths, filepath.Join(config.OutputDir,
+		fmt.Sprintf("%s.%s", config.VMName, strings.ToLower(format))))
+	sizes = append(sizes, config.DiskSize)
+
+	for i, size := range config.AdditionalDiskSize {
+		paths = append(paths, filepath.Join(config.OutputDir,
+			fmt.Sprintf("%s-%d.%s", config.VMName, i+1, strings.ToLower(format))))
+		sizes = append(sizes, size)
 	}
 
 	ui.Say("Creating hard drive...")
-	err := driver.VBoxManage(command...)
-	if err != nil {
-		err := fmt.Errorf("Error creating hard drive: %s", err)
-		state["error"] = err
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	output := func(line string) {
+		ui.Message(line)
+	}
+
+	for i, path := range paths {
+		command := []string{
+			"createhd",
+			"--filename", path,
+			"--size", strconv.FormatUint(uint64(sizes[i]), 10),
+			"--format", format,
+			"--variant", "Standard",
+		}
+
+		if err := driver.VBoxManageStream(output, command...); err != nil {
+			err := fmt.Errorf("Error creating hard drive: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		s.unattached = append(s.unattached, path)
 	}
 
-	// Add the IDE controller so we can later attach the disk
+	// Add the IDE controller so we can later attach the disk. The IDE
+	// controller is always created since the ISO is attached to it.
 	controllerName := "IDE Controller"
-	err = driver.VBoxManage("storagectl", vmName, "--name", controllerName, "--add", "ide")
+	err := driver.VBoxManage("storagectl", vmName, "--name", controllerName, "--add", "ide")
 	if err != nil {
 		err := fmt.Errorf("Error creating disk controller: %s", err)
 		state["error"] = err
@@ -49,23 +97,86 @@ func (s *stepCreateDisk) Run(state map[string]interface{}) multistep.StepAction
 		return multistep.ActionHalt
 	}
 
-	// Attach the disk to the controller
-	command = []string{
-		"storageattach", vmName,
-		"--storagectl", controllerName,
-		"--port", "0",
-		"--device", "0",
-		"--type", "hdd",
-		"--medium", path,
+	// Add a SATA or SCSI controller if the disk should be attached to
+	// one of those instead.
+	var controllerCommand []string
+	switch config.HardDriveInterface {
+	case "sata":
+		controllerName = "SATA Controller"
+		controllerCommand = []string{
+			"storagectl", vmName,
+			"--name", controllerName,
+			"--add", "sata",
+			"--sataportcount", strconv.FormatInt(int64(len(paths)), 10),
+		}
+	case "scsi":
+		controllerName = "SCSI Controller"
+		controllerCommand = []string{
+			"storagectl", vmName,
+			"--name", controllerName,
+			"--add", "scsi",
+			"--controller", "LsiLogic",
+		}
 	}
-	if err := driver.VBoxManage(command...); err != nil {
-		err := fmt.Errorf("Error attaching hard drive: %s", err)
-		state["error"] = err
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+
+	if controllerCommand != nil {
+		if err := driver.VBoxManage(controllerCommand...); err != nil {
+			err := fmt.Errorf("Error creating disk controller: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	// Attach the disks to the controller
+	for i, path := range paths {
+		port, device := i, 0
+		if config.HardDriveInterface == "ide" && i > 0 {
+			// Port 0 device 1 has the ISO and port 1 device 0 is used
+			// for guest additions, so the additional disk goes last.
+			port, device = 1, 1
+		}
+
+		command := []string{
+			"storageattach", vmName,
+			"--storagectl", controllerName,
+			"--port", strconv.FormatInt(int64(port), 10),
+			"--device", strconv.FormatInt(int64(device), 10),
+			"--type", "hdd",
+			"--medium", path,
+		}
+		if err := driver.VBoxManage(command...); err != nil {
+			err := fmt.Errorf("Error attaching hard drive: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		s.unattached = s.unattached[1:]
 	}
 
 	return multistep.ActionContinue
 }
 
-func (s *stepCreateDisk) Cleanup(state map[string]interface{}) {}
+func (s *stepCreateDisk) Cleanup(state map[string]interface{}) {
+	if len(s.unattached) == 0 {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	// Disks that are attached are deleted along with the VM, but any that
+	// never made it that far need to be deleted on their own.
+	for _, path := range s.unattached {
+		log.Printf("Deleting unattached hard drive: %s", path)
+		if err := driver.VBoxManage("closemedium", "disk", path, "--delete"); err != nil {
+			ui.Error(fmt.Sprintf("Error deleting hard drive: %s", err))
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package virtualbox
 
 import (
+	"errors"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"log"
+	"strconv"
 )
 
 // This step creates the actual virtual machine.
@@ -15,9 +19,13 @@ type stepCreateVM struct {
 }
 
 func (s *stepCreateVM) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
 	name := config.VMName
 
@@ -30,6 +38,47 @@ func (s *stepCreateVM) Run(state map[string]interface{}) multistep.StepAction {
 	commands[2] = []string{"modifyvm", name, "--cpus", "1"}
 	commands[3] = []string{"modifyvm", name, "--memory", "512"}
 
+	// The firmware has to be set before the first boot
+	if config.Firmware == "efi" {
+		commands = append(commands, []string{"modifyvm", name, "--firmware", "efi"})
+	}
+
+	// Leave out the virtual hardware that isn't needed unless asked for,
+	// since it trips up some importers of the exported VM.
+	if !config.Sound {
+		commands = append(commands, []string{"modifyvm", name, "--audio", "none"})
+	}
+
+	usb := "off"
+	if config.USB {
+		usb = "on"
+	}
+	commands = append(commands, []string{"modifyvm", name, "--usb", usb})
+
+	rtcUseUTC := "off"
+	if config.RTCTimeBase == "utc" {
+		rtcUseUTC = "on"
+	}
+	commands = append(commands, []string{"modifyvm", name, "--rtcuseutc", rtcUseUTC})
+
+	// The first adapter is always the NAT adapter that is used for SSH,
+	// and any additional adapters come after it.
+	if config.NICType != "" {
+		commands = append(commands, []string{"modifyvm", name, "--nictype1", config.NICType})
+	}
+
+	for i, adapter := range config.NetworkAdapters {
+		commands = append(commands, adapter.modifyArgs(name, i+2, config.NICType))
+	}
+
+	if config.PackerForce {
+		if err := deleteExistingVM(driver, ui, name); err != nil {
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	ui.Say("Creating virtual machine...")
 	for _, command := range commands {
 		err := driver.VBoxManage(command...)
@@ -57,11 +106,221 @@ func (s *stepCreateVM) Cleanup(state map[string]interface{}) {
 		return
 	}
 
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	if keepRegistered(config, state) {
+		ui.Say(fmt.Sprintf(
+			"Keeping virtual machine registered with VirtualBox: %s", s.vmName))
+		return
+	}
+
+	if keepVMFiles(config, state) {
+		ui.Say("Unregistering virtual machine...")
+		if err := driver.VBoxManage("unregistervm", s.vmName); err != nil {
+			ui.Error(fmt.Sprintf("Error unregistering virtual machine: %s", err))
+		}
+
+		return
+	}
 
 	ui.Say("Unregistering and deleting virtual machine...")
 	if err := driver.VBoxManage("unregistervm", s.vmName, "--delete"); err != nil {
 		ui.Error(fmt.Sprintf("Error deleting virtual machine: %s", err))
 	}
 }
+
+// keepRegistered determines whether the VM should be left registered with
+// VirtualBox during cleanup. VMs are kept after a successful build if
+// keep_registered is set, and after a failed one only if debugging too.
+func keepRegistered(config *config, state map[string]interface{}) bool {
+	if !config.KeepRegistered {
+		return false
+	}
+
+	_, cancelled := state[multistep.StateCancelled]
+	_, halted := state[multistep.StateHalted]
+	return !(cancelled || halted) || config.PackerDebug
+}
+
+// keepVMFiles determines whether the files of the VM should be left on disk
+// when it is unregistered. This is the case when a successful build skipped
+// the export, since the VM files themselves are then the artifact.
+func keepVMFiles(config *config, state map[string]interface{}) bool {
+	if !config.SkipExport {
+		return false
+	}
+
+	_, cancelled := state[multistep.StateCancelled]
+	_, halted := state[multistep.StateHalted]
+	return !(cancelled || halted)
+}
+
+// validNICTypes are the virtual network card types VirtualBox supports.
+var validNICTypes = []string{
+	"Am79C970A", "Am79C973", "82540EM", "82543GC", "82545EM", "virtio",
+}
+
+// nicTypeAliases maps the common names of network cards to the VirtualBox
+// NIC type that emulates them. "e1000" is the Intel PRO/1000 MT Desktop.
+var nicTypeAliases = map[string]string{
+	"e1000": "82540EM",
+}
+
+// networkAdapter is an additional network adapter for the VM, attached
+// to a NAT, host-only, or bridged network.
+type networkAdapter struct {
+	Type      string `mapstructure:"type"`
+	Network   string `mapstructure:"network"`
+	Interface string `mapstructure:"interface"`
+}
+
+// modifyArgs returns the arguments to VBoxManage that configure the
+// adapter as the nth network adapter of the VM.
+func (a *networkAdapter) modifyArgs(vmName string, n int, nicType string) []string {
+	nic := strconv.FormatInt(int64(n), 10)
+	args := []string{"modifyvm", vmName, "--nic" + nic, a.Type}
+	switch a.Type {
+	case "hostonly":
+		args = append(args, "--hostonlyadapter"+nic, a.Network)
+	case "bridged":
+		args = append(args, "--bridgeadapter"+nic, a.Interface)
+	}
+
+	if nicType != "" {
+		args = append(args, "--nictype"+nic, nicType)
+	}
+
+	return args
+}
+
+// validateNetworkAdapters checks the NIC type and additional network
+// adapters, returning any errors.
+func validateNetworkAdapters(nicType string, adapters []networkAdapter) []error {
+	errs := make([]error, 0)
+
+	if nicType != "" {
+		valid := false
+		for _, t := range validNICTypes {
+			if nicType == t {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			errs = append(errs, fmt.Errorf(
+				"nic_type is invalid. Must be e1000 or one of: %v", validNICTypes))
+		}
+	}
+
+	// VirtualBox supports 8 adapters, and the first is used for SSH
+	if len(adapters) > 7 {
+		errs = append(errs, errors.New("network_adapters can have at most 7 adapters"))
+	}
+
+	for i, adapter := range adapters {
+		switch adapter.Type {
+		case "nat":
+		case "hostonly":
+			if adapter.Network == "" {
+				errs = append(errs, fmt.Errorf(
+					"network_adapters %d: a network must be specified for hostonly", i+1))
+			}
+		case "bridged":
+			if adapter.Interface == "" {
+				errs = append(errs, fmt.Errorf(
+					"network_adapters %d: an interface must be specified for bridged", i+1))
+			}
+		default:
+			errs = append(errs, fmt.Errorf(
+				"network_adapters %d: type must be one of: nat, hostonly, bridged", i+1))
+		}
+	}
+
+	return errs
+}
+
+// validateHostOnlyNetworks checks that the host-only networks that the
+// adapters are attached to exist, returning any errors.
+func validateHostOnlyNetworks(driver Driver, adapters []networkAdapter) []error {
+	errs := make([]error, 0)
+
+	var networks []string
+	for i, adapter := range adapters {
+		if adapter.Type != "hostonly" || adapter.Network == "" {
+			continue
+		}
+
+		if networks == nil {
+			var err error
+			networks, err = driver.ListHostOnlyNetworks()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("Error listing host-only networks: %s", err))
+				return errs
+			}
+		}
+
+		found := false
+		for _, network := range networks {
+			if network == adapter.Network {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			errs = append(errs, fmt.Errorf(
+				"network_adapters %d: host-only network doesn't exist: %s",
+				i+1, adapter.Network))
+		}
+	}
+
+	return errs
+}
+
+// deleteExistingVM stops, unregisters, and deletes the VM with the given
+// name if it exists. Forced builds use this to replace a VM left behind by
+// an earlier build, such as one that failed with -on-error=abort.
+func deleteExistingVM(driver Driver, ui packer.Ui, name string) error {
+	names, err := driver.ListVMs()
+	if err != nil {
+		return fmt.Errorf("Error listing existing virtual machines: %s", err)
+	}
+
+	exists := false
+	for _, existing := range names {
+		if existing == name {
+			exists = true
+			break
+		}
+	}
+
+	if !exists {
+		return nil
+	}
+
+	running, err := driver.IsRunning(name)
+	if err != nil {
+		return fmt.Errorf("Error checking if existing virtual machine is running: %s", err)
+	}
+
+	ui.Say(fmt.Sprintf("Deleting existing virtual machine, since the build is forced: %s", name))
+	if running {
+		if err := driver.Stop(name); err != nil {
+			return fmt.Errorf("Error stopping existing virtual machine: %s", err)
+		}
+	}
+
+	if err := driver.VBoxManage("unregistervm", name, "--delete"); err != nil {
+		return fmt.Errorf("Error deleting existing virtual machine: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package virtualbox
+
+import (
+	"errors"
+	"github.com/mitchellh/packer/packer"
+	"reflect"
+	"testing"
+)
+
+func TestNetworkAdapterModifyArgs(t *testing.T) {
+	adapter := &networkAdapter{Type: "hostonly", Network: "vboxnet0"}
+	expected := []string{
+		"modifyvm", "foo",
+		"--nic2", "hostonly",
+		"--hostonlyadapter2", "vboxnet0",
+		"--nictype2", "virtio",
+	}
+	if result := adapter.modifyArgs("foo", 2, "virtio"); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	adapter = &networkAdapter{Type: "bridged", Interface: "en0"}
+	expected = []string{
+		"modifyvm", "foo",
+		"--nic3", "bridged",
+		"--bridgeadapter3", "en0",
+	}
+	if result := adapter.modifyArgs("foo", 3, ""); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestDeleteExistingVM(t *testing.T) {
+	state := testState(t)
+	driver := state["driver"].(*driverMock)
+	ui := state["ui"].(packer.Ui)
+
+	// A VM that doesn't exist is left alone
+	driver.ListVMsResult = []string{"bar"}
+	if err := deleteExistingVM(driver, ui, "foo"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if driver.IsRunningCalled || len(driver.VBoxManageCalls) > 0 {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
+	}
+
+	// A running VM is stopped and deleted
+	driver.ListVMsResult = []string{"bar", "foo"}
+	driver.IsRunningResult = true
+	if err := deleteExistingVM(driver, ui, "foo"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !driver.StopCalled {
+		t.Fatal("should stop the VM")
+	}
+
+	expected := [][]string{{"unregistervm", "foo", "--delete"}}
+	if !reflect.DeepEqual(driver.VBoxManageCalls, expected) {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
+	}
+}
+
+func TestDeleteExistingVM_errors(t *testing.T) {
+	state := testState(t)
+	driver := state["driver"].(*driverMock)
+	ui := state["ui"].(packer.Ui)
+
+	// Failing to list the VMs isn't taken to mean there is no VM
+	driver.ListVMsErr = errors.New("listing failed")
+	if err := deleteExistingVM(driver, ui, "foo"); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Neither is failing to check whether the VM is running
+	driver.ListVMsErr = nil
+	driver.ListVMsResult = []string{"foo"}
+	driver.IsRunningErr = errors.New("showvminfo failed")
+	if err := deleteExistingVM(driver, ui, "foo"); err == nil {
+		t.Fatal("should have error")
+	}
+
+	if len(driver.VBoxManageCalls) > 0 {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
+	}
+}
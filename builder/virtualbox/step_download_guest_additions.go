@@ -21,8 +21,8 @@ var additionsVersionMap = map[string]string{
 	"4.1.23": "4.1.22",
 }
 
-// This step uploads a file containing the VirtualBox version, which
-// can be useful for various provisioning reasons.
+// This step downloads the VirtualBox guest additions ISO, either from
+// the configured guest_additions_url or from the VirtualBox website.
 //
 // Produces:
 //   guest_additions_path string - Path to the guest additions.
@@ -30,108 +30,76 @@ type stepDownloadGuestAdditions struct{}
 
 func (s *stepDownloadGuestAdditions) Run(state map[string]interface{}) multistep.StepAction {
 	var action multistep.StepAction
-	cache := state["cache"].(packer.Cache)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
-
-	version, err := driver.Version()
-	if err != nil {
-		state["error"] = fmt.Errorf("Error reading version for guest additions download: %s", err)
-		return multistep.ActionHalt
+	bag := common.StateBag(state)
+	var cache packer.Cache
+	var config *config
+	var ui packer.Ui
+	if err := bag.Values("cache", &cache, "config", &config, "ui", &ui); err != nil {
+		return bag.Halt(err)
 	}
 
-	if newVersion, ok := additionsVersionMap[version]; ok {
-		log.Printf("Rewriting guest additions version: %s to %s", version, newVersion)
-		version = newVersion
+	// If we've disabled guest additions, don't download
+	if config.GuestAdditionsMode == GuestAdditionsModeDisable {
+		log.Println("Not downloading guest additions since it is disabled.")
+		return multistep.ActionContinue
 	}
 
-	// First things first, we get the list of checksums for the files available
-	// for this version.
-	checksumsUrl := fmt.Sprintf("http://download.virtualbox.org/virtualbox/%s/SHA256SUMS", version)
-	checksumsFile, err := ioutil.TempFile("", "packer")
-	if err != nil {
-		state["error"] = fmt.Errorf(
-			"Failed creating temporary file to store guest addition checksums: %s",
-			err)
-		return multistep.ActionHalt
+	var version string
+	if err := bag.Values("vboxVersion", &version); err != nil {
+		return bag.Halt(err)
 	}
-	checksumsFile.Close()
-	defer os.Remove(checksumsFile.Name())
 
-	downloadConfig := &common.DownloadConfig{
-		Url:        checksumsUrl,
-		TargetPath: checksumsFile.Name(),
-		Hash:       nil,
-	}
-
-	log.Printf("Downloading guest addition checksums: %s", checksumsUrl)
-	download := common.NewDownloadClient(downloadConfig)
-	checksumsPath, action := s.progressDownload(download, state)
-	if action != multistep.ActionContinue {
-		return action
+	if newVersion, ok := additionsVersionMap[version]; ok {
+		log.Printf("Rewriting guest additions version: %s to %s", version, newVersion)
+		version = newVersion
 	}
 
 	additionsName := fmt.Sprintf("VBoxGuestAdditions_%s.iso", version)
 
-	// Next, we find the checksum for the file we're looking to download.
-	// It is an error if the checksum cannot be found.
-	checksumsF, err := os.Open(checksumsPath)
-	if err != nil {
-		state["error"] = fmt.Errorf("Error opening guest addition checksums: %s", err)
-		return multistep.ActionHalt
+	// Use the provided source (URL or file path) or generate it
+	url := config.GuestAdditionsURL
+	checksum := config.GuestAdditionsSHA256
+	if url == "" {
+		url = fmt.Sprintf(
+			"http://download.virtualbox.org/virtualbox/%s/%s",
+			version, additionsName)
+
+		if checksum == "" {
+			checksum, action = s.downloadAdditionsSHA256(state, version, additionsName)
+			if action != multistep.ActionContinue {
+				return action
+			}
+		}
 	}
-	defer checksumsF.Close()
 
-	// We copy the contents of the file into memory. In general this file
-	// is quite small so that is okay. In the future, we probably want to
-	// use bufio and iterate line by line.
-	var contents bytes.Buffer
-	io.Copy(&contents, checksumsF)
+	log.Printf("Guest additions URL: %s", url)
 
-	checksum := ""
-	for _, line := range strings.Split(contents.String(), "\n") {
-		parts := strings.Fields(line)
-		log.Printf("Checksum file parts: %#v", parts)
-		if len(parts) != 2 {
-			// Bogus line
-			continue
-		}
+	downloadConfig := &common.DownloadConfig{Url: url}
 
-		if strings.HasSuffix(parts[1], additionsName) {
-			checksum = parts[0]
-			log.Printf("Guest additions checksum: %s", checksum)
-			break
+	// Verify the download against the checksum if we have one
+	if checksum != "" {
+		checksumBytes, err := hex.DecodeString(checksum)
+		if err != nil {
+			state["error"] = fmt.Errorf("Couldn't decode checksum into bytes: %s", checksum)
+			return multistep.ActionHalt
 		}
-	}
 
-	if checksum == "" {
-		state["error"] = fmt.Errorf("The checksum for the file '%s' could not be found.", additionsName)
-		return multistep.ActionHalt
+		downloadConfig.Hash = sha256.New()
+		downloadConfig.Checksum = checksumBytes
 	}
 
-	checksumBytes, err := hex.DecodeString(checksum)
-	if err != nil {
-		state["error"] = fmt.Errorf("Couldn't decode checksum into bytes: %s", checksum)
-		return multistep.ActionHalt
+	// The cache is keyed by the checksum when one is available so that
+	// identical additions ISOs are only ever downloaded once.
+	cacheKey := url
+	if checksum != "" {
+		cacheKey = checksum
 	}
 
-	url := fmt.Sprintf(
-		"http://download.virtualbox.org/virtualbox/%s/%s",
-		version, additionsName)
-	log.Printf("Guest additions URL: %s", url)
-
 	log.Printf("Acquiring lock to download the guest additions ISO.")
-	cachePath := cache.Lock(url)
-	defer cache.Unlock(url)
-
-	downloadConfig = &common.DownloadConfig{
-		Url:        url,
-		TargetPath: cachePath,
-		Hash:       sha256.New(),
-		Checksum:   checksumBytes,
-	}
+	downloadConfig.TargetPath = cache.Lock(cacheKey)
+	defer cache.Unlock(cacheKey)
 
-	download = common.NewDownloadClient(downloadConfig)
+	download := common.NewDownloadClient(downloadConfig)
 	ui.Say("Downloading VirtualBox guest additions. Progress will be shown periodically.")
 	state["guest_additions_path"], action = s.progressDownload(download, state)
 	return action
@@ -140,7 +108,11 @@ func (s *stepDownloadGuestAdditions) Run(state map[string]interface{}) multistep
 func (s *stepDownloadGuestAdditions) Cleanup(state map[string]interface{}) {}
 
 func (s *stepDownloadGuestAdditions) progressDownload(c *common.DownloadClient, state map[string]interface{}) (string, multistep.StepAction) {
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var ui packer.Ui
+	if err := bag.Values("ui", &ui); err != nil {
+		return "", bag.Halt(err)
+	}
 
 	var result string
 	downloadCompleteCh := make(chan error, 1)
@@ -179,3 +151,69 @@ DownloadWaitLoop:
 
 	return result, multistep.ActionContinue
 }
+
+func (s *stepDownloadGuestAdditions) downloadAdditionsSHA256(state map[string]interface{}, additionsVersion string, additionsName string) (string, multistep.StepAction) {
+	// First things first, we get the list of checksums for the files available
+	// for this version.
+	checksumsUrl := fmt.Sprintf("http://download.virtualbox.org/virtualbox/%s/SHA256SUMS", additionsVersion)
+	checksumsFile, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		state["error"] = fmt.Errorf(
+			"Failed creating temporary file to store guest addition checksums: %s",
+			err)
+		return "", multistep.ActionHalt
+	}
+	checksumsFile.Close()
+	defer os.Remove(checksumsFile.Name())
+
+	downloadConfig := &common.DownloadConfig{
+		Url:        checksumsUrl,
+		TargetPath: checksumsFile.Name(),
+		Hash:       nil,
+	}
+
+	log.Printf("Downloading guest addition checksums: %s", checksumsUrl)
+	download := common.NewDownloadClient(downloadConfig)
+	checksumsPath, action := s.progressDownload(download, state)
+	if action != multistep.ActionContinue {
+		return "", action
+	}
+
+	// Next, we find the checksum for the file we're looking to download.
+	// It is an error if the checksum cannot be found.
+	checksumsF, err := os.Open(checksumsPath)
+	if err != nil {
+		state["error"] = fmt.Errorf("Error opening guest addition checksums: %s", err)
+		return "", multistep.ActionHalt
+	}
+	defer checksumsF.Close()
+
+	// We copy the contents of the file into memory. In general this file
+	// is quite small so that is okay. In the future, we probably want to
+	// use bufio and iterate line by line.
+	var contents bytes.Buffer
+	io.Copy(&contents, checksumsF)
+
+	checksum := ""
+	for _, line := range strings.Split(contents.String(), "\n") {
+		parts := strings.Fields(line)
+		log.Printf("Checksum file parts: %#v", parts)
+		if len(parts) != 2 {
+			// Bogus line
+			continue
+		}
+
+		if strings.HasSuffix(parts[1], additionsName) {
+			checksum = parts[0]
+			log.Printf("Guest additions checksum: %s", checksum)
+			break
+		}
+	}
+
+	if checksum == "" {
+		state["error"] = fmt.Errorf("The checksum for the file '%s' could not be found.", additionsName)
+		return "", multistep.ActionHalt
+	}
+
+	return checksum, multistep.ActionContinue
+}
@@ -1,48 +1,76 @@
 package virtualbox
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 )
 
-// This step cleans up forwarded ports and exports the VM to an OVF.
+// This step exports the VM to an OVF or OVA. If the export is skipped,
+// the files of the VM are looked up instead.
 //
 // Uses:
 //
 // Produces:
 //   exportPath string - The path to the resulting export.
+//   vmFiles []string - The files of the VM, if the export was skipped.
 type stepExport struct{}
 
 func (s *stepExport) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
-	vmName := state["vmName"].(string)
-
-  // Clear out the Packer-created forwarding rule
-	ui.Say(fmt.Sprintf("Deleting forwarded port mapping for SSH (host port %d)", state["sshHostPort"]))
-	command := []string{"modifyvm", vmName, "--natpf1", "delete", "packerssh"}
-	if err := driver.VBoxManage(command...); err != nil {
-		err := fmt.Errorf("Error deleting port forwarding rule: %s", err)
-    state["error"] = err
-		ui.Error(err.Error())
-    return multistep.ActionHalt
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	if config.SkipExport {
+		ui.Say("Skipping export of virtual machine...")
+		files, err := driver.VMFiles(vmName)
+		if err != nil {
+			err := fmt.Errorf("Error finding virtual machine files: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		state["vmFiles"] = files
+		return multistep.ActionContinue
 	}
 
-  // Export the VM to an OVF
-	outputPath := filepath.Join(config.OutputDir, "packer.ovf")
+	// Export the VM to an OVF or OVA, depending on the configured format
+	outputPath := filepath.Join(config.OutputDir, "packer."+config.Format)
 
-	command = []string{
+	command := []string{
 		"export",
 		vmName,
 		"--output",
 		outputPath,
 	}
 
+	// The manifest of an OVA has to be inside of it, so VirtualBox has
+	// to write it. For an OVF we write it ourselves afterwards.
+	if config.ExportManifest && config.Format == "ova" {
+		command = append(command, "--manifest")
+	}
+
 	ui.Say("Exporting virtual machine...")
-	err := driver.VBoxManage(command...)
+	output := func(line string) {
+		ui.Message(line)
+	}
+
+	err := driver.VBoxManageStream(output, command...)
 	if err != nil {
 		err := fmt.Errorf("Error exporting virtual machine: %s", err)
 		state["error"] = err
@@ -50,9 +78,58 @@ func (s *stepExport) Run(state map[string]interface{}) multistep.StepAction {
 		return multistep.ActionHalt
 	}
 
+	if config.ExportManifest && config.Format == "ovf" {
+		ui.Say("Writing manifest...")
+		if err := writeManifest(outputPath); err != nil {
+			err := fmt.Errorf("Error writing manifest: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	state["exportPath"] = outputPath
 
 	return multistep.ActionContinue
 }
 
 func (s *stepExport) Cleanup(state map[string]interface{}) {}
+
+// writeManifest writes a manifest next to the given OVF, with the SHA1
+// digests of the OVF and every file it references.
+func writeManifest(ovfPath string) error {
+	f, err := os.Open(ovfPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var envelope struct {
+		Files []struct {
+			Href string `xml:"href,attr"`
+		} `xml:"References>File"`
+	}
+
+	if err := xml.NewDecoder(f).Decode(&envelope); err != nil {
+		return fmt.Errorf("Error parsing OVF: %s", err)
+	}
+
+	dir := filepath.Dir(ovfPath)
+	paths := []string{filepath.Base(ovfPath)}
+	for _, file := range envelope.Files {
+		paths = append(paths, file.Href)
+	}
+
+	var manifest bytes.Buffer
+	for _, path := range paths {
+		digest, err := common.ChecksumFile(sha1.New(), filepath.Join(dir, path))
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&manifest, "SHA1(%s)= %s\n", path, hex.EncodeToString(digest))
+	}
+
+	manifestPath := strings.TrimSuffix(ovfPath, filepath.Ext(ovfPath)) + ".mf"
+	return ioutil.WriteFile(manifestPath, manifest.Bytes(), 0644)
+}
@@ -0,0 +1,59 @@
+package virtualbox
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/mitchellh/packer/builder/common"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testOVF = `<?xml version="1.0"?>
+<Envelope ovf:version="1.0" xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1">
+  <References>
+    <File ovf:href="packer-disk1.vmdk" ovf:id="file1"/>
+  </References>
+</Envelope>
+`
+
+func TestWriteManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ovfPath := filepath.Join(dir, "packer.ovf")
+	if err := ioutil.WriteFile(ovfPath, []byte(testOVF), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	diskPath := filepath.Join(dir, "packer-disk1.vmdk")
+	if err := ioutil.WriteFile(diskPath, []byte("foo"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := writeManifest(ovfPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "packer.mf"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ovfDigest, _ := common.ChecksumFile(sha1.New(), ovfPath)
+	expected := "SHA1(packer.ovf)= " + hex.EncodeToString(ovfDigest) + "\n" +
+		"SHA1(packer-disk1.vmdk)= 0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33\n"
+	if string(data) != expected {
+		t.Fatalf("bad: %s", data)
+	}
+
+	// A missing referenced file is an error
+	os.Remove(diskPath)
+	if err := writeManifest(ovfPath); err == nil {
+		t.Fatal("should have error")
+	}
+}
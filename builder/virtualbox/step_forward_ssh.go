@@ -3,6 +3,7 @@ package virtualbox
 import (
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
 	"math/rand"
@@ -15,27 +16,53 @@ import (
 // Uses:
 //
 // Produces:
-type stepForwardSSH struct{}
+//   sshHostPort uint - The host port that is forwarded to SSH.
+//   sshHostPortListener net.Listener - A listener holding the host port
+//     until the VM is started, so that parallel builds don't choose the
+//     same port.
+type stepForwardSSH struct {
+	l net.Listener
+}
 
 func (s *stepForwardSSH) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
-	vmName := state["vmName"].(string)
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
 
+	// Find an available port to forward to the guest's SSH port. The
+	// listener is held until the VM is started so that a parallel build
+	// can't take the same port out from under us.
 	log.Printf("Looking for available SSH port between %d and %d", config.SSHHostPortMin, config.SSHHostPortMax)
 	var sshHostPort uint
-	portRange := int(config.SSHHostPortMax - config.SSHHostPortMin)
-	for {
-		sshHostPort = uint(rand.Intn(portRange)) + config.SSHHostPortMin
+	var l net.Listener
+	portRange := int(config.SSHHostPortMax-config.SSHHostPortMin) + 1
+	start := rand.Intn(portRange)
+	for i := 0; i < portRange; i++ {
+		var err error
+
+		sshHostPort = config.SSHHostPortMin + uint((start+i)%portRange)
 		log.Printf("Trying port: %d", sshHostPort)
-		l, err := net.Listen("tcp", fmt.Sprintf(":%d", sshHostPort))
+		l, err = net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", sshHostPort))
 		if err == nil {
-			defer l.Close()
 			break
 		}
 	}
 
+	if l == nil {
+		err := fmt.Errorf(
+			"Error creating port forwarding rule: no open port found between %d and %d",
+			config.SSHHostPortMin, config.SSHHostPortMax)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.l = l
+
 	// Create a forwarded port mapping to the VM
 	ui.Say(fmt.Sprintf("Creating forwarded port mapping for SSH (host port %d)", sshHostPort))
 	command := []string{
@@ -52,8 +79,15 @@ func (s *stepForwardSSH) Run(state map[string]interface{}) multistep.StepAction
 
 	// Save the port we're using so that future steps can use it
 	state["sshHostPort"] = sshHostPort
+	state["sshHostPortListener"] = l
 
 	return multistep.ActionContinue
 }
 
-func (s *stepForwardSSH) Cleanup(state map[string]interface{}) {}
+func (s *stepForwardSSH) Cleanup(state map[string]interface{}) {
+	if s.l != nil {
+		// The run step normally closes this already, in which case this
+		// just returns an error we don't care about.
+		s.l.Close()
+	}
+}
@@ -0,0 +1,41 @@
+package virtualbox
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"net"
+	"testing"
+)
+
+func TestStepForwardSSH_impl(t *testing.T) {
+	var _ multistep.Step = new(stepForwardSSH)
+}
+
+func TestStepForwardSSH_holdsPort(t *testing.T) {
+	state := testState(t)
+
+	step := new(stepForwardSSH)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	port := state["sshHostPort"].(uint)
+	if _, ok := state["sshHostPortListener"].(net.Listener); !ok {
+		t.Fatalf("bad: %#v", state["sshHostPortListener"])
+	}
+
+	// The port is still held after the step, until the VM is started
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	if l, err := net.Listen("tcp", addr); err == nil {
+		l.Close()
+		t.Fatalf("port %d should still be held", port)
+	}
+
+	step.Cleanup(state)
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	l.Close()
+}
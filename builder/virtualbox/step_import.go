@@ -0,0 +1,92 @@
+package virtualbox
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step imports an OVF or OVA into VirtualBox as the virtual machine
+// that will be built.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   vmName string - The name of the VM
+type stepImport struct {
+	vmName string
+}
+
+func (s *stepImport) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	if config.PackerForce {
+		if err := deleteExistingVM(driver, ui, config.VMName); err != nil {
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	ui.Say(fmt.Sprintf("Importing VM: %s", config.SourcePath))
+	output := func(line string) {
+		ui.Message(line)
+	}
+
+	if err := driver.Import(config.SourcePath, config.VMName, output); err != nil {
+		err := fmt.Errorf("Error importing VM: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.vmName = config.VMName
+	state["vmName"] = s.vmName
+	return multistep.ActionContinue
+}
+
+func (s *stepImport) Cleanup(state map[string]interface{}) {
+	if s.vmName == "" {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	if keepRegistered(config, state) {
+		ui.Say(fmt.Sprintf(
+			"Keeping virtual machine registered with VirtualBox: %s", s.vmName))
+		return
+	}
+
+	if keepVMFiles(config, state) {
+		ui.Say("Unregistering imported VM...")
+		if err := driver.VBoxManage("unregistervm", s.vmName); err != nil {
+			ui.Error(fmt.Sprintf("Error unregistering VM: %s", err))
+		}
+
+		return
+	}
+
+	ui.Say("Unregistering and deleting imported VM...")
+	if err := driver.VBoxManage("unregistervm", s.vmName, "--delete"); err != nil {
+		ui.Error(fmt.Sprintf("Error deleting VM: %s", err))
+	}
+}
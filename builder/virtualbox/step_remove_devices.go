@@ -0,0 +1,110 @@
+package virtualbox
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"strings"
+)
+
+// This step removes the SSH port forwarding rule, disables VRDP, and
+// detaches any ISOs that Packer added, so that they don't end up in the
+// export. Anything configured by "vboxmanage_post" afterwards is kept.
+//
+// Uses:
+//   attachedGuestAdditionsPath string
+//   attachedIsoPath string
+//   config *config
+//   driver Driver
+//   ui packer.Ui
+//   vmName string
+//   vrdpPort uint
+//
+// Produces:
+//   <nothing>
+type stepRemoveDevices struct{}
+
+func (s *stepRemoveDevices) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
+
+	if !config.KeepSSHForwarding {
+		ui.Say("Deleting forwarded port mapping for SSH...")
+		command := []string{"modifyvm", vmName, "--natpf1", "delete", "packerssh"}
+		if err := driver.VBoxManage(command...); err != nil {
+			// If the rule is already gone there is nothing to do
+			if !strings.Contains(err.Error(), "does not exist") {
+				err := fmt.Errorf("Error deleting port forwarding rule: %s", err)
+				state["error"] = err
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+
+			log.Printf("Port forwarding rule already deleted: %s", err)
+		}
+	}
+
+	if _, ok := state["vrdpPort"]; ok {
+		ui.Say("Disabling VRDP...")
+		if err := driver.VBoxManage("modifyvm", vmName, "--vrde", "off"); err != nil {
+			err := fmt.Errorf("Error disabling VRDP: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if config.KeepAttachedISO {
+		return multistep.ActionContinue
+	}
+
+	if _, ok := state["attachedIsoPath"]; ok {
+		ui.Say("Detaching ISO...")
+		command := []string{
+			"storageattach", vmName,
+			"--storagectl", "IDE Controller",
+			"--port", "0",
+			"--device", "1",
+			"--medium", "none",
+		}
+		if err := driver.VBoxManage(command...); err != nil {
+			err := fmt.Errorf("Error detaching ISO: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		delete(state, "attachedIsoPath")
+	}
+
+	if _, ok := state["attachedGuestAdditionsPath"]; ok {
+		ui.Say("Detaching guest additions...")
+		command := []string{
+			"storageattach", vmName,
+			"--storagectl", "IDE Controller",
+			"--port", "1",
+			"--device", "0",
+			"--medium", "none",
+		}
+		if err := driver.VBoxManage(command...); err != nil {
+			err := fmt.Errorf("Error detaching guest additions: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		delete(state, "attachedGuestAdditionsPath")
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRemoveDevices) Cleanup(state map[string]interface{}) {}
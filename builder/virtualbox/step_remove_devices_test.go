@@ -0,0 +1,84 @@
+package virtualbox
+
+import (
+	"errors"
+	"github.com/mitchellh/multistep"
+	"reflect"
+	"testing"
+)
+
+func TestStepRemoveDevices_impl(t *testing.T) {
+	var _ multistep.Step = new(stepRemoveDevices)
+}
+
+func TestStepRemoveDevices(t *testing.T) {
+	state := testState(t)
+	state["attachedIsoPath"] = "foo.iso"
+	driver := state["driver"].(*driverMock)
+
+	step := new(stepRemoveDevices)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state["error"]; ok {
+		t.Fatal("should not have error")
+	}
+
+	if _, ok := state["attachedIsoPath"]; ok {
+		t.Fatal("attachedIsoPath should be removed")
+	}
+
+	if len(driver.VBoxManageCalls) != 2 {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
+	}
+
+	expected := []string{"modifyvm", "foo", "--natpf1", "delete", "packerssh"}
+	if !reflect.DeepEqual(driver.VBoxManageCalls[0], expected) {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls[0])
+	}
+}
+
+func TestStepRemoveDevices_keep(t *testing.T) {
+	state := testState(t)
+	state["attachedIsoPath"] = "foo.iso"
+	state["config"].(*config).KeepAttachedISO = true
+	state["config"].(*config).KeepSSHForwarding = true
+	driver := state["driver"].(*driverMock)
+
+	step := new(stepRemoveDevices)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if len(driver.VBoxManageCalls) != 0 {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
+	}
+
+	if _, ok := state["attachedIsoPath"]; !ok {
+		t.Fatal("attachedIsoPath should be kept")
+	}
+}
+
+func TestStepRemoveDevices_ruleGone(t *testing.T) {
+	state := testState(t)
+	state["config"].(*config).KeepAttachedISO = true
+	driver := state["driver"].(*driverMock)
+	driver.VBoxManageErr = errors.New(
+		"VBoxManage error: A NAT rule of this name does not exist")
+
+	step := new(stepRemoveDevices)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state["error"]; ok {
+		t.Fatal("should not have error")
+	}
+
+	// Any other error is still a failure
+	driver.VBoxManageErr = errors.New("VBoxManage error: something bad")
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
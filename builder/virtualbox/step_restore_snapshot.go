@@ -0,0 +1,75 @@
+package virtualbox
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step restores a snapshot of an existing, registered VM so that it
+// can be provisioned again without installing the OS from scratch. If the
+// build fails, the snapshot is restored again rather than the VM deleted.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   vmName string - The name of the VM
+type stepRestoreSnapshot struct {
+	vmName string
+}
+
+func (s *stepRestoreSnapshot) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say(fmt.Sprintf("Restoring snapshot '%s' of VM '%s'...",
+		config.SourceSnapshot, config.SourceVM))
+	err := driver.VBoxManage("snapshot", config.SourceVM, "restore", config.SourceSnapshot)
+	if err != nil {
+		err := fmt.Errorf("Error restoring snapshot: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.vmName = config.SourceVM
+	state["vmName"] = s.vmName
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRestoreSnapshot) Cleanup(state map[string]interface{}) {
+	if s.vmName == "" {
+		return
+	}
+
+	_, cancelled := state[multistep.StateCancelled]
+	_, halted := state[multistep.StateHalted]
+	if !cancelled && !halted {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	ui.Say(fmt.Sprintf("Restoring snapshot '%s' to undo the failed build...", config.SourceSnapshot))
+	if err := driver.VBoxManage("snapshot", s.vmName, "restore", config.SourceSnapshot); err != nil {
+		ui.Error(fmt.Sprintf("Error restoring snapshot: %s", err))
+	}
+}
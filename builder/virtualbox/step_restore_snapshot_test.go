@@ -0,0 +1,48 @@
+package virtualbox
+
+import (
+	"github.com/mitchellh/multistep"
+	"reflect"
+	"testing"
+)
+
+func TestStepRestoreSnapshot_impl(t *testing.T) {
+	var _ multistep.Step = new(stepRestoreSnapshot)
+}
+
+func TestStepRestoreSnapshot(t *testing.T) {
+	state := testState(t)
+	delete(state, "vmName")
+	config := state["config"].(*config)
+	config.SourceVM = "foo"
+	config.SourceSnapshot = "bar"
+	driver := state["driver"].(*driverMock)
+
+	step := new(stepRestoreSnapshot)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if state["vmName"] != "foo" {
+		t.Fatalf("bad vmName: %#v", state["vmName"])
+	}
+
+	expected := [][]string{{"snapshot", "foo", "restore", "bar"}}
+	if !reflect.DeepEqual(driver.VBoxManageCalls, expected) {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
+	}
+
+	// The snapshot isn't restored after a successful build
+	step.Cleanup(state)
+	if len(driver.VBoxManageCalls) != 1 {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
+	}
+
+	// The snapshot is restored after a failed build
+	state[multistep.StateHalted] = true
+	step.Cleanup(state)
+	expected = append(expected, []string{"snapshot", "foo", "restore", "bar"})
+	if !reflect.DeepEqual(driver.VBoxManageCalls, expected) {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
+	}
+}
@@ -3,24 +3,37 @@ package virtualbox
 import (
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
 	"time"
 )
 
-// This step starts the virtual machine.
+// This step starts the virtual machine. In headless mode, VRDP is enabled
+// first so that the console of the VM can still be viewed.
 //
 // Uses:
 //
 // Produces:
+//   vrdpPort uint - The VRDP port, if VRDP was enabled.
 type stepRun struct {
-	vmName string
+	vmName       string
+	vrdpEnabled  bool
+	vrdpListener net.Listener
 }
 
 func (s *stepRun) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
-	vmName := state["vmName"].(string)
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
 
 	ui.Say("Starting the virtual machine...")
 	guiArgument := "gui"
@@ -29,7 +42,32 @@ func (s *stepRun) Run(state map[string]interface{}) multistep.StepAction {
 			"In headless mode, errors during the boot sequence or OS setup\n" +
 			"won't be easily visible. Use at your own discretion.")
 		guiArgument = "headless"
+
+		vrdpPort, err := s.enableVRDP(state)
+		if err != nil {
+			err := fmt.Errorf("Error enabling VRDP: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		ui.Message(fmt.Sprintf(
+			"The console of the VM can be viewed by connecting with an RDP\n"+
+				"client, without a password, to %s:%d", config.VRDPBindAddress, vrdpPort))
+		state["vrdpPort"] = vrdpPort
+	}
+
+	// Release the ports we've been holding so that VirtualBox can bind
+	// them for the port forwarding and VRDP.
+	if l, ok := state["sshHostPortListener"].(net.Listener); ok {
+		l.Close()
+	}
+
+	if s.vrdpListener != nil {
+		s.vrdpListener.Close()
+		s.vrdpListener = nil
 	}
+
 	command := []string{"startvm", vmName, "--type", guiArgument}
 	if err := driver.VBoxManage(command...); err != nil {
 		err := fmt.Errorf("Error starting VM: %s", err)
@@ -49,16 +87,82 @@ func (s *stepRun) Run(state map[string]interface{}) multistep.StepAction {
 }
 
 func (s *stepRun) Cleanup(state map[string]interface{}) {
+	if s.vrdpListener != nil {
+		s.vrdpListener.Close()
+	}
+
 	if s.vmName == "" {
 		return
 	}
 
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
 
 	if running, _ := driver.IsRunning(s.vmName); running {
 		if err := driver.VBoxManage("controlvm", s.vmName, "poweroff"); err != nil {
 			ui.Error(fmt.Sprintf("Error shutting down VM: %s", err))
 		}
 	}
+
+	if s.vrdpEnabled {
+		if err := driver.VBoxManage("modifyvm", s.vmName, "--vrde", "off"); err != nil {
+			ui.Error(fmt.Sprintf("Error disabling VRDP: %s", err))
+		}
+	}
+}
+
+// enableVRDP finds an available port in the configured range and enables
+// VRDP on it, returning the port. The port is held by s.vrdpListener until
+// the VM is started.
+func (s *stepRun) enableVRDP(state map[string]interface{}) (uint, error) {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "vmName", &vmName); err != nil {
+		return 0, err
+	}
+
+	// The listener is held until right before the VM starts so that a
+	// parallel build can't choose the same port, and is closed then so
+	// that VirtualBox can listen on the port itself.
+	log.Printf("Looking for available VRDP port between %d and %d", config.VRDPPortMin, config.VRDPPortMax)
+	var vrdpPort uint
+	var l net.Listener
+	portRange := int(config.VRDPPortMax-config.VRDPPortMin) + 1
+	start := rand.Intn(portRange)
+	for i := 0; i < portRange; i++ {
+		var err error
+
+		vrdpPort = config.VRDPPortMin + uint((start+i)%portRange)
+		log.Printf("Trying port: %d", vrdpPort)
+		l, err = net.Listen("tcp", fmt.Sprintf("%s:%d", config.VRDPBindAddress, vrdpPort))
+		if err == nil {
+			break
+		}
+	}
+
+	if l == nil {
+		return 0, fmt.Errorf("no open port found between %d and %d",
+			config.VRDPPortMin, config.VRDPPortMax)
+	}
+	s.vrdpListener = l
+
+	command := []string{
+		"modifyvm", vmName,
+		"--vrde", "on",
+		"--vrdeaddress", config.VRDPBindAddress,
+		"--vrdeport", strconv.FormatUint(uint64(vrdpPort), 10),
+	}
+	if err := driver.VBoxManage(command...); err != nil {
+		return 0, err
+	}
+
+	s.vrdpEnabled = true
+	return vrdpPort, nil
 }
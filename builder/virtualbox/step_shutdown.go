@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
 	"time"
@@ -24,57 +25,100 @@ import (
 type stepShutdown struct{}
 
 func (s *stepShutdown) Run(state map[string]interface{}) multistep.StepAction {
-	comm := state["communicator"].(packer.Communicator)
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
-	vmName := state["vmName"].(string)
+	bag := common.StateBag(state)
+	var comm packer.Communicator
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("communicator", &comm, "config", &config, "driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
 
 	if config.ShutdownCommand != "" {
 		ui.Say("Gracefully halting virtual machine...")
 		log.Printf("Executing shutdown command: %s", config.ShutdownCommand)
 		cmd := &packer.RemoteCmd{Command: config.ShutdownCommand}
-		if err := comm.Start(cmd); err != nil {
-			err := fmt.Errorf("Failed to send shutdown command: %s", err)
-			state["error"] = err
-			ui.Error(err.Error())
-			return multistep.ActionHalt
+
+		// The connection is often torn down by the shutdown itself, so a
+		// failed command isn't fatal as long as the machine stops.
+		var cmdErr error
+		if err := cmd.StartWithUi(comm, ui); err != nil {
+			cmdErr = fmt.Errorf("Failed to send shutdown command: %s", err)
+		} else if !validExitCode(config.ShutdownValidCodes, cmd.ExitStatus) {
+			cmdErr = fmt.Errorf(
+				"Shutdown command exited with status %d", cmd.ExitStatus)
 		}
 
-		// Wait for the command to run
-		cmd.Wait()
+		if cmdErr != nil {
+			log.Printf("%s. Waiting for the machine to shut down anyways.", cmdErr)
+		}
 
 		// Wait for the machine to actually shut down
 		log.Printf("Waiting max %s for shutdown to complete", config.ShutdownTimeout)
-		shutdownTimer := time.After(config.ShutdownTimeout)
-		for {
-			running, _ := driver.IsRunning(vmName)
-			if !running {
-				break
+		if !waitForShutdown(driver, vmName, config.ShutdownTimeout) {
+			err := errors.New("Timeout while waiting for machine to shut down.")
+			if cmdErr != nil {
+				err = fmt.Errorf("%s, and the machine didn't shut down.", cmdErr)
 			}
 
-			select {
-			case <-shutdownTimer:
-				err := errors.New("Timeout while waiting for machine to shut down.")
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	} else {
+		// Try to shut down gracefully using the ACPI power button first
+		// so that the filesystems of the guest are left in a clean state.
+		ui.Say("Halting the virtual machine via the ACPI power button...")
+		stopped := false
+		if err := driver.StopViaACPI(vmName); err != nil {
+			log.Printf("Error sending ACPI power button: %s", err)
+		} else {
+			log.Printf("Waiting max %s for ACPI shutdown to complete", config.ShutdownTimeout)
+			stopped = waitForShutdown(driver, vmName, config.ShutdownTimeout)
+		}
+
+		if !stopped {
+			ui.Message("WARNING: The VM didn't shut down via ACPI. Forcefully\n" +
+				"powering off, which may leave the disk in a dirty state.")
+			if err := driver.Stop(vmName); err != nil {
+				err := fmt.Errorf("Error stopping VM: %s", err)
 				state["error"] = err
 				ui.Error(err.Error())
 				return multistep.ActionHalt
-			default:
-				time.Sleep(1 * time.Second)
 			}
 		}
-	} else {
-		ui.Say("Halting the virtual machine...")
-		if err := driver.Stop(vmName); err != nil {
-			err := fmt.Errorf("Error stopping VM: %s", err)
-			state["error"] = err
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
 	}
 
 	log.Println("VM shut down.")
+
+	if config.PostShutdownDelay > 0 {
+		ui.Say(fmt.Sprintf(
+			"Waiting %s after shutdown for VirtualBox to release locks...",
+			config.PostShutdownDelay))
+		time.Sleep(config.PostShutdownDelay)
+	}
+
 	return multistep.ActionContinue
 }
 
 func (s *stepShutdown) Cleanup(state map[string]interface{}) {}
+
+// waitForShutdown waits until the VM is no longer running, returning false
+// if it is still running once the timeout has passed.
+func waitForShutdown(driver Driver, vmName string, timeout time.Duration) bool {
+	return common.WaitForShutdown(func() (bool, error) {
+		return driver.IsRunning(vmName)
+	}, timeout)
+}
+
+// validExitCode checks if the exit code is one of the valid codes.
+func validExitCode(valid []int, code int) bool {
+	for _, v := range valid {
+		if v == code {
+			return true
+		}
+	}
+
+	return false
+}
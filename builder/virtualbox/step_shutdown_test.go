@@ -0,0 +1,143 @@
+package virtualbox
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// commMock is a Communicator whose commands write the given output
+// and then immediately exit with the given status.
+type commMock struct {
+	output     string
+	exitStatus int
+	startErr   error
+}
+
+func (c *commMock) Start(cmd *packer.RemoteCmd) error {
+	if c.startErr != nil {
+		return c.startErr
+	}
+
+	go func() {
+		io.WriteString(cmd.Stdout, c.output)
+		io.WriteString(cmd.Stderr, c.output)
+		cmd.ExitStatus = c.exitStatus
+		cmd.Exited = true
+	}()
+
+	return nil
+}
+
+func (c *commMock) Upload(string, io.Reader) error {
+	return nil
+}
+
+func (c *commMock) Download(string, io.Writer) error {
+	return nil
+}
+
+func testState(t *testing.T) map[string]interface{} {
+	var b Builder
+	if err := b.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := make(map[string]interface{})
+	state["config"] = &b.config
+	state["driver"] = new(driverMock)
+	state["ui"] = &packer.ReaderWriterUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	state["vmName"] = "foo"
+	return state
+}
+
+func TestStepShutdown_impl(t *testing.T) {
+	var _ multistep.Step = new(stepShutdown)
+}
+
+func TestStepShutdown_lotsOfOutput(t *testing.T) {
+	state := testState(t)
+	state["config"].(*config).ShutdownCommand = "shutdown -h now"
+	state["communicator"] = &commMock{
+		output: strings.Repeat(strings.Repeat("x", 1023)+"\n", 4096),
+	}
+
+	step := new(stepShutdown)
+	result := make(chan multistep.StepAction)
+	go func() {
+		result <- step.Run(state)
+	}()
+
+	select {
+	case action := <-result:
+		if action != multistep.ActionContinue {
+			t.Fatalf("bad action: %#v", action)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("step never completed")
+	}
+
+	if _, ok := state["error"]; ok {
+		t.Fatal("should not have error")
+	}
+}
+
+func TestStepShutdown_invalidExitCode(t *testing.T) {
+	state := testState(t)
+	state["config"].(*config).ShutdownCommand = "shutdown -h now"
+	state["communicator"] = &commMock{exitStatus: 255}
+
+	// The command failed but the machine stopped anyways
+	step := new(stepShutdown)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state["error"]; ok {
+		t.Fatal("should not have error")
+	}
+
+	// The command failed and the machine is still running
+	state["config"].(*config).ShutdownTimeout = 10 * time.Millisecond
+	state["driver"].(*driverMock).IsRunningResult = true
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state["error"]; !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestStepShutdown_startError(t *testing.T) {
+	state := testState(t)
+	state["config"].(*config).ShutdownCommand = "shutdown -h now"
+	state["communicator"] = &commMock{startErr: errors.New("connection closed")}
+
+	step := new(stepShutdown)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state["error"]; ok {
+		t.Fatal("should not have error")
+	}
+}
+
+func TestValidExitCode(t *testing.T) {
+	if !validExitCode([]int{0, 1}, 1) {
+		t.Fatal("1 should be valid")
+	}
+
+	if validExitCode([]int{0}, 1) {
+		t.Fatal("1 should not be valid")
+	}
+}
@@ -4,13 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
-	"log"
-	"strings"
 	"text/template"
 	"time"
-	"unicode"
-	"unicode/utf8"
 )
 
 const KeyLeftShift uint32 = 0xFFE1
@@ -35,11 +32,15 @@ type bootCommandTemplateData struct {
 type stepTypeBootCommand struct{}
 
 func (s *stepTypeBootCommand) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	httpPort := state["http_port"].(uint)
-	ui := state["ui"].(packer.Ui)
-	vmName := state["vmName"].(string)
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var httpPort uint
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("config", &config, "driver", &driver, "http_port", &httpPort, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
 
 	tplData := &bootCommandTemplateData{
 		"10.0.2.2",
@@ -53,7 +54,7 @@ func (s *stepTypeBootCommand) Run(state map[string]interface{}) multistep.StepAc
 		t := template.Must(template.New("boot").Parse(command))
 		t.Execute(&buf, tplData)
 
-		for _, code := range scancodes(buf.String()) {
+		for _, code := range common.Scancodes(buf.String()) {
 			if code == "wait" {
 				time.Sleep(1 * time.Second)
 				continue
@@ -88,95 +89,3 @@ func (s *stepTypeBootCommand) Run(state map[string]interface{}) multistep.StepAc
 }
 
 func (*stepTypeBootCommand) Cleanup(map[string]interface{}) {}
-
-func scancodes(message string) []string {
-	special := make(map[string][]string)
-	special["<enter>"] = []string{"1c", "9c"}
-	special["<esc>"] = []string{"01", "81"}
-	special["<return>"] = []string{"1c", "9c"}
-	special["<tab>"] = []string{"0f", "8f"}
-
-	shiftedChars := "~!@#$%^&*()_+{}|:\"<>?"
-
-	// Scancodes reference: http://www.win.tue.nl/~aeb/linux/kbd/scancodes-1.html
-	scancodeIndex := make(map[string]uint)
-	scancodeIndex["1234567890-="] = 0x02
-	scancodeIndex["!@#$%^&*()_+"] = 0x02
-	scancodeIndex["qwertyuiop[]"] = 0x10
-	scancodeIndex["QWERTYUIOP{}"] = 0x10
-	scancodeIndex["asdfghjkl;'`"] = 0x1e
-	scancodeIndex[`ASDFGHJKL:"~`] = 0x1e
-	scancodeIndex[`\zxcvbnm,./`] = 0x2b
-	scancodeIndex["|ZXCVBNM<>?"] = 0x2b
-	scancodeIndex[" "] = 0x39
-
-	scancodeMap := make(map[rune]uint)
-	for chars, start := range scancodeIndex {
-		var i uint = 0
-		for len(chars) > 0 {
-			r, size := utf8.DecodeRuneInString(chars)
-			chars = chars[size:]
-			scancodeMap[r] = start + i
-			i += 1
-		}
-	}
-
-	result := make([]string, 0, len(message)*2)
-	for len(message) > 0 {
-		var scancode []string
-
-		if strings.HasPrefix(message, "<wait>") {
-			log.Printf("Special code <wait> found, will sleep 1 second at this point.")
-			scancode = []string{"wait"}
-			message = message[len("<wait>"):]
-		}
-
-		if strings.HasPrefix(message, "<wait5>") {
-			log.Printf("Special code <wait5> found, will sleep 5 seconds at this point.")
-			scancode = []string{"wait5"}
-			message = message[len("<wait5>"):]
-		}
-
-		if strings.HasPrefix(message, "<wait10>") {
-			log.Printf("Special code <wait10> found, will sleep 10 seconds at this point.")
-			scancode = []string{"wait10"}
-			message = message[len("<wait10>"):]
-		}
-
-		if scancode == nil {
-			for specialCode, specialValue := range special {
-				if strings.HasPrefix(message, specialCode) {
-					log.Printf("Special code '%s' found, replacing with: %s", specialCode, specialValue)
-					scancode = specialValue
-					message = message[len(specialCode):]
-					break
-				}
-			}
-		}
-
-		if scancode == nil {
-			r, size := utf8.DecodeRuneInString(message)
-			message = message[size:]
-			scancodeInt := scancodeMap[r]
-			keyShift := unicode.IsUpper(r) || strings.ContainsRune(shiftedChars, r)
-
-			scancode = make([]string, 0, 4)
-			if keyShift {
-				scancode = append(scancode, "2a")
-			}
-
-			scancode = append(scancode, fmt.Sprintf("%02x", scancodeInt))
-
-			if keyShift {
-				scancode = append(scancode, "aa")
-			}
-
-			scancode = append(scancode, fmt.Sprintf("%02x", scancodeInt+0x80))
-			log.Printf("Sending char '%c', code '%v', shift %v", r, scancode, keyShift)
-		}
-
-		result = append(result, scancode...)
-	}
-
-	return result
-}
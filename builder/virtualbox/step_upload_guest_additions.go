@@ -2,9 +2,13 @@ package virtualbox
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"log"
 	"os"
 	"text/template"
 )
@@ -17,16 +21,46 @@ type guestAdditionsPathTemplate struct {
 type stepUploadGuestAdditions struct{}
 
 func (s *stepUploadGuestAdditions) Run(state map[string]interface{}) multistep.StepAction {
-	comm := state["communicator"].(packer.Communicator)
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	guestAdditionsPath := state["guest_additions_path"].(string)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var comm packer.Communicator
+	var config *config
+	var ui packer.Ui
+	if err := bag.Values("communicator", &comm, "config", &config, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
-	version, err := driver.Version()
-	if err != nil {
-		state["error"] = fmt.Errorf("Error reading version for guest additions upload: %s", err)
-		return multistep.ActionHalt
+	// If we're attaching then don't do this, since we attached.
+	if config.GuestAdditionsMode != GuestAdditionsModeUpload {
+		log.Println("Not uploading guest additions since mode is not upload")
+		return multistep.ActionContinue
+	}
+
+	var guestAdditionsPath string
+	var version string
+	if err := bag.Values("guest_additions_path", &guestAdditionsPath, "vboxVersion", &version); err != nil {
+		return bag.Halt(err)
+	}
+
+	// Verify the ISO right before uploading it, so that we never upload
+	// one that was corrupted since it was downloaded.
+	if config.GuestAdditionsSHA256 != "" {
+		log.Printf("Verifying checksum of guest additions ISO: %s", guestAdditionsPath)
+		checksum, err := common.ChecksumFile(sha256.New(), guestAdditionsPath)
+		if err != nil {
+			err := fmt.Errorf("Error verifying guest additions ISO: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		if actual := hex.EncodeToString(checksum); actual != config.GuestAdditionsSHA256 {
+			err := fmt.Errorf(
+				"Guest additions ISO checksum mismatch. Expected %s, got %s",
+				config.GuestAdditionsSHA256, actual)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
 
 	f, err := os.Open(guestAdditionsPath)
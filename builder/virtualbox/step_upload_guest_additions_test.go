@@ -0,0 +1,43 @@
+package virtualbox
+
+import (
+	"github.com/mitchellh/multistep"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestStepUploadGuestAdditions_checksum(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tf.Write([]byte("hello"))
+	tf.Close()
+
+	state := testState(t)
+	state["communicator"] = new(commMock)
+	state["guest_additions_path"] = tf.Name()
+	state["vboxVersion"] = "4.2.16"
+	config := state["config"].(*config)
+
+	// Test with a bad checksum
+	config.GuestAdditionsSHA256 = "00"
+	step := new(stepUploadGuestAdditions)
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state["error"]; !ok {
+		t.Fatal("should have error")
+	}
+
+	// Test with a good checksum
+	delete(state, "error")
+	config.GuestAdditionsSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
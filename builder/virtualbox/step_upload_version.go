@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
 )
@@ -13,26 +14,25 @@ import (
 type stepUploadVersion struct{}
 
 func (s *stepUploadVersion) Run(state map[string]interface{}) multistep.StepAction {
-	comm := state["communicator"].(packer.Communicator)
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var comm packer.Communicator
+	var config *config
+	var ui packer.Ui
+	var version string
+	if err := bag.Values("communicator", &comm, "config", &config, "ui", &ui, "vboxVersion", &version); err != nil {
+		return bag.Halt(err)
+	}
 
-	if config.VBoxVersionFile == "" {
+	if *config.VBoxVersionFile == "" {
 		log.Println("VBoxVersionFile is empty. Not uploading.")
+		ui.Message("Not uploading VirtualBox version info since virtualbox_version_file is empty.")
 		return multistep.ActionContinue
 	}
 
-	version, err := driver.Version()
-	if err != nil {
-		state["error"] = fmt.Errorf("Error reading version for metadata upload: %s", err)
-		return multistep.ActionHalt
-	}
-
 	ui.Say(fmt.Sprintf("Uploading VirtualBox version info (%s)", version))
 	var data bytes.Buffer
 	data.WriteString(version)
-	if err := comm.Upload(config.VBoxVersionFile, &data); err != nil {
+	if err := comm.Upload(*config.VBoxVersionFile, &data); err != nil {
 		state["error"] = fmt.Errorf("Error uploading VirtualBox version: %s", err)
 		return multistep.ActionHalt
 	}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"strings"
 	"text/template"
@@ -14,20 +15,29 @@ type commandTemplate struct {
 }
 
 // This step executes additional VBoxManage commands as specified by the
-// template.
+// template. It is used both before the VM boots ("vboxmanage") and after
+// it is shut down ("vboxmanage_post").
 //
 // Uses:
+//   driver Driver
+//   ui     packer.Ui
+//   vmName string
 //
 // Produces:
-type stepVBoxManage struct{}
+type stepVBoxManage struct {
+	commands [][]string
+}
 
 func (s *stepVBoxManage) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
-	vmName := state["vmName"].(string)
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	var vmName string
+	if err := bag.Values("driver", &driver, "ui", &ui, "vmName", &vmName); err != nil {
+		return bag.Halt(err)
+	}
 
-	if len(config.VBoxManage) > 0 {
+	if len(s.commands) > 0 {
 		ui.Say("Executing custom VBoxManage commands...")
 	}
 
@@ -35,7 +45,7 @@ func (s *stepVBoxManage) Run(state map[string]interface{}) multistep.StepAction
 		Name: vmName,
 	}
 
-	for _, originalCommand := range config.VBoxManage {
+	for _, originalCommand := range s.commands {
 		command := make([]string, len(originalCommand))
 		copy(command, originalCommand)
 
@@ -59,3 +69,23 @@ func (s *stepVBoxManage) Run(state map[string]interface{}) multistep.StepAction
 }
 
 func (s *stepVBoxManage) Cleanup(state map[string]interface{}) {}
+
+// validateVBoxManage verifies that the given list of VBoxManage commands,
+// specified by the given configuration key, are valid.
+func validateVBoxManage(key string, commands [][]string) []error {
+	errs := make([]error, 0)
+	for i, command := range commands {
+		if len(command) == 0 {
+			errs = append(errs, fmt.Errorf("%s command %d is empty", key, i+1))
+			continue
+		}
+
+		for _, arg := range command {
+			if _, err := template.New("arg").Parse(arg); err != nil {
+				errs = append(errs, fmt.Errorf("Error parsing %s command %d: %s", key, i+1, err))
+			}
+		}
+	}
+
+	return errs
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/communicator/ssh"
 	"github.com/mitchellh/packer/packer"
 	"log"
@@ -28,8 +29,12 @@ type stepWaitForSSH struct {
 }
 
 func (s *stepWaitForSSH) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var config *config
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
 	var comm packer.Communicator
 	var err error
@@ -81,9 +86,30 @@ func (s *stepWaitForSSH) Cleanup(map[string]interface{}) {
 // This blocks until SSH becomes available, and sends the communicator
 // on the given channel.
 func (s *stepWaitForSSH) waitForSSH(state map[string]interface{}) (packer.Communicator, error) {
-	config := state["config"].(*config)
-	ui := state["ui"].(packer.Ui)
-	sshHostPort := state["sshHostPort"].(uint)
+	bag := common.StateBag(state)
+	var config *config
+	var ui packer.Ui
+	var sshHostPort uint
+	if err := bag.Values("config", &config, "ui", &ui, "sshHostPort", &sshHostPort); err != nil {
+		return nil, err
+	}
+
+	// The agent is only needed until the connection is made
+	var agent *gossh.AgentClient
+	if config.SSHAgentAuth {
+		var agentConn net.Conn
+		var err error
+		agent, agentConn, err = common.SSHAgent()
+		if err != nil {
+			return nil, err
+		}
+		defer agentConn.Close()
+	}
+
+	auth, err := common.SSHAuth(config.SSHPassword, config.SSHPrivateKeyFile, config.SSHKeyPassphrase, agent)
+	if err != nil {
+		return nil, fmt.Errorf("Error setting up SSH config: %s", err)
+	}
 
 	ui.Say("Waiting for SSH to become available...")
 	var comm packer.Communicator
@@ -110,11 +136,7 @@ func (s *stepWaitForSSH) waitForSSH(state map[string]interface{}) (packer.Commun
 		// Then we attempt to connect via SSH
 		sshConfig := &gossh.ClientConfig{
 			User: config.SSHUser,
-			Auth: []gossh.ClientAuth{
-				gossh.ClientAuthPassword(ssh.Password(config.SSHPassword)),
-				gossh.ClientAuthKeyboardInteractive(
-					ssh.PasswordKeyboardInteractive(config.SSHPassword)),
-			},
+			Auth: auth,
 		}
 
 		sshConnectSuccess := make(chan bool, 1)
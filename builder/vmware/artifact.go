@@ -2,7 +2,11 @@ package vmware
 
 import (
 	"fmt"
+	"github.com/mitchellh/packer/packer"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 )
 
 // Artifact is the result of running the VMware builder, namely a set
@@ -12,6 +16,28 @@ type Artifact struct {
 	f   []string
 }
 
+// NewArtifact returns an artifact of all the files in the given directory.
+func NewArtifact(dir string) (packer.Artifact, error) {
+	files := make([]string, 0, 10)
+	visit := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+
+		return nil
+	}
+
+	if err := filepath.Walk(dir, visit); err != nil {
+		return nil, err
+	}
+
+	return &Artifact{dir, files}, nil
+}
+
 func (*Artifact) BuilderId() string {
 	return BuilderId
 }
@@ -31,3 +57,44 @@ func (a *Artifact) String() string {
 func (a *Artifact) Destroy() error {
 	return os.RemoveAll(a.dir)
 }
+
+// RemoteArtifact is the result of running the VMware builder against a
+// remote host, namely a VM directory on the datastore of that host.
+type RemoteArtifact struct {
+	// The datastore the VM is on.
+	Datastore string
+
+	// The full path to the VM directory on the remote host.
+	VMDir string
+
+	// The full path to the VMX file on the remote host.
+	VMXPath string
+
+	f      []string
+	driver RemoteDriver
+}
+
+func (*RemoteArtifact) BuilderId() string {
+	return RemoteBuilderId
+}
+
+func (a *RemoteArtifact) Files() []string {
+	return a.f
+}
+
+// Id returns the VMX file in the datastore path notation VMware uses,
+// such as "[datastore1] packer-foo/packer-foo.vmx".
+func (a *RemoteArtifact) Id() string {
+	rel := strings.TrimPrefix(a.VMXPath, path.Join("/vmfs/volumes", a.Datastore)+"/")
+	return fmt.Sprintf("[%s] %s", a.Datastore, rel)
+}
+
+func (a *RemoteArtifact) String() string {
+	return fmt.Sprintf(
+		"VM on remote host:\n  datastore: %s\n  directory: %s\n  vmx: %s",
+		a.Datastore, a.VMDir, a.VMXPath)
+}
+
+func (a *RemoteArtifact) Destroy() error {
+	return a.driver.RemoveOutputDir()
+}
@@ -12,3 +12,32 @@ func TestArtifact_Impl(t *testing.T) {
 		t.Fatal("Artifact must be a proper artifact")
 	}
 }
+
+func TestRemoteArtifact_Impl(t *testing.T) {
+	var raw interface{}
+	raw = &RemoteArtifact{}
+	if _, ok := raw.(packer.Artifact); !ok {
+		t.Fatal("RemoteArtifact must be a proper artifact")
+	}
+}
+
+func TestRemoteArtifactId(t *testing.T) {
+	a := &RemoteArtifact{
+		Datastore: "datastore1",
+		VMDir:     "/vmfs/volumes/datastore1/foo",
+		VMXPath:   "/vmfs/volumes/datastore1/foo/foo.vmx",
+	}
+
+	expected := "[datastore1] foo/foo.vmx"
+	if a.Id() != expected {
+		t.Fatalf("bad: %s", a.Id())
+	}
+
+	expected = `VM on remote host:
+  datastore: datastore1
+  directory: /vmfs/volumes/datastore1/foo
+  vmx: /vmfs/volumes/datastore1/foo/foo.vmx`
+	if a.String() != expected {
+		t.Fatalf("bad: %s", a.String())
+	}
+}
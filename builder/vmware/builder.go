@@ -7,11 +7,14 @@ import (
 	"github.com/mitchellh/multistep"
 	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
 	"log"
 	"math/rand"
+	"net"
 	"net/url"
 	"os"
-	"path/filepath"
+	"path"
+	"runtime"
 	"strings"
 	"text/template"
 	"time"
@@ -19,6 +22,10 @@ import (
 
 const BuilderId = "mitchellh.vmware"
 
+// RemoteBuilderId is the ID of artifacts that are left on a remote host,
+// rather than being files on the local machine.
+const RemoteBuilderId = "mitchellh.vmware.remote"
+
 type Builder struct {
 	config config
 	driver Driver
@@ -26,34 +33,59 @@ type Builder struct {
 }
 
 type config struct {
-	DiskName          string            `mapstructure:"vmdk_name"`
-	DiskSize          uint              `mapstructure:"disk_size"`
-	GuestOSType       string            `mapstructure:"guest_os_type"`
-	ISOMD5            string            `mapstructure:"iso_md5"`
-	ISOUrl            string            `mapstructure:"iso_url"`
-	VMName            string            `mapstructure:"vm_name"`
-	OutputDir         string            `mapstructure:"output_directory"`
-	Headless          bool              `mapstructure:"headless"`
-	HTTPDir           string            `mapstructure:"http_directory"`
-	HTTPPortMin       uint              `mapstructure:"http_port_min"`
-	HTTPPortMax       uint              `mapstructure:"http_port_max"`
-	BootCommand       []string          `mapstructure:"boot_command"`
-	BootWait          time.Duration     ``
-	SkipCompaction    bool              `mapstructure:"skip_compaction"`
-	ShutdownCommand   string            `mapstructure:"shutdown_command"`
-	ShutdownTimeout   time.Duration     ``
-	SSHUser           string            `mapstructure:"ssh_username"`
-	SSHPassword       string            `mapstructure:"ssh_password"`
-	SSHPort           uint              `mapstructure:"ssh_port"`
-	SSHWaitTimeout    time.Duration     ``
-	ToolsUploadFlavor string            `mapstructure:"tools_upload_flavor"`
-	ToolsUploadPath   string            `mapstructure:"tools_upload_path"`
-	VMXData           map[string]string `mapstructure:"vmx_data"`
-	VNCPortMin        uint              `mapstructure:"vnc_port_min"`
-	VNCPortMax        uint              `mapstructure:"vnc_port_max"`
+	AdditionalDiskSize []uint            `mapstructure:"disk_additional_size"`
+	DiskName           string            `mapstructure:"vmdk_name"`
+	DiskSize           uint              `mapstructure:"disk_size"`
+	DiskTypeId         string            `mapstructure:"disk_type_id"`
+	FusionAppPath      string            `mapstructure:"fusion_app_path"`
+	GuestOSType        string            `mapstructure:"guest_os_type"`
+	ISOMD5             string            `mapstructure:"iso_md5"`
+	ISOUrl             string            `mapstructure:"iso_url"`
+	Network            string            `mapstructure:"network"`
+	NetworkAdapterType string            `mapstructure:"network_adapter_type"`
+	SourcePath         string            `mapstructure:"source_path"`
+	LinkedClone        bool              `mapstructure:"linked_clone"`
+	VMName             string            `mapstructure:"vm_name"`
+	OutputDir          string            `mapstructure:"output_directory"`
+	RemoteType         string            `mapstructure:"remote_type"`
+	RemoteDatastore    string            `mapstructure:"remote_datastore"`
+	RemoteHost         string            `mapstructure:"remote_host"`
+	RemoteFingerprint  string            `mapstructure:"remote_host_fingerprint"`
+	RemoteOutput       bool              `mapstructure:"remote_output"`
+	RemotePassword     string            `mapstructure:"remote_password"`
+	RemotePort         uint              `mapstructure:"remote_port"`
+	RemoteUser         string            `mapstructure:"remote_username"`
+	Headless           bool              `mapstructure:"headless"`
+	HTTPDir            string            `mapstructure:"http_directory"`
+	HTTPPortMin        uint              `mapstructure:"http_port_min"`
+	HTTPPortMax        uint              `mapstructure:"http_port_max"`
+	BootCommand        []string          `mapstructure:"boot_command"`
+	BootWait           time.Duration     ``
+	SkipCompaction     bool              `mapstructure:"skip_compaction"`
+	ShutdownCommand    string            `mapstructure:"shutdown_command"`
+	ShutdownTimeout    time.Duration     ``
+	SSHUser            string            `mapstructure:"ssh_username"`
+	SSHPassword        string            `mapstructure:"ssh_password"`
+	SSHPrivateKeyFile  string            `mapstructure:"ssh_private_key_file"`
+	SSHKeyPassphrase   string            `mapstructure:"ssh_key_passphrase"`
+	SSHAgentAuth       bool              `mapstructure:"ssh_agent_auth"`
+	SSHPort            uint              `mapstructure:"ssh_port"`
+	SSHWaitTimeout     time.Duration     ``
+	ToolsUploadFlavor  string            `mapstructure:"tools_upload_flavor"`
+	ToolsUploadPath    string            `mapstructure:"tools_upload_path"`
+	VMXData            map[string]string `mapstructure:"vmx_data"`
+	VMXDataPost        map[string]string `mapstructure:"vmx_data_post"`
+	VMXTemplatePath    string            `mapstructure:"vmx_path_template"`
+	VdiskManagerPath   string            `mapstructure:"vdiskmanager_path"`
+	VmrunPath          string            `mapstructure:"vmrun_path"`
+	VNCBindAddress     string            `mapstructure:"vnc_bind_address"`
+	VNCPortMin         uint              `mapstructure:"vnc_port_min"`
+	VNCPortMax         uint              `mapstructure:"vnc_port_max"`
 
 	PackerBuildName string `mapstructure:"packer_build_name"`
 	PackerDebug     bool   `mapstructure:"packer_debug"`
+	PackerForce     bool   `mapstructure:"packer_force"`
+	PackerOnError   string `mapstructure:"packer_on_error"`
 
 	RawBootWait        string `mapstructure:"boot_wait"`
 	RawShutdownTimeout string `mapstructure:"shutdown_timeout"`
@@ -80,8 +112,12 @@ func (b *Builder) Prepare(raws ...interface{}) error {
 		b.config.GuestOSType = "other"
 	}
 
-	if b.config.VMName == "" {
-		b.config.VMName = fmt.Sprintf("packer-%s", b.config.PackerBuildName)
+	if b.config.Network == "" && b.config.RemoteType == "" {
+		b.config.Network = "nat"
+	}
+
+	if b.config.NetworkAdapterType == "" {
+		b.config.NetworkAdapterType = "e1000"
 	}
 
 	if b.config.HTTPPortMin == 0 {
@@ -96,32 +132,95 @@ func (b *Builder) Prepare(raws ...interface{}) error {
 		b.config.RawBootWait = "10s"
 	}
 
-	if b.config.VNCPortMin == 0 {
-		b.config.VNCPortMin = 5900
+	if b.config.DiskTypeId == "" {
+		// Default is growable virtual disk split in 2GB files, or a thin
+		// disk on ESXi.
+		b.config.DiskTypeId = "1"
+		if b.config.RemoteType != "" {
+			b.config.DiskTypeId = "thin"
+		}
 	}
 
-	if b.config.VNCPortMax == 0 {
-		b.config.VNCPortMax = 6000
+	if b.config.RemoteType != "" {
+		if b.config.RemoteDatastore == "" {
+			b.config.RemoteDatastore = "datastore1"
+		}
+
+		if b.config.RemotePort == 0 {
+			b.config.RemotePort = 22
+		}
+
+		if b.config.RemoteUser == "" {
+			b.config.RemoteUser = "root"
+		}
 	}
 
-	if b.config.OutputDir == "" {
-		b.config.OutputDir = fmt.Sprintf("output-%s", b.config.PackerBuildName)
+	errs := b.config.prepareCommon()
+
+	if b.config.HTTPPortMin > b.config.HTTPPortMax {
+		errs = append(errs, errors.New("http_port_min must be less than http_port_max"))
 	}
 
-	if b.config.SSHPort == 0 {
-		b.config.SSHPort = 22
+	// The main disk is SCSI unit 0, and unit 7 is the controller itself
+	if len(b.config.AdditionalDiskSize) > 14 {
+		errs = append(errs, errors.New("disk_additional_size can have at most 14 disks"))
 	}
 
-	if b.config.ToolsUploadPath == "" {
-		b.config.ToolsUploadPath = "{{ .Flavor }}.iso"
+	validDiskTypes := []string{"0", "1", "2", "3", "4", "5"}
+	if b.config.RemoteType != "" {
+		validDiskTypes = []string{"thin", "zeroedthick", "eagerzeroedthick"}
 	}
 
-	// Accumulate any errors
-	var err error
-	errs := make([]error, 0)
+	validDiskType := false
+	for _, t := range validDiskTypes {
+		if b.config.DiskTypeId == t {
+			validDiskType = true
+			break
+		}
+	}
 
-	if b.config.HTTPPortMin > b.config.HTTPPortMax {
-		errs = append(errs, errors.New("http_port_min must be less than http_port_max"))
+	if !validDiskType {
+		errs = append(errs, fmt.Errorf(
+			"disk_type_id must be one of: %s", strings.Join(validDiskTypes, ", ")))
+	}
+
+	// Preallocated disks can't be compacted, and the tools error if asked to
+	if diskTypePreallocated(b.config.DiskTypeId) {
+		b.config.SkipCompaction = true
+	}
+
+	validNetworks := []string{"nat", "hostonly"}
+	_, validNetwork := networkDevices[b.config.Network]
+
+	if b.config.RemoteType != "" {
+		if b.config.Network != "" {
+			errs = append(errs, errors.New("network can't be used for remote builds"))
+		}
+	} else if b.config.Network == "bridged" {
+		// The guest's IP is looked up in the leases of the VMware DHCP
+		// server, which doesn't serve bridged networks.
+		errs = append(errs, errors.New(
+			"network can't be bridged, since the IP of a bridged VM can't be found"))
+	} else if !validNetwork {
+		errs = append(errs, fmt.Errorf(
+			"network must be one of: %s", strings.Join(validNetworks, ", ")))
+	}
+
+	validAdapterTypes := []string{"e1000", "e1000e", "vmxnet3"}
+	validAdapterType := false
+	for _, t := range validAdapterTypes {
+		if b.config.NetworkAdapterType == t {
+			validAdapterType = true
+			break
+		}
+	}
+
+	if !validAdapterType {
+		errs = append(errs, fmt.Errorf(
+			"network_adapter_type must be one of: %s", strings.Join(validAdapterTypes, ", ")))
+	} else if b.config.NetworkAdapterType == "vmxnet3" && !guestOSSupportsVmxnet3(b.config.GuestOSType) {
+		errs = append(errs, fmt.Errorf(
+			"network_adapter_type vmxnet3 isn't supported by guest_os_type %s", b.config.GuestOSType))
 	}
 
 	if b.config.ISOMD5 == "" {
@@ -169,57 +268,173 @@ func (b *Builder) Prepare(raws ...interface{}) error {
 		}
 	}
 
-	if _, err := os.Stat(b.config.OutputDir); err == nil {
-		errs = append(errs, errors.New("Output directory already exists. It must not exist."))
+	if b.config.VMXTemplatePath != "" {
+		if err := validateVMXTemplate(b.config.VMXTemplatePath); err != nil {
+			errs = append(errs, fmt.Errorf("vmx_path_template is invalid: %s", err))
+		}
 	}
 
-	if b.config.SSHUser == "" {
-		errs = append(errs, errors.New("An ssh_username must be specified."))
+	if b.config.RemoteType != "" {
+		if b.config.RemoteType != "esx5" {
+			errs = append(errs, errors.New("remote_type must be 'esx5'"))
+		}
+
+		if b.config.RemoteHost == "" {
+			errs = append(errs, errors.New("remote_host must be specified with remote_type"))
+		}
+
+		if b.config.RemotePassword == "" {
+			errs = append(errs, errors.New("remote_password must be specified with remote_type"))
+		}
+
+		if b.config.ToolsUploadFlavor != "" {
+			errs = append(errs, errors.New("tools_upload_flavor can't be used with remote_type"))
+		}
 	}
 
-	if b.config.RawBootWait != "" {
-		b.config.BootWait, err = time.ParseDuration(b.config.RawBootWait)
+	// The driver of a remote build connects to the remote host, so only
+	// bother if the configuration is otherwise valid.
+	if b.config.RemoteType == "" || len(errs) == 0 {
+		var err error
+		b.driver, err = newDriver(&b.config)
 		if err != nil {
-			errs = append(errs, fmt.Errorf("Failed parsing boot_wait: %s", err))
+			errs = append(errs, fmt.Errorf("Failed creating VMware driver: %s", err))
 		}
 	}
 
-	if b.config.RawShutdownTimeout == "" {
-		b.config.RawShutdownTimeout = "5m"
+	if len(errs) > 0 {
+		return &packer.MultiError{errs}
 	}
 
-	b.config.ShutdownTimeout, err = time.ParseDuration(b.config.RawShutdownTimeout)
-	if err != nil {
-		errs = append(errs, fmt.Errorf("Failed parsing shutdown_timeout: %s", err))
+	return nil
+}
+
+// prepareCommon sets the defaults of the configuration shared by the ISO
+// and VMX builders, and validates it, returning any errors.
+func (c *config) prepareCommon() []error {
+	var err error
+
+	if c.FusionAppPath == "" {
+		c.FusionAppPath = "/Applications/VMware Fusion.app"
+	}
+
+	if c.VMName == "" {
+		c.VMName = fmt.Sprintf("packer-%s", c.PackerBuildName)
+	}
+
+	// VNC on ESXi is reached over the network, so it can't default to the
+	// loopback address like a local VMware product can.
+	if c.VNCBindAddress == "" && c.RemoteType == "" {
+		c.VNCBindAddress = "127.0.0.1"
+	}
+
+	if c.VNCPortMin == 0 {
+		c.VNCPortMin = 5900
+	}
+
+	if c.VNCPortMax == 0 {
+		c.VNCPortMax = 6000
+	}
+
+	if c.OutputDir == "" {
+		c.OutputDir = fmt.Sprintf("output-%s", c.PackerBuildName)
 	}
 
-	if b.config.RawSSHWaitTimeout == "" {
-		b.config.RawSSHWaitTimeout = "20m"
+	if c.RawShutdownTimeout == "" {
+		c.RawShutdownTimeout = "5m"
 	}
 
-	b.config.SSHWaitTimeout, err = time.ParseDuration(b.config.RawSSHWaitTimeout)
+	if c.RawSSHWaitTimeout == "" {
+		c.RawSSHWaitTimeout = "20m"
+	}
+
+	if c.SSHPort == 0 {
+		c.SSHPort = 22
+	}
+
+	if c.ToolsUploadPath == "" {
+		c.ToolsUploadPath = "{{ .Flavor }}.iso"
+	}
+
+	// Accumulate any errors
+	errs := make([]error, 0)
+
+	if _, err := os.Stat(c.OutputDir); err == nil && !c.PackerForce {
+		errs = append(errs, errors.New("Output directory already exists. It must not exist, unless the build is forced."))
+	}
+
+	if c.SSHUser == "" {
+		errs = append(errs, errors.New("An ssh_username must be specified."))
+	}
+
+	if c.SSHPrivateKeyFile != "" {
+		if _, err := common.SSHKeychain(c.SSHPrivateKeyFile, c.SSHKeyPassphrase); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_private_key_file is invalid: %s", err))
+		}
+	} else if c.SSHKeyPassphrase != "" {
+		errs = append(errs, errors.New("ssh_key_passphrase requires an ssh_private_key_file"))
+	}
+
+	if c.SSHAgentAuth {
+		if _, conn, err := common.SSHAgent(); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_agent_auth is set, but the agent can't be used: %s", err))
+		} else {
+			conn.Close()
+		}
+	}
+
+	if c.RawBootWait != "" {
+		c.BootWait, err = time.ParseDuration(c.RawBootWait)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed parsing boot_wait: %s", err))
+		}
+	}
+
+	c.ShutdownTimeout, err = time.ParseDuration(c.RawShutdownTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing shutdown_timeout: %s", err))
+	}
+
+	c.SSHWaitTimeout, err = time.ParseDuration(c.RawSSHWaitTimeout)
 	if err != nil {
 		errs = append(errs, fmt.Errorf("Failed parsing ssh_wait_timeout: %s", err))
 	}
 
-	if _, err := template.New("path").Parse(b.config.ToolsUploadPath); err != nil {
+	if c.ToolsUploadFlavor != "" {
+		validFlavors := []string{"darwin", "linux", "windows"}
+		validFlavor := false
+		for _, flavor := range validFlavors {
+			if c.ToolsUploadFlavor == flavor {
+				validFlavor = true
+				break
+			}
+		}
+
+		if !validFlavor {
+			errs = append(errs, fmt.Errorf(
+				"tools_upload_flavor must be one of: %s", strings.Join(validFlavors, ", ")))
+		}
+	}
+
+	if _, err := template.New("path").Parse(c.ToolsUploadPath); err != nil {
 		errs = append(errs, fmt.Errorf("tools_upload_path invalid: %s", err))
 	}
 
-	if b.config.VNCPortMin > b.config.VNCPortMax {
-		errs = append(errs, fmt.Errorf("vnc_port_min must be less than vnc_port_max"))
+	errs = append(errs, validateBinaryPaths(c)...)
+
+	if c.VNCBindAddress != "" && net.ParseIP(c.VNCBindAddress) == nil {
+		errs = append(errs, fmt.Errorf("vnc_bind_address is not a valid IP address: %s", c.VNCBindAddress))
 	}
 
-	b.driver, err = b.newDriver()
-	if err != nil {
-		errs = append(errs, fmt.Errorf("Failed creating VMware driver: %s", err))
+	if c.VNCPortMin > c.VNCPortMax {
+		errs = append(errs, fmt.Errorf("vnc_port_min must be less than vnc_port_max"))
 	}
 
-	if len(errs) > 0 {
-		return &packer.MultiError{errs}
+	if c.VNCPortMax > 65535 {
+		errs = append(errs, fmt.Errorf("vnc_port_max must be less than 65536"))
 	}
 
-	return nil
+	return errs
 }
 
 func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
@@ -228,20 +443,38 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 
 	steps := []multistep.Step{
 		&stepPrepareTools{},
-		&stepDownloadISO{},
-		&stepPrepareOutputDir{},
+		&common.StepDownloadISO{
+			Checksum:     b.config.ISOMD5,
+			ChecksumType: "md5",
+			Urls:         []string{b.config.ISOUrl},
+		},
+		&stepRemoteUploadISO{},
+		&common.StepPrepareOutputDir{
+			Dir:   b.config.OutputDir,
+			Force: b.config.PackerForce,
+		},
+		&stepPrepareRemoteOutputDir{},
 		&stepCreateDisk{},
 		&stepCreateVMX{},
-		&stepHTTPServer{},
+		&stepConfigureVMX{CustomData: b.config.VMXData},
+		&common.StepHTTPServer{
+			Dir:     b.config.HTTPDir,
+			PortMin: b.config.HTTPPortMin,
+			PortMax: b.config.HTTPPortMax,
+		},
 		&stepConfigureVNC{},
+		&stepRegister{},
 		&stepRun{},
 		&stepTypeBootCommand{},
 		&stepWaitForSSH{},
 		&stepUploadTools{},
 		&stepProvision{},
 		&stepShutdown{},
+		&stepCleanVMX{},
+		&stepConfigureVMX{CustomData: b.config.VMXDataPost},
 		&stepCleanFiles{},
 		&stepCompactDisk{},
+		&stepRemoteDownload{},
 	}
 
 	// Setup the state bag
@@ -253,16 +486,10 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	state["ui"] = ui
 
 	// Run!
-	if b.config.PackerDebug {
-		b.runner = &multistep.DebugRunner{
-			Steps:   steps,
-			PauseFn: common.MultistepDebugFn(ui),
-		}
-	} else {
-		b.runner = &multistep.BasicRunner{Steps: steps}
-	}
+	b.runner = common.NewRunner(steps, b.config.PackerDebug, b.config.PackerOnError, ui)
 
 	b.runner.Run(state)
+	common.ReportStepTimings(ui, state)
 
 	// If there was an error, return that
 	if rawErr, ok := state["error"]; ok {
@@ -278,25 +505,25 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		return nil, errors.New("Build was halted.")
 	}
 
-	// Compile the artifact list
-	files := make([]string, 0, 10)
-	visit := func(path string, info os.FileInfo, err error) error {
+	// Remote builds leave the VM on the remote host, unless it was
+	// downloaded into the output directory.
+	if driver, ok := b.driver.(RemoteDriver); ok && !b.config.RemoteOutput {
+		files, err := driver.OutputFiles()
 		if err != nil {
-			return err
-		}
-
-		if !info.IsDir() {
-			files = append(files, path)
+			return nil, err
 		}
 
-		return nil
-	}
-
-	if err := filepath.Walk(b.config.OutputDir, visit); err != nil {
-		return nil, err
+		dir := path.Join("/vmfs/volumes", b.config.RemoteDatastore, b.config.VMName)
+		return &RemoteArtifact{
+			Datastore: b.config.RemoteDatastore,
+			VMDir:     dir,
+			VMXPath:   path.Join(dir, b.config.VMName+".vmx"),
+			f:         files,
+			driver:    driver,
+		}, nil
 	}
 
-	return &Artifact{b.config.OutputDir, files}, nil
+	return NewArtifact(b.config.OutputDir)
 }
 
 func (b *Builder) Cancel() {
@@ -306,12 +533,137 @@ func (b *Builder) Cancel() {
 	}
 }
 
-func (b *Builder) newDriver() (Driver, error) {
-	fusionAppPath := "/Applications/VMware Fusion.app"
-	driver := &Fusion5Driver{fusionAppPath}
-	if err := driver.Verify(); err != nil {
-		return nil, err
+// newDriver returns the driver for the VMware product to build with.
+func newDriver(config *config) (Driver, error) {
+	if config.RemoteType == "esx5" {
+		driver := &ESX5Driver{
+			Host:            config.RemoteHost,
+			Port:            config.RemotePort,
+			Username:        config.RemoteUser,
+			Password:        config.RemotePassword,
+			HostFingerprint: config.RemoteFingerprint,
+			Datastore:       config.RemoteDatastore,
+			VMName:          config.VMName,
+		}
+
+		if err := driver.Verify(); err != nil {
+			return nil, err
+		}
+
+		return driver, nil
+	}
+
+	// Try the drivers for the products that can be installed on this
+	// OS, in the order they're most likely to be installed.
+	var drivers []Driver
+	fusion := &Fusion5Driver{
+		AppPath:          config.FusionAppPath,
+		VmrunPath:        config.VmrunPath,
+		VdiskManagerPath: config.VdiskManagerPath,
+	}
+	workstation := &Workstation9Driver{
+		VmrunPath:        config.VmrunPath,
+		VdiskManagerPath: config.VdiskManagerPath,
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		drivers = []Driver{fusion}
+	case "linux":
+		drivers = []Driver{workstation}
+	default:
+		drivers = []Driver{fusion, workstation}
+	}
+
+	errs := ""
+	for _, driver := range drivers {
+		err := driver.Verify()
+		if err == nil {
+			return driver, nil
+		}
+
+		errs += "* " + err.Error() + "\n"
+	}
+
+	return nil, fmt.Errorf(
+		"Unable to find VMware. The errors from each product that was\n"+
+			"looked for are shown below:\n\n%s", errs)
+}
+
+// networkDevices maps the network types to the VMware network device that
+// the VM is connected to, whose DHCP leases hold the IP of the VM and
+// whose interface on the host has the IP the VM can reach us on.
+var networkDevices = map[string]string{
+	"hostonly": "vmnet1",
+	"nat":      "vmnet8",
+}
+
+// networkDevice returns the VMware network device for the network type.
+// VMs cloned from a VMX file keep the network of the source, which is
+// assumed to be NAT, the default of the VMware products.
+func networkDevice(network string) string {
+	if device, ok := networkDevices[network]; ok {
+		return device
+	}
+
+	return "vmnet8"
+}
+
+// guestOSSupportsVmxnet3 returns whether VMware offers the vmxnet3 network
+// adapter for the given guest OS type. Only guests that predate vmxnet3
+// are known not to support it.
+func guestOSSupportsVmxnet3(guestOS string) bool {
+	unsupported := []string{
+		"dos", "other", "other24xlinux", "redhat", "win31", "win95",
+		"win98", "winme", "winnt", "win2000pro", "win2000serv",
+		"win2000advserv", "winnetweb", "solaris8", "solaris9",
+		"netware4", "netware5", "netware6", "freebsd",
+	}
+
+	for _, t := range unsupported {
+		if strings.ToLower(guestOS) == t {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateBinaryPaths checks that the VMware binaries that were explicitly
+// configured exist and are executable.
+func validateBinaryPaths(config *config) []error {
+	paths := []struct {
+		key  string
+		path string
+	}{
+		{"vdiskmanager_path", config.VdiskManagerPath},
+		{"vmrun_path", config.VmrunPath},
+	}
+
+	errs := make([]error, 0)
+	for _, p := range paths {
+		if p.path == "" {
+			continue
+		}
+
+		info, err := os.Stat(p.path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s is invalid: %s", p.key, err))
+		} else if info.IsDir() || info.Mode()&0111 == 0 {
+			errs = append(errs, fmt.Errorf("%s is not executable: %s", p.key, p.path))
+		}
+	}
+
+	return errs
+}
+
+// validateVMXTemplate checks that the file at the given path can be read
+// and parsed as a VMX template.
+func validateVMXTemplate(path string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
 	}
 
-	return driver, nil
+	_, err = template.New("vmx").Parse(string(contents))
+	return err
 }
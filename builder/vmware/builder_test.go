@@ -81,6 +81,28 @@ func TestBuilderPrepare_Defaults(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_AdditionalDiskSize(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["disk_additional_size"] = []uint{10000, 20000}
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(b.config.AdditionalDiskSize) != 2 {
+		t.Fatalf("bad: %#v", b.config.AdditionalDiskSize)
+	}
+
+	config["disk_additional_size"] = make([]uint, 15)
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
 func TestBuilderPrepare_DiskSize(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -107,6 +129,47 @@ func TestBuilderPrepare_DiskSize(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_DiskTypeId(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	delete(config, "disk_type_id")
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("bad err: %s", err)
+	}
+
+	if b.config.DiskTypeId != "1" {
+		t.Fatalf("bad type: %s", b.config.DiskTypeId)
+	}
+
+	config["disk_type_id"] = "2"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.DiskTypeId != "2" {
+		t.Fatalf("bad type: %s", b.config.DiskTypeId)
+	}
+
+	config["disk_type_id"] = "6"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// ESXi disk formats are only valid for remote builds
+	config["disk_type_id"] = "thin"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
 func TestBuilderPrepare_HTTPPort(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -220,7 +283,16 @@ func TestBuilderPrepare_OutputDir(t *testing.T) {
 		t.Fatal("should have error")
 	}
 
+	// Test with existing dir and a forced build
+	config[packer.ForceConfigKey] = true
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
 	// Test with a good one
+	delete(config, packer.ForceConfigKey)
 	config["output_directory"] = "i-hope-i-dont-exist"
 	err = b.Prepare(config)
 	if err != nil {
@@ -247,6 +319,76 @@ func TestBuilderPrepare_ShutdownTimeout(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_RemoteType(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Bad
+	config["remote_type"] = "foobar"
+	config["remote_host"] = "foo"
+	config["remote_password"] = "bar"
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Bad, no host
+	config["remote_type"] = "esx5"
+	delete(config, "remote_host")
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Bad, tools upload
+	config["remote_host"] = "foo"
+	config["tools_upload_flavor"] = "linux"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_SkipCompaction(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if b.config.SkipCompaction {
+		t.Fatal("should not skip compaction by default")
+	}
+
+	config["skip_compaction"] = true
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !b.config.SkipCompaction {
+		t.Fatal("should skip compaction")
+	}
+
+	// Preallocated disks imply skipping compaction
+	delete(config, "skip_compaction")
+	config["disk_type_id"] = "2"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !b.config.SkipCompaction {
+		t.Fatal("should skip compaction")
+	}
+}
+
 func TestBuilderPrepare_SSHUser(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -311,6 +453,26 @@ func TestBuilderPrepare_SSHWaitTimeout(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_ToolsUploadFlavor(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Good
+	config["tools_upload_flavor"] = "linux"
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Bad
+	config["tools_upload_flavor"] = "solaris"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
 func TestBuilderPrepare_ToolsUploadPath(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -341,6 +503,130 @@ func TestBuilderPrepare_ToolsUploadPath(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_Network(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test the default
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.Network != "nat" {
+		t.Fatalf("bad: %s", b.config.Network)
+	}
+
+	// Bad
+	config["network"] = "nope"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Bad, the IP of a bridged VM can't be found
+	config["network"] = "bridged"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Good
+	config["network"] = "hostonly"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestNetworkDevice(t *testing.T) {
+	cases := map[string]string{
+		"":         "vmnet8",
+		"hostonly": "vmnet1",
+		"nat":      "vmnet8",
+	}
+
+	for network, expected := range cases {
+		if device := networkDevice(network); device != expected {
+			t.Fatalf("bad device for %q: %s", network, device)
+		}
+	}
+}
+
+func TestBuilderPrepare_NetworkAdapterType(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test the default
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.NetworkAdapterType != "e1000" {
+		t.Fatalf("bad: %s", b.config.NetworkAdapterType)
+	}
+
+	// Bad
+	config["network_adapter_type"] = "pcnet32"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Bad, the guest OS is too old for vmxnet3
+	config["guest_os_type"] = "win2000pro"
+	config["network_adapter_type"] = "vmxnet3"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Good
+	config["guest_os_type"] = "ubuntu-64"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_VNCBindAddress(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test the default
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.VNCBindAddress != "127.0.0.1" {
+		t.Fatalf("bad: %s", b.config.VNCBindAddress)
+	}
+
+	// Bad
+	config["vnc_bind_address"] = "nope"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Good
+	config["vnc_bind_address"] = "0.0.0.0"
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
 func TestBuilderPrepare_VNCPort(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -360,7 +646,17 @@ func TestBuilderPrepare_VNCPort(t *testing.T) {
 		t.Fatal("should have error")
 	}
 
+	// Bad
+	b = Builder{}
+	config["vnc_port_min"] = 60000
+	config["vnc_port_max"] = 70000
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
 	// Good
+	b = Builder{}
 	config["vnc_port_min"] = 500
 	config["vnc_port_max"] = 1000
 	err = b.Prepare(config)
@@ -369,6 +665,85 @@ func TestBuilderPrepare_VNCPort(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_VMXTemplatePath(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Bad
+	config["vmx_path_template"] = "/i/dont/exist.vmx"
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	// Bad, not a valid template
+	tf.WriteString(`displayName = "{{ .Name"`)
+	tf.Close()
+	config["vmx_path_template"] = tf.Name()
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Good
+	if err := ioutil.WriteFile(tf.Name(), []byte(`displayName = "{{ .Name }}"`), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_VmrunPath(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Bad
+	config["vmrun_path"] = "/i/dont/exist"
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	// Bad, not executable
+	os.Chmod(tf.Name(), 0644)
+	config["vmrun_path"] = tf.Name()
+	b = Builder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Good
+	os.Chmod(tf.Name(), 0755)
+	b = Builder{}
+	err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.VmrunPath != tf.Name() {
+		t.Fatalf("bad: %s", b.config.VmrunPath)
+	}
+}
+
 func TestBuilderPrepare_VMXData(t *testing.T) {
 	var b Builder
 	config := testConfig()
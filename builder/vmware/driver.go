@@ -2,11 +2,8 @@ package vmware
 
 import (
 	"bytes"
-	"fmt"
 	"log"
-	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 )
 
@@ -15,8 +12,12 @@ type Driver interface {
 	// CompactDisk compacts a virtual disk.
 	CompactDisk(string) error
 
-	// CreateDisk creates a virtual disk with the given size.
-	CreateDisk(string, string) error
+	// CreateDisk creates a virtual disk with the given size and disk type.
+	CreateDisk(string, string, string) error
+
+	// DHCPLeasePath returns the path to the DHCP leases file of the given
+	// network device, used to find the IP of the VM.
+	DHCPLeasePath(string) string
 
 	// Checks if the VMX file at the given path is running.
 	IsRunning(string) (bool, error)
@@ -27,6 +28,10 @@ type Driver interface {
 	// Stop stops a VM specified by the path to the VMX given.
 	Stop(string) error
 
+	// StopSoft asks the guest OS of the VM specified by the path to the
+	// VMX given to shut down, without waiting for it to do so.
+	StopSoft(string) error
+
 	// Get the path to the VMware ISO for the given flavor.
 	ToolsIsoPath(string) string
 
@@ -37,120 +42,56 @@ type Driver interface {
 	Verify() error
 }
 
-// Fusion5Driver is a driver that can run VMWare Fusion 5.
-type Fusion5Driver struct {
-	// This is the path to the "VMware Fusion.app"
-	AppPath string
+// A LinkedCloner is a Driver that can create linked clones of VMs, which
+// share the disks of the source VM rather than copying them.
+type LinkedCloner interface {
+	// LinkedClone creates a linked clone at the given destination VMX path of
+	// the VM of the given source VMX path.
+	LinkedClone(string, string) error
 }
 
-func (d *Fusion5Driver) CompactDisk(diskPath string) error {
-	defragCmd := exec.Command(d.vdiskManagerPath(), "-d", diskPath)
-	if _, _, err := d.runAndLog(defragCmd); err != nil {
-		return err
-	}
-
-	shrinkCmd := exec.Command(d.vdiskManagerPath(), "-k", diskPath)
-	if _, _, err := d.runAndLog(shrinkCmd); err != nil {
-		return err
-	}
+// A RemoteDriver is a Driver that builds the VM on a remote host rather
+// than the local machine. The VMX file is still written to the local
+// output directory, and is then uploaded to the remote host.
+type RemoteDriver interface {
+	Driver
+	GuestIPFinder
+	HostIPFinder
 
-	return nil
-}
+	// CreateOutputDir creates the directory on the remote host that the VM
+	// is built in. It is an error if it already exists.
+	CreateOutputDir() error
 
-func (d *Fusion5Driver) CreateDisk(output string, size string) error {
-	cmd := exec.Command(d.vdiskManagerPath(), "-c", "-s", size, "-a", "lsilogic", "-t", "1", output)
-	if _, _, err := d.runAndLog(cmd); err != nil {
-		return err
-	}
+	// RemoveOutputDir deletes the VM's directory on the remote host.
+	RemoveOutputDir() error
 
-	return nil
-}
+	// OutputFiles lists the files in the VM's directory on the remote host.
+	OutputFiles() ([]string, error)
 
-func (d *Fusion5Driver) IsRunning(vmxPath string) (bool, error) {
-	vmxPath, err := filepath.Abs(vmxPath)
-	if err != nil {
-		return false, err
-	}
-
-	cmd := exec.Command(d.vmrunPath(), "-T", "fusion", "list")
-	stdout, _, err := d.runAndLog(cmd)
-	if err != nil {
-		return false, err
-	}
-
-	for _, line := range strings.Split(stdout, "\n") {
-		if line == vmxPath {
-			return true, nil
-		}
-	}
-
-	return false, nil
-}
+	// Download copies a file on the remote host to a local path.
+	Download(string, string) error
 
-func (d *Fusion5Driver) Start(vmxPath string, headless bool) error {
-	guiArgument := "gui"
-	if headless == true {
-		guiArgument = "nogui"
-	}
+	// UploadISO uploads the ISO at the given local path to the remote host,
+	// unless it is there already. It returns the path of the ISO on the
+	// remote host and whether it was uploaded.
+	UploadISO(string) (string, bool, error)
 
-	cmd := exec.Command(d.vmrunPath(), "-T", "fusion", "start", vmxPath, guiArgument)
-	if _, _, err := d.runAndLog(cmd); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Fusion5Driver) Stop(vmxPath string) error {
-	cmd := exec.Command(d.vmrunPath(), "-T", "fusion", "stop", vmxPath, "hard")
-	if _, _, err := d.runAndLog(cmd); err != nil {
-		return err
-	}
-
-	return nil
-}
+	// RemoveFile deletes a file on the remote host.
+	RemoveFile(string) error
 
-func (d *Fusion5Driver) Verify() error {
-	if _, err := os.Stat(d.AppPath); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("Fusion application not found at path: %s", d.AppPath)
-		}
+	// UploadVMX uploads the local VMX file at the given path into the VM's
+	// directory on the remote host.
+	UploadVMX(string) error
 
-		return err
-	}
-
-	if _, err := os.Stat(d.vmrunPath()); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("Critical application 'vmrun' not found at path: %s", d.vmrunPath())
-		}
-
-		return err
-	}
-
-	if _, err := os.Stat(d.vdiskManagerPath()); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("Critical application vdisk manager not found at path: %s", d.vdiskManagerPath())
-		}
-
-		return err
-	}
-
-	return nil
-}
-
-func (d *Fusion5Driver) vdiskManagerPath() string {
-	return filepath.Join(d.AppPath, "Contents", "Library", "vmware-vdiskmanager")
-}
-
-func (d *Fusion5Driver) vmrunPath() string {
-	return filepath.Join(d.AppPath, "Contents", "Library", "vmrun")
-}
+	// Register registers the VM of the given VMX file with the remote host.
+	Register(string) error
 
-func (d *Fusion5Driver) ToolsIsoPath(k string) string {
-	return filepath.Join(d.AppPath, "Contents", "Library", "isoimages", k+".iso")
+	// Unregister unregisters the VM of the given VMX file from the remote
+	// host, leaving its files in place.
+	Unregister(string) error
 }
 
-func (d *Fusion5Driver) runAndLog(cmd *exec.Cmd) (string, string, error) {
+func runAndLog(cmd *exec.Cmd) (string, string, error) {
 	var stdout, stderr bytes.Buffer
 
 	log.Printf("Executing: %s %v", cmd.Path, cmd.Args[1:])
@@ -0,0 +1,426 @@
+package vmware
+
+import (
+	"bytes"
+	gossh "code.google.com/p/go.crypto/ssh"
+	"crypto/md5"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/mitchellh/packer/communicator/ssh"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ESX5Driver is a driver that builds on a remote VMware ESXi 5 host. It
+// talks to the host over SSH, controlling VMs with vim-cmd and creating
+// disks with vmkfstools. The VM is created in a directory named after the
+// VM on the given datastore.
+type ESX5Driver struct {
+	Host            string
+	Port            uint
+	Username        string
+	Password        string
+	HostFingerprint string
+	Datastore       string
+	VMName          string
+
+	comm packer.Communicator
+	conn net.Conn
+	vmId string
+}
+
+func (d *ESX5Driver) CompactDisk(diskPath string) error {
+	_, err := d.sh("vmkfstools", "--punchzero", ssh.ShellQuote(d.remotePath(diskPath)))
+	return err
+}
+
+func (d *ESX5Driver) CreateDisk(output string, size string, typeId string) error {
+	_, err := d.sh("vmkfstools", "-c", size, "-d", typeId, "-a", "lsilogic",
+		ssh.ShellQuote(d.remotePath(output)))
+	return err
+}
+
+// DHCPLeasePath isn't used, since the ESX5Driver finds the IP of the
+// guest itself.
+func (d *ESX5Driver) DHCPLeasePath(string) string {
+	return ""
+}
+
+func (d *ESX5Driver) IsRunning(string) (bool, error) {
+	if d.vmId == "" {
+		return false, nil
+	}
+
+	stdout, err := d.sh("vim-cmd", "vmsvc/power.getstate", d.vmId)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.Contains(stdout, "Powered on"), nil
+}
+
+func (d *ESX5Driver) Start(string, bool) error {
+	_, err := d.sh("vim-cmd", "vmsvc/power.on", d.vmId)
+	return err
+}
+
+func (d *ESX5Driver) Stop(string) error {
+	_, err := d.sh("vim-cmd", "vmsvc/power.off", d.vmId)
+	return err
+}
+
+// StopSoft shuts down the guest OS, which requires VMware Tools to be
+// running in the guest.
+func (d *ESX5Driver) StopSoft(string) error {
+	_, err := d.sh("vim-cmd", "vmsvc/power.shutdown", d.vmId)
+	return err
+}
+
+// ToolsIsoPath returns the path to the tools ISO on the ESXi host. These
+// can't be uploaded into the VM from there, so the builder doesn't allow
+// tools_upload_flavor for remote builds.
+func (d *ESX5Driver) ToolsIsoPath(flavor string) string {
+	return path.Join("/vmimages/tools-isoimages", flavor+".iso")
+}
+
+func (d *ESX5Driver) Verify() error {
+	if err := d.connect(); err != nil {
+		return err
+	}
+
+	if _, err := d.sh("esxcli", "system", "version", "get"); err != nil {
+		return fmt.Errorf("Remote host doesn't appear to be ESXi: %s", err)
+	}
+
+	if _, err := d.sh("test", "-d", ssh.ShellQuote(d.datastoreDir())); err != nil {
+		return fmt.Errorf("Datastore not found on remote host: %s", d.Datastore)
+	}
+
+	return nil
+}
+
+func (d *ESX5Driver) CreateOutputDir() error {
+	if _, err := d.sh("test", "-e", ssh.ShellQuote(d.outputDir())); err == nil {
+		return fmt.Errorf("Output directory already exists on datastore: %s", d.outputDir())
+	}
+
+	_, err := d.sh("mkdir", "-p", ssh.ShellQuote(d.outputDir()))
+	return err
+}
+
+func (d *ESX5Driver) RemoveOutputDir() error {
+	_, err := d.sh("rm", "-rf", ssh.ShellQuote(d.outputDir()))
+	return err
+}
+
+func (d *ESX5Driver) OutputFiles() ([]string, error) {
+	stdout, err := d.sh("find", ssh.ShellQuote(d.outputDir()), "-type", "f")
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, 10)
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+
+	return files, nil
+}
+
+func (d *ESX5Driver) Download(src, dst string) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.comm.Download(src, f)
+}
+
+func (d *ESX5Driver) UploadISO(localPath string) (string, bool, error) {
+	remotePath := path.Join(d.datastoreDir(), "packer_cache", filepath.Base(localPath))
+	if _, err := d.sh("test", "-e", ssh.ShellQuote(remotePath)); err == nil {
+		log.Printf("ISO already on datastore: %s", remotePath)
+		return remotePath, false, nil
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", false, err
+	}
+
+	free, err := d.datastoreFree()
+	if err != nil {
+		return "", false, fmt.Errorf("Error checking free space on datastore: %s", err)
+	}
+
+	if uint64(info.Size()) > free {
+		return "", false, fmt.Errorf(
+			"Not enough free space on datastore %s for the ISO: need %d bytes, %d available",
+			d.Datastore, info.Size(), free)
+	}
+
+	if _, err := d.sh("mkdir", "-p", ssh.ShellQuote(path.Dir(remotePath))); err != nil {
+		return "", false, err
+	}
+
+	// Upload to a temporary name first, so that a partial upload is never
+	// mistaken for a cached ISO.
+	partPath := remotePath + ".part"
+	if err := d.comm.Upload(partPath, f); err != nil {
+		d.RemoveFile(partPath)
+		return "", false, err
+	}
+
+	if _, err := d.sh("mv", ssh.ShellQuote(partPath), ssh.ShellQuote(remotePath)); err != nil {
+		d.RemoveFile(partPath)
+		return "", false, err
+	}
+
+	return remotePath, true, nil
+}
+
+func (d *ESX5Driver) RemoveFile(remotePath string) error {
+	_, err := d.sh("rm", "-f", ssh.ShellQuote(remotePath))
+	return err
+}
+
+func (d *ESX5Driver) UploadVMX(vmxPath string) error {
+	f, err := os.Open(vmxPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.comm.Upload(d.remotePath(vmxPath), f)
+}
+
+func (d *ESX5Driver) Register(vmxPath string) error {
+	stdout, err := d.sh("vim-cmd", "solo/registervm", ssh.ShellQuote(d.remotePath(vmxPath)))
+	if err != nil {
+		return err
+	}
+
+	d.vmId = strings.TrimSpace(stdout)
+	return nil
+}
+
+func (d *ESX5Driver) Unregister(string) error {
+	if _, err := d.sh("vim-cmd", "vmsvc/unregister", d.vmId); err != nil {
+		return err
+	}
+
+	d.vmId = ""
+	return nil
+}
+
+// GuestIP finds the IP of the VM from the ports of the virtual switch,
+// which doesn't require VMware Tools to be running in the guest.
+func (d *ESX5Driver) GuestIP() (string, error) {
+	stdout, err := d.sh("esxcli", "--formatter", "csv", "network", "vm", "list")
+	if err != nil {
+		return "", err
+	}
+
+	worldId := ""
+	for _, row := range parseESXCLICSV(stdout) {
+		if row["Name"] == d.VMName {
+			worldId = row["WorldID"]
+			break
+		}
+	}
+
+	if worldId == "" {
+		return "", errors.New("VM isn't running on the network yet")
+	}
+
+	stdout, err = d.sh("esxcli", "--formatter", "csv", "network", "vm", "port", "list", "-w", worldId)
+	if err != nil {
+		return "", err
+	}
+
+	for _, row := range parseESXCLICSV(stdout) {
+		if ip := row["IPAddress"]; ip != "" && ip != "0.0.0.0" {
+			return ip, nil
+		}
+	}
+
+	return "", errors.New("VM doesn't have an IP yet")
+}
+
+// HostIP returns the IP of this machine as seen by the ESXi host, which
+// is the address the VM can reach the HTTP server on.
+func (d *ESX5Driver) HostIP() (string, error) {
+	addr, ok := d.conn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return "", fmt.Errorf("unexpected local address: %s", d.conn.LocalAddr())
+	}
+
+	return addr.IP.String(), nil
+}
+
+func (d *ESX5Driver) connect() error {
+	address := net.JoinHostPort(d.Host, strconv.Itoa(int(d.Port)))
+	log.Printf("Connecting to ESXi host: %s", address)
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return fmt.Errorf("Error connecting to remote host: %s", err)
+	}
+
+	sshConfig := &gossh.ClientConfig{
+		User: d.Username,
+		Auth: []gossh.ClientAuth{
+			gossh.ClientAuthPassword(ssh.Password(d.Password)),
+			gossh.ClientAuthKeyboardInteractive(
+				ssh.PasswordKeyboardInteractive(d.Password)),
+		},
+		HostKeyChecker: &fingerprintHostKeyChecker{d.HostFingerprint},
+	}
+
+	comm, err := ssh.New(conn, sshConfig)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("Error connecting to remote host: %s", err)
+	}
+
+	d.comm = comm
+	d.conn = conn
+	return nil
+}
+
+func (d *ESX5Driver) datastoreDir() string {
+	return path.Join("/vmfs/volumes", d.Datastore)
+}
+
+func (d *ESX5Driver) datastoreFree() (uint64, error) {
+	stdout, err := d.sh("esxcli", "--formatter", "csv", "storage", "filesystem", "list")
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range parseESXCLICSV(stdout) {
+		if row["VolumeName"] == d.Datastore {
+			return strconv.ParseUint(row["Free"], 10, 64)
+		}
+	}
+
+	return 0, fmt.Errorf("datastore not found: %s", d.Datastore)
+}
+
+func (d *ESX5Driver) outputDir() string {
+	return path.Join(d.datastoreDir(), d.VMName)
+}
+
+// remotePath returns the path on the remote host for a file in the local
+// output directory.
+func (d *ESX5Driver) remotePath(localPath string) string {
+	return path.Join(d.outputDir(), filepath.Base(localPath))
+}
+
+func (d *ESX5Driver) sh(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	command := strings.Join(args, " ")
+	log.Printf("Executing on ESXi host: %s", command)
+	cmd := &packer.RemoteCmd{
+		Command: command,
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+
+	if err := d.comm.Start(cmd); err != nil {
+		return "", err
+	}
+
+	cmd.Wait()
+
+	log.Printf("stdout: %s", strings.TrimSpace(stdout.String()))
+	log.Printf("stderr: %s", strings.TrimSpace(stderr.String()))
+
+	if cmd.ExitStatus != 0 {
+		return "", fmt.Errorf("'%s' exited with status %d: %s",
+			command, cmd.ExitStatus, strings.TrimSpace(stdout.String()+stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// fingerprintHostKeyChecker verifies the SSH host key of the ESXi host
+// against the MD5 fingerprint that was configured.
+type fingerprintHostKeyChecker struct {
+	Fingerprint string
+}
+
+func (c *fingerprintHostKeyChecker) Check(addr string, remote net.Addr, algorithm string, hostKey []byte) error {
+	fingerprint := sshFingerprint(hostKey)
+	if c.Fingerprint == "" {
+		return fmt.Errorf(
+			"The SSH host key of %s has the fingerprint %s. If this is correct,\n"+
+				"set remote_host_fingerprint to it.", addr, fingerprint)
+	}
+
+	if !strings.EqualFold(c.Fingerprint, fingerprint) {
+		return fmt.Errorf(
+			"SSH host key of %s doesn't match remote_host_fingerprint!\n"+
+				"Expected %s, got %s", addr, c.Fingerprint, fingerprint)
+	}
+
+	return nil
+}
+
+// parseESXCLICSV parses the output of "esxcli --formatter csv" into a row
+// per line, each a map of the column name to the value.
+func parseESXCLICSV(output string) []map[string]string {
+	r := csv.NewReader(strings.NewReader(output))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil
+	}
+
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string)
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = value
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// sshFingerprint returns the MD5 fingerprint of an SSH public key in the
+// familiar "aa:bb:..." format.
+func sshFingerprint(key []byte) string {
+	h := md5.New()
+	h.Write(key)
+	sum := h.Sum(nil)
+
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+
+	return strings.Join(parts, ":")
+}
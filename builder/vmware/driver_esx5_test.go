@@ -0,0 +1,59 @@
+package vmware
+
+import (
+	"testing"
+)
+
+func TestESX5Driver_implDriver(t *testing.T) {
+	var _ RemoteDriver = new(ESX5Driver)
+}
+
+func TestESX5Driver_remotePath(t *testing.T) {
+	d := &ESX5Driver{Datastore: "datastore1", VMName: "foo"}
+	result := d.remotePath("output-vmware/disk.vmdk")
+	if result != "/vmfs/volumes/datastore1/foo/disk.vmdk" {
+		t.Fatalf("bad: %s", result)
+	}
+}
+
+func TestFingerprintHostKeyChecker(t *testing.T) {
+	key := []byte("foo")
+	fingerprint := sshFingerprint(key)
+	if fingerprint != "ac:bd:18:db:4c:c2:f8:5c:ed:ef:65:4f:cc:c4:a4:d8" {
+		t.Fatalf("bad fingerprint: %s", fingerprint)
+	}
+
+	c := &fingerprintHostKeyChecker{"AC:BD:18:DB:4C:C2:F8:5C:ED:EF:65:4F:CC:C4:A4:D8"}
+	if err := c.Check("host:22", nil, "ssh-rsa", key); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := c.Check("host:22", nil, "ssh-rsa", []byte("bar")); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// No fingerprint is never trusted
+	c = &fingerprintHostKeyChecker{""}
+	if err := c.Check("host:22", nil, "ssh-rsa", key); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestParseESXCLICSV(t *testing.T) {
+	output := "Free,MountPoint,Mounted,Size,Type,UUID,VolumeName,\r\n" +
+		"1024,/vmfs/volumes/5190-aa,true,4096,VMFS-5,5190-aa,datastore1,\r\n" +
+		"2048,/vmfs/volumes/5190-bb,true,8192,VMFS-5,5190-bb,my datastore,\r\n"
+
+	rows := parseESXCLICSV(output)
+	if len(rows) != 2 {
+		t.Fatalf("bad: %#v", rows)
+	}
+
+	if rows[0]["VolumeName"] != "datastore1" || rows[0]["Free"] != "1024" {
+		t.Fatalf("bad: %#v", rows[0])
+	}
+
+	if rows[1]["VolumeName"] != "my datastore" || rows[1]["Free"] != "2048" {
+		t.Fatalf("bad: %#v", rows[1])
+	}
+}
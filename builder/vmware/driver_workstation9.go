@@ -0,0 +1,174 @@
+package vmware
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Workstation9Driver is a driver that can run VMware Workstation 9 on
+// Linux, and the Player that comes with it.
+type Workstation9Driver struct {
+	// These are the paths to the "vmrun" and "vmware-vdiskmanager"
+	// binaries. They're found on the PATH or in the usual install
+	// locations by Verify if empty.
+	VmrunPath        string
+	VdiskManagerPath string
+}
+
+func (d *Workstation9Driver) CompactDisk(diskPath string) error {
+	defragCmd := exec.Command(d.VdiskManagerPath, "-d", diskPath)
+	if _, _, err := runAndLog(defragCmd); err != nil {
+		return err
+	}
+
+	shrinkCmd := exec.Command(d.VdiskManagerPath, "-k", diskPath)
+	if _, _, err := runAndLog(shrinkCmd); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *Workstation9Driver) CreateDisk(output string, size string, typeId string) error {
+	cmd := exec.Command(d.VdiskManagerPath, "-c", "-s", size, "-a", "lsilogic", "-t", typeId, output)
+	if _, _, err := runAndLog(cmd); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *Workstation9Driver) DHCPLeasePath(device string) string {
+	return filepath.Join("/etc/vmware", device, "dhcpd", "dhcpd.leases")
+}
+
+func (d *Workstation9Driver) IsRunning(vmxPath string) (bool, error) {
+	vmxPath, err := filepath.Abs(vmxPath)
+	if err != nil {
+		return false, err
+	}
+
+	cmd := exec.Command(d.VmrunPath, "-T", "ws", "list")
+	stdout, _, err := runAndLog(cmd)
+	if err != nil {
+		return false, err
+	}
+
+	for _, line := range strings.Split(stdout, "\n") {
+		if line == vmxPath {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (d *Workstation9Driver) LinkedClone(dst, src string) error {
+	cmd := exec.Command(d.VmrunPath, "-T", "ws", "clone", src, dst, "linked")
+	if _, _, err := runAndLog(cmd); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *Workstation9Driver) Start(vmxPath string, headless bool) error {
+	guiArgument := "gui"
+	if headless {
+		guiArgument = "nogui"
+	}
+
+	cmd := exec.Command(d.VmrunPath, "-T", "ws", "start", vmxPath, guiArgument)
+	if _, _, err := runAndLog(cmd); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *Workstation9Driver) Stop(vmxPath string) error {
+	cmd := exec.Command(d.VmrunPath, "-T", "ws", "stop", vmxPath, "hard")
+	if _, _, err := runAndLog(cmd); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *Workstation9Driver) StopSoft(vmxPath string) error {
+	cmd := exec.Command(d.VmrunPath, "-T", "ws", "stop", vmxPath, "soft")
+	if _, _, err := runAndLog(cmd); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *Workstation9Driver) ToolsIsoPath(flavor string) string {
+	return filepath.Join("/usr/lib/vmware/isoimages", flavor+".iso")
+}
+
+func (d *Workstation9Driver) Verify() error {
+	if d.VmrunPath == "" {
+		path, err := workstationFindBinary("vmrun")
+		if err != nil {
+			return err
+		}
+
+		d.VmrunPath = path
+	}
+
+	if d.VdiskManagerPath == "" {
+		path, err := workstationFindBinary("vmware-vdiskmanager")
+		if err != nil {
+			return err
+		}
+
+		d.VdiskManagerPath = path
+	}
+
+	for _, path := range []string{d.VmrunPath, d.VdiskManagerPath} {
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("Critical application not found at path: %s", path)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The directories searched for the Workstation binaries, after the PATH.
+var workstationBinaryDirs = []string{
+	"/usr/bin",
+	"/usr/local/bin",
+	"/usr/lib/vmware/bin",
+	"/opt/vmware/bin",
+}
+
+// workstationFindBinary looks for the named binary on the PATH and then in
+// the directories Workstation is usually installed into.
+func workstationFindBinary(name string) (string, error) {
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+
+	searched := filepath.SplitList(os.Getenv("PATH"))
+	for _, dir := range workstationBinaryDirs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+
+		searched = append(searched, dir)
+	}
+
+	return "", fmt.Errorf(
+		"Critical application '%s' not found. Searched:\n  %s",
+		name, strings.Join(searched, "\n  "))
+}
@@ -1,7 +1,6 @@
 package vmware
 
 import (
-	"fmt"
 	"io/ioutil"
 	"os"
 	"regexp"
@@ -17,6 +16,9 @@ type GuestIPFinder interface {
 // DHCPLeaseGuestLookup looks up the IP address of a guest using DHCP
 // lease information from the VMware network devices.
 type DHCPLeaseGuestLookup struct {
+	// Driver that is being used (to find leases)
+	Driver Driver
+
 	// Device that the guest is connected to.
 	Device string
 
@@ -25,7 +27,7 @@ type DHCPLeaseGuestLookup struct {
 }
 
 func (f *DHCPLeaseGuestLookup) GuestIP() (string, error) {
-	fh, err := os.Open(fmt.Sprintf("/var/db/vmware/vmnet-dhcpd-%s.leases", f.Device))
+	fh, err := os.Open(f.Driver.DHCPLeasePath(f.Device))
 	if err != nil {
 		return "", err
 	}
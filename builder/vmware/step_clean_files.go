@@ -3,6 +3,7 @@ package vmware
 import (
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"os"
 	"path/filepath"
@@ -24,8 +25,12 @@ var KeepFileExtensions = []string{".nvram", ".vmdk", ".vmsd", ".vmx", ".vmxf"}
 type stepCleanFiles struct{}
 
 func (stepCleanFiles) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var config *config
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
 	ui.Say("Deleting unnecessary VMware files...")
 	visit := func(path string, info os.FileInfo, err error) error {
@@ -36,16 +41,7 @@ func (stepCleanFiles) Run(state map[string]interface{}) multistep.StepAction {
 		if !info.IsDir() {
 			// If the file isn't critical to the function of the
 			// virtual machine, we get rid of it.
-			keep := false
-			ext := filepath.Ext(path)
-			for _, goodExt := range KeepFileExtensions {
-				if goodExt == ext {
-					keep = true
-					break
-				}
-			}
-
-			if !keep {
+			if !keepFile(path) {
 				ui.Message(fmt.Sprintf("Deleting: %s", path))
 				return os.Remove(path)
 			}
@@ -63,3 +59,16 @@ func (stepCleanFiles) Run(state map[string]interface{}) multistep.StepAction {
 }
 
 func (stepCleanFiles) Cleanup(map[string]interface{}) {}
+
+// keepFile returns true if the file at the given path is critical to the
+// function of the virtual machine.
+func keepFile(path string) bool {
+	ext := filepath.Ext(path)
+	for _, goodExt := range KeepFileExtensions {
+		if goodExt == ext {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,70 @@
+package vmware
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"strings"
+)
+
+// This step removes the VNC configuration that was only needed to type
+// the boot command from the VMX file, so it doesn't end up in the artifact.
+// This runs once the VM is shut down, since VMware may rewrite the VMX
+// file while the VM is running.
+//
+// Uses:
+//   driver Driver
+//   ui     packer.Ui
+//   vmx_path string
+//
+// Produces:
+//   <nothing>
+type stepCleanVMX struct{}
+
+func (stepCleanVMX) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var ui packer.Ui
+	var vmxPath string
+	if err := bag.Values("ui", &ui, "vmx_path", &vmxPath); err != nil {
+		return bag.Halt(err)
+	}
+
+	vmxData, err := ReadVMX(vmxPath)
+	if err != nil {
+		err := fmt.Errorf("Error reading VMX file: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	for k := range vmxData {
+		if strings.HasPrefix(k, "remotedisplay.vnc.") {
+			log.Printf("Removing VMX: '%s'", k)
+			delete(vmxData, k)
+		}
+	}
+
+	if err := WriteVMX(vmxPath, vmxData); err != nil {
+		err := fmt.Errorf("Error writing VMX file: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Remote builds need the changes on the remote host as well
+	if driver, ok := state["driver"].(RemoteDriver); ok {
+		if err := driver.UploadVMX(vmxPath); err != nil {
+			err := fmt.Errorf("Error uploading VMX file: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (stepCleanVMX) Cleanup(map[string]interface{}) {
+}
@@ -0,0 +1,55 @@
+package vmware
+
+import (
+	"bytes"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStepCleanVMX_impl(t *testing.T) {
+	var _ multistep.Step = new(stepCleanVMX)
+}
+
+func TestStepCleanVMX(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	vmxPath := filepath.Join(dir, "foo.vmx")
+	err = WriteVMX(vmxPath, map[string]string{
+		"displayname":               "foo",
+		"remotedisplay.vnc.enabled": "TRUE",
+		"remotedisplay.vnc.ip":      "127.0.0.1",
+		"remotedisplay.vnc.port":    "5901",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := make(map[string]interface{})
+	state["ui"] = &packer.ReaderWriterUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	state["vmx_path"] = vmxPath
+
+	step := new(stepCleanVMX)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	vmxData, err := ReadVMX(vmxPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(vmxData) != 1 || vmxData["displayname"] != "foo" {
+		t.Fatalf("bad: %#v", vmxData)
+	}
+}
@@ -0,0 +1,190 @@
+package vmware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// This step clones the source VM into the output directory, either by
+// copying its files or as a linked clone.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   additional_disk_paths ([]string) - The full paths to the other disks.
+//   full_disk_path (string) - The full path to the first disk.
+//   vmx_path string - The path to the VMX file.
+type stepCloneVMX struct{}
+
+func (stepCloneVMX) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	running, err := driver.IsRunning(config.SourcePath)
+	if err != nil {
+		err := fmt.Errorf("Error checking if the source VM is running: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if running {
+		err := errors.New("The source VM is running. It must be powered off to be cloned.")
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	vmxPath := filepath.Join(config.OutputDir, config.VMName+".vmx")
+
+	if config.LinkedClone {
+		ui.Say("Creating a linked clone of the source VM...")
+		cloner := driver.(LinkedCloner)
+		err = cloner.LinkedClone(vmxPath, config.SourcePath)
+	} else {
+		ui.Say("Cloning the source VM...")
+		err = cloneFiles(config.SourcePath, vmxPath)
+	}
+
+	if err != nil {
+		err := fmt.Errorf("Error cloning source VM: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Stale locks would make VMware think the clone is in use
+	locks, _ := filepath.Glob(filepath.Join(config.OutputDir, "*.lck"))
+	for _, lock := range locks {
+		log.Printf("Removing stale lock: %s", lock)
+		os.RemoveAll(lock)
+	}
+
+	vmxData, err := ReadVMX(vmxPath)
+	if err != nil {
+		err := fmt.Errorf("Error reading VMX file: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	vmxData["displayname"] = config.VMName
+	if err := WriteVMX(vmxPath, vmxData); err != nil {
+		err := fmt.Errorf("Error writing VMX file: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	disks := vmxDiskPaths(config.OutputDir, vmxData)
+	if len(disks) == 0 {
+		err := errors.New("The source VM doesn't have any disks.")
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state["additional_disk_paths"] = disks[1:]
+	state["full_disk_path"] = disks[0]
+	state["vmx_path"] = vmxPath
+
+	return multistep.ActionContinue
+}
+
+func (stepCloneVMX) Cleanup(map[string]interface{}) {
+}
+
+// cloneFiles copies the files of the VM of the source VMX file into the
+// directory of the destination VMX file, renaming the VMX file. Locks and
+// logs aren't copied.
+func cloneFiles(srcVMX, dstVMX string) error {
+	srcDir := filepath.Dir(srcVMX)
+	dstDir := filepath.Dir(dstVMX)
+
+	infos, err := ioutil.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || strings.HasSuffix(name, ".lck") || strings.HasSuffix(name, ".log") {
+			continue
+		}
+
+		dst := filepath.Join(dstDir, name)
+		if name == filepath.Base(srcVMX) {
+			dst = dstVMX
+		}
+
+		log.Printf("Copying %s to %s", name, dst)
+		if err := copyFile(dst, filepath.Join(srcDir, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(dst, src string) error {
+	srcF, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcF.Close()
+
+	dstF, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstF.Close()
+
+	_, err = io.Copy(dstF, srcF)
+	return err
+}
+
+// vmxDiskPaths returns the full paths of the virtual disks attached to the
+// VM in the given VMX data, sorted by the device they're attached to.
+func vmxDiskPaths(dir string, vmxData map[string]string) []string {
+	diskRe := regexp.MustCompile(`^(ide|sata|scsi)\d+:\d+\.filename$`)
+
+	keys := make([]string, 0)
+	for k, v := range vmxData {
+		if diskRe.MatchString(k) && strings.HasSuffix(strings.ToLower(v), ".vmdk") {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	paths := make([]string, len(keys))
+	for i, k := range keys {
+		path := vmxData[k]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		paths[i] = path
+	}
+
+	return paths
+}
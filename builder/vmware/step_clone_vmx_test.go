@@ -0,0 +1,70 @@
+package vmware
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCloneFiles(t *testing.T) {
+	src, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(src)
+
+	dst, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dst)
+
+	for _, name := range []string{"source.vmx", "disk.vmdk", "vmware.log", "source.vmx.lck"} {
+		if err := ioutil.WriteFile(filepath.Join(src, name), []byte(name), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	err = cloneFiles(filepath.Join(src, "source.vmx"), filepath.Join(dst, "clone.vmx"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	infos, err := ioutil.ReadDir(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+
+	expected := []string{"clone.vmx", "disk.vmdk"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+}
+
+func TestVMXDiskPaths(t *testing.T) {
+	vmxData := map[string]string{
+		"scsi0:1.filename": "disk-1.vmdk",
+		"scsi0:0.filename": "disk.vmdk",
+		"ide1:0.filename":  "/abs/cdrom.iso",
+		"ide0:0.filename":  "/abs/ide.vmdk",
+		"displayname":      "foo.vmdk",
+	}
+
+	paths := vmxDiskPaths("/out", vmxData)
+	expected := []string{
+		"/abs/ide.vmdk",
+		filepath.Join("/out", "disk.vmdk"),
+		filepath.Join("/out", "disk-1.vmdk"),
+	}
+
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("bad: %#v", paths)
+	}
+}
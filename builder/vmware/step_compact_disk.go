@@ -3,8 +3,8 @@ package vmware
 import (
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
-	"log"
 )
 
 // This step compacts the virtual disk for the VM unless the "skip_compaction"
@@ -13,6 +13,7 @@ import (
 // Uses:
 //   config *config
 //   driver Driver
+//   additional_disk_paths []string
 //   full_disk_path string
 //   ui     packer.Ui
 //
@@ -21,23 +22,41 @@ import (
 type stepCompactDisk struct{}
 
 func (stepCompactDisk) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
-	full_disk_path := state["full_disk_path"].(string)
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var full_disk_path string
+	var additional_disk_paths []string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "full_disk_path", &full_disk_path, "additional_disk_paths", &additional_disk_paths); err != nil {
+		return bag.Halt(err)
+	}
 
 	if config.SkipCompaction == true {
-		log.Println("Skipping disk compaction step...")
+		ui.Say("Skipping disk compaction")
 		return multistep.ActionContinue
 	}
 
 	ui.Say("Compacting the disk image")
-	if err := driver.CompactDisk(full_disk_path); err != nil {
-		state["error"] = fmt.Errorf("Error compacting disk: %s", err)
-		return multistep.ActionHalt
+	for _, path := range append([]string{full_disk_path}, additional_disk_paths...) {
+		if err := driver.CompactDisk(path); err != nil {
+			state["error"] = fmt.Errorf("Error compacting disk: %s", err)
+			return multistep.ActionHalt
+		}
 	}
 
 	return multistep.ActionContinue
 }
 
 func (stepCompactDisk) Cleanup(map[string]interface{}) {}
+
+// diskTypePreallocated returns true if the disk type, either a
+// vmware-vdiskmanager type or an ESXi disk format, preallocates the disk.
+func diskTypePreallocated(typeId string) bool {
+	switch typeId {
+	case "2", "3", "4", "zeroedthick", "eagerzeroedthick":
+		return true
+	}
+
+	return false
+}
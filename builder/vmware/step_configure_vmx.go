@@ -0,0 +1,74 @@
+package vmware
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"strings"
+)
+
+// This step merges custom data into the VMX file. Custom data overrides
+// anything already in the VMX.
+//
+// Uses:
+//   driver Driver
+//   ui     packer.Ui
+//   vmx_path string
+//
+// Produces:
+//   <nothing>
+type stepConfigureVMX struct {
+	CustomData map[string]string
+}
+
+func (s *stepConfigureVMX) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var ui packer.Ui
+	var vmxPath string
+	if err := bag.Values("ui", &ui, "vmx_path", &vmxPath); err != nil {
+		return bag.Halt(err)
+	}
+
+	if len(s.CustomData) == 0 {
+		return multistep.ActionContinue
+	}
+
+	vmxData, err := ReadVMX(vmxPath)
+	if err != nil {
+		err := fmt.Errorf("Error reading VMX file: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	log.Println("Setting custom VMX data...")
+	for k, v := range s.CustomData {
+		k = strings.ToLower(k)
+		log.Printf("Setting VMX: '%s' = '%s'", k, v)
+		vmxData[k] = v
+	}
+
+	if err := WriteVMX(vmxPath, vmxData); err != nil {
+		err := fmt.Errorf("Error writing VMX file: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Remote builds need the changes on the remote host as well
+	if driver, ok := state["driver"].(RemoteDriver); ok {
+		if err := driver.UploadVMX(vmxPath); err != nil {
+			err := fmt.Errorf("Error uploading VMX file: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepConfigureVMX) Cleanup(map[string]interface{}) {
+}
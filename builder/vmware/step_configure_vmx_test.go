@@ -0,0 +1,78 @@
+package vmware
+
+import (
+	"bytes"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStepConfigureVMX_impl(t *testing.T) {
+	var _ multistep.Step = new(stepConfigureVMX)
+}
+
+func TestStepConfigureVMX(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	vmxPath := filepath.Join(dir, "foo.vmx")
+	err = WriteVMX(vmxPath, map[string]string{
+		"displayname":          "foo",
+		"ethernet0.virtualdev": "e1000",
+		"memsize":              "512",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := make(map[string]interface{})
+	state["ui"] = &packer.ReaderWriterUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	state["vmx_path"] = vmxPath
+
+	// vmx_data overrides the defaults, regardless of key case
+	pre := &stepConfigureVMX{CustomData: map[string]string{
+		"ethernet0.virtualDev": "vmxnet3",
+		"memsize":              "1024",
+	}}
+	if action := pre.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// vmx_data_post runs later and overrides vmx_data
+	post := &stepConfigureVMX{CustomData: map[string]string{
+		"MemSize": "2048",
+	}}
+	if action := post.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	vmxData, err := ReadVMX(vmxPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"displayname":          "foo",
+		"ethernet0.virtualdev": "vmxnet3",
+		"memsize":              "2048",
+	}
+
+	if len(vmxData) != len(expected) {
+		t.Fatalf("bad: %#v", vmxData)
+	}
+
+	for k, v := range expected {
+		if vmxData[k] != v {
+			t.Errorf("bad %s: %#v", k, vmxData[k])
+		}
+	}
+}
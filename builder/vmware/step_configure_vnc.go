@@ -3,29 +3,42 @@ package vmware
 import (
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"io/ioutil"
 	"log"
 	"math/rand"
 	"net"
 	"os"
+	"strconv"
+	"time"
 )
 
 // This step configures the VM to enable the VNC server.
 //
 // Uses:
 //   config *config
+//   driver Driver
 //   ui     packer.Ui
 //   vmx_path string
 //
 // Produces:
+//   vnc_listener net.Listener - A listener holding the VNC port until the
+//     VM is started, so that parallel builds don't choose the same port.
+//   vnc_ip string - The IP to connect to VNC on.
 //   vnc_port uint - The port that VNC is configured to listen on.
-type stepConfigureVNC struct{}
+type stepConfigureVNC struct {
+	l net.Listener
+}
 
-func (stepConfigureVNC) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	ui := state["ui"].(packer.Ui)
-	vmxPath := state["vmx_path"].(string)
+func (s *stepConfigureVNC) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var ui packer.Ui
+	var vmxPath string
+	if err := bag.Values("config", &config, "ui", &ui, "vmx_path", &vmxPath); err != nil {
+		return bag.Halt(err)
+	}
 
 	f, err := os.Open(vmxPath)
 	if err != nil {
@@ -43,27 +56,35 @@ func (stepConfigureVNC) Run(state map[string]interface{}) multistep.StepAction {
 		return multistep.ActionHalt
 	}
 
-	// Find an open VNC port. Note that this can still fail later on
-	// because we have to release the port at some point. But this does its
-	// best.
+	// Find an open VNC port. The listener is kept open until right before
+	// the VM is started so no other build can grab the port in the meantime.
+	// For remote builds the VNC server is on the remote host, so the best
+	// we can do there is find a port nothing is listening on yet.
 	log.Printf("Looking for available port between %d and %d", config.VNCPortMin, config.VNCPortMax)
+	vncIp := config.VNCBindAddress
 	var vncPort uint
-	portRange := int(config.VNCPortMax - config.VNCPortMin)
-	for {
-		vncPort = uint(rand.Intn(portRange)) + config.VNCPortMin
-		log.Printf("Trying port: %d", vncPort)
-		l, err := net.Listen("tcp", fmt.Sprintf(":%d", vncPort))
-		if err == nil {
-			defer l.Close()
-			break
-		}
+	if _, ok := state["driver"].(RemoteDriver); ok {
+		vncIp = config.RemoteHost
+		vncPort, err = vncDialProbe(vncIp, config.VNCPortMin, config.VNCPortMax)
+	} else {
+		s.l, vncPort, err = vncListen(config.VNCBindAddress, config.VNCPortMin, config.VNCPortMax)
+	}
+
+	if err != nil {
+		err := fmt.Errorf("Error finding VNC port: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
 	}
 
 	log.Printf("Found available VNC port: %d", vncPort)
 
 	vmxData := ParseVMX(string(vmxBytes))
-	vmxData["RemoteDisplay.vnc.enabled"] = "TRUE"
-	vmxData["RemoteDisplay.vnc.port"] = fmt.Sprintf("%d", vncPort)
+	vmxData["remotedisplay.vnc.enabled"] = "TRUE"
+	vmxData["remotedisplay.vnc.port"] = fmt.Sprintf("%d", vncPort)
+	if config.VNCBindAddress != "" {
+		vmxData["remotedisplay.vnc.ip"] = config.VNCBindAddress
+	}
 
 	if err := WriteVMX(vmxPath, vmxData); err != nil {
 		err := fmt.Errorf("Error writing VMX data: %s", err)
@@ -72,10 +93,63 @@ func (stepConfigureVNC) Run(state map[string]interface{}) multistep.StepAction {
 		return multistep.ActionHalt
 	}
 
+	if s.l != nil {
+		state["vnc_listener"] = s.l
+	}
+
+	// Listening on all addresses means we can reach it over loopback
+	if ip := net.ParseIP(vncIp); ip != nil && ip.IsUnspecified() {
+		vncIp = "127.0.0.1"
+	}
+
+	state["vnc_ip"] = vncIp
 	state["vnc_port"] = vncPort
 
 	return multistep.ActionContinue
 }
 
-func (stepConfigureVNC) Cleanup(map[string]interface{}) {
+func (s *stepConfigureVNC) Cleanup(map[string]interface{}) {
+	if s.l != nil {
+		// The run step normally closes this already, in which case this
+		// just returns an error we don't care about.
+		s.l.Close()
+	}
+}
+
+// vncListen binds to a free port on addr between min and max, inclusive,
+// starting at a random offset so that parallel builds tend not to race for
+// the same port. The returned listener must be closed before VMware can use
+// the port.
+func vncListen(addr string, min, max uint) (net.Listener, uint, error) {
+	portRange := int(max-min) + 1
+	offset := rand.Intn(portRange)
+	for i := 0; i < portRange; i++ {
+		port := min + uint((offset+i)%portRange)
+		log.Printf("Trying port: %d", port)
+		l, err := net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(int(port))))
+		if err == nil {
+			return l, port, nil
+		}
+	}
+
+	return nil, 0, fmt.Errorf("no free port between %d and %d on %s", min, max, addr)
+}
+
+// vncDialProbe finds a port between min and max, inclusive, on the given
+// host that nothing is listening on.
+func vncDialProbe(host string, min, max uint) (uint, error) {
+	portRange := int(max-min) + 1
+	offset := rand.Intn(portRange)
+	for i := 0; i < portRange; i++ {
+		port := min + uint((offset+i)%portRange)
+		log.Printf("Trying port: %d", port)
+		c, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))), 5*time.Second)
+		if err != nil {
+			return port, nil
+		}
+
+		c.Close()
+	}
+
+	return 0, fmt.Errorf("no free port between %d and %d on %s", min, max, host)
 }
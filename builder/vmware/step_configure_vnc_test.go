@@ -0,0 +1,32 @@
+package vmware
+
+import (
+	"net"
+	"testing"
+)
+
+func TestVNCListen(t *testing.T) {
+	l, port, err := vncListen("127.0.0.1", 5900, 6000)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+
+	if port < 5900 || port > 6000 {
+		t.Fatalf("bad port: %d", port)
+	}
+
+	addr := l.Addr().(*net.TCPAddr)
+	if !addr.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("listener not on loopback: %s", addr)
+	}
+
+	if addr.Port != int(port) {
+		t.Fatalf("listener not on port %d: %s", port, l.Addr())
+	}
+
+	// The port is held, so a range of just that port has nothing free
+	if _, _, err := vncListen("127.0.0.1", port, port); err == nil {
+		t.Fatal("should have error")
+	}
+}
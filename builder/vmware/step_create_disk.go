@@ -3,8 +3,12 @@ package vmware
 import (
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"log"
+	"os"
 	"path/filepath"
+	"strings"
 )
 
 // This step creates the virtual disks for the VM.
@@ -15,26 +19,104 @@ import (
 //   ui     packer.Ui
 //
 // Produces:
+//   additional_disk_paths ([]string) - The full paths to the additional disks.
 //   full_disk_path (string) - The full path to the created disk.
-type stepCreateDisk struct{}
+type stepCreateDisk struct {
+	created []string
+}
 
-func (stepCreateDisk) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
+func (s *stepCreateDisk) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
 	ui.Say("Creating virtual machine disk")
 	full_disk_path := filepath.Join(config.OutputDir, config.DiskName+".vmdk")
-	if err := driver.CreateDisk(full_disk_path, fmt.Sprintf("%dM", config.DiskSize)); err != nil {
+	if err := driver.CreateDisk(full_disk_path, fmt.Sprintf("%dM", config.DiskSize), config.DiskTypeId); err != nil {
 		err := fmt.Errorf("Error creating disk: %s", err)
 		state["error"] = err
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
 
+	s.created = append(s.created, full_disk_path)
+
+	additionalPaths := make([]string, len(config.AdditionalDiskSize))
+	for i, size := range config.AdditionalDiskSize {
+		ui.Say(fmt.Sprintf("Creating additional disk %d", i+1))
+		path := filepath.Join(config.OutputDir, additionalDiskName(config.DiskName, i))
+		if err := driver.CreateDisk(path, fmt.Sprintf("%dM", size), config.DiskTypeId); err != nil {
+			err := fmt.Errorf("Error creating additional disk: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		s.created = append(s.created, path)
+		additionalPaths[i] = path
+	}
+
+	state["additional_disk_paths"] = additionalPaths
 	state["full_disk_path"] = full_disk_path
 
 	return multistep.ActionContinue
 }
 
-func (stepCreateDisk) Cleanup(map[string]interface{}) {}
+func (s *stepCreateDisk) Cleanup(state map[string]interface{}) {
+	_, cancelled := state[multistep.StateCancelled]
+	_, halted := state[multistep.StateHalted]
+	if !cancelled && !halted {
+		return
+	}
+
+	// Remote disks are deleted along with the VM's directory
+	if _, ok := state["driver"].(RemoteDriver); ok {
+		return
+	}
+
+	for _, path := range s.created {
+		for _, file := range diskFiles(path) {
+			log.Printf("Deleting disk file: %s", file)
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				log.Printf("Error deleting disk file: %s", err)
+			}
+		}
+	}
+}
+
+// additionalDiskName returns the file name of the additional disk with
+// the given index.
+func additionalDiskName(diskName string, i int) string {
+	return fmt.Sprintf("%s-%d.vmdk", diskName, i+1)
+}
+
+// additionalDiskUnit returns the SCSI unit number that the additional disk
+// with the given index is attached to. The main disk is unit 0 and unit 7
+// is reserved for the controller itself.
+func additionalDiskUnit(i int) int {
+	unit := i + 1
+	if unit >= 7 {
+		unit++
+	}
+
+	return unit
+}
+
+// diskFiles returns the files that make up the virtual disk at the given
+// path: the descriptor, and the extents of split or preallocated disks.
+func diskFiles(path string) []string {
+	base := strings.TrimSuffix(path, ".vmdk")
+	files := []string{path}
+	for _, pattern := range []string{"-s[0-9][0-9][0-9]", "-f[0-9][0-9][0-9]", "-flat"} {
+		matches, err := filepath.Glob(base + pattern + ".vmdk")
+		if err == nil {
+			files = append(files, matches...)
+		}
+	}
+
+	return files
+}
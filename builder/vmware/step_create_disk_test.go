@@ -0,0 +1,55 @@
+package vmware
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAdditionalDiskUnit(t *testing.T) {
+	expected := []int{1, 2, 3, 4, 5, 6, 8, 9}
+	for i, unit := range expected {
+		if result := additionalDiskUnit(i); result != unit {
+			t.Errorf("bad unit for disk %d: %d", i, result)
+		}
+	}
+}
+
+func TestDiskFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	names := []string{
+		"disk.vmdk", "disk-s001.vmdk", "disk-s002.vmdk",
+		"disk-1.vmdk", "disk-1-s001.vmdk", "other.vmdk",
+	}
+	for _, name := range names {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	result := diskFiles(filepath.Join(dir, "disk.vmdk"))
+	expected := []string{
+		filepath.Join(dir, "disk.vmdk"),
+		filepath.Join(dir, "disk-s001.vmdk"),
+		filepath.Join(dir, "disk-s002.vmdk"),
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	result = diskFiles(filepath.Join(dir, "disk-1.vmdk"))
+	expected = []string{
+		filepath.Join(dir, "disk-1.vmdk"),
+		filepath.Join(dir, "disk-1-s001.vmdk"),
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
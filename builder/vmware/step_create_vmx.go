@@ -4,23 +4,27 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
-	"log"
+	"io/ioutil"
 	"path/filepath"
 	"text/template"
 )
 
 type vmxTemplateData struct {
-	Name     string
-	GuestOS  string
-	DiskName string
-	ISOPath  string
+	Name               string
+	GuestOS            string
+	DiskName           string
+	ISOPath            string
+	Network            string
+	NetworkAdapterType string
 }
 
 // This step creates the VMX file for the VM.
 //
 // Uses:
 //   config *config
+//   driver Driver
 //   iso_path string
 //   ui     packer.Ui
 //
@@ -29,9 +33,14 @@ type vmxTemplateData struct {
 type stepCreateVMX struct{}
 
 func (stepCreateVMX) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	isoPath := state["iso_path"].(string)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var isoPath string
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "iso_path", &isoPath, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
 	ui.Say("Building and writing VMX file")
 
@@ -40,19 +49,49 @@ func (stepCreateVMX) Run(state map[string]interface{}) multistep.StepAction {
 		config.GuestOSType,
 		config.DiskName,
 		isoPath,
+		config.Network,
+		config.NetworkAdapterType,
+	}
+
+	vmxTemplate := DefaultVMXTemplate
+	if config.VMXTemplatePath != "" {
+		contents, err := ioutil.ReadFile(config.VMXTemplatePath)
+		if err != nil {
+			err := fmt.Errorf("Error reading VMX template: %s", err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		vmxTemplate = string(contents)
 	}
 
 	var buf bytes.Buffer
-	t := template.Must(template.New("vmx").Parse(DefaultVMXTemplate))
-	t.Execute(&buf, tplData)
+	t, err := template.New("vmx").Parse(vmxTemplate)
+	if err == nil {
+		err = t.Execute(&buf, tplData)
+	}
+
+	if err != nil {
+		err := fmt.Errorf("Error building VMX file from template: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
 
 	vmxData := ParseVMX(buf.String())
-	if config.VMXData != nil {
-		log.Println("Setting custom VMX data...")
-		for k, v := range config.VMXData {
-			log.Printf("Setting VMX: '%s' = '%s'", k, v)
-			vmxData[k] = v
-		}
+
+	for i := range config.AdditionalDiskSize {
+		unit := additionalDiskUnit(i)
+		vmxData[fmt.Sprintf("scsi0:%d.filename", unit)] = additionalDiskName(config.DiskName, i)
+		vmxData[fmt.Sprintf("scsi0:%d.present", unit)] = "TRUE"
+		vmxData[fmt.Sprintf("scsi0:%d.redo", unit)] = ""
+	}
+
+	// ESXi has no NAT network, so connect the VM to the default port group
+	if _, ok := driver.(RemoteDriver); ok {
+		vmxData["ethernet0.networkname"] = "VM Network"
+		delete(vmxData, "ethernet0.connectiontype")
 	}
 
 	vmxPath := filepath.Join(config.OutputDir, config.VMName+".vmx")
@@ -73,7 +112,7 @@ func (stepCreateVMX) Cleanup(map[string]interface{}) {
 
 // This is the default VMX template used if no other template is given.
 // This is hardcoded here. If you wish to use a custom template please
-// do so by specifying in the builder configuration.
+// do so by specifying "vmx_path_template" in the builder configuration.
 const DefaultVMXTemplate = `
 .encoding = "UTF-8"
 bios.bootOrder = "hdd,CDROM"
@@ -85,12 +124,12 @@ ehci.pciSlotNumber = "34"
 ehci.present = "TRUE"
 ethernet0.addressType = "generated"
 ethernet0.bsdName = "en0"
-ethernet0.connectionType = "nat"
+ethernet0.connectionType = "{{ .Network }}"
 ethernet0.displayName = "Ethernet"
 ethernet0.linkStatePropagation.enable = "FALSE"
 ethernet0.pciSlotNumber = "33"
 ethernet0.present = "TRUE"
-ethernet0.virtualDev = "e1000"
+ethernet0.virtualDev = "{{ .NetworkAdapterType }}"
 ethernet0.wakeOnPcktRcv = "FALSE"
 extendedConfigFile = "{{ .Name }}.vmxf"
 floppy0.present = "FALSE"
@@ -0,0 +1,67 @@
+package vmware
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step creates the directory for the VM on the remote host of remote
+// builds, in addition to the local output directory. The directory is
+// deleted again if the build is cancelled or fails.
+//
+// Uses:
+//   driver Driver
+//   ui     packer.Ui
+type stepPrepareRemoteOutputDir struct {
+	remoteCreated bool
+}
+
+func (s *stepPrepareRemoteOutputDir) Run(state map[string]interface{}) multistep.StepAction {
+	driver, ok := state["driver"].(RemoteDriver)
+	if !ok {
+		return multistep.ActionContinue
+	}
+
+	bag := common.StateBag(state)
+	var ui packer.Ui
+	if err := bag.Values("ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	if err := driver.CreateOutputDir(); err != nil {
+		err := fmt.Errorf("Error creating output directory on remote host: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.remoteCreated = true
+	return multistep.ActionContinue
+}
+
+func (s *stepPrepareRemoteOutputDir) Cleanup(state map[string]interface{}) {
+	if !s.remoteCreated {
+		return
+	}
+
+	_, cancelled := state[multistep.StateCancelled]
+	_, halted := state[multistep.StateHalted]
+
+	if cancelled || halted {
+		bag := common.StateBag(state)
+		var driver RemoteDriver
+		var ui packer.Ui
+		if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+			log.Printf("Skipping cleanup: %s", err)
+			return
+		}
+
+		ui.Say("Deleting output directory on remote host...")
+		if err := driver.RemoveOutputDir(); err != nil {
+			ui.Error(fmt.Sprintf("Error deleting output directory on remote host: %s", err))
+		}
+	}
+}
@@ -3,14 +3,21 @@ package vmware
 import (
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
 	"os"
 )
 
 type stepPrepareTools struct{}
 
 func (*stepPrepareTools) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
 	if config.ToolsUploadFlavor == "" {
 		return multistep.ActionContinue
@@ -18,11 +25,13 @@ func (*stepPrepareTools) Run(state map[string]interface{}) multistep.StepAction
 
 	path := driver.ToolsIsoPath(config.ToolsUploadFlavor)
 	if _, err := os.Stat(path); err != nil {
-		state["error"] = fmt.Errorf(
-			"Couldn't find VMware tools for '%s'! VMware often downloads these\n"+
-				"tools on-demand. However, to do this, you need to create a fake VM\n"+
+		err := fmt.Errorf(
+			"Couldn't find VMware tools for '%s' at %s! VMware often downloads\n"+
+				"these tools on-demand. However, to do this, you need to create a fake VM\n"+
 				"of the proper type then click the 'install tools' option in the\n"+
-				"VMware GUI.", config.ToolsUploadFlavor)
+				"VMware GUI.", config.ToolsUploadFlavor, path)
+		state["error"] = err
+		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
 
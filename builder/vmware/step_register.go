@@ -0,0 +1,74 @@
+package vmware
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step uploads the VMX file to the remote host and registers the VM
+// there, if the build is remote.
+//
+// Uses:
+//   driver Driver
+//   ui     packer.Ui
+//   vmx_path string
+//
+// Produces:
+//   <nothing>
+type stepRegister struct {
+	registeredPath string
+}
+
+func (s *stepRegister) Run(state map[string]interface{}) multistep.StepAction {
+	driver, ok := state["driver"].(RemoteDriver)
+	if !ok {
+		return multistep.ActionContinue
+	}
+
+	bag := common.StateBag(state)
+	var ui packer.Ui
+	var vmxPath string
+	if err := bag.Values("ui", &ui, "vmx_path", &vmxPath); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say("Registering VM with remote host...")
+	if err := driver.UploadVMX(vmxPath); err != nil {
+		err := fmt.Errorf("Error uploading VMX file: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if err := driver.Register(vmxPath); err != nil {
+		err := fmt.Errorf("Error registering VM: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.registeredPath = vmxPath
+	return multistep.ActionContinue
+}
+
+func (s *stepRegister) Cleanup(state map[string]interface{}) {
+	if s.registeredPath == "" {
+		return
+	}
+
+	bag := common.StateBag(state)
+	var driver RemoteDriver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
+
+	ui.Say("Unregistering VM from remote host...")
+	if err := driver.Unregister(s.registeredPath); err != nil {
+		ui.Error(fmt.Sprintf("Error unregistering VM: %s", err))
+	}
+}
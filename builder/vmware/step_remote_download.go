@@ -0,0 +1,68 @@
+package vmware
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"path"
+	"path/filepath"
+)
+
+// This step downloads the VM files from the remote host into the local
+// output directory, if the build is remote and "remote_output" is set.
+// Only the files that are kept for the local builds are downloaded.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   <nothing>
+type stepRemoteDownload struct{}
+
+func (stepRemoteDownload) Run(state map[string]interface{}) multistep.StepAction {
+	bag := common.StateBag(state)
+	var config *config
+	if err := bag.Values("config", &config); err != nil {
+		return bag.Halt(err)
+	}
+	driver, ok := state["driver"].(RemoteDriver)
+	if !ok || !config.RemoteOutput {
+		return multistep.ActionContinue
+	}
+
+	var ui packer.Ui
+	if err := bag.Values("ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say("Downloading VM files from remote host...")
+	files, err := driver.OutputFiles()
+	if err != nil {
+		err := fmt.Errorf("Error listing VM files: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	for _, remotePath := range files {
+		if !keepFile(remotePath) {
+			continue
+		}
+
+		localPath := filepath.Join(config.OutputDir, path.Base(remotePath))
+		ui.Message(fmt.Sprintf("Downloading: %s", path.Base(remotePath)))
+		if err := driver.Download(remotePath, localPath); err != nil {
+			err := fmt.Errorf("Error downloading %s: %s", remotePath, err)
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (stepRemoteDownload) Cleanup(map[string]interface{}) {}
@@ -0,0 +1,83 @@
+package vmware
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// This step uploads the ISO to the datastore of the remote host, if the
+// build is remote.
+//
+// Uses:
+//   driver Driver
+//   iso_path string
+//   ui     packer.Ui
+//
+// Produces:
+//   iso_path string - The path to the ISO on the remote host.
+type stepRemoteUploadISO struct {
+	uploadedPath string
+}
+
+func (s *stepRemoteUploadISO) Run(state map[string]interface{}) multistep.StepAction {
+	driver, ok := state["driver"].(RemoteDriver)
+	if !ok {
+		return multistep.ActionContinue
+	}
+
+	bag := common.StateBag(state)
+	var isoPath string
+	var ui packer.Ui
+	if err := bag.Values("iso_path", &isoPath, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
+
+	ui.Say("Uploading ISO to remote host...")
+	remotePath, uploaded, err := driver.UploadISO(isoPath)
+	if err != nil {
+		err := fmt.Errorf("Error uploading ISO: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if uploaded {
+		s.uploadedPath = remotePath
+	} else {
+		ui.Message("ISO is already on the remote host.")
+	}
+
+	log.Printf("Remote ISO path: %s", remotePath)
+	state["iso_path"] = remotePath
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRemoteUploadISO) Cleanup(state map[string]interface{}) {
+	if s.uploadedPath == "" {
+		return
+	}
+
+	_, cancelled := state[multistep.StateCancelled]
+	_, halted := state[multistep.StateHalted]
+
+	// The ISO stays on the remote host for future builds, unless this
+	// build failed and it was this build that uploaded it.
+	if cancelled || halted {
+		bag := common.StateBag(state)
+		var driver RemoteDriver
+		var ui packer.Ui
+		if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+			log.Printf("Skipping cleanup: %s", err)
+			return
+		}
+
+		ui.Say("Deleting ISO from remote host...")
+		if err := driver.RemoveFile(s.uploadedPath); err != nil {
+			ui.Error(fmt.Sprintf("Error deleting ISO: %s", err))
+		}
+	}
+}
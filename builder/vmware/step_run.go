@@ -3,7 +3,10 @@ package vmware
 import (
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
+	"log"
+	"net"
 	"time"
 )
 
@@ -14,6 +17,9 @@ import (
 //   driver Driver
 //   ui     packer.Ui
 //   vmx_path string
+//   vnc_ip string
+//   vnc_listener net.Listener
+//   vnc_port uint
 //
 // Produces:
 //   <nothing>
@@ -23,11 +29,16 @@ type stepRun struct {
 }
 
 func (s *stepRun) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
-	vmxPath := state["vmx_path"].(string)
-	vncPort := state["vnc_port"].(uint)
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmxPath string
+	var vncIp string
+	var vncPort uint
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmx_path", &vmxPath, "vnc_ip", &vncIp, "vnc_port", &vncPort); err != nil {
+		return bag.Halt(err)
+	}
 
 	// Set the VMX path so that we know we started the machine
 	s.bootTime = time.Now()
@@ -38,7 +49,12 @@ func (s *stepRun) Run(state map[string]interface{}) multistep.StepAction {
 		ui.Message(fmt.Sprintf(
 			"The VM will be run headless, without a GUI. If you want to\n"+
 				"view the screen of the VM, connect via VNC without a password to\n"+
-				"127.0.0.1:%d", vncPort))
+				"%s:%d", vncIp, vncPort))
+	}
+
+	// Release the VNC port we've been holding so that VMware can bind it.
+	if l, ok := state["vnc_listener"].(net.Listener); ok {
+		l.Close()
 	}
 
 	if err := driver.Start(vmxPath, config.Headless); err != nil {
@@ -58,8 +74,13 @@ func (s *stepRun) Run(state map[string]interface{}) multistep.StepAction {
 }
 
 func (s *stepRun) Cleanup(state map[string]interface{}) {
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var driver Driver
+	var ui packer.Ui
+	if err := bag.Values("driver", &driver, "ui", &ui); err != nil {
+		log.Printf("Skipping cleanup: %s", err)
+		return
+	}
 
 	// If we started the machine... stop it.
 	if s.vmxPath != "" {
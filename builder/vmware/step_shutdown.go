@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
 	"path/filepath"
@@ -26,11 +27,19 @@ import (
 type stepShutdown struct{}
 
 func (s *stepShutdown) Run(state map[string]interface{}) multistep.StepAction {
-	comm := state["communicator"].(packer.Communicator)
-	config := state["config"].(*config)
-	driver := state["driver"].(Driver)
-	ui := state["ui"].(packer.Ui)
-	vmxPath := state["vmx_path"].(string)
+	bag := common.StateBag(state)
+	var comm packer.Communicator
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmxPath string
+	if err := bag.Values("communicator", &comm, "config", &config, "driver", &driver, "ui", &ui, "vmx_path", &vmxPath); err != nil {
+		return bag.Halt(err)
+	}
+
+	isRunning := func() (bool, error) {
+		return driver.IsRunning(vmxPath)
+	}
 
 	if config.ShutdownCommand != "" {
 		ui.Say("Gracefully halting virtual machine...")
@@ -48,30 +57,34 @@ func (s *stepShutdown) Run(state map[string]interface{}) multistep.StepAction {
 
 		// Wait for the machine to actually shut down
 		log.Printf("Waiting max %s for shutdown to complete", config.ShutdownTimeout)
-		shutdownTimer := time.After(config.ShutdownTimeout)
-		for {
-			running, _ := driver.IsRunning(vmxPath)
-			if !running {
-				break
-			}
+		if !common.WaitForShutdown(isRunning, config.ShutdownTimeout) {
+			err := errors.New("Timeout while waiting for machine to shut down.")
+			state["error"] = err
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	} else {
+		// Ask the guest to shut down first so that its filesystems are
+		// left in a clean state.
+		ui.Say("Halting the virtual machine with a soft stop...")
+		stopped := false
+		if err := driver.StopSoft(vmxPath); err != nil {
+			log.Printf("Error doing a soft stop: %s", err)
+		} else {
+			log.Printf("Waiting max %s for soft stop to complete", config.ShutdownTimeout)
+			stopped = common.WaitForShutdown(isRunning, config.ShutdownTimeout)
+		}
 
-			select {
-			case <-shutdownTimer:
-				err := errors.New("Timeout while waiting for machine to shut down.")
+		if !stopped {
+			ui.Message("WARNING: The VM didn't shut down with a soft stop. Forcefully\n" +
+				"powering off, which may leave the disk in a dirty state.")
+			if err := driver.Stop(vmxPath); err != nil {
+				err := fmt.Errorf("Error stopping VM: %s", err)
 				state["error"] = err
 				ui.Error(err.Error())
 				return multistep.ActionHalt
-			default:
-				time.Sleep(1 * time.Second)
 			}
 		}
-	} else {
-		if err := driver.Stop(vmxPath); err != nil {
-			err := fmt.Errorf("Error stopping VM: %s", err)
-			state["error"] = err
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
 	}
 
 	ui.Message("Waiting for VMware to clean up after itself...")
@@ -5,18 +5,14 @@ import (
 	"fmt"
 	"github.com/mitchellh/go-vnc"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
 	"net"
-	"strings"
+	"strconv"
 	"text/template"
-	"time"
-	"unicode"
-	"unicode/utf8"
 )
 
-const KeyLeftShift uint32 = 0xFFE1
-
 type bootCommandTemplateData struct {
 	HTTPIP   string
 	HTTPPort uint
@@ -27,8 +23,10 @@ type bootCommandTemplateData struct {
 //
 // Uses:
 //   config *config
+//   driver Driver
 //   http_port int
 //   ui     packer.Ui
+//   vnc_ip string
 //   vnc_port uint
 //
 // Produces:
@@ -36,14 +34,20 @@ type bootCommandTemplateData struct {
 type stepTypeBootCommand struct{}
 
 func (s *stepTypeBootCommand) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	httpPort := state["http_port"].(uint)
-	ui := state["ui"].(packer.Ui)
-	vncPort := state["vnc_port"].(uint)
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var httpPort uint
+	var ui packer.Ui
+	var vncIp string
+	var vncPort uint
+	if err := bag.Values("config", &config, "driver", &driver, "http_port", &httpPort, "ui", &ui, "vnc_ip", &vncIp, "vnc_port", &vncPort); err != nil {
+		return bag.Halt(err)
+	}
 
 	// Connect to VNC
 	ui.Say("Connecting to VM via VNC")
-	nc, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", vncPort))
+	nc, err := net.Dial("tcp", net.JoinHostPort(vncIp, strconv.Itoa(int(vncPort))))
 	if err != nil {
 		err := fmt.Errorf("Error connecting to VNC: %s", err)
 		state["error"] = err
@@ -63,8 +67,13 @@ func (s *stepTypeBootCommand) Run(state map[string]interface{}) multistep.StepAc
 
 	log.Printf("Connected to VNC desktop: %s", c.DesktopName)
 
-	// Determine the host IP
-	ipFinder := &IfconfigIPFinder{"vmnet8"}
+	// Determine the host IP. Remote drivers know the address the VM can
+	// reach us on.
+	var ipFinder HostIPFinder = &IfconfigIPFinder{networkDevice(config.Network)}
+	if finder, ok := driver.(HostIPFinder); ok {
+		ipFinder = finder
+	}
+
 	hostIp, err := ipFinder.HostIP()
 	if err != nil {
 		err := fmt.Errorf("Error detecting host IP: %s", err)
@@ -85,76 +94,10 @@ func (s *stepTypeBootCommand) Run(state map[string]interface{}) multistep.StepAc
 		t := template.Must(template.New("boot").Parse(command))
 		t.Execute(&buf, tplData)
 
-		vncSendString(c, buf.String())
+		common.VNCSendString(c, buf.String())
 	}
 
 	return multistep.ActionContinue
 }
 
 func (*stepTypeBootCommand) Cleanup(map[string]interface{}) {}
-
-func vncSendString(c *vnc.ClientConn, original string) {
-	special := make(map[string]uint32)
-	special["<enter>"] = 0xFF0D
-	special["<esc>"] = 0xFF1B
-	special["<return>"] = 0xFF0D
-	special["<tab>"] = 0xFF09
-
-	shiftedChars := "~!@#$%^&*()_+{}|:\"<>?"
-
-	// TODO(mitchellh): Ripe for optimizations of some point, perhaps.
-	for len(original) > 0 {
-		var keyCode uint32
-		keyShift := false
-
-		if strings.HasPrefix(original, "<wait>") {
-			log.Printf("Special code '<wait>' found, sleeping one second")
-			time.Sleep(1 * time.Second)
-			original = original[len("<wait>"):]
-			continue
-		}
-
-		if strings.HasPrefix(original, "<wait5>") {
-			log.Printf("Special code '<wait5>' found, sleeping 5 seconds")
-			time.Sleep(5 * time.Second)
-			original = original[len("<wait5>"):]
-			continue
-		}
-
-		if strings.HasPrefix(original, "<wait10>") {
-			log.Printf("Special code '<wait10>' found, sleeping 10 seconds")
-			time.Sleep(10 * time.Second)
-			original = original[len("<wait10>"):]
-			continue
-		}
-
-		for specialCode, specialValue := range special {
-			if strings.HasPrefix(original, specialCode) {
-				log.Printf("Special code '%s' found, replacing with: %d", specialCode, specialValue)
-				keyCode = specialValue
-				original = original[len(specialCode):]
-				break
-			}
-		}
-
-		if keyCode == 0 {
-			r, size := utf8.DecodeRuneInString(original)
-			original = original[size:]
-			keyCode = uint32(r)
-			keyShift = unicode.IsUpper(r) || strings.ContainsRune(shiftedChars, r)
-
-			log.Printf("Sending char '%c', code %d, shift %v", r, keyCode, keyShift)
-		}
-
-		if keyShift {
-			c.KeyEvent(KeyLeftShift, true)
-		}
-
-		c.KeyEvent(keyCode, true)
-		c.KeyEvent(keyCode, false)
-
-		if keyShift {
-			c.KeyEvent(KeyLeftShift, false)
-		}
-	}
-}
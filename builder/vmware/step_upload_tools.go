@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"os"
 	"text/template"
@@ -16,19 +17,28 @@ type toolsUploadPathTemplate struct {
 type stepUploadTools struct{}
 
 func (*stepUploadTools) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
+	bag := common.StateBag(state)
+	var config *config
+	if err := bag.Values("config", &config); err != nil {
+		return bag.Halt(err)
+	}
 	if config.ToolsUploadFlavor == "" {
 		return multistep.ActionContinue
 	}
 
-	comm := state["communicator"].(packer.Communicator)
-	tools_source := state["tools_upload_source"].(string)
-	ui := state["ui"].(packer.Ui)
+	var comm packer.Communicator
+	var tools_source string
+	var ui packer.Ui
+	if err := bag.Values("communicator", &comm, "tools_upload_source", &tools_source, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
 	ui.Say(fmt.Sprintf("Uploading the '%s' VMware Tools", config.ToolsUploadFlavor))
 	f, err := os.Open(tools_source)
 	if err != nil {
-		state["error"] = fmt.Errorf("Error opening VMware Tools ISO: %s", err)
+		err := fmt.Errorf("Error opening VMware Tools ISO: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
 	defer f.Close()
@@ -39,7 +49,9 @@ func (*stepUploadTools) Run(state map[string]interface{}) multistep.StepAction {
 	t.Execute(&processedPath, tplData)
 
 	if err := comm.Upload(processedPath.String(), f); err != nil {
-		state["error"] = fmt.Errorf("Error uploading VMware Tools: %s", err)
+		err := fmt.Errorf("Error uploading VMware Tools: %s", err)
+		state["error"] = err
+		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
 
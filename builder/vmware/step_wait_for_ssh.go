@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/communicator/ssh"
 	"github.com/mitchellh/packer/packer"
 	"io/ioutil"
@@ -19,6 +20,7 @@ import (
 //
 // Uses:
 //   config *config
+//   driver Driver
 //   ui     packer.Ui
 //   vmx_path string
 //
@@ -30,8 +32,12 @@ type stepWaitForSSH struct {
 }
 
 func (s *stepWaitForSSH) Run(state map[string]interface{}) multistep.StepAction {
-	config := state["config"].(*config)
-	ui := state["ui"].(packer.Ui)
+	bag := common.StateBag(state)
+	var config *config
+	var ui packer.Ui
+	if err := bag.Values("config", &config, "ui", &ui); err != nil {
+		return bag.Halt(err)
+	}
 
 	var comm packer.Communicator
 	var err error
@@ -80,8 +86,8 @@ func (s *stepWaitForSSH) Cleanup(map[string]interface{}) {
 	}
 }
 
-// Reads the network information for lookup via DHCP.
-func (s *stepWaitForSSH) dhcpLeaseLookup(vmxPath string) (GuestIPFinder, error) {
+// Reads the network information for lookup via DHCP on the given device.
+func (s *stepWaitForSSH) dhcpLeaseLookup(driver Driver, device string, vmxPath string) (GuestIPFinder, error) {
 	f, err := os.Open(vmxPath)
 	if err != nil {
 		return nil, err
@@ -98,23 +104,45 @@ func (s *stepWaitForSSH) dhcpLeaseLookup(vmxPath string) (GuestIPFinder, error)
 	var ok bool
 	macAddress := ""
 	if macAddress, ok = vmxData["ethernet0.address"]; !ok || macAddress == "" {
-		if macAddress, ok = vmxData["ethernet0.generatedAddress"]; !ok || macAddress == "" {
+		if macAddress, ok = vmxData["ethernet0.generatedaddress"]; !ok || macAddress == "" {
 			return nil, errors.New("couldn't find MAC address in VMX")
 		}
 	}
 
-	return &DHCPLeaseGuestLookup{"vmnet8", macAddress}, nil
+	return &DHCPLeaseGuestLookup{driver, device, macAddress}, nil
 }
 
 // This blocks until SSH becomes available, and sends the communicator
 // on the given channel.
 func (s *stepWaitForSSH) waitForSSH(state map[string]interface{}) (packer.Communicator, error) {
-	config := state["config"].(*config)
-	ui := state["ui"].(packer.Ui)
-	vmxPath := state["vmx_path"].(string)
+	bag := common.StateBag(state)
+	var config *config
+	var driver Driver
+	var ui packer.Ui
+	var vmxPath string
+	if err := bag.Values("config", &config, "driver", &driver, "ui", &ui, "vmx_path", &vmxPath); err != nil {
+		return nil, err
+	}
 
 	handshakeAttempts := 0
 
+	// The agent is only needed until the connection is made
+	var agent *gossh.AgentClient
+	if config.SSHAgentAuth {
+		var agentConn net.Conn
+		var err error
+		agent, agentConn, err = common.SSHAgent()
+		if err != nil {
+			return nil, err
+		}
+		defer agentConn.Close()
+	}
+
+	auth, err := common.SSHAuth(config.SSHPassword, config.SSHPrivateKeyFile, config.SSHKeyPassphrase, agent)
+	if err != nil {
+		return nil, fmt.Errorf("Error setting up SSH config: %s", err)
+	}
+
 	ui.Say("Waiting for SSH to become available...")
 	var comm packer.Communicator
 	var nc net.Conn
@@ -130,11 +158,17 @@ func (s *stepWaitForSSH) waitForSSH(state map[string]interface{}) (packer.Commun
 			return nil, errors.New("SSH wait cancelled")
 		}
 
-		// First we wait for the IP to become available...
+		// First we wait for the IP to become available. Remote drivers
+		// know how to find it themselves.
 		log.Println("Lookup up IP information...")
-		ipLookup, err := s.dhcpLeaseLookup(vmxPath)
-		if err != nil {
-			log.Printf("Can't lookup via DHCP lease: %s", err)
+		ipLookup, ok := driver.(GuestIPFinder)
+		if !ok {
+			var err error
+			ipLookup, err = s.dhcpLeaseLookup(driver, networkDevice(config.Network), vmxPath)
+			if err != nil {
+				log.Printf("Can't lookup via DHCP lease: %s", err)
+				continue
+			}
 		}
 
 		ip, err := ipLookup.GuestIP()
@@ -155,11 +189,7 @@ func (s *stepWaitForSSH) waitForSSH(state map[string]interface{}) (packer.Commun
 		// Then we attempt to connect via SSH
 		sshConfig := &gossh.ClientConfig{
 			User: config.SSHUser,
-			Auth: []gossh.ClientAuth{
-				gossh.ClientAuthPassword(ssh.Password(config.SSHPassword)),
-				gossh.ClientAuthKeyboardInteractive(
-					ssh.PasswordKeyboardInteractive(config.SSHPassword)),
-			},
+			Auth: auth,
 		}
 
 		comm, err = ssh.New(nc, sshConfig)
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"regexp"
 	"sort"
@@ -11,7 +12,8 @@ import (
 )
 
 // ParseVMX parses the keys and values from a VMX file and returns
-// them as a Go map.
+// them as a Go map. VMware treats keys case insensitively, so the keys
+// are lower-cased.
 func ParseVMX(contents string) map[string]string {
 	results := make(map[string]string)
 
@@ -23,7 +25,8 @@ func ParseVMX(contents string) map[string]string {
 			continue
 		}
 
-		results[matches[1]] = matches[2]
+		key := strings.ToLower(matches[1])
+		results[key] = matches[2]
 	}
 
 	return results
@@ -48,6 +51,16 @@ func EncodeVMX(contents map[string]string) string {
 	return buf.String()
 }
 
+// ReadVMX takes a path to a VMX file and reads it into a map.
+func ReadVMX(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseVMX(string(data)), nil
+}
+
 // WriteVMX takes a path to a VMX file and contents in the form of a
 // map and writes it out.
 func WriteVMX(path string, data map[string]string) (err error) {
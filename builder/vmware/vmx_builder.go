@@ -0,0 +1,128 @@
+package vmware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/builder/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// VMXBuilder is a VMware builder that clones an existing VM from its VMX
+// file rather than installing an operating system from an ISO. It uses
+// the same configuration structure and steps as the ISO builder.
+type VMXBuilder struct {
+	config config
+	driver Driver
+	runner multistep.Runner
+}
+
+func (b *VMXBuilder) Prepare(raws ...interface{}) error {
+	for _, raw := range raws {
+		err := mapstructure.Decode(raw, &b.config)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Linked clones share the disks of the source VM, so we can't compact them
+	if b.config.LinkedClone {
+		b.config.SkipCompaction = true
+	}
+
+	errs := b.config.prepareCommon()
+
+	if b.config.SourcePath == "" {
+		errs = append(errs, errors.New("A source_path must be specified."))
+	} else if _, err := os.Stat(b.config.SourcePath); err != nil {
+		errs = append(errs, fmt.Errorf("source_path is invalid: %s", err))
+	}
+
+	if b.config.RemoteType != "" {
+		errs = append(errs, errors.New("remote_type isn't supported when building from a VMX"))
+	}
+
+	var err error
+	b.driver, err = newDriver(&b.config)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed creating VMware driver: %s", err))
+	} else if b.config.LinkedClone {
+		if _, ok := b.driver.(LinkedCloner); !ok {
+			errs = append(errs, errors.New("linked_clone isn't supported by this VMware product"))
+		}
+	}
+
+	if len(errs) > 0 {
+		return &packer.MultiError{errs}
+	}
+
+	return nil
+}
+
+func (b *VMXBuilder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	// Seed the random number generator
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	steps := []multistep.Step{
+		&stepPrepareTools{},
+		&common.StepPrepareOutputDir{
+			Dir:   b.config.OutputDir,
+			Force: b.config.PackerForce,
+		},
+		&stepPrepareRemoteOutputDir{},
+		&stepCloneVMX{},
+		&stepConfigureVMX{CustomData: b.config.VMXData},
+		&stepConfigureVNC{},
+		&stepRun{},
+		&stepWaitForSSH{},
+		&stepUploadTools{},
+		&stepProvision{},
+		&stepShutdown{},
+		&stepCleanVMX{},
+		&stepConfigureVMX{CustomData: b.config.VMXDataPost},
+		&stepCleanFiles{},
+		&stepCompactDisk{},
+	}
+
+	// Setup the state bag
+	state := make(map[string]interface{})
+	state["cache"] = cache
+	state["config"] = &b.config
+	state["driver"] = b.driver
+	state["hook"] = hook
+	state["ui"] = ui
+
+	// Run!
+	b.runner = common.NewRunner(steps, b.config.PackerDebug, b.config.PackerOnError, ui)
+
+	b.runner.Run(state)
+	common.ReportStepTimings(ui, state)
+
+	// If there was an error, return that
+	if rawErr, ok := state["error"]; ok {
+		return nil, rawErr.(error)
+	}
+
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state[multistep.StateCancelled]; ok {
+		return nil, errors.New("Build was cancelled.")
+	}
+
+	if _, ok := state[multistep.StateHalted]; ok {
+		return nil, errors.New("Build was halted.")
+	}
+
+	return NewArtifact(b.config.OutputDir)
+}
+
+func (b *VMXBuilder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
@@ -0,0 +1,110 @@
+package vmware
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func testVMXConfig(t *testing.T) map[string]interface{} {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tf.Close()
+
+	return map[string]interface{}{
+		"source_path":  tf.Name(),
+		"ssh_username": "foo",
+
+		packer.BuildNameConfigKey: "foo",
+	}
+}
+
+func TestVMXBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &VMXBuilder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Error("VMXBuilder must implement builder.")
+	}
+}
+
+func TestVMXBuilderPrepare_Defaults(t *testing.T) {
+	var b VMXBuilder
+	config := testVMXConfig(t)
+	defer os.Remove(config["source_path"].(string))
+
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.OutputDir != "output-foo" {
+		t.Errorf("bad output dir: %s", b.config.OutputDir)
+	}
+
+	if b.config.VMName != "packer-foo" {
+		t.Errorf("bad vm name: %s", b.config.VMName)
+	}
+
+	if b.config.SkipCompaction {
+		t.Error("should not skip compaction")
+	}
+}
+
+func TestVMXBuilderPrepare_LinkedClone(t *testing.T) {
+	var b VMXBuilder
+	config := testVMXConfig(t)
+	defer os.Remove(config["source_path"].(string))
+
+	config["linked_clone"] = true
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if !b.config.SkipCompaction {
+		t.Error("linked clones should skip compaction")
+	}
+}
+
+func TestVMXBuilderPrepare_RemoteType(t *testing.T) {
+	var b VMXBuilder
+	config := testVMXConfig(t)
+	defer os.Remove(config["source_path"].(string))
+
+	config["remote_type"] = "esx5"
+	err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestVMXBuilderPrepare_SourcePath(t *testing.T) {
+	var b VMXBuilder
+	config := testVMXConfig(t)
+	defer os.Remove(config["source_path"].(string))
+
+	// Test with a good one
+	err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test with a missing one
+	delete(config, "source_path")
+	b = VMXBuilder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test with a file that doesn't exist
+	config["source_path"] = "i/dont/exist.vmx"
+	b = VMXBuilder{}
+	err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -22,6 +22,22 @@ config.version = "8"
 	}
 }
 
+func TestParseVMX_lowerCase(t *testing.T) {
+	contents := `
+displayName = "foo"
+RemoteDisplay.vnc.enabled = "TRUE"
+`
+
+	results := ParseVMX(contents)
+	if results["displayname"] != "foo" {
+		t.Errorf("invalid displayname: %s", results["displayname"])
+	}
+
+	if results["remotedisplay.vnc.enabled"] != "TRUE" {
+		t.Errorf("invalid remotedisplay.vnc.enabled: %s", results["remotedisplay.vnc.enabled"])
+	}
+}
+
 func TestEncodeVMX(t *testing.T) {
 	contents := map[string]string{
 		".encoding":      "UTF-8",
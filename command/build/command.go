@@ -2,17 +2,28 @@ package build
 
 import (
 	"bytes"
+	"cgl.tideland.biz/identifier"
+	"encoding/hex"
 	"flag"
 	"fmt"
+	cmdcommon "github.com/mitchellh/packer/command/common"
 	"github.com/mitchellh/packer/packer"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
+// ExitBuildsFailed is the exit status when the builds ran but at least
+// one of them failed. Errors before any build starts, such as an invalid
+// template, exit with 1 instead.
+const ExitBuildsFailed = 2
+
 type Command byte
 
 func (Command) Help() string {
@@ -20,15 +31,19 @@ func (Command) Help() string {
 }
 
 func (c Command) Run(env packer.Environment, args []string) int {
-	var cfgDebug bool
-	var cfgExcept []string
-	var cfgOnly []string
+	var cfgColor, cfgDebug, cfgForce, cfgParallel bool
+	var cfgManifest, cfgOnError string
+	var buildOptions cmdcommon.BuildOptions
 
 	cmdFlags := flag.NewFlagSet("build", flag.ContinueOnError)
 	cmdFlags.Usage = func() { env.Ui().Say(c.Help()) }
+	cmdFlags.BoolVar(&cfgColor, "color", true, "colorize the output of builds")
 	cmdFlags.BoolVar(&cfgDebug, "debug", false, "debug mode for builds")
-	cmdFlags.Var((*stringSliceValue)(&cfgExcept), "except", "build all builds except these")
-	cmdFlags.Var((*stringSliceValue)(&cfgOnly), "only", "only build the given builds by name")
+	cmdFlags.BoolVar(&cfgForce, "force", false, "force a build if artifacts exist")
+	cmdFlags.StringVar(&cfgManifest, "manifest", "", "path to write the artifact manifest to")
+	cmdFlags.StringVar(&cfgOnError, "on-error", "cleanup", "what to do when a step fails")
+	cmdFlags.BoolVar(&cfgParallel, "parallel", true, "run builds in parallel")
+	cmdcommon.BuildOptionFlags(cmdFlags, &buildOptions)
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
 	}
@@ -39,8 +54,36 @@ func (c Command) Run(env packer.Environment, args []string) int {
 		return 1
 	}
 
-	if len(cfgOnly) > 0 && len(cfgExcept) > 0 {
-		env.Ui().Error("Only one of '-except' or '-only' may be specified.\n")
+	// Debug mode pauses for input, which a machine can't give
+	if cfgDebug && os.Getenv("PACKER_MACHINE_READABLE") != "" {
+		env.Ui().Error("-debug can't be used with -machine-readable.\n")
+		env.Ui().Error(c.Help())
+		return 1
+	}
+
+	switch cfgOnError {
+	case "cleanup", "abort", "ask":
+	default:
+		env.Ui().Error("-on-error must be one of: cleanup, abort, ask\n")
+		env.Ui().Error(c.Help())
+		return 1
+	}
+
+	// Debug mode already decides what is cleaned up after a failure
+	if cfgDebug && cfgOnError != "cleanup" {
+		env.Ui().Error("-on-error can't be used with -debug.\n")
+		env.Ui().Error(c.Help())
+		return 1
+	}
+
+	if cfgOnError == "ask" && os.Getenv("PACKER_MACHINE_READABLE") != "" {
+		env.Ui().Error("-on-error=ask can't be used with -machine-readable.\n")
+		env.Ui().Error(c.Help())
+		return 1
+	}
+
+	if err := buildOptions.Validate(); err != nil {
+		env.Ui().Error(err.Error() + "\n")
 		env.Ui().Error(c.Help())
 		return 1
 	}
@@ -61,6 +104,13 @@ func (c Command) Run(env packer.Environment, args []string) int {
 		return 1
 	}
 
+	// Components resolve relative paths against the template's directory
+	tpl.Path, err = filepath.Abs(args[0])
+	if err != nil {
+		env.Ui().Error(fmt.Sprintf("Failed to find the path of the template: %s", err))
+		return 1
+	}
+
 	// The component finder for our builds
 	components := &packer.ComponentFinder{
 		Builder:       env.Builder,
@@ -70,39 +120,14 @@ func (c Command) Run(env packer.Environment, args []string) int {
 	}
 
 	// Go through each builder and compile the builds that we care about
-	buildNames := tpl.BuildNames()
+	buildNames, err := buildOptions.BuildNames(tpl)
+	if err != nil {
+		env.Ui().Error(err.Error())
+		return 1
+	}
+
 	builds := make([]packer.Build, 0, len(buildNames))
 	for _, buildName := range buildNames {
-		if len(cfgExcept) > 0 {
-			found := false
-			for _, only := range cfgExcept {
-				if buildName == only {
-					found = true
-					break
-				}
-			}
-
-			if found {
-				log.Printf("Skipping build '%s' because specified by -except.", buildName)
-				continue
-			}
-		}
-
-		if len(cfgOnly) > 0 {
-			found := false
-			for _, only := range cfgOnly {
-				if buildName == only {
-					found = true
-					break
-				}
-			}
-
-			if !found {
-				log.Printf("Skipping build '%s' because not specified by -only.", buildName)
-				continue
-			}
-		}
-
 		log.Printf("Creating build: %s", buildName)
 		build, err := tpl.Build(buildName, components)
 		if err != nil {
@@ -115,6 +140,13 @@ func (c Command) Run(env packer.Environment, args []string) int {
 
 	if cfgDebug {
 		env.Ui().Say("Debug mode enabled. Builds will not be parallelized.")
+		cfgParallel = false
+	}
+
+	// Packer itself sets this when its output isn't a terminal, since
+	// we can't tell from within the command.
+	if os.Getenv("PACKER_NO_COLOR") != "" {
+		cfgColor = false
 	}
 
 	// Compile all the UIs for the builds
@@ -126,37 +158,57 @@ func (c Command) Run(env packer.Environment, args []string) int {
 		packer.UiColorBlue,
 	}
 
+	// Every line of a build is prefixed with its name by the build
+	// itself, so without color the builds use the Ui as-is.
 	buildUis := make(map[string]packer.Ui)
 	for i, b := range builds {
-		ui := &packer.ColoredUi{
-			Color: colors[i%len(colors)],
-			Ui:    env.Ui(),
+		var ui packer.Ui
+		ui = env.Ui()
+		if cfgColor {
+			ui = &packer.ColoredUi{
+				Color: colors[i%len(colors)],
+				Ui:    env.Ui(),
+			}
+
+			ui.Say(fmt.Sprintf("%s output will be in this color.", b.Name()))
 		}
 
 		buildUis[b.Name()] = ui
-		ui.Say(fmt.Sprintf("%s output will be in this color.", b.Name()))
 	}
 
 	// Add a newline between the color output and the actual output
-	env.Ui().Say("")
+	if cfgColor {
+		env.Ui().Say("")
+	}
 
 	log.Printf("Build debug mode: %v", cfgDebug)
+	log.Printf("Force build: %v", cfgForce)
+	log.Printf("On error: %s", cfgOnError)
 
-	// Set the debug mode and prepare all the builds
+	// Set the debug, force and on-error modes and prepare all the builds
 	for _, b := range builds {
 		log.Printf("Preparing build: %s", b.Name())
 		b.SetDebug(cfgDebug)
+		b.SetForce(cfgForce)
+		b.SetOnError(cfgOnError)
 		err := b.Prepare()
 		if err != nil {
 			env.Ui().Error(err.Error())
 			return 1
 		}
+
+		for _, warning := range b.Warnings() {
+			env.Ui().Say(fmt.Sprintf("Warning for build '%s': %s", b.Name(), warning))
+		}
 	}
 
-	// Run all the builds in parallel and wait for them to complete
+	// Run all the builds, in parallel unless told otherwise, and wait
+	// for them to complete
 	var interruptWg, wg sync.WaitGroup
+	var resultsLock sync.Mutex
 	interrupted := false
 	artifacts := make(map[string][]packer.Artifact)
+	buildTimes := make(map[string]time.Time)
 	errors := make(map[string]error)
 	for _, b := range builds {
 		// Increment the waitgroup so we wait for this item to finish properly
@@ -172,6 +224,11 @@ func (c Command) Run(env packer.Environment, args []string) int {
 			defer interruptWg.Done()
 			interrupted = true
 
+			// Let the user know why the build is stopping, since its
+			// cleanup may take a while. A second interrupt force quits.
+			buildUis[b.Name()].Error(fmt.Sprintf(
+				"Interrupted. Cancelling build '%s' and cleaning up...", b.Name()))
+
 			log.Printf("Stopping build: %s", b.Name())
 			b.Cancel()
 			log.Printf("Build cancelled: %s", b.Name())
@@ -186,17 +243,21 @@ func (c Command) Run(env packer.Environment, args []string) int {
 			ui := buildUis[name]
 			runArtifacts, err := b.Run(ui, env.Cache())
 
+			resultsLock.Lock()
+			defer resultsLock.Unlock()
+
 			if err != nil {
 				ui.Error(fmt.Sprintf("Build '%s' errored: %s", name, err))
 				errors[name] = err
 			} else {
 				ui.Say(fmt.Sprintf("Build '%s' finished.", name))
 				artifacts[name] = runArtifacts
+				buildTimes[name] = time.Now()
 			}
 		}(b)
 
-		if cfgDebug {
-			log.Printf("Debug enabled, so waiting for build to finish: %s", b.Name())
+		if !cfgParallel {
+			log.Printf("Parallelization disabled, waiting for build to finish: %s", b.Name())
 			wg.Wait()
 		}
 
@@ -214,22 +275,48 @@ func (c Command) Run(env packer.Environment, args []string) int {
 	log.Printf("Builds completed. Waiting on interrupt barrier...")
 	interruptWg.Wait()
 
+	// Record the artifacts of the successful builds, even if others
+	// failed or we were interrupted, so they can be found and cleaned up.
+	manifestFailed := false
+	if cfgManifest != "" {
+		runUUID := hex.EncodeToString(identifier.NewUUID().Raw())
+		entries := make([]manifestArtifact, 0)
+		for _, b := range builds {
+			name := b.Name()
+			for _, artifact := range artifacts[name] {
+				if artifact != nil {
+					entries = append(entries, newManifestArtifact(
+						name, tpl.Builders[name].Type, buildTimes[name], artifact))
+				}
+			}
+		}
+
+		log.Printf("Writing manifest: %s", cfgManifest)
+		if err := writeManifest(cfgManifest, runUUID, entries); err != nil {
+			env.Ui().Error(fmt.Sprintf("Failed to write manifest: %s", err))
+			manifestFailed = true
+		}
+	}
+
 	if interrupted {
 		env.Ui().Say("Cleanly cancelled builds after being interrupted.")
 		return 1
 	}
 
-	if len(errors) > 0 {
-		env.Ui().Error("\n==> Some builds didn't complete successfully and had errors:")
-		for name, err := range errors {
-			env.Ui().Error(fmt.Sprintf("--> %s: %s", name, err))
-		}
-	}
-
 	if len(artifacts) > 0 {
 		env.Ui().Say("\n==> Builds finished. The artifacts of successful builds are:")
 		for name, buildArtifacts := range artifacts {
-			for _, artifact := range buildArtifacts {
+			// Machine-readable records for the artifacts are targeted
+			// at the build that created them.
+			ui := &packer.TargetedUi{
+				Target: name,
+				Ui:     env.Ui(),
+			}
+
+			ui.Machine("artifact-count", strconv.Itoa(len(buildArtifacts)))
+			for i, artifact := range buildArtifacts {
+				machineArtifact(ui, i, artifact)
+
 				var message bytes.Buffer
 				fmt.Fprintf(&message, "--> %s: ", name)
 
@@ -246,9 +333,60 @@ func (c Command) Run(env packer.Environment, args []string) int {
 		env.Ui().Say("\n==> Builds finished but no artifacts were created.")
 	}
 
+	// Summarize every build last, since a failed build is easy to miss in
+	// the interleaved output of builds running in parallel.
+	env.Ui().Say("\n==> Summary of builds:")
+	for _, b := range builds {
+		name := b.Name()
+		err, ok := errors[name]
+		if !ok {
+			env.Ui().Say(fmt.Sprintf("--> %s: succeeded", name))
+			continue
+		}
+
+		ui := &packer.TargetedUi{
+			Target: name,
+			Ui:     env.Ui(),
+		}
+
+		ui.Machine("error", err.Error())
+		env.Ui().Error(fmt.Sprintf("--> %s: failed: %s", name, err))
+	}
+
+	if len(errors) > 0 {
+		return ExitBuildsFailed
+	}
+
+	if manifestFailed {
+		return 1
+	}
+
 	return 0
 }
 
+// machineArtifact outputs the machine-readable records for the artifact
+// at the given index of a build's artifacts.
+func machineArtifact(ui packer.Ui, i int, artifact packer.Artifact) {
+	iStr := strconv.Itoa(i)
+	if artifact == nil {
+		ui.Machine("artifact", iStr, "nil")
+		ui.Machine("artifact", iStr, "end")
+		return
+	}
+
+	ui.Machine("artifact", iStr, "builder-id", artifact.BuilderId())
+	ui.Machine("artifact", iStr, "id", artifact.Id())
+	ui.Machine("artifact", iStr, "string", artifact.String())
+
+	files := artifact.Files()
+	ui.Machine("artifact", iStr, "files-count", strconv.Itoa(len(files)))
+	for j, f := range files {
+		ui.Machine("artifact", iStr, "file", strconv.Itoa(j), f)
+	}
+
+	ui.Machine("artifact", iStr, "end")
+}
+
 func (Command) Synopsis() string {
 	return "build image(s) from template"
 }
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"cgl.tideland.biz/asserts"
 	"github.com/mitchellh/packer/packer"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -53,3 +55,32 @@ func TestCommand_Run_MissingFile(t *testing.T) {
 	result := command.Run(testEnvironment(), args)
 	assert.Equal(result, 1, "a non-existent file should error")
 }
+
+func TestCommand_Run_DebugMachineReadable(t *testing.T) {
+	assert := asserts.NewTestingAsserts(t, true)
+	command := new(Command)
+
+	defer os.Setenv("PACKER_MACHINE_READABLE", os.Getenv("PACKER_MACHINE_READABLE"))
+	os.Setenv("PACKER_MACHINE_READABLE", "1")
+
+	env := testEnvironment()
+	args := []string{"-debug", "template.json"}
+	result := command.Run(env, args)
+	assert.Equal(result, 1, "debug mode should fail with machine-readable")
+
+	output := env.Ui().(*packer.ReaderWriterUi).Writer.(*bytes.Buffer).String()
+	assert.True(strings.Contains(output, "-machine-readable"), "should mention machine-readable")
+}
+
+func TestCommand_Run_OnErrorInvalid(t *testing.T) {
+	assert := asserts.NewTestingAsserts(t, true)
+	command := new(Command)
+
+	env := testEnvironment()
+	args := []string{"-on-error=bad", "template.json"}
+	result := command.Run(env, args)
+	assert.Equal(result, 1, "bad on-error should fail")
+
+	output := env.Ui().(*packer.ReaderWriterUi).Writer.(*bytes.Buffer).String()
+	assert.True(strings.Contains(output, "-on-error"), "should mention on-error")
+}
@@ -3,12 +3,18 @@ package build
 const helpText = `
 Usage: packer build [options] TEMPLATE
 
-  Will execute multiple builds in parallel as defined in the template.
+  Will execute multiple builds in parallel as defined in the template,
+  unless -parallel=false is given.
   The various artifacts created by the template will be outputted.
 
 Options:
 
+  -color=false               Disable color output (on by default)
   -debug                     Debug mode enabled for builds
   -except=foo,bar,baz        Build all builds other than these
+  -force                     Force a build to continue if artifacts exist, deletes existing artifacts
+  -manifest=path.json        Append the artifacts of the builds to a JSON manifest
+  -on-error=cleanup          If a build fails: cleanup (default), abort without cleanup, or ask
   -only=foo,bar,baz          Only build the given builds by name
+  -parallel=false            Disable parallelization (on by default)
 `
@@ -0,0 +1,132 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// manifestLock keeps writes of the manifest within this process apart,
+// since the lock file doesn't on every OS.
+var manifestLock sync.Mutex
+
+// manifest is the structure of the artifact manifest file. Every run
+// appends its artifacts to Builds and sets LastRunUUID, so the artifacts
+// of the most recent run can be told apart from those of earlier runs.
+type manifest struct {
+	Builds      []manifestArtifact `json:"builds"`
+	LastRunUUID string             `json:"last_run_uuid"`
+}
+
+// manifestArtifact is a single artifact recorded in the manifest.
+type manifestArtifact struct {
+	BuildName   string   `json:"name"`
+	BuilderType string   `json:"builder_type"`
+	BuildTime   int64    `json:"build_time"`
+	BuilderId   string   `json:"builder_id"`
+	ArtifactId  string   `json:"artifact_id"`
+	Files       []string `json:"files"`
+	RunUUID     string   `json:"packer_run_uuid"`
+}
+
+// newManifestArtifact creates the manifest entry for an artifact of the
+// given build.
+func newManifestArtifact(name, builderType string, buildTime time.Time, a packer.Artifact) manifestArtifact {
+	files := a.Files()
+	if files == nil {
+		files = []string{}
+	}
+
+	return manifestArtifact{
+		BuildName:   name,
+		BuilderType: builderType,
+		BuildTime:   buildTime.Unix(),
+		BuilderId:   a.BuilderId(),
+		ArtifactId:  a.Id(),
+		Files:       files,
+	}
+}
+
+// writeManifest appends the given artifacts to the manifest at path,
+// creating it if it doesn't exist. A lock file next to the manifest keeps
+// Packer processes running at the same time from overwriting each other's
+// artifacts, and the manifest is replaced atomically so that readers never
+// see a partially written file.
+func writeManifest(path string, runUUID string, artifacts []manifestArtifact) error {
+	unlock, err := lockManifest(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var m manifest
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("Error reading existing manifest: %s", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, a := range artifacts {
+		a.RunUUID = runUUID
+		m.Builds = append(m.Builds, a)
+	}
+
+	m.LastRunUUID = runUUID
+
+	data, err = json.MarshalIndent(&m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempPath := path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+
+	// Renaming over the manifest replaces it atomically, so a reader never
+	// sees it half written. Windows can't rename over an existing file, so
+	// there it has to be removed first.
+	if runtime.GOOS == "windows" {
+		os.Remove(path)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return nil
+}
+
+// lockManifest locks the lock file next to the manifest at path, waiting
+// for any other Packer process holding it. The lock is released by the
+// returned function, or by the OS if Packer exits without calling it, so
+// a killed process can't leave the manifest locked.
+func lockManifest(path string) (func(), error) {
+	manifestLock.Lock()
+
+	lockPath := path + ".lock"
+	log.Printf("Acquiring manifest lock: %s", lockPath)
+	f, err := packer.LockFile(lockPath)
+	if err != nil {
+		manifestLock.Unlock()
+		return nil, fmt.Errorf("Error locking manifest: %s", err)
+	}
+
+	return func() {
+		if err := packer.UnlockFile(f); err != nil {
+			log.Printf("[WARN] Failed to unlock manifest %s: %s", lockPath, err)
+		}
+
+		manifestLock.Unlock()
+	}, nil
+}
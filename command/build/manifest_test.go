@@ -0,0 +1,119 @@
+package build
+
+import (
+	"cgl.tideland.biz/asserts"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testManifestPath(t *testing.T) (string, func()) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return filepath.Join(td, "manifest.json"), func() { os.RemoveAll(td) }
+}
+
+func readTestManifest(t *testing.T, path string) *manifest {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &m
+}
+
+func TestWriteManifest(t *testing.T) {
+	assert := asserts.NewTestingAsserts(t, true)
+
+	path, cleanup := testManifestPath(t)
+	defer cleanup()
+
+	artifacts := []manifestArtifact{
+		{BuildName: "foo", BuilderType: "amazon-ebs", ArtifactId: "us-east-1:ami-1234"},
+	}
+
+	err := writeManifest(path, "run1", artifacts)
+	assert.Nil(err, "should not error")
+
+	artifacts = []manifestArtifact{
+		{BuildName: "bar", BuilderType: "virtualbox", Files: []string{"a.ovf"}},
+	}
+
+	err = writeManifest(path, "run2", artifacts)
+	assert.Nil(err, "should not error")
+
+	m := readTestManifest(t, path)
+	assert.Equal(m.LastRunUUID, "run2", "should have the last run")
+	assert.Length(m.Builds, 2, "should append the builds")
+	assert.Equal(m.Builds[0].BuildName, "foo", "should keep the first run")
+	assert.Equal(m.Builds[0].RunUUID, "run1", "should have the first run UUID")
+	assert.Equal(m.Builds[1].BuildName, "bar", "should have the second run")
+	assert.Equal(m.Builds[1].RunUUID, "run2", "should have the second run UUID")
+	assert.Equal(m.Builds[1].Files, []string{"a.ovf"}, "should have files")
+}
+
+func TestWriteManifest_Concurrent(t *testing.T) {
+	assert := asserts.NewTestingAsserts(t, true)
+
+	path, cleanup := testManifestPath(t)
+	defer cleanup()
+
+	errCh := make(chan error)
+	for i := 0; i < 5; i++ {
+		go func() {
+			errCh <- writeManifest(path, "run", []manifestArtifact{{BuildName: "foo"}})
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		assert.Nil(<-errCh, "should not error")
+	}
+
+	m := readTestManifest(t, path)
+	assert.Length(m.Builds, 5, "should have every build")
+}
+
+func TestWriteManifest_StaleLock(t *testing.T) {
+	assert := asserts.NewTestingAsserts(t, true)
+
+	path, cleanup := testManifestPath(t)
+	defer cleanup()
+
+	// A lock file left behind by a killed process isn't locked anymore
+	if err := ioutil.WriteFile(path+".lock", []byte{}, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err := writeManifest(path, "run", []manifestArtifact{{BuildName: "foo"}})
+	assert.Nil(err, "should not error")
+
+	m := readTestManifest(t, path)
+	assert.Length(m.Builds, 1, "should have the build")
+}
+
+func TestWriteManifest_Invalid(t *testing.T) {
+	assert := asserts.NewTestingAsserts(t, true)
+
+	path, cleanup := testManifestPath(t)
+	defer cleanup()
+
+	if err := ioutil.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err := writeManifest(path, "run", []manifestArtifact{{BuildName: "foo"}})
+	assert.NotNil(err, "should error")
+
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(string(data), "not json", "should not overwrite the file")
+}
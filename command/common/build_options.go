@@ -0,0 +1,86 @@
+// The common package contains the parts of the commands that are shared
+// between them, such as the flags that select the builds of a template.
+
+package common
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"sort"
+	"strings"
+)
+
+// BuildOptions are the options that select which builds of a template a
+// command works with, set by the "-except" and "-only" flags.
+type BuildOptions struct {
+	Except []string
+	Only   []string
+}
+
+// BuildOptionFlags adds the flags for the build options to the flag set.
+func BuildOptionFlags(fs *flag.FlagSet, o *BuildOptions) {
+	fs.Var((*SliceValue)(&o.Except), "except", "build all builds except these")
+	fs.Var((*SliceValue)(&o.Only), "only", "only build the given builds by name")
+}
+
+// Validate checks that the build options make sense.
+func (o *BuildOptions) Validate() error {
+	if len(o.Except) > 0 && len(o.Only) > 0 {
+		return errors.New("Only one of '-except' or '-only' may be specified.")
+	}
+
+	return nil
+}
+
+// BuildNames returns the names of the builds of the template that are
+// selected by the options, sorted by name. Naming a build that isn't in
+// the template is an error.
+func (o *BuildOptions) BuildNames(t *packer.Template) ([]string, error) {
+	buildNames := t.BuildNames()
+	sort.Strings(buildNames)
+
+	valid := make(map[string]bool)
+	for _, name := range buildNames {
+		valid[name] = true
+	}
+
+	for _, names := range [][]string{o.Except, o.Only} {
+		for _, name := range names {
+			if !valid[name] {
+				return nil, fmt.Errorf(
+					"No such build in template: %s. Valid builds are: %s",
+					name, strings.Join(buildNames, ", "))
+			}
+		}
+	}
+
+	result := make([]string, 0, len(buildNames))
+	for _, buildName := range buildNames {
+		if len(o.Except) > 0 && contains(o.Except, buildName) {
+			log.Printf("Skipping build '%s' because specified by -except.", buildName)
+			continue
+		}
+
+		if len(o.Only) > 0 && !contains(o.Only, buildName) {
+			log.Printf("Skipping build '%s' because not specified by -only.", buildName)
+			continue
+		}
+
+		result = append(result, buildName)
+	}
+
+	return result, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+package common
+
+import (
+	"flag"
+	"github.com/mitchellh/packer/packer"
+	"reflect"
+	"testing"
+)
+
+func testTemplate(t *testing.T) *packer.Template {
+	data := `
+	{
+		"builders": [
+			{ "type": "amazon-ebs" },
+			{ "name": "vbox", "type": "virtualbox" },
+			{ "type": "vmware" }
+		]
+	}
+	`
+
+	tpl, err := packer.ParseTemplate([]byte(data))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return tpl
+}
+
+func TestBuildOptionFlags(t *testing.T) {
+	var opts BuildOptions
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	BuildOptionFlags(fs, &opts)
+
+	if err := fs.Parse([]string{"-only=foo,bar", "-except=baz"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(opts.Only, []string{"foo", "bar"}) {
+		t.Fatalf("bad: %#v", opts.Only)
+	}
+
+	if !reflect.DeepEqual(opts.Except, []string{"baz"}) {
+		t.Fatalf("bad: %#v", opts.Except)
+	}
+}
+
+func TestBuildOptionsValidate(t *testing.T) {
+	opts := &BuildOptions{Only: []string{"foo"}}
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	opts.Except = []string{"bar"}
+	if err := opts.Validate(); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuildOptionsBuildNames(t *testing.T) {
+	tpl := testTemplate(t)
+
+	// All builds
+	opts := new(BuildOptions)
+	names, err := opts.BuildNames(tpl)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{"amazon-ebs", "vbox", "vmware"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+
+	// Only, by type and by explicit name
+	opts = &BuildOptions{Only: []string{"vmware", "vbox"}}
+	names, err = opts.BuildNames(tpl)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected = []string{"vbox", "vmware"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+
+	// Except
+	opts = &BuildOptions{Except: []string{"amazon-ebs"}}
+	names, err = opts.BuildNames(tpl)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+}
+
+func TestBuildOptionsBuildNames_Unknown(t *testing.T) {
+	tpl := testTemplate(t)
+
+	for _, opts := range []*BuildOptions{
+		&BuildOptions{Only: []string{"nope"}},
+		&BuildOptions{Except: []string{"nope"}},
+	} {
+		_, err := opts.BuildNames(tpl)
+		if err == nil {
+			t.Fatal("should have error")
+		}
+
+		expected := "No such build in template: nope. Valid builds are: amazon-ebs, vbox, vmware"
+		if err.Error() != expected {
+			t.Fatalf("bad: %s", err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package common
+
+import "strings"
+
+// SliceValue is a flag.Value for a comma-separated list of strings.
+type SliceValue []string
+
+func (s *SliceValue) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *SliceValue) Set(value string) error {
+	*s = strings.Split(value, ",")
+	return nil
+}
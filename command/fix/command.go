@@ -0,0 +1,90 @@
+package fix
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/mitchellh/packer/fix"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+type Command byte
+
+func (Command) Help() string {
+	return strings.TrimSpace(helpText)
+}
+
+func (c Command) Run(env packer.Environment, args []string) int {
+	var cfgValidate bool
+
+	cmdFlags := flag.NewFlagSet("fix", flag.ContinueOnError)
+	cmdFlags.Usage = func() { env.Ui().Say(c.Help()) }
+	cmdFlags.BoolVar(&cfgValidate, "validate", true, "validate the fixed template")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = cmdFlags.Args()
+	if len(args) != 1 {
+		cmdFlags.Usage()
+		return 1
+	}
+
+	// Read the file and decode it as raw JSON, since the fixers work on
+	// the template before it is parsed.
+	log.Printf("Reading template: %s", args[0])
+	tplData, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		env.Ui().Error(fmt.Sprintf("Failed to read template file: %s", err))
+		return 1
+	}
+
+	var templateData map[string]interface{}
+	if err := json.Unmarshal(tplData, &templateData); err != nil {
+		env.Ui().Error(fmt.Sprintf("Error parsing template: %s", err))
+		return 1
+	}
+
+	// Run all the fixers in order
+	input := templateData
+	for _, name := range fix.FixerOrder {
+		log.Printf("Running fixer: %s", name)
+		input, err = fix.Fixers[name].Fix(input)
+		if err != nil {
+			env.Ui().Error(fmt.Sprintf("Error fixing with %s: %s", name, err))
+			return 1
+		}
+	}
+
+	result, err := json.MarshalIndent(input, "", "  ")
+	if err != nil {
+		env.Ui().Error(fmt.Sprintf("Error encoding fixed template: %s", err))
+		return 1
+	}
+
+	// The encoder escapes the characters that are special in HTML, which
+	// boot commands such as "<enter>" are full of, so put them back.
+	result = bytes.Replace(result, []byte("\\u003c"), []byte("<"), -1)
+	result = bytes.Replace(result, []byte("\\u003e"), []byte(">"), -1)
+	result = bytes.Replace(result, []byte("\\u0026"), []byte("&"), -1)
+
+	if cfgValidate {
+		if _, err := packer.ParseTemplate(result); err != nil {
+			env.Ui().Error(fmt.Sprintf(
+				"The fixed template is not valid. This is a bug in Packer, "+
+					"please report it. The error was: %s", err))
+			return 1
+		}
+	}
+
+	env.Ui().Say(string(result))
+	return 0
+}
+
+func (Command) Synopsis() string {
+	return "fixes templates from old versions of packer"
+}
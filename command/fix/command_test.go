@@ -0,0 +1,96 @@
+package fix
+
+import (
+	"bytes"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func testEnvironment(out *bytes.Buffer) packer.Environment {
+	config := packer.DefaultEnvironmentConfig()
+	config.Ui = &packer.ReaderWriterUi{
+		Reader: new(bytes.Buffer),
+		Writer: out,
+	}
+
+	env, err := packer.NewEnvironment(config)
+	if err != nil {
+		panic(err)
+	}
+
+	return env
+}
+
+func testTemplate(t *testing.T, contents string) string {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer tf.Close()
+
+	if _, err := tf.Write([]byte(contents)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return tf.Name()
+}
+
+func TestCommand_Implements(t *testing.T) {
+	var _ packer.Command = new(Command)
+}
+
+func TestCommand_Run_NoArgs(t *testing.T) {
+	command := new(Command)
+	if result := command.Run(testEnvironment(new(bytes.Buffer)), nil); result != 1 {
+		t.Fatalf("bad: %d", result)
+	}
+}
+
+func TestCommand_Run(t *testing.T) {
+	path := testTemplate(t, `
+	{
+		"builders": [{
+			"type": "virtualbox",
+			"iso_md5": "foo",
+			"boot_command": ["<enter>"]
+		}]
+	}
+	`)
+	defer os.Remove(path)
+
+	out := new(bytes.Buffer)
+	command := new(Command)
+	if result := command.Run(testEnvironment(out), []string{path}); result != 0 {
+		t.Fatalf("bad: %d\n\n%s", result, out.String())
+	}
+
+	for _, e := range []string{`"iso_checksum": "foo"`, `"iso_checksum_type": "md5"`, `"<enter>"`} {
+		if !strings.Contains(out.String(), e) {
+			t.Fatalf("output should contain %s:\n\n%s", e, out.String())
+		}
+	}
+
+	if strings.Contains(out.String(), "iso_md5") {
+		t.Fatalf("output should not contain iso_md5:\n\n%s", out.String())
+	}
+}
+
+func TestCommand_Run_Validate(t *testing.T) {
+	// A builder without a type isn't a valid template, which the fixers
+	// leave alone.
+	path := testTemplate(t, `{ "builders": [{ "iso_md5": "foo" }] }`)
+	defer os.Remove(path)
+
+	command := new(Command)
+	if result := command.Run(testEnvironment(new(bytes.Buffer)), []string{path}); result != 1 {
+		t.Fatalf("bad: %d", result)
+	}
+
+	args := []string{"-validate=false", path}
+	if result := command.Run(testEnvironment(new(bytes.Buffer)), args); result != 0 {
+		t.Fatalf("bad: %d", result)
+	}
+}
@@ -0,0 +1,23 @@
+package fix
+
+const helpText = `
+Usage: packer fix [options] TEMPLATE
+
+  Reads the JSON template and attempts to fix known backwards
+  incompatibilities. The fixed template will be outputted to standard out.
+
+  If the template cannot be fixed due to an error, the command will exit
+  with a non-zero exit status and output the error instead.
+
+Fixes that are run:
+
+  iso-md5             Replaces "iso_md5" in VirtualBox builders with
+                      "iso_checksum" and "iso_checksum_type"
+  digitalocean-ids    Replaces the deprecated "region_id", "size_id" and
+                      "image_id" of DigitalOcean builders with "region",
+                      "size" and "image"
+
+Options:
+
+  -validate=false     Don't validate the fixed template
+`
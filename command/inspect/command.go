@@ -0,0 +1,124 @@
+package inspect
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Command byte
+
+func (Command) Help() string {
+	return strings.TrimSpace(helpText)
+}
+
+func (c Command) Run(env packer.Environment, args []string) int {
+	cmdFlags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	cmdFlags.Usage = func() { env.Ui().Say(c.Help()) }
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = cmdFlags.Args()
+	if len(args) != 1 {
+		cmdFlags.Usage()
+		return 1
+	}
+
+	// Read the file into a byte array so that we can parse the template
+	log.Printf("Reading template: %s", args[0])
+	tplData, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		env.Ui().Error(fmt.Sprintf("Failed to read template file: %s", err))
+		return 1
+	}
+
+	// Parse the template into a machine-usable format. Nothing is
+	// prepared, so inspecting needs no credentials or plugins.
+	log.Println("Parsing template...")
+	tpl, err := packer.ParseTemplate(tplData)
+	if err != nil {
+		env.Ui().Error(fmt.Sprintf("Failed to parse template: %s", err))
+		return 1
+	}
+
+	ui := env.Ui()
+
+	// Builders, sorted by name since the template keeps them in a map
+	buildNames := tpl.BuildNames()
+	sort.Strings(buildNames)
+
+	ui.Say("Builders:\n")
+	if len(buildNames) == 0 {
+		ui.Say("  <No builders>")
+	}
+
+	width := 0
+	for _, name := range buildNames {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	for _, name := range buildNames {
+		builder := tpl.Builders[name]
+		ui.Machine("template-builder", name, builder.Type)
+		if builder.Type == name {
+			ui.Say(fmt.Sprintf("  %s", name))
+		} else {
+			ui.Say(fmt.Sprintf("  %-*s (%s)", width, name, builder.Type))
+		}
+	}
+
+	// Provisioners, in the order they run
+	ui.Say("\nProvisioners:\n")
+	if len(tpl.Provisioners) == 0 {
+		ui.Say("  <No provisioners>")
+	}
+
+	for _, prov := range tpl.Provisioners {
+		ui.Machine("template-provisioner", prov.Type)
+		line := fmt.Sprintf("  %s", prov.Type)
+		if len(prov.Override) > 0 {
+			overrides := make([]string, 0, len(prov.Override))
+			for name := range prov.Override {
+				overrides = append(overrides, name)
+			}
+			sort.Strings(overrides)
+
+			line += fmt.Sprintf(" (overridden for: %s)", strings.Join(overrides, ", "))
+		}
+
+		ui.Say(line)
+	}
+
+	// Post-processors, one line per sequence
+	ui.Say("\nPost-processors:\n")
+	if len(tpl.PostProcessors) == 0 {
+		ui.Say("  <No post-processors>")
+	}
+
+	for i, seq := range tpl.PostProcessors {
+		types := make([]string, len(seq))
+		for j, pp := range seq {
+			ui.Machine("template-postprocessor", strconv.Itoa(i), pp.Type)
+			types[j] = pp.Type
+			if pp.KeepInputArtifact {
+				types[j] += " (keeps input artifact)"
+			}
+		}
+
+		ui.Say(fmt.Sprintf("  %d: %s", i+1, strings.Join(types, " -> ")))
+	}
+
+	return 0
+}
+
+func (Command) Synopsis() string {
+	return "see components of a template"
+}
@@ -0,0 +1,95 @@
+package inspect
+
+import (
+	"bytes"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func testEnvironment(out *bytes.Buffer) packer.Environment {
+	config := packer.DefaultEnvironmentConfig()
+	config.Ui = &packer.ReaderWriterUi{
+		Reader: new(bytes.Buffer),
+		Writer: out,
+	}
+
+	env, err := packer.NewEnvironment(config)
+	if err != nil {
+		panic(err)
+	}
+
+	return env
+}
+
+func TestCommand_Implements(t *testing.T) {
+	var _ packer.Command = new(Command)
+}
+
+func TestCommand_Run_NoArgs(t *testing.T) {
+	command := new(Command)
+	if result := command.Run(testEnvironment(new(bytes.Buffer)), nil); result != 1 {
+		t.Fatalf("bad: %d", result)
+	}
+}
+
+func TestCommand_Run_MissingFile(t *testing.T) {
+	command := new(Command)
+	args := []string{"i-better-not-exist"}
+	if result := command.Run(testEnvironment(new(bytes.Buffer)), args); result != 1 {
+		t.Fatalf("bad: %d", result)
+	}
+}
+
+func TestCommand_Run(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tf.Write([]byte(`
+	{
+		"builders": [
+			{ "type": "amazon-ebs" },
+			{ "name": "vbox", "type": "virtualbox" }
+		],
+
+		"provisioners": [
+			{ "type": "shell", "override": { "vbox": {} } },
+			{ "type": "file" }
+		],
+
+		"post-processors": [
+			"vagrant",
+			[
+				"vagrant",
+				{ "type": "compress", "keep_input_artifact": true }
+			]
+		]
+	}
+	`))
+	tf.Close()
+
+	out := new(bytes.Buffer)
+	command := new(Command)
+	if result := command.Run(testEnvironment(out), []string{tf.Name()}); result != 0 {
+		t.Fatalf("bad: %d\n\n%s", result, out.String())
+	}
+
+	expected := []string{
+		"  amazon-ebs\n",
+		"  vbox       (virtualbox)\n",
+		"  shell (overridden for: vbox)\n  file\n",
+		"  1: vagrant\n",
+		"  2: vagrant -> compress (keeps input artifact)\n",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e) {
+			t.Fatalf("output should contain %q:\n\n%s", e, out.String())
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package inspect
+
+const helpText = `
+Usage: packer inspect TEMPLATE
+
+  Inspects a template, showing the components it is made of: the builds
+  by name and builder type, the provisioners in the order they run, and
+  the post-processors. Nothing is prepared or run, so this works without
+  credentials for any cloud providers.
+`
@@ -3,9 +3,11 @@ package validate
 import (
 	"flag"
 	"fmt"
+	cmdcommon "github.com/mitchellh/packer/command/common"
 	"github.com/mitchellh/packer/packer"
 	"io/ioutil"
 	"log"
+	"path/filepath"
 	"strings"
 )
 
@@ -17,10 +19,12 @@ func (Command) Help() string {
 
 func (c Command) Run(env packer.Environment, args []string) int {
 	var cfgSyntaxOnly bool
+	var buildOptions cmdcommon.BuildOptions
 
 	cmdFlags := flag.NewFlagSet("validate", flag.ContinueOnError)
 	cmdFlags.Usage = func() { env.Ui().Say(c.Help()) }
 	cmdFlags.BoolVar(&cfgSyntaxOnly, "syntax-only", false, "check syntax only")
+	cmdcommon.BuildOptionFlags(cmdFlags, &buildOptions)
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
 	}
@@ -31,6 +35,12 @@ func (c Command) Run(env packer.Environment, args []string) int {
 		return 1
 	}
 
+	if err := buildOptions.Validate(); err != nil {
+		env.Ui().Error(err.Error() + "\n")
+		env.Ui().Error(c.Help())
+		return 1
+	}
+
 	// Read the file into a byte array so that we can parse the template
 	log.Printf("Reading template: %s", args[0])
 	tplData, err := ioutil.ReadFile(args[0])
@@ -47,6 +57,13 @@ func (c Command) Run(env packer.Environment, args []string) int {
 		return 1
 	}
 
+	// Components resolve relative paths against the template's directory
+	tpl.Path, err = filepath.Abs(args[0])
+	if err != nil {
+		env.Ui().Error(fmt.Sprintf("Failed to find the path of the template: %s", err))
+		return 1
+	}
+
 	if cfgSyntaxOnly {
 		env.Ui().Say("Syntax-only check passed. Everything looks okay.")
 		return 0
@@ -62,8 +79,13 @@ func (c Command) Run(env packer.Environment, args []string) int {
 		Provisioner:   env.Provisioner,
 	}
 
-	// Otherwise, get all the builds
-	buildNames := tpl.BuildNames()
+	// Otherwise, get all the builds that were selected
+	buildNames, err := buildOptions.BuildNames(tpl)
+	if err != nil {
+		env.Ui().Error(err.Error())
+		return 1
+	}
+
 	builds := make([]packer.Build, 0, len(buildNames))
 	for _, buildName := range buildNames {
 		log.Printf("Creating build from template for: %s", buildName)
@@ -77,12 +99,26 @@ func (c Command) Run(env packer.Environment, args []string) int {
 	}
 
 	// Check the configuration of all builds
+	warnings := make([]string, 0)
 	for _, b := range builds {
 		log.Printf("Preparing build: %s", b.Name())
 		err := b.Prepare()
 		if err != nil {
 			errs = append(errs, fmt.Errorf("Errors validating build '%s'. %s", b.Name(), err))
 		}
+
+		for _, warning := range b.Warnings() {
+			warnings = append(warnings, fmt.Sprintf("Build '%s': %s", b.Name(), warning))
+		}
+	}
+
+	if len(warnings) > 0 {
+		env.Ui().Say("Warnings were found in the template.\n")
+		for _, warning := range warnings {
+			env.Ui().Say(warning)
+		}
+
+		env.Ui().Say("")
 	}
 
 	if len(errs) > 0 {
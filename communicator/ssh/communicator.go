@@ -1,6 +1,7 @@
 package ssh
 
 import (
+	"bufio"
 	"bytes"
 	"code.google.com/p/go.crypto/ssh"
 	"fmt"
@@ -8,7 +9,9 @@ import (
 	"io"
 	"log"
 	"net"
+	"os"
 	"path/filepath"
+	"strings"
 )
 
 type comm struct {
@@ -105,25 +108,40 @@ func (c *comm) Upload(path string, input io.Reader) error {
 	target_file := filepath.Base(path)
 
 	// Start the sink mode on the other side
-	// TODO(mitchellh): There are probably issues with shell escaping the path
 	log.Println("Starting remote scp process in sink mode")
-	if err = session.Start("scp -vt " + target_dir); err != nil {
+	if err = session.Start("scp -vt " + ShellQuote(target_dir)); err != nil {
 		return err
 	}
 
-	// Determine the length of the upload content by copying it
-	// into an in-memory buffer. Note that this means what we upload
-	// must fit into memory.
-	log.Println("Copying input data into in-memory buffer so we can get the length")
-	input_memory := new(bytes.Buffer)
-	if _, err = io.Copy(input_memory, input); err != nil {
-		return err
+	// Determine the length of the upload content. Files can just be
+	// stat-ed, which matters for large files such as ISOs. Anything else
+	// is copied into an in-memory buffer, which means that it must fit
+	// into memory.
+	var size int64
+	if f, ok := input.(*os.File); ok {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		size = info.Size()
+	} else {
+		log.Println("Copying input data into in-memory buffer so we can get the length")
+		input_memory := new(bytes.Buffer)
+		if _, err = io.Copy(input_memory, input); err != nil {
+			return err
+		}
+
+		size = int64(input_memory.Len())
+		input = input_memory
 	}
 
 	// Start the protocol
 	log.Println("Beginning file upload...")
-	fmt.Fprintln(w, "C0644", input_memory.Len(), target_file)
-	io.Copy(w, input_memory)
+	fmt.Fprintln(w, "C0644", size, target_file)
+	if _, err = io.CopyN(w, input, size); err != nil {
+		return err
+	}
 	fmt.Fprint(w, "\x00")
 
 	// TODO(mitchellh): Each step above results in a 0/1/2 being sent by
@@ -156,6 +174,86 @@ func (c *comm) Upload(path string, input io.Reader) error {
 	return nil
 }
 
-func (c *comm) Download(string, io.Writer) error {
-	panic("not implemented yet")
+func (c *comm) Download(path string, output io.Writer) error {
+	log.Println("Opening new SSH session")
+	session, err := c.client.NewSession()
+	if err != nil {
+		return err
+	}
+
+	defer session.Close()
+
+	w, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if w != nil {
+			w.Close()
+		}
+	}()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	stderr := new(bytes.Buffer)
+	session.Stderr = stderr
+
+	// Start the source mode on the other side
+	log.Println("Starting remote scp process in source mode")
+	if err = session.Start("scp -vf " + ShellQuote(path)); err != nil {
+		return err
+	}
+
+	// Tell the remote side we're ready, and read the file header, which
+	// looks like "C0644 <size> <name>".
+	r := bufio.NewReader(stdout)
+	fmt.Fprint(w, "\x00")
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	if header[0] != 'C' {
+		return fmt.Errorf("scp error downloading %s: %s", path, strings.TrimSpace(header[1:]))
+	}
+
+	var mode string
+	var size int64
+	if _, err := fmt.Sscanf(header, "C%s %d", &mode, &size); err != nil {
+		return fmt.Errorf("invalid scp header %#v: %s", header, err)
+	}
+
+	log.Printf("Beginning file download (%d bytes)...", size)
+	fmt.Fprint(w, "\x00")
+	if _, err := io.CopyN(output, r, size); err != nil {
+		return err
+	}
+
+	// The file is followed by a status byte, which we confirm
+	if status, err := r.ReadByte(); err != nil {
+		return err
+	} else if status != 0 {
+		return fmt.Errorf("scp error downloading %s: status %d", path, status)
+	}
+
+	fmt.Fprint(w, "\x00")
+
+	log.Println("Download complete, closing stdin pipe")
+	w.Close()
+	w = nil
+
+	log.Println("Waiting for SSH session to complete")
+	err = session.Wait()
+	log.Printf("scp stderr (length %d): %s", stderr.Len(), stderr.String())
+	return err
+}
+
+// ShellQuote quotes a string for the shell on the remote host, so that
+// paths with spaces or quotes in them can be used in commands.
+func ShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
 }
@@ -151,3 +151,10 @@ func TestStart(t *testing.T) {
 
 	client.Start(&cmd)
 }
+
+func TestShellQuote(t *testing.T) {
+	result := ShellQuote("/vmfs/volumes/datastore 1/it's here")
+	if result != `'/vmfs/volumes/datastore 1/it'\''s here'` {
+		t.Fatalf("bad: %s", result)
+	}
+}
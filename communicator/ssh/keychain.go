@@ -3,27 +3,68 @@ package ssh
 import (
 	"crypto"
 	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
+	"math/big"
 )
 
 type SimpleKeychain struct {
 	keys []interface{}
 }
 
-// AddPEMKey adds a simple PEM encoded private key to the keychain.
-func (k *SimpleKeychain) AddPEMKey(key string) (err error) {
+// AddPEMKey adds a simple PEM encoded private key to the keychain. RSA,
+// DSA and ECDSA keys are supported.
+func (k *SimpleKeychain) AddPEMKey(key string) error {
+	return k.AddPEMKeyPassword(key, "")
+}
+
+// AddPEMKeyPassword adds a PEM encoded private key to the keychain, like
+// AddPEMKey, decrypting it with the password if the key is encrypted.
+func (k *SimpleKeychain) AddPEMKeyPassword(key string, password string) error {
 	block, _ := pem.Decode([]byte(key))
-	rsakey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if block == nil {
+		return errors.New("no PEM encoded private key found")
+	}
+
+	der := block.Bytes
+	if x509.IsEncryptedPEMBlock(block) {
+		if password == "" {
+			return errors.New("private key is encrypted, but no passphrase was given")
+		}
+
+		var err error
+		der, err = x509.DecryptPEMBlock(block, []byte(password))
+		if err != nil {
+			return fmt.Errorf("error decrypting private key: %s", err)
+		}
+	}
+
+	var privateKey interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(der)
+	case "DSA PRIVATE KEY":
+		privateKey, err = parseDSAPrivateKey(der)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(der)
+	default:
+		err = fmt.Errorf("unsupported private key type: %s", block.Type)
+	}
+
 	if err != nil {
-		return
+		return err
 	}
 
-	k.keys = append(k.keys, rsakey)
-	return
+	k.keys = append(k.keys, privateKey)
+	return nil
 }
 
 // Key method for ssh.ClientKeyring interface
@@ -36,19 +77,96 @@ func (k *SimpleKeychain) Key(i int) (interface{}, error) {
 		return &key.PublicKey, nil
 	case *dsa.PrivateKey:
 		return &key.PublicKey, nil
+	case *ecdsa.PrivateKey:
+		return &key.PublicKey, nil
 	}
 	panic("unknown key type")
 }
 
 // Sign method for ssh.ClientKeyring interface
 func (k *SimpleKeychain) Sign(i int, rand io.Reader, data []byte) (sig []byte, err error) {
-	hashFunc := crypto.SHA1
-	h := hashFunc.New()
-	h.Write(data)
-	digest := h.Sum(nil)
 	switch key := k.keys[i].(type) {
 	case *rsa.PrivateKey:
-		return rsa.SignPKCS1v15(rand, key, hashFunc, digest)
+		return rsa.SignPKCS1v15(rand, key, crypto.SHA1, hash(crypto.SHA1, data))
+	case *dsa.PrivateKey:
+		r, s, err := dsa.Sign(rand, key, hash(crypto.SHA1, data))
+		if err != nil {
+			return nil, err
+		}
+
+		// ssh-dss signatures are r and s as 160-bit unsigned integers
+		sig := make([]byte, 40)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(sig[20-len(rb):20], rb)
+		copy(sig[40-len(sb):], sb)
+		return sig, nil
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand, key, hash(ecdsaHash(key.Curve), data))
+		if err != nil {
+			return nil, err
+		}
+
+		return append(marshalMPInt(r), marshalMPInt(s)...), nil
 	}
 	return nil, errors.New("ssh: unknown key type")
 }
+
+// parseDSAPrivateKey parses a DSA private key in the ASN.1 structure used
+// by OpenSSL, which the standard library has no parser for.
+func parseDSAPrivateKey(der []byte) (*dsa.PrivateKey, error) {
+	var raw struct {
+		Version       int
+		P, Q, G, Y, X *big.Int
+	}
+
+	rest, err := asn1.Unmarshal(der, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after DSA private key")
+	}
+
+	return &dsa.PrivateKey{
+		PublicKey: dsa.PublicKey{
+			Parameters: dsa.Parameters{P: raw.P, Q: raw.Q, G: raw.G},
+			Y:          raw.Y,
+		},
+		X: raw.X,
+	}, nil
+}
+
+// ecdsaHash returns the hash that RFC 5656 specifies for signing with a
+// key on the given curve.
+func ecdsaHash(curve elliptic.Curve) crypto.Hash {
+	bits := curve.Params().BitSize
+	switch {
+	case bits <= 256:
+		return crypto.SHA256
+	case bits <= 384:
+		return crypto.SHA384
+	}
+	return crypto.SHA512
+}
+
+func hash(hashFunc crypto.Hash, data []byte) []byte {
+	h := hashFunc.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// marshalMPInt encodes a non-negative integer as an SSH mpint.
+func marshalMPInt(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+
+	result := make([]byte, 4, 4+len(b))
+	result[0] = byte(len(b) >> 24)
+	result[1] = byte(len(b) >> 16)
+	result[2] = byte(len(b) >> 8)
+	result[3] = byte(len(b))
+	return append(result, b...)
+}
@@ -2,6 +2,14 @@ package ssh
 
 import (
 	"code.google.com/p/go.crypto/ssh"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
 	"testing"
 )
 
@@ -23,6 +31,96 @@ func TestAddPEMKey(t *testing.T) {
 	}
 }
 
+func TestAddPEMKey_DSA(t *testing.T) {
+	key := new(dsa.PrivateKey)
+	if err := dsa.GenerateParameters(&key.Parameters, rand.Reader, dsa.L1024N160); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := dsa.GenerateKey(key, rand.Reader); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	der, err := asn1.Marshal(struct {
+		Version       int
+		P, Q, G, Y, X *big.Int
+	}{0, key.P, key.Q, key.G, key.Y, key.X})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	k := &SimpleKeychain{}
+	err = k.AddPEMKey(string(pem.EncodeToMemory(&pem.Block{Type: "DSA PRIVATE KEY", Bytes: der})))
+	if err != nil {
+		t.Fatalf("error while adding key: %s", err)
+	}
+
+	if _, err := k.Sign(0, rand.Reader, []byte("foo")); err != nil {
+		t.Fatalf("error signing: %s", err)
+	}
+}
+
+func TestAddPEMKey_ECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	k := &SimpleKeychain{}
+	err = k.AddPEMKey(string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})))
+	if err != nil {
+		t.Fatalf("error while adding key: %s", err)
+	}
+
+	if _, ok := k.keys[0].(*ecdsa.PrivateKey); !ok {
+		t.Fatalf("bad key: %#v", k.keys[0])
+	}
+
+	if _, err := k.Sign(0, rand.Reader, []byte("foo")); err != nil {
+		t.Fatalf("error signing: %s", err)
+	}
+}
+
+func TestAddPEMKeyPassword(t *testing.T) {
+	block, _ := pem.Decode([]byte(testPrivateKey))
+	encrypted, err := x509.EncryptPEMBlock(
+		rand.Reader, block.Type, block.Bytes, []byte("foo"), x509.PEMCipherAES128)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	key := string(pem.EncodeToMemory(encrypted))
+
+	k := &SimpleKeychain{}
+	if err := k.AddPEMKey(key); err == nil {
+		t.Fatal("should error without a passphrase")
+	}
+
+	if err := k.AddPEMKeyPassword(key, "bar"); err == nil {
+		t.Fatal("should error with the wrong passphrase")
+	}
+
+	if err := k.AddPEMKeyPassword(key, "foo"); err != nil {
+		t.Fatalf("error while adding key: %s", err)
+	}
+
+	if len(k.keys) != 1 {
+		t.Fatalf("bad keys: %d", len(k.keys))
+	}
+}
+
+func TestAddPEMKey_Invalid(t *testing.T) {
+	k := &SimpleKeychain{}
+	if err := k.AddPEMKey("not a key"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
 func TestSimpleKeyChain_ImplementsClientkeyring(t *testing.T) {
 	var raw interface{}
 	raw = &SimpleKeychain{}
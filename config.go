@@ -7,8 +7,11 @@ import (
 	"github.com/mitchellh/packer/packer/plugin"
 	"io"
 	"log"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 )
 
 // This is the default, built-in configuration that ships with
@@ -20,13 +23,25 @@ const defaultConfig = `
 
 	"builders": {
 		"amazon-ebs": "packer-builder-amazon-ebs",
+		"amazon-instance": "packer-builder-amazon-instance",
 		"digitalocean": "packer-builder-digitalocean",
+		"docker": "packer-builder-docker",
+		"hyperv-iso": "packer-builder-hyperv-iso",
+		"lxc": "packer-builder-lxc",
+		"null": "packer-builder-null",
+		"openstack": "packer-builder-openstack",
+		"parallels-iso": "packer-builder-parallels-iso",
+		"qemu": "packer-builder-qemu",
 		"virtualbox": "packer-builder-virtualbox",
-		"vmware": "packer-builder-vmware"
+		"virtualbox-ovf": "packer-builder-virtualbox-ovf",
+		"vmware": "packer-builder-vmware",
+		"vmware-vmx": "packer-builder-vmware-vmx"
 	},
 
 	"commands": {
 		"build": "packer-command-build",
+		"fix": "packer-command-fix",
+		"inspect": "packer-command-inspect",
 		"validate": "packer-command-validate"
 	},
 
@@ -44,6 +59,7 @@ const defaultConfig = `
 type config struct {
 	PluginMinPort uint
 	PluginMaxPort uint
+	CacheDir      string `json:"cache_dir"`
 
 	Builders       map[string]string
 	Commands       map[string]string
@@ -58,6 +74,117 @@ func decodeConfig(r io.Reader, c *config) error {
 	return decoder.Decode(c)
 }
 
+// Discover finds plugins in the directory of the packer executable, the
+// current directory, and the plugins directory, in that order, and adds
+// them to the configuration. Plugins found later replace the ones found
+// earlier with the same name. The configuration file should be loaded
+// after this, so that the plugins it names win.
+func (c *config) Discover() error {
+	dirs := make([]string, 0, 3)
+
+	exePath, err := osext.Executable()
+	if err != nil {
+		log.Printf("Couldn't get current exe path, not discovering plugins there: %s", err)
+	} else {
+		dirs = append(dirs, filepath.Dir(exePath))
+	}
+
+	dirs = append(dirs, ".")
+
+	pluginDir, err := pluginsDir()
+	if err != nil {
+		log.Printf("Couldn't get plugins directory, not discovering plugins there: %s", err)
+	} else {
+		dirs = append(dirs, pluginDir)
+	}
+
+	for _, dir := range dirs {
+		if err := c.discover(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// discover adds the plugins in a single directory to the configuration.
+func (c *config) discover(dir string) error {
+	kinds := []struct {
+		prefix  string
+		plugins *map[string]string
+	}{
+		{"packer-builder-", &c.Builders},
+		{"packer-post-processor-", &c.PostProcessors},
+		{"packer-provisioner-", &c.Provisioners},
+	}
+
+	for _, kind := range kinds {
+		found, err := discoverPlugins(dir, kind.prefix)
+		if err != nil {
+			return err
+		}
+
+		if len(found) > 0 && *kind.plugins == nil {
+			*kind.plugins = make(map[string]string)
+		}
+
+		for name, path := range found {
+			log.Printf("Discovered plugin: %s = %s", name, path)
+			(*kind.plugins)[name] = path
+		}
+	}
+
+	return nil
+}
+
+// discoverPlugins returns the executables in dir whose names start with
+// prefix, keyed by the rest of their names. Files that can't be plugins
+// are skipped with a warning in the log.
+func discoverPlugins(dir, prefix string) (map[string]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, prefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for _, match := range matches {
+		name := strings.TrimPrefix(filepath.Base(match), prefix)
+		if runtime.GOOS == "windows" {
+			if !strings.HasSuffix(strings.ToLower(name), ".exe") {
+				continue
+			}
+
+			name = name[:len(name)-len(".exe")]
+		}
+
+		if name == "" {
+			log.Printf("[WARN] Plugin has no name, ignoring: %s", match)
+			continue
+		}
+
+		fi, err := os.Stat(match)
+		if err != nil {
+			log.Printf("[WARN] Error checking plugin, ignoring: %s", err)
+			continue
+		}
+
+		if fi.IsDir() || (runtime.GOOS != "windows" && fi.Mode().Perm()&0111 == 0) {
+			log.Printf("[WARN] Plugin isn't an executable, ignoring: %s", match)
+			continue
+		}
+
+		path, err := filepath.Abs(match)
+		if err != nil {
+			log.Printf("[WARN] Error finding plugin path, ignoring: %s", err)
+			continue
+		}
+
+		result[name] = path
+	}
+
+	return result, nil
+}
+
 // Returns an array of defined command names.
 func (c *config) CommandNames() (result []string) {
 	result = make([]string, 0, len(c.Commands))
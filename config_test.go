@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfig_discover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]os.FileMode{
+		"packer-builder-foo":        0755,
+		"packer-post-processor-bar": 0755,
+		"packer-provisioner-baz":    0755,
+		"packer-provisioner-noexec": 0644,
+		"packer-builder-":           0755,
+		"not-a-plugin":              0755,
+	}
+
+	for name, mode := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("foo"), mode); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	c := &config{
+		Builders: map[string]string{"foo": "original", "other": "other"},
+	}
+	if err := c.discover(dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"foo":   filepath.Join(dir, "packer-builder-foo"),
+		"other": "other",
+	}
+	if !reflect.DeepEqual(c.Builders, expected) {
+		t.Fatalf("bad: %#v", c.Builders)
+	}
+
+	expected = map[string]string{
+		"bar": filepath.Join(dir, "packer-post-processor-bar"),
+	}
+	if !reflect.DeepEqual(c.PostProcessors, expected) {
+		t.Fatalf("bad: %#v", c.PostProcessors)
+	}
+
+	expected = map[string]string{
+		"baz": filepath.Join(dir, "packer-provisioner-baz"),
+	}
+	if !reflect.DeepEqual(c.Provisioners, expected) {
+		t.Fatalf("bad: %#v", c.Provisioners)
+	}
+}
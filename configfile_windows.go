@@ -24,6 +24,15 @@ func configFile() (string, error) {
 	return filepath.Join(dir, "packer.config"), nil
 }
 
+func pluginsDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "packer.d", "plugins"), nil
+}
+
 func configDir() (string, error) {
 	b := make([]uint16, syscall.MAX_PATH)
 
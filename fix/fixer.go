@@ -0,0 +1,50 @@
+// The fix package contains the fixers that upgrade templates written for
+// older versions of Packer, such as by renaming deprecated configuration
+// keys. Each fixer transforms the raw JSON of the template.
+
+package fix
+
+// A Fixer is something that can perform a fix operation on a template.
+type Fixer interface {
+	// Fix takes a raw map structure input, potentially transforms it
+	// in some way, and returns the new, transformed structure. The
+	// Fix method is allowed to mutate the input. Keys that the fixer
+	// doesn't know about must be left as they are.
+	Fix(input map[string]interface{}) (map[string]interface{}, error)
+}
+
+// Fixers is the map of all available fixers, by name.
+var Fixers map[string]Fixer
+
+// FixerOrder is the default order the fixers should run.
+var FixerOrder []string
+
+func init() {
+	Fixers = map[string]Fixer{
+		"digitalocean-ids": new(FixerDigitalOceanIds),
+		"iso-md5":          new(FixerISOMD5),
+	}
+
+	FixerOrder = []string{
+		"iso-md5",
+		"digitalocean-ids",
+	}
+}
+
+// builders returns the builder configurations of the template, skipping
+// any that aren't objects, which the template parser reports.
+func builders(input map[string]interface{}) []map[string]interface{} {
+	raw, ok := input["builders"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	result := make([]map[string]interface{}, 0, len(raw))
+	for _, v := range raw {
+		if builder, ok := v.(map[string]interface{}); ok {
+			result = append(result, builder)
+		}
+	}
+
+	return result
+}
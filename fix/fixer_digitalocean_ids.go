@@ -0,0 +1,43 @@
+package fix
+
+import (
+	"fmt"
+)
+
+// FixerDigitalOceanIds is a Fixer that replaces the deprecated
+// "region_id", "size_id" and "image_id" keys of DigitalOcean builders
+// with "region", "size" and "image", which take the ID as a string.
+type FixerDigitalOceanIds struct{}
+
+func (FixerDigitalOceanIds) Fix(input map[string]interface{}) (map[string]interface{}, error) {
+	for _, builder := range builders(input) {
+		if builder["type"] != "digitalocean" {
+			continue
+		}
+
+		for _, key := range []string{"region", "size", "image"} {
+			id, ok := builder[key+"_id"]
+			if !ok {
+				continue
+			}
+
+			// Having both is an error the builder reports, so don't
+			// hide it by picking one.
+			if _, ok := builder[key]; ok {
+				continue
+			}
+
+			// JSON numbers are decoded as floats, so format them as
+			// integers.
+			value := fmt.Sprintf("%v", id)
+			if f, ok := id.(float64); ok {
+				value = fmt.Sprintf("%d", int64(f))
+			}
+
+			delete(builder, key+"_id")
+			builder[key] = value
+		}
+	}
+
+	return input, nil
+}
@@ -0,0 +1,83 @@
+package fix
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFixerDigitalOceanIds_Impl(t *testing.T) {
+	var _ Fixer = new(FixerDigitalOceanIds)
+}
+
+func TestFixerDigitalOceanIds_Fix(t *testing.T) {
+	cases := []struct {
+		Input    map[string]interface{}
+		Expected map[string]interface{}
+	}{
+		// Replaced, with JSON numbers as integers
+		{
+			Input: map[string]interface{}{
+				"type":      "digitalocean",
+				"region_id": float64(1),
+				"size_id":   float64(66),
+				"image_id":  "284203",
+			},
+
+			Expected: map[string]interface{}{
+				"type":   "digitalocean",
+				"region": "1",
+				"size":   "66",
+				"image":  "284203",
+			},
+		},
+
+		// Both given are left for the builder to report
+		{
+			Input: map[string]interface{}{
+				"type":      "digitalocean",
+				"region":    "nyc1",
+				"region_id": float64(1),
+			},
+
+			Expected: map[string]interface{}{
+				"type":      "digitalocean",
+				"region":    "nyc1",
+				"region_id": float64(1),
+			},
+		},
+
+		// Other builders are untouched
+		{
+			Input: map[string]interface{}{
+				"type":      "openstack",
+				"region_id": float64(1),
+			},
+
+			Expected: map[string]interface{}{
+				"type":      "openstack",
+				"region_id": float64(1),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		var f FixerDigitalOceanIds
+
+		input := map[string]interface{}{
+			"builders": []interface{}{tc.Input},
+		}
+
+		expected := map[string]interface{}{
+			"builders": []interface{}{tc.Expected},
+		}
+
+		output, err := f.Fix(input)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if !reflect.DeepEqual(output, expected) {
+			t.Fatalf("unexpected: %#v\nexpected: %#v\n", output, expected)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package fix
+
+// FixerISOMD5 is a Fixer that replaces the "iso_md5" configuration key
+// of VirtualBox builders with "iso_checksum" and "iso_checksum_type".
+// The VMware builder still uses "iso_md5", so it is left alone.
+type FixerISOMD5 struct{}
+
+func (FixerISOMD5) Fix(input map[string]interface{}) (map[string]interface{}, error) {
+	for _, builder := range builders(input) {
+		if builder["type"] != "virtualbox" {
+			continue
+		}
+
+		md5, ok := builder["iso_md5"]
+		if !ok {
+			continue
+		}
+
+		// An explicit checksum wins over iso_md5, the same as when the
+		// builder reads the template.
+		delete(builder, "iso_md5")
+		if _, ok := builder["iso_checksum"]; ok {
+			continue
+		}
+
+		builder["iso_checksum"] = md5
+		if _, ok := builder["iso_checksum_type"]; !ok {
+			builder["iso_checksum_type"] = "md5"
+		}
+	}
+
+	return input, nil
+}
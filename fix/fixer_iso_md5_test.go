@@ -0,0 +1,85 @@
+package fix
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFixerISOMD5_Impl(t *testing.T) {
+	var _ Fixer = new(FixerISOMD5)
+}
+
+func TestFixerISOMD5_Fix(t *testing.T) {
+	cases := []struct {
+		Input    map[string]interface{}
+		Expected map[string]interface{}
+	}{
+		// Replaced
+		{
+			Input: map[string]interface{}{
+				"type":    "virtualbox",
+				"iso_md5": "foo",
+			},
+
+			Expected: map[string]interface{}{
+				"type":              "virtualbox",
+				"iso_checksum":      "foo",
+				"iso_checksum_type": "md5",
+			},
+		},
+
+		// An existing checksum wins
+		{
+			Input: map[string]interface{}{
+				"type":              "virtualbox",
+				"iso_md5":           "foo",
+				"iso_checksum":      "bar",
+				"iso_checksum_type": "sha256",
+			},
+
+			Expected: map[string]interface{}{
+				"type":              "virtualbox",
+				"iso_checksum":      "bar",
+				"iso_checksum_type": "sha256",
+			},
+		},
+
+		// Other builders and keys are untouched
+		{
+			Input: map[string]interface{}{
+				"type":    "vmware",
+				"iso_md5": "foo",
+				"unknown": "bar",
+			},
+
+			Expected: map[string]interface{}{
+				"type":    "vmware",
+				"iso_md5": "foo",
+				"unknown": "bar",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		var f FixerISOMD5
+
+		input := map[string]interface{}{
+			"builders":     []interface{}{tc.Input},
+			"provisioners": "untouched",
+		}
+
+		expected := map[string]interface{}{
+			"builders":     []interface{}{tc.Expected},
+			"provisioners": "untouched",
+		}
+
+		output, err := f.Fix(input)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if !reflect.DeepEqual(output, expected) {
+			t.Fatalf("unexpected: %#v\nexpected: %#v\n", output, expected)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package fix
+
+import (
+	"testing"
+)
+
+func TestFixers(t *testing.T) {
+	// Every fixer in the order exists, and every fixer is in the order
+	if len(FixerOrder) != len(Fixers) {
+		t.Fatalf("bad: %#v %#v", FixerOrder, Fixers)
+	}
+
+	for _, name := range FixerOrder {
+		if _, ok := Fixers[name]; !ok {
+			t.Fatalf("fixer not found: %s", name)
+		}
+	}
+}
@@ -11,12 +11,22 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 func main() {
 	if os.Getenv("PACKER_LOG") == "" {
 		// If we don't have logging explicitly enabled, then disable it
 		log.SetOutput(ioutil.Discard)
+	} else if logPath := os.Getenv("PACKER_LOG_PATH"); logPath != "" {
+		// Logging is enabled, and should go to the given file
+		f, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't open log file: %s\n", err)
+			os.Exit(1)
+		}
+
+		log.SetOutput(f)
 	} else {
 		// Logging is enabled, make sure it goes to stderr
 		log.SetOutput(os.Stderr)
@@ -38,7 +48,13 @@ func main() {
 
 	log.Printf("Packer config: %+v", config)
 
+	// The environment variable takes precedence over the configuration,
+	// so that a single run can use a different cache.
 	cacheDir := os.Getenv("PACKER_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = config.CacheDir
+	}
+
 	if cacheDir == "" {
 		cacheDir = "packer_cache"
 	}
@@ -59,6 +75,16 @@ func main() {
 
 	defer plugin.CleanupClients()
 
+	// Determine if we're in machine-readable mode by looking for the
+	// flag before the command, and strip it so the CLI never sees it.
+	args, machineReadable := extractMachineReadable(os.Args[1:])
+
+	// The commands run as plugins and can't see our stdout, so tell them
+	// through the environment when it isn't a terminal to color.
+	if !isTerminal(os.Stdout) {
+		os.Setenv("PACKER_NO_COLOR", "1")
+	}
+
 	envConfig := packer.DefaultEnvironmentConfig()
 	envConfig.Cache = cache
 	envConfig.Commands = config.CommandNames()
@@ -68,6 +94,16 @@ func main() {
 	envConfig.Components.PostProcessor = config.LoadPostProcessor
 	envConfig.Components.Provisioner = config.LoadProvisioner
 
+	if machineReadable {
+		envConfig.Ui = &packer.MachineReadableUi{
+			Writer: os.Stdout,
+		}
+
+		// Commands can't ask the Ui what it is over RPC, so let them know
+		// through the environment.
+		os.Setenv("PACKER_MACHINE_READABLE", "1")
+	}
+
 	env, err := packer.NewEnvironment(envConfig)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Packer initialization error: \n\n%s\n", err)
@@ -76,7 +112,7 @@ func main() {
 
 	setupSignalHandlers(env)
 
-	exitCode, err := env.Cli(os.Args[1:])
+	exitCode, err := env.Cli(args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error executing CLI: %s\n", err.Error())
 		os.Exit(1)
@@ -86,12 +122,50 @@ func main() {
 	os.Exit(exitCode)
 }
 
+// extractMachineReadable checks the args for the machine-readable flag
+// and returns the args without it, as well as whether it was set.
+func extractMachineReadable(args []string) ([]string, bool) {
+	for i, arg := range args {
+		if arg == "-machine-readable" || arg == "--machine-readable" {
+			// Create a new slice so we don't modify the one we were given
+			result := make([]string, 0, len(args)-1)
+			result = append(result, args[:i]...)
+			result = append(result, args[i+1:]...)
+			return result, true
+		}
+
+		// The flag is only global if it comes before the command
+		if !strings.HasPrefix(arg, "-") {
+			break
+		}
+	}
+
+	return args, false
+}
+
+// isTerminal returns whether the given file is a terminal rather than a
+// pipe or regular file.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
 func loadConfig() (*config, error) {
 	var config config
 	if err := decodeConfig(bytes.NewBufferString(defaultConfig), &config); err != nil {
 		return nil, err
 	}
 
+	// Discovered plugins replace the built-in ones, but the plugins named
+	// in the configuration file replace both.
+	if err := config.Discover(); err != nil {
+		return nil, err
+	}
+
 	mustExist := true
 	configFilePath := os.Getenv("PACKER_CONFIG")
 	if configFilePath == "" {
@@ -3,6 +3,7 @@ package packer
 import (
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 )
 
@@ -14,6 +15,24 @@ const BuildNameConfigKey = "packer_build_name"
 // debugging is enabled.
 const DebugConfigKey = "packer_debug"
 
+// This is the key in configurations that is set to "true" when Packer
+// should force a build, replacing the artifacts of prior builds.
+const ForceConfigKey = "packer_force"
+
+// This is the key in configurations that is set to what should happen
+// when a step of a build fails: "cleanup", "abort", or "ask".
+const OnErrorConfigKey = "packer_on_error"
+
+// This is the key in configurations that is set to the time the template
+// was loaded, in unix seconds. It is the same for every component of every
+// build, so that "{{timestamp}}" gives the same value everywhere.
+const TemplateTimestampConfigKey = "packer_template_timestamp"
+
+// This is the key in configurations that is set to the absolute path of
+// the template file, if the build came from one, so that components can
+// resolve relative paths against the directory of the template.
+const TemplatePathConfigKey = "packer_template_path"
+
 // A Build represents a single job within Packer that is responsible for
 // building some machine image artifact. Builds are meant to be parallelized.
 type Build interface {
@@ -42,6 +61,24 @@ type Build interface {
 	// When SetDebug is set to true, parallelism between builds is
 	// strictly prohibited.
 	SetDebug(bool)
+
+	// Warnings returns the warnings found while preparing the build, such
+	// as configuration that uses environmental variables that aren't set.
+	// There are none until Prepare is called.
+	Warnings() []string
+
+	// SetForce will enable/disable forcing a build. Forcing is always
+	// enabled by adding the additional key "packer_force" to boolean
+	// true in the configuration of the various components. What forcing
+	// means is up to each component, but usually it replaces artifacts
+	// left by prior builds. This must be called prior to Prepare.
+	SetForce(bool)
+
+	// SetOnError sets what should happen when a step of the build fails,
+	// which is given to the components as "packer_on_error". It is one
+	// of "cleanup", the default, "abort", or "ask". This must be called
+	// prior to Prepare.
+	SetOnError(string)
 }
 
 // A build struct represents a single build job, the result of which should
@@ -56,10 +93,15 @@ type coreBuild struct {
 	hooks          map[string][]Hook
 	postProcessors [][]coreBuildPostProcessor
 	provisioners   []coreBuildProvisioner
+	timestamp      int64
+	templatePath   string
 
 	debug         bool
+	force         bool
+	onError       string
 	l             sync.Mutex
 	prepareCalled bool
+	warnings      []string
 }
 
 // Keeps track of the post-processor and the configuration of the
@@ -84,8 +126,10 @@ func (b *coreBuild) Name() string {
 }
 
 // Prepare prepares the build by doing some initialization for the builder
-// and any hooks. This _must_ be called prior to Run.
-func (b *coreBuild) Prepare() (err error) {
+// and any hooks. This _must_ be called prior to Run. Every component is
+// prepared even if an earlier one fails, so that all of the errors are
+// reported at once.
+func (b *coreBuild) Prepare() error {
 	b.l.Lock()
 	defer b.l.Unlock()
 
@@ -96,39 +140,67 @@ func (b *coreBuild) Prepare() (err error) {
 	b.prepareCalled = true
 
 	packerConfig := map[string]interface{}{
-		BuildNameConfigKey: b.name,
-		DebugConfigKey:     b.debug,
+		BuildNameConfigKey:         b.name,
+		DebugConfigKey:             b.debug,
+		ForceConfigKey:             b.force,
+		OnErrorConfigKey:           b.onError,
+		TemplatePathConfigKey:      b.templatePath,
+		TemplateTimestampConfigKey: b.timestamp,
 	}
 
+	errs := make([]error, 0)
+
+	// Environmental variables are expanded for every component here, so
+	// that they don't each have to support them.
+	missingEnv := make(map[string]bool)
+
 	// Prepare the builder
-	err = b.builder.Prepare(b.builderConfig, packerConfig)
-	if err != nil {
+	builderConfig := interpolateEnv(b.builderConfig, missingEnv)
+	if err := b.builder.Prepare(builderConfig, packerConfig); err != nil {
 		log.Printf("Build '%s' prepare failure: %s\n", b.name, err)
-		return
+		errs = appendErrors(errs, err)
 	}
 
 	// Prepare the provisioners
 	for _, coreProv := range b.provisioners {
 		configs := make([]interface{}, len(coreProv.config), len(coreProv.config)+1)
-		copy(configs, coreProv.config)
+		for i, config := range coreProv.config {
+			configs[i] = interpolateEnv(config, missingEnv)
+		}
 		configs = append(configs, packerConfig)
 
-		if err = coreProv.provisioner.Prepare(configs...); err != nil {
-			return
+		if err := coreProv.provisioner.Prepare(configs...); err != nil {
+			errs = appendErrors(errs, err)
 		}
 	}
 
 	// Prepare the post-processors
 	for _, ppSeq := range b.postProcessors {
 		for _, corePP := range ppSeq {
-			err = corePP.processor.Configure(corePP.config, packerConfig)
+			config := interpolateEnv(corePP.config, missingEnv)
+			err := corePP.processor.Configure(config, packerConfig)
 			if err != nil {
-				return
+				errs = appendErrors(errs, err)
 			}
 		}
 	}
 
-	return
+	names := make([]string, 0, len(missingEnv))
+	for name := range missingEnv {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		b.warnings = append(b.warnings, fmt.Sprintf(
+			"Environmental variable '%s' isn't set, so it is empty.", name))
+	}
+
+	if len(errs) > 0 {
+		return &MultiError{errs}
+	}
+
+	return nil
 }
 
 // Runs the actual build. Prepare must be called prior to running this.
@@ -183,9 +255,10 @@ func (b *coreBuild) Run(originalUi Ui, cache Cache) ([]Artifact, error) {
 	errors := make([]error, 0)
 	keepOriginalArtifact := len(b.postProcessors) == 0
 
-	// Run the post-processors
-PostProcessorRunSeqLoop:
-	for _, ppSeq := range b.postProcessors {
+	// Run the post-processors. Within a sequence, an input artifact is only
+	// kept if the post-processor or its configuration asks for it, so only
+	// the last artifact of each sequence and the kept ones survive.
+	for seqI, ppSeq := range b.postProcessors {
 		priorArtifact := builderArtifact
 		for i, corePP := range ppSeq {
 			ppUi := &PrefixedUi{
@@ -197,13 +270,19 @@ PostProcessorRunSeqLoop:
 			builderUi.Say(fmt.Sprintf("Running post-processor: %s", corePP.processorType))
 			artifact, keep, err := corePP.processor.PostProcess(ppUi, priorArtifact)
 			if err != nil {
-				errors = append(errors, fmt.Errorf("Post-processor failed: %s", err))
-				continue PostProcessorRunSeqLoop
-			}
-
-			if artifact == nil {
+				// Say which post-processor of which sequence failed, since
+				// the same type may be used in more than one place.
+				errors = append(errors, fmt.Errorf(
+					"Post-processor %d.%d (%s) failed: %s",
+					seqI+1, i+1, corePP.processorType, err))
+
+				// The input of a failed post-processor is always kept,
+				// since it is all that is left of this stage. For the
+				// first post-processor that is the builder's artifact.
+				artifact = nil
+				keep = true
+			} else if artifact == nil {
 				log.Println("Nil artifact, halting post-processor chain.")
-				continue PostProcessorRunSeqLoop
 			}
 
 			keep = keep || corePP.keepInputArtifact
@@ -230,7 +309,11 @@ PostProcessorRunSeqLoop:
 				}
 			}
 
+			// The sequence halts without an artifact to pass along
 			priorArtifact = artifact
+			if priorArtifact == nil {
+				break
+			}
 		}
 
 		// Add on the last artifact to the results
@@ -265,6 +348,26 @@ func (b *coreBuild) SetDebug(val bool) {
 	b.debug = val
 }
 
+func (b *coreBuild) Warnings() []string {
+	return b.warnings
+}
+
+func (b *coreBuild) SetForce(val bool) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.force = val
+}
+
+func (b *coreBuild) SetOnError(val string) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.onError = val
+}
+
 // Cancels the build if it is running.
 func (b *coreBuild) Cancel() {
 	b.builder.Cancel()
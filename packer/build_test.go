@@ -2,7 +2,10 @@ package packer
 
 import (
 	"cgl.tideland.biz/asserts"
+	"errors"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -22,6 +25,8 @@ func testBuild() *coreBuild {
 				coreBuildPostProcessor{&TestPostProcessor{artifactId: "pp"}, "testPP", 42, true},
 			},
 		},
+		timestamp:    1373000000,
+		templatePath: "/tmp/template.json",
 	}
 }
 
@@ -40,8 +45,12 @@ func TestBuild_Prepare(t *testing.T) {
 	assert := asserts.NewTestingAsserts(t, true)
 
 	packerConfig := map[string]interface{}{
-		BuildNameConfigKey: "test",
-		DebugConfigKey:     false,
+		BuildNameConfigKey:         "test",
+		DebugConfigKey:             false,
+		ForceConfigKey:             false,
+		OnErrorConfigKey:           "",
+		TemplatePathConfigKey:      "/tmp/template.json",
+		TemplateTimestampConfigKey: int64(1373000000),
 	}
 
 	build := testBuild()
@@ -62,6 +71,87 @@ func TestBuild_Prepare(t *testing.T) {
 	assert.Equal(pp.configVal, []interface{}{42, packerConfig}, "config should have right value")
 }
 
+func TestBuild_Prepare_Env(t *testing.T) {
+	defer os.Setenv("PACKER_TEST_ENV", os.Getenv("PACKER_TEST_ENV"))
+	defer os.Setenv("PACKER_TEST_ENV_EMPTY", os.Getenv("PACKER_TEST_ENV_EMPTY"))
+	os.Setenv("PACKER_TEST_ENV", "bar")
+	os.Setenv("PACKER_TEST_ENV_EMPTY", "")
+
+	build := testBuild()
+	build.builderConfig = map[string]interface{}{
+		"foo":     "{{env `PACKER_TEST_ENV`}}",
+		"empty":   "a{{env `PACKER_TEST_ENV_EMPTY`}}b",
+		"unset":   "a{{env `PACKER_TEST_ENV_UNSET`}}b",
+		"nested":  "{{printf \"%s\" (env `PACKER_TEST_ENV`)}}-{{timestamp}}",
+		"escaped": "{{\"{{env `PACKER_TEST_ENV_ESCAPED`}}\"}}",
+		"invalid": "{{env `PACKER_TEST_ENV_INVALID`}}{{",
+	}
+	build.provisioners[0].config = []interface{}{
+		map[string]interface{}{"foo": []interface{}{"{{ env \"PACKER_TEST_ENV\" }}"}},
+	}
+
+	if warnings := build.Warnings(); len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+
+	if err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	builderConfig := build.builder.(*TestBuilder).prepareConfig[0].(map[string]interface{})
+	expected := map[string]interface{}{
+		"foo":     "bar",
+		"empty":   "ab",
+		"unset":   "ab",
+		"nested":  "{{printf \"%s\" (\"bar\")}}-{{timestamp}}",
+		"escaped": "{{\"{{env `PACKER_TEST_ENV_ESCAPED`}}\"}}",
+		"invalid": "{{env `PACKER_TEST_ENV_INVALID`}}{{",
+	}
+	if !reflect.DeepEqual(builderConfig, expected) {
+		t.Fatalf("bad: %#v", builderConfig)
+	}
+
+	provConfig := build.provisioners[0].provisioner.(*TestProvisioner).prepConfigs[0].(map[string]interface{})
+	if provConfig["foo"].([]interface{})[0] != "bar" {
+		t.Fatalf("bad: %#v", provConfig)
+	}
+
+	// The template's configuration shouldn't change
+	if build.builderConfig.(map[string]interface{})["foo"] != "{{env `PACKER_TEST_ENV`}}" {
+		t.Fatalf("bad: %#v", build.builderConfig)
+	}
+
+	// Only the variable that isn't set at all is a warning
+	warnings := build.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "PACKER_TEST_ENV_UNSET") {
+		t.Fatalf("bad: %#v", warnings)
+	}
+}
+
+func TestBuild_Prepare_Errors(t *testing.T) {
+	build := testBuild()
+	build.builder.(*TestBuilder).prepareErr = &MultiError{
+		[]error{errors.New("builder 1"), errors.New("builder 2")},
+	}
+	build.provisioners[0].provisioner.(*TestProvisioner).prepErr = errors.New("provisioner")
+	build.postProcessors[0][0].processor.(*TestPostProcessor).configErr = errors.New("post-processor")
+
+	err := build.Prepare()
+	merr, ok := err.(*MultiError)
+	if !ok {
+		t.Fatalf("should be a MultiError: %#v", err)
+	}
+
+	// Every component is prepared, and the errors are flattened
+	if len(merr.Errors) != 4 {
+		t.Fatalf("bad: %#v", merr.Errors)
+	}
+
+	if merr.Errors[1].Error() != "builder 2" || merr.Errors[3].Error() != "post-processor" {
+		t.Fatalf("bad: %#v", merr.Errors)
+	}
+}
+
 func TestBuild_Prepare_Twice(t *testing.T) {
 	build := testBuild()
 	if err := build.Prepare(); err != nil {
@@ -86,8 +176,12 @@ func TestBuild_Prepare_Debug(t *testing.T) {
 	assert := asserts.NewTestingAsserts(t, true)
 
 	packerConfig := map[string]interface{}{
-		BuildNameConfigKey: "test",
-		DebugConfigKey:     true,
+		BuildNameConfigKey:         "test",
+		DebugConfigKey:             true,
+		ForceConfigKey:             false,
+		OnErrorConfigKey:           "",
+		TemplatePathConfigKey:      "/tmp/template.json",
+		TemplateTimestampConfigKey: int64(1373000000),
 	}
 
 	build := testBuild()
@@ -104,6 +198,53 @@ func TestBuild_Prepare_Debug(t *testing.T) {
 	assert.Equal(prov.prepConfigs, []interface{}{42, packerConfig}, "prepare should be called with proper config")
 }
 
+func TestBuild_Prepare_Force(t *testing.T) {
+	assert := asserts.NewTestingAsserts(t, true)
+
+	packerConfig := map[string]interface{}{
+		BuildNameConfigKey:         "test",
+		DebugConfigKey:             false,
+		ForceConfigKey:             true,
+		OnErrorConfigKey:           "",
+		TemplatePathConfigKey:      "/tmp/template.json",
+		TemplateTimestampConfigKey: int64(1373000000),
+	}
+
+	build := testBuild()
+	builder := build.builder.(*TestBuilder)
+
+	build.SetForce(true)
+	build.Prepare()
+	assert.True(builder.prepareCalled, "prepare should be called")
+	assert.Equal(builder.prepareConfig, []interface{}{42, packerConfig}, "prepare config should be 42")
+
+	coreProv := build.provisioners[0]
+	prov := coreProv.provisioner.(*TestProvisioner)
+	assert.True(prov.prepCalled, "prepare should be called")
+	assert.Equal(prov.prepConfigs, []interface{}{42, packerConfig}, "prepare should be called with proper config")
+}
+
+func TestBuild_Prepare_OnError(t *testing.T) {
+	assert := asserts.NewTestingAsserts(t, true)
+
+	packerConfig := map[string]interface{}{
+		BuildNameConfigKey:         "test",
+		DebugConfigKey:             false,
+		ForceConfigKey:             false,
+		OnErrorConfigKey:           "abort",
+		TemplatePathConfigKey:      "/tmp/template.json",
+		TemplateTimestampConfigKey: int64(1373000000),
+	}
+
+	build := testBuild()
+	builder := build.builder.(*TestBuilder)
+
+	build.SetOnError("abort")
+	build.Prepare()
+	assert.True(builder.prepareCalled, "prepare should be called")
+	assert.Equal(builder.prepareConfig, []interface{}{42, packerConfig}, "prepare config should be 42")
+}
+
 func TestBuild_Run(t *testing.T) {
 	assert := asserts.NewTestingAsserts(t, true)
 
@@ -274,6 +415,88 @@ func TestBuild_Run_Artifacts(t *testing.T) {
 	}
 }
 
+func TestBuild_Run_PostProcessorErrors(t *testing.T) {
+	cache := &TestCache{}
+	ui := testUi()
+
+	// Test case: Test that a failing post-processor in a sequence keeps
+	// the intermediary it was given.
+	ppFail := &TestPostProcessor{ppErr: errors.New("bad")}
+	build := testBuild()
+	build.postProcessors = [][]coreBuildPostProcessor{
+		[]coreBuildPostProcessor{
+			coreBuildPostProcessor{&TestPostProcessor{artifactId: "pp1a"}, "pp", 42, false},
+			coreBuildPostProcessor{ppFail, "pp", 42, false},
+		},
+	}
+
+	build.Prepare()
+	artifacts, err := build.Run(ui, cache)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if len(artifacts) != 1 || artifacts[0].Id() != "pp1a" {
+		t.Fatalf("bad: %#v", artifacts)
+	}
+
+	if ppFail.ppArtifact.(*TestArtifact).destroyCalled {
+		t.Fatal("intermediary artifact should not be destroyed")
+	}
+
+	// Test case: Test that a failing first post-processor keeps the
+	// original artifact, even if it isn't configured to keep its input.
+	ppFail = &TestPostProcessor{ppErr: errors.New("bad")}
+	build = testBuild()
+	build.postProcessors = [][]coreBuildPostProcessor{
+		[]coreBuildPostProcessor{
+			coreBuildPostProcessor{ppFail, "pp", 42, false},
+		},
+	}
+
+	build.Prepare()
+	artifacts, err = build.Run(ui, cache)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if len(artifacts) != 1 || artifacts[0].Id() != "b" {
+		t.Fatalf("bad: %#v", artifacts)
+	}
+
+	if ppFail.ppArtifact.(*TestArtifact).destroyCalled {
+		t.Fatal("original artifact should not be destroyed")
+	}
+
+	// Test case: Test that a failing sequence doesn't stop its siblings,
+	// and that the error says which post-processor failed.
+	ppFail = &TestPostProcessor{ppErr: errors.New("bad")}
+	build = testBuild()
+	build.postProcessors = [][]coreBuildPostProcessor{
+		[]coreBuildPostProcessor{
+			coreBuildPostProcessor{&TestPostProcessor{artifactId: "pp1a"}, "pp", 42, false},
+			coreBuildPostProcessor{ppFail, "fail", 42, false},
+		},
+		[]coreBuildPostProcessor{
+			coreBuildPostProcessor{&TestPostProcessor{artifactId: "pp2a"}, "pp", 42, false},
+		},
+	}
+
+	build.Prepare()
+	artifacts, err = build.Run(ui, cache)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	if !strings.Contains(err.Error(), "Post-processor 1.2 (fail) failed: bad") {
+		t.Fatalf("bad error: %s", err)
+	}
+
+	if len(artifacts) != 2 || artifacts[0].Id() != "pp1a" || artifacts[1].Id() != "pp2a" {
+		t.Fatalf("bad: %#v", artifacts)
+	}
+}
+
 func TestBuild_RunBeforePrepare(t *testing.T) {
 	assert := asserts.NewTestingAsserts(t, true)
 
@@ -3,6 +3,8 @@ package packer
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"log"
+	"os"
 	"path/filepath"
 	"sync"
 )
@@ -33,10 +35,15 @@ type Cache interface {
 
 // FileCache implements a Cache by caching the data directly to a cache
 // directory.
+//
+// While a key is locked for writing, FileCache also holds a lock on a
+// ".lock" file next to it, so that other Packer processes sharing the
+// cache directory wait rather than writing the same file at once.
 type FileCache struct {
 	CacheDir string
 	l        sync.Mutex
 	rw       map[string]*sync.RWMutex
+	files    map[string]*os.File
 }
 
 func (f *FileCache) Lock(key string) string {
@@ -44,11 +51,41 @@ func (f *FileCache) Lock(key string) string {
 	rw := f.rwLock(hashKey)
 	rw.Lock()
 
-	return filepath.Join(f.CacheDir, hashKey)
+	path := filepath.Join(f.CacheDir, hashKey)
+	lockPath := path + ".lock"
+	log.Printf("Acquiring cache lock: %s", lockPath)
+	file, err := LockFile(lockPath)
+	if err != nil {
+		// The lock within this process is still held, so this only
+		// loses the protection against other processes.
+		log.Printf("[WARN] Failed to lock cache file %s: %s", lockPath, err)
+	} else {
+		f.l.Lock()
+		if f.files == nil {
+			f.files = make(map[string]*os.File)
+		}
+
+		f.files[hashKey] = file
+		f.l.Unlock()
+	}
+
+	return path
 }
 
 func (f *FileCache) Unlock(key string) {
 	hashKey := f.hashKey(key)
+
+	f.l.Lock()
+	file, ok := f.files[hashKey]
+	delete(f.files, hashKey)
+	f.l.Unlock()
+
+	if ok {
+		if err := UnlockFile(file); err != nil {
+			log.Printf("[WARN] Failed to unlock cache file %s: %s", file.Name(), err)
+		}
+	}
+
 	rw := f.rwLock(hashKey)
 	rw.Unlock()
 }
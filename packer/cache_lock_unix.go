@@ -0,0 +1,31 @@
+// +build darwin freebsd linux netbsd openbsd
+
+package packer
+
+import (
+	"os"
+	"syscall"
+)
+
+// LockFile opens the file at the given path, creating it if it doesn't
+// exist, and takes an exclusive lock on it, blocking until it is available.
+// The lock is released by the OS if the process exits without unlocking.
+func LockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// UnlockFile releases a lock taken with LockFile and closes the file.
+func UnlockFile(f *os.File) error {
+	defer f.Close()
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
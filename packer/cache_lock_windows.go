@@ -0,0 +1,19 @@
+// +build windows
+
+package packer
+
+import (
+	"os"
+)
+
+// LockFile opens the file at the given path, creating it if it doesn't
+// exist. Windows has no flock, so the file isn't locked and other Packer
+// processes aren't kept out.
+func LockFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+}
+
+// UnlockFile closes a file opened with LockFile.
+func UnlockFile(f *os.File) error {
+	return f.Close()
+}
@@ -3,7 +3,9 @@ package packer
 import (
 	"io/ioutil"
 	"os"
+	"runtime"
 	"testing"
+	"time"
 )
 
 type TestCache struct{}
@@ -59,3 +61,43 @@ func TestFileCache(t *testing.T) {
 		t.Fatalf("unknown data: %s", data)
 	}
 }
+
+func TestFileCache_LockBetweenCaches(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("cache files aren't locked on Windows")
+	}
+
+	cacheDir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("error creating temporary dir: %s", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	// Two caches on the same directory act like two Packer processes
+	cache1 := &FileCache{CacheDir: cacheDir}
+	cache2 := &FileCache{CacheDir: cacheDir}
+
+	cache1.Lock("foo")
+
+	locked := make(chan struct{})
+	go func() {
+		cache2.Lock("foo")
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("second cache shouldn't get the lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cache1.Unlock("foo")
+
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second cache should get the lock")
+	}
+
+	cache2.Unlock("foo")
+}
@@ -1,7 +1,10 @@
 package packer
 
 import (
+	"github.com/mitchellh/iochan"
 	"io"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -63,3 +66,54 @@ func (r *RemoteCmd) Wait() {
 		time.Sleep(50 * time.Millisecond)
 	}
 }
+
+// StartWithUi runs the remote command with the given communicator and
+// waits for it to exit, sending each line of output to the Ui along
+// the way. Stdout and stderr are each drained by their own goroutine
+// until they're closed, so a command that writes a lot of output right
+// before exiting can never block on a full pipe. Any Stdout or Stderr
+// that is already set is still written to as well.
+func (r *RemoteCmd) StartWithUi(c Communicator, ui Ui) error {
+	stdout_r, stdout_w := io.Pipe()
+	stderr_r, stderr_w := io.Pipe()
+	defer stdout_w.Close()
+	defer stderr_w.Close()
+
+	if r.Stdout == nil {
+		r.Stdout = stdout_w
+	} else {
+		r.Stdout = io.MultiWriter(r.Stdout, stdout_w)
+	}
+
+	if r.Stderr == nil {
+		r.Stderr = stderr_w
+	} else {
+		r.Stderr = io.MultiWriter(r.Stderr, stderr_w)
+	}
+
+	if err := c.Start(r); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	streamFunc := func(pipe io.Reader) {
+		defer wg.Done()
+
+		for output := range iochan.DelimReader(pipe, '\n') {
+			ui.Message(strings.TrimSpace(output))
+		}
+	}
+
+	wg.Add(2)
+	go streamFunc(stdout_r)
+	go streamFunc(stderr_r)
+
+	// Wait for the command to exit, then close the pipes so that the
+	// goroutines above finish once they've sent the remaining output.
+	r.Wait()
+	stdout_w.Close()
+	stderr_w.Close()
+	wg.Wait()
+
+	return nil
+}
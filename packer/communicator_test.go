@@ -1,10 +1,50 @@
 package packer
 
 import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
+// loudCommunicator is a Communicator whose commands write the given
+// output to stdout and stderr and then immediately exit.
+type loudCommunicator struct {
+	stdout string
+	stderr string
+}
+
+func (c *loudCommunicator) Start(cmd *RemoteCmd) error {
+	go func() {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			io.WriteString(cmd.Stdout, c.stdout)
+		}()
+		go func() {
+			defer wg.Done()
+			io.WriteString(cmd.Stderr, c.stderr)
+		}()
+
+		wg.Wait()
+		cmd.ExitStatus = 0
+		cmd.Exited = true
+	}()
+
+	return nil
+}
+
+func (c *loudCommunicator) Upload(string, io.Reader) error {
+	return nil
+}
+
+func (c *loudCommunicator) Download(string, io.Writer) error {
+	return nil
+}
+
 func TestRemoteCmd_Wait(t *testing.T) {
 	var cmd RemoteCmd
 
@@ -24,3 +64,40 @@ func TestRemoteCmd_Wait(t *testing.T) {
 		t.Fatal("never got exit notification")
 	}
 }
+
+func TestRemoteCmd_StartWithUi(t *testing.T) {
+	// Several megabytes of output, written right before the command exits
+	line := strings.Repeat("x", 1023) + "\n"
+	comm := &loudCommunicator{
+		stdout: strings.Repeat(line, 4096),
+		stderr: strings.Repeat("y", 1<<20),
+	}
+
+	var original bytes.Buffer
+	cmd := &RemoteCmd{Command: "foo", Stdout: &original}
+	ui := testUi()
+
+	result := make(chan error)
+	go func() {
+		result <- cmd.StartWithUi(comm, ui)
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("command never completed")
+	}
+
+	if original.String() != comm.stdout {
+		t.Fatal("original stdout didn't get all the output")
+	}
+
+	// Every line, plus the newline added by the Ui
+	expected := len(comm.stdout) + len(comm.stderr) + 1
+	if n := ui.Writer.(*bytes.Buffer).Len(); n != expected {
+		t.Fatalf("bad output length: %d", n)
+	}
+}
@@ -0,0 +1,185 @@
+package packer
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// envTemplateFuncs are the functions that configuration strings are parsed
+// with to find the uses of "env". Only "env" is replaced here; the others
+// are the functions of configuration templates, which the components
+// render themselves, and only have to be known for the strings to parse.
+var envTemplateFuncs = template.FuncMap{
+	"build_name": func() string { return "" },
+	"env":        os.Getenv,
+	"timestamp":  func() string { return "" },
+	"uuid":       func() string { return "" },
+}
+
+// interpolateEnv returns a copy of the given raw configuration with every
+// use of the "env" template function within its strings replaced by the
+// value of the environmental variable. This works for every key of every
+// component, whether or not the component processes the value as a
+// template itself. The names of variables that aren't set are added to
+// missing, and they are replaced by an empty string.
+func interpolateEnv(raw interface{}, missing map[string]bool) interface{} {
+	switch v := raw.(type) {
+	case string:
+		return interpolateEnvString(v, missing)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			result[key] = interpolateEnv(value, missing)
+		}
+
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, value := range v {
+			result[i] = interpolateEnv(value, missing)
+		}
+
+		return result
+	default:
+		return raw
+	}
+}
+
+// interpolateEnvString replaces the uses of "env" with a literal name in
+// the string. The string is parsed as a template, so that "env" within a
+// string literal or a comment isn't taken for a use of it. An action that
+// is only a use of "env" is replaced by the value, and a use of "env"
+// within a larger pipeline is replaced by the value as a string literal,
+// leaving the rest of the template to the component. Strings that aren't
+// valid templates are left as they are, for the component to report.
+func interpolateEnvString(value string, missing map[string]bool) string {
+	if !strings.Contains(value, "{{") {
+		return value
+	}
+
+	tpl, err := template.New("env").Funcs(envTemplateFuncs).Parse(value)
+	if err != nil || tpl.Tree == nil || len(tpl.Templates()) > 1 {
+		return value
+	}
+
+	if !replaceEnvNode(tpl.Tree.Root, missing) {
+		return value
+	}
+
+	return tpl.Tree.Root.String()
+}
+
+// replaceEnvNode replaces the uses of "env" within the node, returning
+// true if any were replaced.
+func replaceEnvNode(node parse.Node, missing map[string]bool) bool {
+	replaced := false
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+
+		for i, child := range n.Nodes {
+			if action, ok := child.(*parse.ActionNode); ok && len(action.Pipe.Decl) == 0 && len(action.Pipe.Cmds) == 1 {
+				if value, ok := envCommand(action.Pipe.Cmds[0], missing); ok {
+					n.Nodes[i] = &parse.TextNode{NodeType: parse.NodeText, Pos: action.Pos, Text: []byte(value)}
+					replaced = true
+					continue
+				}
+			}
+
+			replaced = replaceEnvNode(child, missing) || replaced
+		}
+	case *parse.ActionNode:
+		replaced = replaceEnvNode(n.Pipe, missing)
+	case *parse.IfNode:
+		replaced = replaceEnvBranch(&n.BranchNode, missing)
+	case *parse.RangeNode:
+		replaced = replaceEnvBranch(&n.BranchNode, missing)
+	case *parse.WithNode:
+		replaced = replaceEnvBranch(&n.BranchNode, missing)
+	case *parse.TemplateNode:
+		replaced = replaceEnvNode(n.Pipe, missing)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+
+		for _, cmd := range n.Cmds {
+			if value, ok := envCommand(cmd, missing); ok {
+				cmd.Args = []parse.Node{&parse.StringNode{
+					NodeType: parse.NodeString,
+					Pos:      cmd.Pos,
+					Quoted:   strconv.Quote(value),
+					Text:     value,
+				}}
+				replaced = true
+				continue
+			}
+
+			for _, arg := range cmd.Args {
+				replaced = replaceEnvNode(arg, missing) || replaced
+			}
+		}
+	case *parse.ChainNode:
+		replaced = replaceEnvNode(n.Node, missing)
+	}
+
+	return replaced
+}
+
+func replaceEnvBranch(n *parse.BranchNode, missing map[string]bool) bool {
+	replaced := replaceEnvNode(n.Pipe, missing)
+	replaced = replaceEnvNode(n.List, missing) || replaced
+	return replaceEnvNode(n.ElseList, missing) || replaced
+}
+
+// envCommand returns the value of the environmental variable if the
+// command is a use of "env" with a literal name. The name is added to
+// missing if the variable isn't set.
+func envCommand(cmd *parse.CommandNode, missing map[string]bool) (string, bool) {
+	if len(cmd.Args) != 2 {
+		return "", false
+	}
+
+	ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+	if !ok || ident.Ident != "env" {
+		return "", false
+	}
+
+	name, ok := cmd.Args[1].(*parse.StringNode)
+	if !ok {
+		return "", false
+	}
+
+	value, ok := lookupEnv(name.Text)
+	if !ok {
+		missing[name.Text] = true
+	}
+
+	return value, true
+}
+
+// lookupEnv returns the value of the environmental variable and whether it
+// is set at all, so that a variable that is set to an empty string isn't
+// reported as missing.
+func lookupEnv(name string) (string, bool) {
+	for _, kv := range os.Environ() {
+		idx := strings.Index(kv, "=")
+		if idx < 0 {
+			continue
+		}
+
+		// Windows ignores the case of the names of variables
+		key := kv[:idx]
+		if key == name || (runtime.GOOS == "windows" && strings.EqualFold(key, name)) {
+			return kv[idx+1:], true
+		}
+	}
+
+	return "", false
+}
@@ -279,7 +279,7 @@ func (e *coreEnvironment) printHelp() {
 	// Sort the keys
 	sort.Strings(e.commands)
 
-	e.ui.Say("usage: packer [--version] [--help] <command> [<args>]\n")
+	e.ui.Say("usage: packer [--version] [--help] [-machine-readable] <command> [<args>]\n")
 	e.ui.Say("Available commands are:")
 	for _, key := range e.commands {
 		var synopsis string
@@ -12,6 +12,16 @@ type MultiError struct {
 	Errors []error
 }
 
+// appendErrors appends the error to the list, flattening it into its
+// errors if it is a MultiError so that they aren't nested.
+func appendErrors(errs []error, err error) []error {
+	if merr, ok := err.(*MultiError); ok {
+		return append(errs, merr.Errors...)
+	}
+
+	return append(errs, err)
+}
+
 func (e *MultiError) Error() string {
 	points := make([]string, len(e.Errors))
 	for i, err := range e.Errors {
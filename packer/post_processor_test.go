@@ -5,7 +5,9 @@ type TestPostProcessor struct {
 	keep         bool
 	configCalled bool
 	configVal    []interface{}
+	configErr    error
 	ppCalled     bool
+	ppErr        error
 	ppArtifact   Artifact
 	ppUi         Ui
 }
@@ -13,12 +15,16 @@ type TestPostProcessor struct {
 func (pp *TestPostProcessor) Configure(v ...interface{}) error {
 	pp.configCalled = true
 	pp.configVal = v
-	return nil
+	return pp.configErr
 }
 
 func (pp *TestPostProcessor) PostProcess(ui Ui, a Artifact) (Artifact, bool, error) {
 	pp.ppCalled = true
 	pp.ppArtifact = a
 	pp.ppUi = ui
+	if pp.ppErr != nil {
+		return nil, false, pp.ppErr
+	}
+
 	return &TestArtifact{id: pp.artifactId}, pp.keep, nil
 }
@@ -5,13 +5,14 @@ import "testing"
 type TestProvisioner struct {
 	prepCalled  bool
 	prepConfigs []interface{}
+	prepErr     error
 	provCalled  bool
 }
 
 func (t *TestProvisioner) Prepare(configs ...interface{}) error {
 	t.prepCalled = true
 	t.prepConfigs = configs
-	return nil
+	return t.prepErr
 }
 
 func (t *TestProvisioner) Provision(Ui, Communicator) error {
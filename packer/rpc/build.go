@@ -69,6 +69,26 @@ func (b *build) SetDebug(val bool) {
 	}
 }
 
+func (b *build) Warnings() (result []string) {
+	if err := b.client.Call("Build.Warnings", new(interface{}), &result); err != nil {
+		panic(err)
+	}
+
+	return
+}
+
+func (b *build) SetForce(val bool) {
+	if err := b.client.Call("Build.SetForce", val, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
+func (b *build) SetOnError(val string) {
+	if err := b.client.Call("Build.SetOnError", val, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
 func (b *build) Cancel() {
 	if err := b.client.Call("Build.Cancel", new(interface{}), new(interface{})); err != nil {
 		panic(err)
@@ -111,6 +131,21 @@ func (b *BuildServer) SetDebug(val *bool, reply *interface{}) error {
 	return nil
 }
 
+func (b *BuildServer) Warnings(args *interface{}, reply *[]string) error {
+	*reply = b.build.Warnings()
+	return nil
+}
+
+func (b *BuildServer) SetForce(val *bool, reply *interface{}) error {
+	b.build.SetForce(*val)
+	return nil
+}
+
+func (b *BuildServer) SetOnError(val *string, reply *interface{}) error {
+	b.build.SetOnError(*val)
+	return nil
+}
+
 func (b *BuildServer) Cancel(args *interface{}, reply *interface{}) error {
 	b.build.Cancel()
 	return nil
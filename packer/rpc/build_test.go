@@ -17,6 +17,9 @@ type testBuild struct {
 	runCache       packer.Cache
 	runUi          packer.Ui
 	setDebugCalled bool
+	setForceCalled bool
+	warningsCalled bool
+	onErrorValue   string
 	cancelCalled   bool
 
 	errRunResult bool
@@ -48,6 +51,19 @@ func (b *testBuild) SetDebug(bool) {
 	b.setDebugCalled = true
 }
 
+func (b *testBuild) Warnings() []string {
+	b.warningsCalled = true
+	return []string{"foo"}
+}
+
+func (b *testBuild) SetForce(bool) {
+	b.setForceCalled = true
+}
+
+func (b *testBuild) SetOnError(val string) {
+	b.onErrorValue = val
+}
+
 func (b *testBuild) Cancel() {
 	b.cancelCalled = true
 }
@@ -104,6 +120,19 @@ func TestBuildRPC(t *testing.T) {
 	bClient.SetDebug(true)
 	assert.True(b.setDebugCalled, "should be called")
 
+	// Test Warnings
+	warnings := bClient.Warnings()
+	assert.True(b.warningsCalled, "should be called")
+	assert.Equal(warnings, []string{"foo"}, "should have warnings")
+
+	// Test SetForce
+	bClient.SetForce(true)
+	assert.True(b.setForceCalled, "should be called")
+
+	// Test SetOnError
+	bClient.SetOnError("abort")
+	assert.Equal(b.onErrorValue, "abort", "should be set")
+
 	// Test Cancel
 	bClient.Cancel()
 	assert.True(b.cancelCalled, "cancel should be called")
@@ -17,6 +17,14 @@ type UiServer struct {
 	ui packer.Ui
 }
 
+// UiMachineArgs are the arguments for a machine-readable record that is
+// sent over RPC.
+type UiMachineArgs struct {
+	Target   string
+	Category string
+	Args     []string
+}
+
 func (u *Ui) Ask(query string) (result string, err error) {
 	err = u.client.Call("Ui.Ask", query, &result)
 	return
@@ -28,6 +36,22 @@ func (u *Ui) Error(message string) {
 	}
 }
 
+func (u *Ui) Machine(t string, args ...string) {
+	u.MachineTarget("", t, args...)
+}
+
+func (u *Ui) MachineTarget(target string, t string, args ...string) {
+	rpcArgs := &UiMachineArgs{
+		Target:   target,
+		Category: t,
+		Args:     args,
+	}
+
+	if err := u.client.Call("Ui.Machine", rpcArgs, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
 func (u *Ui) Message(message string) {
 	if err := u.client.Call("Ui.Message", message, new(interface{})); err != nil {
 		panic(err)
@@ -52,6 +76,18 @@ func (u *UiServer) Error(message *string, reply *interface{}) error {
 	return nil
 }
 
+func (u *UiServer) Machine(args *UiMachineArgs, reply *interface{}) error {
+	if args.Target != "" {
+		ui := &packer.TargetedUi{Target: args.Target, Ui: u.ui}
+		ui.Machine(args.Category, args.Args...)
+	} else {
+		u.ui.Machine(args.Category, args.Args...)
+	}
+
+	*reply = nil
+	return nil
+}
+
 func (u *UiServer) Message(message *string, reply *interface{}) error {
 	u.ui.Message(*message)
 	*reply = nil
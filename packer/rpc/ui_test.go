@@ -11,6 +11,10 @@ type testUi struct {
 	askQuery       string
 	errorCalled    bool
 	errorMessage   string
+	machineCalled  bool
+	machineType    []string
+	machineArgs    [][]string
+	machineTarget  []string
 	messageCalled  bool
 	messageMessage string
 	sayCalled      bool
@@ -28,6 +32,17 @@ func (u *testUi) Error(message string) {
 	u.errorMessage = message
 }
 
+func (u *testUi) Machine(t string, args ...string) {
+	u.machineCalled = true
+	u.machineType = append(u.machineType, t)
+	u.machineArgs = append(u.machineArgs, args)
+}
+
+func (u *testUi) MachineTarget(target string, t string, args ...string) {
+	u.machineTarget = append(u.machineTarget, target)
+	u.Machine(t, args...)
+}
+
 func (u *testUi) Message(message string) {
 	u.messageCalled = true
 	u.messageMessage = message
@@ -67,6 +82,16 @@ func TestUiRPC(t *testing.T) {
 	uiClient.Error("message")
 	assert.Equal(ui.errorMessage, "message", "message should be correct")
 
+	uiClient.Machine("foo", "bar", "baz")
+	assert.True(ui.machineCalled, "machine should be called")
+	assert.Equal(ui.machineType, []string{"foo"}, "type should be correct")
+	assert.Equal(ui.machineArgs, [][]string{{"bar", "baz"}}, "args should be correct")
+	assert.Nil(ui.machineTarget, "target should not be set")
+
+	uiClient.MachineTarget("mitchell", "foo", "bar")
+	assert.Equal(ui.machineTarget, []string{"mitchell"}, "target should be correct")
+	assert.Equal(ui.machineType, []string{"foo", "foo"}, "type should be correct")
+
 	uiClient.Message("message")
 	assert.Equal(ui.messageMessage, "message", "message should be correct")
 
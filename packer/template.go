@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/mitchellh/mapstructure"
+	"strings"
+	"time"
 )
 
 // The rawTemplate struct represents the structure of a template read
@@ -12,6 +14,7 @@ import (
 // "interface{}" pointers since we actually don't know what their contents
 // are until we read the "type" field.
 type rawTemplate struct {
+	MinVersion     string `json:"min_packer_version"`
 	Builders       []map[string]interface{}
 	Hooks          map[string][]string
 	Provisioners   []map[string]interface{}
@@ -25,6 +28,14 @@ type Template struct {
 	Hooks          map[string][]string
 	PostProcessors [][]rawPostProcessorConfig
 	Provisioners   []rawProvisionerConfig
+
+	// Timestamp is the time the template was loaded. It is given to every
+	// build so that they all agree on the value of "{{timestamp}}".
+	Timestamp time.Time
+
+	// Path is the absolute path of the file the template was read from,
+	// if any. It is given to every build as "packer_template_path".
+	Path string
 }
 
 // The rawBuilderConfig struct represents a raw, unprocessed builder
@@ -49,10 +60,13 @@ type rawPostProcessorConfig struct {
 
 // rawProvisionerConfig represents a raw, unprocessed provisioner configuration.
 // It contains the type of the provisioner as well as the raw configuration
-// that is handed to the provisioner for it to process.
+// that is handed to the provisioner for it to process. Only and Except
+// restrict the provisioner to a subset of the named builds.
 type rawProvisionerConfig struct {
 	Type     string
 	Override map[string]interface{}
+	Only     []string
+	Except   []string
 
 	rawConfig interface{}
 }
@@ -92,16 +106,27 @@ func ParseTemplate(data []byte) (t *Template, err error) {
 		return
 	}
 
+	// Check the version first, since a template for a newer Packer may
+	// not make sense to the rest of the parsing.
+	if rawTpl.MinVersion != "" {
+		if err = checkMinVersion(rawTpl.MinVersion); err != nil {
+			return
+		}
+	}
+
 	t = &Template{}
 	t.Builders = make(map[string]rawBuilderConfig)
 	t.Hooks = rawTpl.Hooks
 	t.PostProcessors = make([][]rawPostProcessorConfig, len(rawTpl.PostProcessors))
 	t.Provisioners = make([]rawProvisionerConfig, len(rawTpl.Provisioners))
+	t.Timestamp = time.Now().UTC()
 
 	errors := make([]error, 0)
 
 	// Gather all the builders
 	for i, v := range rawTpl.Builders {
+		removeCommentKeys(v)
+
 		var raw rawBuilderConfig
 		if err := mapstructure.Decode(v, &raw); err != nil {
 			if merr, ok := err.(*mapstructure.Error); ok {
@@ -151,6 +176,8 @@ func ParseTemplate(data []byte) (t *Template, err error) {
 		t.PostProcessors[i] = make([]rawPostProcessorConfig, len(rawPP))
 		configs := t.PostProcessors[i]
 		for j, pp := range rawPP {
+			removeCommentKeys(pp)
+
 			config := &configs[j]
 			if err := mapstructure.Decode(pp, config); err != nil {
 				if merr, ok := err.(*mapstructure.Error); ok {
@@ -175,6 +202,15 @@ func ParseTemplate(data []byte) (t *Template, err error) {
 
 	// Gather all the provisioners
 	for i, v := range rawTpl.Provisioners {
+		removeCommentKeys(v)
+		if override, ok := v["override"].(map[string]interface{}); ok {
+			for _, overrideV := range override {
+				if overrideConfig, ok := overrideV.(map[string]interface{}); ok {
+					removeCommentKeys(overrideConfig)
+				}
+			}
+		}
+
 		raw := &t.Provisioners[i]
 		if err := mapstructure.Decode(v, raw); err != nil {
 			if merr, ok := err.(*mapstructure.Error); ok {
@@ -193,6 +229,22 @@ func ParseTemplate(data []byte) (t *Template, err error) {
 			continue
 		}
 
+		if len(raw.Only) > 0 && len(raw.Except) > 0 {
+			errors = append(errors, fmt.Errorf("provisioner %d: only one of 'only' or 'except' may be specified", i+1))
+		}
+
+		for _, name := range raw.Only {
+			if _, ok := t.Builders[name]; !ok {
+				errors = append(errors, fmt.Errorf("provisioner %d: 'only' specified builder '%s' not found", i+1, name))
+			}
+		}
+
+		for _, name := range raw.Except {
+			if _, ok := t.Builders[name]; !ok {
+				errors = append(errors, fmt.Errorf("provisioner %d: 'except' specified builder '%s' not found", i+1, name))
+			}
+		}
+
 		raw.rawConfig = v
 	}
 
@@ -209,6 +261,38 @@ func ParseTemplate(data []byte) (t *Template, err error) {
 	return
 }
 
+// removeCommentKeys deletes the keys starting with an underscore from the
+// configuration of a component. JSON has no comments, so these keys are
+// used for them instead, and components never see them.
+func removeCommentKeys(config map[string]interface{}) {
+	for k := range config {
+		if strings.HasPrefix(k, "_") {
+			delete(config, k)
+		}
+	}
+}
+
+// checkMinVersion returns an error if the running Packer is older than
+// the given minimum version, or if the minimum version is invalid.
+func checkMinVersion(minVersion string) error {
+	required, err := parseVersion(minVersion)
+	if err != nil {
+		return fmt.Errorf("min_packer_version is invalid: %s", err)
+	}
+
+	current, err := parseVersion(fullVersion())
+	if err != nil {
+		panic(err)
+	}
+
+	if current.compare(required) < 0 {
+		return fmt.Errorf(
+			"template requires packer %s, you have %s", minVersion, fullVersion())
+	}
+
+	return nil
+}
+
 func parsePostProvisioner(i int, rawV interface{}) (result []map[string]interface{}, errors []error) {
 	switch v := rawV.(type) {
 	case string:
@@ -287,7 +371,7 @@ func (t *Template) Build(name string, components *ComponentFinder) (b Build, err
 	}
 
 	if builder == nil {
-		err = fmt.Errorf("Builder type not found: %s", builderConfig.Type)
+		err = fmt.Errorf("Build '%s': builder type not found: %s", name, builderConfig.Type)
 		return
 	}
 
@@ -325,7 +409,7 @@ func (t *Template) Build(name string, components *ComponentFinder) (b Build, err
 			}
 
 			if pp == nil {
-				return nil, fmt.Errorf("PostProcessor type not found: %s", rawPP.Type)
+				return nil, fmt.Errorf("Build '%s': post-processor type not found: %s", name, rawPP.Type)
 			}
 
 			current[i] = coreBuildPostProcessor{
@@ -342,6 +426,10 @@ func (t *Template) Build(name string, components *ComponentFinder) (b Build, err
 	// Prepare the provisioners
 	provisioners := make([]coreBuildProvisioner, 0, len(t.Provisioners))
 	for _, rawProvisioner := range t.Provisioners {
+		if !rawProvisioner.appliesTo(name) {
+			continue
+		}
+
 		var provisioner Provisioner
 		provisioner, err = components.Provisioner(rawProvisioner.Type)
 		if err != nil {
@@ -349,7 +437,7 @@ func (t *Template) Build(name string, components *ComponentFinder) (b Build, err
 		}
 
 		if provisioner == nil {
-			err = fmt.Errorf("Provisioner type not found: %s", rawProvisioner.Type)
+			err = fmt.Errorf("Build '%s': provisioner type not found: %s", name, rawProvisioner.Type)
 			return
 		}
 
@@ -374,7 +462,31 @@ func (t *Template) Build(name string, components *ComponentFinder) (b Build, err
 		hooks:          hooks,
 		postProcessors: postProcessors,
 		provisioners:   provisioners,
+		timestamp:      t.Timestamp.Unix(),
+		templatePath:   t.Path,
 	}
 
 	return
 }
+
+// appliesTo reports whether the provisioner should run as part of the
+// build with the given name, based on its "only" and "except" settings.
+func (r *rawProvisionerConfig) appliesTo(name string) bool {
+	if len(r.Only) > 0 {
+		for _, n := range r.Only {
+			if n == name {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, n := range r.Except {
+		if n == name {
+			return false
+		}
+	}
+
+	return true
+}
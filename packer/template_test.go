@@ -37,6 +37,96 @@ func TestParseTemplate_Invalid(t *testing.T) {
 	assert.Nil(result, "should have no result")
 }
 
+func TestParseTemplate_MinVersion(t *testing.T) {
+	// An old enough version is fine
+	data := `
+	{
+		"min_packer_version": "0.1.0",
+		"builders": [{"type": "something"}]
+	}
+	`
+
+	if _, err := ParseTemplate([]byte(data)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A newer version errors
+	data = `
+	{
+		"min_packer_version": "1000.0.0",
+		"builders": [{"type": "something"}]
+	}
+	`
+
+	_, err := ParseTemplate([]byte(data))
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	expected := "template requires packer 1000.0.0, you have " + fullVersion()
+	if err.Error() != expected {
+		t.Fatalf("bad: %s", err)
+	}
+
+	// An invalid version errors
+	data = `
+	{
+		"min_packer_version": "foo",
+		"builders": [{"type": "something"}]
+	}
+	`
+
+	if _, err := ParseTemplate([]byte(data)); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestParseTemplate_CommentKeys(t *testing.T) {
+	data := `
+	{
+		"_comment": "top level",
+		"builders": [{"type": "something", "_comment": "builder"}],
+		"provisioners": [{
+			"type": "shell",
+			"_comment": "provisioner",
+			"override": {
+				"something": {"foo": "bar", "_comment": "override"}
+			}
+		}],
+		"post-processors": [{"type": "vagrant", "_comment": "pp"}]
+	}
+	`
+
+	result, err := ParseTemplate([]byte(data))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	builder := result.Builders["something"].rawConfig.(map[string]interface{})
+	if _, ok := builder["_comment"]; ok {
+		t.Fatalf("builder should not have comment: %#v", builder)
+	}
+
+	prov := result.Provisioners[0]
+	if _, ok := prov.rawConfig.(map[string]interface{})["_comment"]; ok {
+		t.Fatalf("provisioner should not have comment: %#v", prov.rawConfig)
+	}
+
+	override := prov.Override["something"].(map[string]interface{})
+	if _, ok := override["_comment"]; ok {
+		t.Fatalf("override should not have comment: %#v", override)
+	}
+
+	if override["foo"] != "bar" {
+		t.Fatalf("override should keep other keys: %#v", override)
+	}
+
+	pp := result.PostProcessors[0][0].rawConfig.(map[string]interface{})
+	if _, ok := pp["_comment"]; ok {
+		t.Fatalf("post-processor should not have comment: %#v", pp)
+	}
+}
+
 func TestParseTemplate_BuilderWithoutType(t *testing.T) {
 	assert := asserts.NewTestingAsserts(t, true)
 
@@ -269,6 +359,65 @@ func TestParseTemplate_Provisioners(t *testing.T) {
 	assert.NotNil(result.Provisioners[0].rawConfig, "should have raw config")
 }
 
+func TestParseTemplate_ProvisionerOnlyExcept(t *testing.T) {
+	assert := asserts.NewTestingAsserts(t, true)
+
+	// Both only and except
+	data := `
+	{
+		"builders": [{"name": "a", "type": "foo"}, {"name": "b", "type": "foo"}],
+
+		"provisioners": [
+			{
+				"type": "shell",
+				"only": ["a"],
+				"except": ["b"]
+			}
+		]
+	}
+	`
+
+	_, err := ParseTemplate([]byte(data))
+	assert.NotNil(err, "should have error")
+
+	// Unknown build in only
+	data = `
+	{
+		"builders": [{"name": "a", "type": "foo"}],
+
+		"provisioners": [{"type": "shell", "only": ["c"]}]
+	}
+	`
+
+	_, err = ParseTemplate([]byte(data))
+	assert.NotNil(err, "should have error")
+
+	// Unknown build in except
+	data = `
+	{
+		"builders": [{"name": "a", "type": "foo"}],
+
+		"provisioners": [{"type": "shell", "except": ["c"]}]
+	}
+	`
+
+	_, err = ParseTemplate([]byte(data))
+	assert.NotNil(err, "should have error")
+
+	// Valid
+	data = `
+	{
+		"builders": [{"name": "a", "type": "foo"}, {"name": "b", "type": "foo"}],
+
+		"provisioners": [{"type": "shell", "only": ["b"]}]
+	}
+	`
+
+	result, err := ParseTemplate([]byte(data))
+	assert.Nil(err, "should not error")
+	assert.Equal(result.Provisioners[0].Only, []string{"b"}, "should have only")
+}
+
 func TestTemplate_BuildNames(t *testing.T) {
 	assert := asserts.NewTestingAsserts(t, true)
 
@@ -497,6 +646,8 @@ func TestTemplate_Build(t *testing.T) {
 		Provisioner:   provFactory,
 	}
 
+	template.Path = "/tmp/template.json"
+
 	// Get the build, verifying we can get it without issue, but also
 	// that the proper builder was looked up and used for the build.
 	build, err := template.Build("test1", components)
@@ -512,6 +663,8 @@ func TestTemplate_Build(t *testing.T) {
 	assert.Equal(len(coreBuild.postProcessors[1]), 2, "should have correct number")
 	assert.False(coreBuild.postProcessors[1][0].keepInputArtifact, "shoule be correct")
 	assert.True(coreBuild.postProcessors[1][1].keepInputArtifact, "shoule be correct")
+	assert.Equal(coreBuild.timestamp, template.Timestamp.Unix(), "should have the template timestamp")
+	assert.Equal(coreBuild.templatePath, "/tmp/template.json", "should have the template path")
 }
 
 func TestTemplate_Build_ProvisionerOverride(t *testing.T) {
@@ -568,3 +721,62 @@ func TestTemplate_Build_ProvisionerOverride(t *testing.T) {
 	assert.Equal(len(coreBuild.provisioners), 1, "should have one provisioner")
 	assert.Equal(len(coreBuild.provisioners[0].config), 2, "should have two configs on the provisioner")
 }
+
+func TestTemplate_Build_ProvisionerOnlyExcept(t *testing.T) {
+	assert := asserts.NewTestingAsserts(t, true)
+
+	data := `
+	{
+		"builders": [
+			{
+				"name": "test1",
+				"type": "test-builder"
+			},
+			{
+				"name": "test2",
+				"type": "test-builder"
+			}
+		],
+
+		"provisioners": [
+			{
+				"type": "test-prov",
+				"only": ["test1"]
+			},
+			{
+				"type": "test-prov",
+				"except": ["test1"]
+			},
+			{
+				"type": "test-prov"
+			}
+		]
+	}
+	`
+
+	template, err := ParseTemplate([]byte(data))
+	assert.Nil(err, "should not error")
+
+	builderMap := map[string]Builder{
+		"test-builder": testBuilder(),
+	}
+
+	provisionerMap := map[string]Provisioner{
+		"test-prov": &TestProvisioner{},
+	}
+
+	builderFactory := func(n string) (Builder, error) { return builderMap[n], nil }
+	provFactory := func(n string) (Provisioner, error) { return provisionerMap[n], nil }
+	components := &ComponentFinder{
+		Builder:     builderFactory,
+		Provisioner: provFactory,
+	}
+
+	build, err := template.Build("test1", components)
+	assert.Nil(err, "should not error")
+	assert.Equal(len(build.(*coreBuild).provisioners), 2, "should have two provisioners")
+
+	build, err = template.Build("test2", components)
+	assert.Nil(err, "should not error")
+	assert.Equal(len(build.(*coreBuild).provisioners), 2, "should have two provisioners")
+}
@@ -8,8 +8,10 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 )
 
 type UiColor uint
@@ -31,6 +33,18 @@ type Ui interface {
 	Say(string)
 	Message(string)
 	Error(string)
+
+	// Machine outputs a machine-readable record of the given type with
+	// the given data. It is only shown in machine-readable mode.
+	Machine(string, ...string)
+}
+
+// MachineTargetUi is a Ui that can output machine-readable records about a
+// target, such as the name of the build they are about. Uis that only pass
+// records through implement it too, so that the target isn't lost on the
+// way to the Ui that outputs them.
+type MachineTargetUi interface {
+	MachineTarget(target string, t string, args ...string)
 }
 
 // ColoredUi is a UI that is colored using terminal colors.
@@ -48,6 +62,23 @@ type PrefixedUi struct {
 	Ui            Ui
 }
 
+// TargetedUi is a UI that wraps another UI implementation and sets the
+// target of the machine-readable records going out, such as the name of
+// the build they are about. Other output is passed through as-is.
+type TargetedUi struct {
+	Target string
+	Ui     Ui
+}
+
+// MachineReadableUi is a UI that outputs machine-readable records rather
+// than human-readable output, one per line in the form of
+// "timestamp,target,type,data...". Human-readable output is turned into
+// records of type "ui". It can't ask for input.
+type MachineReadableUi struct {
+	Writer io.Writer
+	l      sync.Mutex
+}
+
 // The ReaderWriterUi is a UI that writes and reads from standard Go
 // io.Reader and io.Writer.
 type ReaderWriterUi struct {
@@ -78,6 +109,14 @@ func (u *ColoredUi) Error(message string) {
 	u.Ui.Error(u.colorize(message, color, true))
 }
 
+func (u *ColoredUi) Machine(t string, args ...string) {
+	u.Ui.Machine(t, args...)
+}
+
+func (u *ColoredUi) MachineTarget(target string, t string, args ...string) {
+	machineTarget(u.Ui, target, t, args...)
+}
+
 func (u *ColoredUi) colorize(message string, color UiColor, bold bool) string {
 	attr := 0
 	if bold {
@@ -103,6 +142,14 @@ func (u *PrefixedUi) Error(message string) {
 	u.Ui.Error(u.prefixLines(u.SayPrefix, message))
 }
 
+func (u *PrefixedUi) Machine(t string, args ...string) {
+	u.Ui.Machine(t, args...)
+}
+
+func (u *PrefixedUi) MachineTarget(target string, t string, args ...string) {
+	machineTarget(u.Ui, target, t, args...)
+}
+
 func (u *PrefixedUi) prefixLines(prefix, message string) string {
 	var result bytes.Buffer
 
@@ -113,6 +160,89 @@ func (u *PrefixedUi) prefixLines(prefix, message string) string {
 	return strings.TrimSpace(result.String())
 }
 
+func (u *TargetedUi) Ask(query string) (string, error) {
+	return u.Ui.Ask(query)
+}
+
+func (u *TargetedUi) Say(message string) {
+	u.Ui.Say(message)
+}
+
+func (u *TargetedUi) Message(message string) {
+	u.Ui.Message(message)
+}
+
+func (u *TargetedUi) Error(message string) {
+	u.Ui.Error(message)
+}
+
+func (u *TargetedUi) Machine(t string, args ...string) {
+	machineTarget(u.Ui, u.Target, t, args...)
+}
+
+// machineTarget outputs a machine-readable record about the target through
+// the Ui. If the Ui can't tell which target a record is about, the record
+// is output without one.
+func machineTarget(ui Ui, target string, t string, args ...string) {
+	if targetUi, ok := ui.(MachineTargetUi); ok {
+		targetUi.MachineTarget(target, t, args...)
+		return
+	}
+
+	ui.Machine(t, args...)
+}
+
+// Matches the terminal escape codes that ColoredUi adds, which don't
+// belong in machine-readable output.
+var colorCodeRe = regexp.MustCompile("\033\\[[0-9;]*m")
+
+func (u *MachineReadableUi) Ask(query string) (string, error) {
+	return "", errors.New("machine-readable UI can't ask for input")
+}
+
+func (u *MachineReadableUi) Say(message string) {
+	u.Machine("ui", "say", message)
+}
+
+func (u *MachineReadableUi) Message(message string) {
+	u.Machine("ui", "message", message)
+}
+
+func (u *MachineReadableUi) Error(message string) {
+	u.Machine("ui", "error", message)
+}
+
+func (u *MachineReadableUi) Machine(t string, args ...string) {
+	u.MachineTarget("", t, args...)
+}
+
+func (u *MachineReadableUi) MachineTarget(target string, t string, args ...string) {
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	fields := make([]string, 0, len(args)+3)
+	fields = append(fields, fmt.Sprintf("%d", time.Now().UTC().Unix()), target, t)
+	for _, arg := range args {
+		fields = append(fields, escapeMachineData(colorCodeRe.ReplaceAllString(arg, "")))
+	}
+
+	log.Printf("machine readable: %s %#v", t, args)
+	_, err := fmt.Fprint(u.Writer, strings.Join(fields, ",")+"\n")
+	if err != nil {
+		panic(err)
+	}
+}
+
+// escapeMachineData escapes the commas and newlines in the data of a
+// machine-readable record, so that every record is a single line with
+// one field between each comma.
+func escapeMachineData(data string) string {
+	data = strings.Replace(data, ",", "%!(PACKER_COMMA)", -1)
+	data = strings.Replace(data, "\r", "\\r", -1)
+	data = strings.Replace(data, "\n", "\\n", -1)
+	return data
+}
+
 func (rw *ReaderWriterUi) Ask(query string) (string, error) {
 	rw.l.Lock()
 	defer rw.l.Unlock()
@@ -189,3 +319,9 @@ func (rw *ReaderWriterUi) Error(message string) {
 		panic(err)
 	}
 }
+
+func (rw *ReaderWriterUi) Machine(t string, args ...string) {
+	// Machine-readable records aren't shown to humans, but they're
+	// logged to help debugging.
+	log.Printf("machine readable: %s %#v", t, args)
+}
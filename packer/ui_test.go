@@ -3,6 +3,7 @@ package packer
 import (
 	"bytes"
 	"cgl.tideland.biz/asserts"
+	"strings"
 	"testing"
 )
 
@@ -111,3 +112,77 @@ func readWriter(ui *ReaderWriterUi) (result string) {
 	buffer.Reset()
 	return
 }
+
+func TestTargetedUi_ImplUi(t *testing.T) {
+	var raw interface{}
+	raw = &TargetedUi{}
+	if _, ok := raw.(Ui); !ok {
+		t.Fatalf("TargetedUi must implement Ui")
+	}
+}
+
+func TestMachineReadableUi_ImplUi(t *testing.T) {
+	var raw interface{}
+	raw = &MachineReadableUi{}
+	if _, ok := raw.(Ui); !ok {
+		t.Fatalf("MachineReadableUi must implement Ui")
+	}
+}
+
+func TestMachineReadableUi(t *testing.T) {
+	var data, expected string
+
+	buf := new(bytes.Buffer)
+	ui := &MachineReadableUi{Writer: buf}
+
+	// No target
+	ui.Machine("foo", "bar", "baz")
+	data = strings.SplitN(buf.String(), ",", 2)[1]
+	expected = ",foo,bar,baz\n"
+	if data != expected {
+		t.Fatalf("bad: %s", data)
+	}
+
+	// Target
+	buf.Reset()
+	targetUi := &TargetedUi{Target: "mitchell", Ui: ui}
+	targetUi.Machine("foo", "bar", "baz")
+	data = strings.SplitN(buf.String(), ",", 2)[1]
+	expected = "mitchell,foo,bar,baz\n"
+	if data != expected {
+		t.Fatalf("bad: %s", data)
+	}
+
+	// Escaping
+	buf.Reset()
+	ui.Machine("foo", "foo,bar\nbaz")
+	data = strings.SplitN(buf.String(), ",", 2)[1]
+	expected = ",foo,foo%!(PACKER_COMMA)bar\\nbaz\n"
+	if data != expected {
+		t.Fatalf("bad: %s", data)
+	}
+
+	// Human output through color
+	buf.Reset()
+	colorUi := &ColoredUi{UiColorYellow, UiColorRed, ui}
+	colorUi.Say("foo")
+	data = strings.SplitN(buf.String(), ",", 2)[1]
+	expected = ",ui,say,foo\n"
+	if data != expected {
+		t.Fatalf("bad: %s", data)
+	}
+
+	// Target through color
+	buf.Reset()
+	targetUi = &TargetedUi{Target: "mitchell", Ui: colorUi}
+	targetUi.Machine("foo", "bar")
+	data = strings.SplitN(buf.String(), ",", 2)[1]
+	expected = "mitchell,foo,bar\n"
+	if data != expected {
+		t.Fatalf("bad: %s", data)
+	}
+
+	if _, err := ui.Ask("foo"); err == nil {
+		t.Fatal("ask should error")
+	}
+}
@@ -1,8 +1,10 @@
 package packer
 
 import (
-	"bytes"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
 // The version of packer.
@@ -13,6 +15,110 @@ const Version = "0.1.5"
 // pre-release marker.
 const VersionPrerelease = "dev"
 
+// Matches versions such as "0.8", "0.8.1", "v0.8.1", and versions with a
+// pre-release marker such as "0.8.1-rc1", or "0.8.1.dev" like Packer's own.
+var versionRe = regexp.MustCompile(
+	`^v?(\d+)(?:\.(\d+)(?:\.(\d+)(?:\.([A-Za-z][0-9A-Za-z.-]*))?)?)?(?:-([0-9A-Za-z.-]+))?$`)
+
+// version is a parsed version, compared by semantic version ordering.
+type version struct {
+	parts      [3]int
+	prerelease []string
+}
+
+// fullVersion returns the version of the running Packer, including any
+// pre-release marker.
+func fullVersion() string {
+	if VersionPrerelease == "" {
+		return Version
+	}
+
+	return fmt.Sprintf("%s.%s", Version, VersionPrerelease)
+}
+
+// parseVersion parses a version string. Missing minor or patch versions
+// are zero.
+func parseVersion(v string) (*version, error) {
+	matches := versionRe.FindStringSubmatch(strings.TrimSpace(v))
+	if matches == nil {
+		return nil, fmt.Errorf("Malformed version: %s", v)
+	}
+
+	result := new(version)
+	for i, part := range matches[1:4] {
+		if part == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed version: %s", v)
+		}
+
+		result.parts[i] = n
+	}
+
+	for _, prerelease := range matches[4:6] {
+		if prerelease != "" {
+			result.prerelease = append(result.prerelease, strings.Split(prerelease, ".")...)
+		}
+	}
+
+	return result, nil
+}
+
+// compare returns -1, 0, or 1 if v is older than, the same as, or newer
+// than other. A pre-release is older than the release it leads up to.
+func (v *version) compare(other *version) int {
+	for i := range v.parts {
+		if v.parts[i] != other.parts[i] {
+			return compareInts(v.parts[i], other.parts[i])
+		}
+	}
+
+	// A release is newer than any of its pre-releases
+	if len(v.prerelease) == 0 || len(other.prerelease) == 0 {
+		return compareInts(len(other.prerelease), len(v.prerelease))
+	}
+
+	// Pre-release identifiers are compared one by one. Numeric ones are
+	// compared numerically and are older than alphanumeric ones.
+	for i := 0; i < len(v.prerelease) && i < len(other.prerelease); i++ {
+		a, b := v.prerelease[i], other.prerelease[i]
+		if a == b {
+			continue
+		}
+
+		aNum, aErr := strconv.Atoi(a)
+		bNum, bErr := strconv.Atoi(b)
+		switch {
+		case aErr == nil && bErr == nil:
+			return compareInts(aNum, bNum)
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case a < b:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	return compareInts(len(v.prerelease), len(other.prerelease))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
 type versionCommand byte
 
 func (versionCommand) Help() string {
@@ -23,13 +129,7 @@ command-line flags for this command.`
 }
 
 func (versionCommand) Run(env Environment, args []string) int {
-	var versionString bytes.Buffer
-	fmt.Fprintf(&versionString, "Packer v%s", Version)
-	if VersionPrerelease != "" {
-		fmt.Fprintf(&versionString, ".%s", VersionPrerelease)
-	}
-
-	env.Ui().Say(versionString.String())
+	env.Ui().Say(fmt.Sprintf("Packer v%s", fullVersion()))
 	return 0
 }
 
@@ -0,0 +1,57 @@
+package packer
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	valid := []string{"0.8", "0.8.1", "v0.8.1", "0.8.1-rc1", "0.8.1.dev", "0.8.1-beta.2"}
+	for _, v := range valid {
+		if _, err := parseVersion(v); err != nil {
+			t.Fatalf("%s: %s", v, err)
+		}
+	}
+
+	invalid := []string{"", "foo", "0.8.x", "0..8", "0.8.1-"}
+	for _, v := range invalid {
+		if _, err := parseVersion(v); err == nil {
+			t.Fatalf("%s should be invalid", v)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"0.8.0", "0.8.0", 0},
+		{"0.8", "0.8.0", 0},
+		{"0.8.0", "0.8.1", -1},
+		{"0.10.0", "0.9.0", 1},
+		{"1.0.0", "0.99.99", 1},
+		{"0.8.0-rc1", "0.8.0", -1},
+		{"0.8.0", "0.8.0.dev", 1},
+		{"0.8.0-alpha", "0.8.0-beta", -1},
+		{"0.8.0-beta.2", "0.8.0-beta.11", -1},
+		{"0.8.0-beta", "0.8.0-beta.1", -1},
+		{"0.8.0-1", "0.8.0-alpha", -1},
+		{"0.8.1-rc1", "0.8.0", 1},
+	}
+
+	for _, tc := range cases {
+		a, err := parseVersion(tc.a)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		b, err := parseVersion(tc.b)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if actual := a.compare(b); actual != tc.expected {
+			t.Fatalf("%s <=> %s: expected %d, got %d", tc.a, tc.b, tc.expected, actual)
+		}
+	}
+}
@@ -15,7 +15,8 @@ type AWSBoxConfig struct {
 	OutputPath          string `mapstructure:"output"`
 	VagrantfileTemplate string `mapstructure:"vagrantfile_template"`
 
-	PackerBuildName string `mapstructure:"packer_build_name"`
+	PackerBuildName         string `mapstructure:"packer_build_name"`
+	PackerTemplateTimestamp int64  `mapstructure:"packer_template_timestamp"`
 }
 
 type AWSVagrantfileTemplate struct {
@@ -54,7 +55,7 @@ func (p *AWSBoxPostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact
 
 	// Compile the output path
 	outputPath, err := ProcessOutputPath(p.config.OutputPath,
-		p.config.PackerBuildName, "aws", artifact)
+		p.config.PackerBuildName, p.config.PackerTemplateTimestamp, "aws", artifact)
 	if err != nil {
 		return nil, false, err
 	}
@@ -4,8 +4,10 @@
 package vagrant
 
 import (
+	"errors"
 	"fmt"
 	"github.com/mitchellh/mapstructure"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"log"
 	"text/template"
@@ -20,7 +22,8 @@ var builtins = map[string]string{
 type Config struct {
 	OutputPath string `mapstructure:"output"`
 
-	PackerBuildName string `mapstructure:"packer_build_name"`
+	PackerBuildName         string `mapstructure:"packer_build_name"`
+	PackerTemplateTimestamp int64  `mapstructure:"packer_template_timestamp"`
 }
 
 type PostProcessor struct {
@@ -40,7 +43,8 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 		p.config.OutputPath = "packer_{{ .BuildName }}_{{.Provider}}.box"
 	}
 
-	_, err := template.New("output").Parse(p.config.OutputPath)
+	tpl := common.NewConfigTemplate(p.config.PackerBuildName, p.config.PackerTemplateTimestamp)
+	_, err := template.New("output").Funcs(tpl.Funcs()).Parse(p.config.OutputPath)
 	if err != nil {
 		return fmt.Errorf("output invalid template: %s", err)
 	}
@@ -51,9 +55,7 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 		return err
 	}
 
-	packerConfig := map[string]interface{}{
-		packer.BuildNameConfigKey: p.config.PackerBuildName,
-	}
+	packerConfig := p.packerConfig()
 
 	p.premade = make(map[string]packer.PostProcessor)
 	errors := make([]error, 0)
@@ -78,6 +80,13 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 }
 
 func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	// VirtualBox builds that skipped the export have no OVF to put in
+	// the box, so give a helpful error rather than an unknown type.
+	if artifact.BuilderId() == "mitchellh.virtualbox.vm" {
+		return nil, false, errors.New(
+			"VirtualBox artifact has no OVF since skip_export was set, can't build box")
+	}
+
 	ppName, ok := builtins[artifact.BuilderId()]
 	if !ok {
 		return nil, false, fmt.Errorf("Unknown artifact type, can't build box: %s", artifact.BuilderId())
@@ -94,7 +103,7 @@ func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (pac
 		}
 
 		config := map[string]string{"output": p.config.OutputPath}
-		if err := pp.Configure(config); err != nil {
+		if err := pp.Configure(config, p.packerConfig()); err != nil {
 			return nil, false, err
 		}
 	}
@@ -103,6 +112,15 @@ func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (pac
 	return pp.PostProcess(ui, artifact)
 }
 
+// packerConfig returns the Packer configuration keys that are passed on to
+// the post-processors of each provider.
+func (p *PostProcessor) packerConfig() map[string]interface{} {
+	return map[string]interface{}{
+		packer.BuildNameConfigKey:         p.config.PackerBuildName,
+		packer.TemplateTimestampConfigKey: p.config.PackerTemplateTimestamp,
+	}
+}
+
 func keyToPostProcessor(key string) packer.PostProcessor {
 	switch key {
 	case "aws":
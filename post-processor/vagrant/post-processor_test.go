@@ -28,12 +28,26 @@ func TestBuilderPrepare_OutputPath(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 
+	// Template functions
+	c["output"] = "packer_{{timestamp}}_{{.Provider}}.box"
+	err = p.Configure(c)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
 	// Bad template
 	c["output"] = "bad {{{{.Template}}}}"
 	err = p.Configure(c)
 	if err == nil {
 		t.Fatal("should have error")
 	}
+
+	// Unknown function
+	c["output"] = "bad {{nope}}"
+	err = p.Configure(c)
+	if err == nil {
+		t.Fatal("should have error")
+	}
 }
 
 func TestBuilderPrepare_PPConfig(t *testing.T) {
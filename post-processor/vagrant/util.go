@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/json"
+	"github.com/mitchellh/packer/builder/common"
 	"github.com/mitchellh/packer/packer"
 	"io"
 	"log"
@@ -88,8 +89,10 @@ func DirToBox(dst, dir string) error {
 }
 
 // ProcessOutputPath takes an output path template and executes it,
-// replacing variables with their respective values.
-func ProcessOutputPath(path string, buildName string, provider string, artifact packer.Artifact) (string, error) {
+// replacing variables with their respective values. The configuration
+// template functions are available too, with the timestamp of the given
+// template.
+func ProcessOutputPath(path string, buildName string, timestamp int64, provider string, artifact packer.Artifact) (string, error) {
 	var buf bytes.Buffer
 
 	tplData := &OutputPathTemplate{
@@ -98,7 +101,8 @@ func ProcessOutputPath(path string, buildName string, provider string, artifact
 		Provider:   provider,
 	}
 
-	t, err := template.New("output").Parse(path)
+	tpl := common.NewConfigTemplate(buildName, timestamp)
+	t, err := template.New("output").Funcs(tpl.Funcs()).Parse(path)
 	if err != nil {
 		return "", err
 	}
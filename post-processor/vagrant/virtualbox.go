@@ -19,7 +19,8 @@ type VBoxBoxConfig struct {
 	OutputPath          string `mapstructure:"output"`
 	VagrantfileTemplate string `mapstructure:"vagrantfile_template"`
 
-	PackerBuildName string `mapstructure:"packer_build_name"`
+	PackerBuildName         string `mapstructure:"packer_build_name"`
+	PackerTemplateTimestamp int64  `mapstructure:"packer_template_timestamp"`
 }
 
 type VBoxVagrantfileTemplate struct {
@@ -51,7 +52,7 @@ func (p *VBoxBoxPostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifac
 
 	// Compile the output path
 	outputPath, err := ProcessOutputPath(p.config.OutputPath,
-		p.config.PackerBuildName, "virtualbox", artifact)
+		p.config.PackerBuildName, p.config.PackerTemplateTimestamp, "virtualbox", artifact)
 	if err != nil {
 		return nil, false, err
 	}
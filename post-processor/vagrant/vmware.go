@@ -15,7 +15,8 @@ type VMwareBoxConfig struct {
 	OutputPath          string `mapstructure:"output"`
 	VagrantfileTemplate string `mapstructure:"vagrantfile_template"`
 
-	PackerBuildName string `mapstructure:"packer_build_name"`
+	PackerBuildName         string `mapstructure:"packer_build_name"`
+	PackerTemplateTimestamp int64  `mapstructure:"packer_template_timestamp"`
 }
 
 type VMwareBoxPostProcessor struct {
@@ -36,7 +37,7 @@ func (p *VMwareBoxPostProcessor) Configure(raws ...interface{}) error {
 func (p *VMwareBoxPostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
 	// Compile the output path
 	outputPath, err := ProcessOutputPath(p.config.OutputPath,
-		p.config.PackerBuildName, "vmware", artifact)
+		p.config.PackerBuildName, p.config.PackerTemplateTimestamp, "vmware", artifact)
 	if err != nil {
 		return nil, false, err
 	}